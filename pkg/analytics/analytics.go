@@ -17,7 +17,7 @@ type EventData struct {
 }
 
 func TrackEvent(data EventData) error {
-	conf := config.NewConstants()
+	conf := config.Global()
 
 	url := conf.GetBrevAPIURl() + "/api/brevent"
 
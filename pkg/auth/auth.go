@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/brevdev/brev-cli/pkg/config"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
 	"github.com/brevdev/brev-cli/pkg/terminal"
@@ -51,15 +53,45 @@ func (l NoLoginAuth) GetAccessToken() (string, error) {
 	return token, nil
 }
 
+// ServiceTokenAuth authenticates as the instance it runs on, so it never prompts for a user login
+type ServiceTokenAuth struct {
+	store ServiceTokenStore
+}
+
+type ServiceTokenStore interface {
+	GetCurrentWorkspaceServiceToken() (string, error)
+}
+
+func NewServiceTokenAuth(store ServiceTokenStore) *ServiceTokenAuth {
+	return &ServiceTokenAuth{store: store}
+}
+
+func (s ServiceTokenAuth) GetAccessToken() (string, error) {
+	token, err := s.store.GetCurrentWorkspaceServiceToken()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("no instance service token found")
+	}
+	return token, nil
+}
+
 type AuthStore interface {
 	SaveAuthTokens(tokens entity.AuthTokens) error
 	GetAuthTokens() (*entity.AuthTokens, error)
 	DeleteAuthTokens() error
+	// the token minted with an api key from the env is cached apart from the saved login
+	GetClientCredentialsToken() (*entity.ClientCredentialsToken, error)
+	SaveClientCredentialsToken(token entity.ClientCredentialsToken) error
 }
 
 type OAuth interface {
 	DoDeviceAuthFlow(onStateRetrieved func(url string, code string)) (*LoginTokens, error)
 	GetNewAuthTokensWithRefresh(refreshToken string) (*entity.AuthTokens, error)
+	GetTokensWithClientCredentials(clientID string, clientSecret string) (*entity.AuthTokens, error)
+	RevokeRefreshToken(refreshToken string) error
 }
 
 type Auth struct {
@@ -67,6 +99,7 @@ type Auth struct {
 	oauth                OAuth
 	accessTokenValidator func(string) (bool, error)
 	shouldLogin          func() (bool, error)
+	clientCredentials    func() (clientID string, clientSecret string)
 }
 
 func NewAuth(authStore AuthStore, oauth OAuth) *Auth {
@@ -75,6 +108,7 @@ func NewAuth(authStore AuthStore, oauth OAuth) *Auth {
 		oauth:                oauth,
 		accessTokenValidator: isAccessTokenValid,
 		shouldLogin:          shouldLogin,
+		clientCredentials:    getClientCredentialsFromEnv,
 	}
 }
 
@@ -83,6 +117,19 @@ func (t *Auth) WithAccessTokenValidator(val func(string) (bool, error)) *Auth {
 	return t
 }
 
+func getClientCredentialsFromEnv() (string, string) {
+	return config.Global().GetClientID(), config.Global().GetClientSecret()
+}
+
+// UsesEnvClientCredentials is true when an api key in the env is used over the saved login
+func (t Auth) UsesEnvClientCredentials() (clientID string, ok bool) {
+	if t.clientCredentials == nil {
+		return "", false
+	}
+	clientID, clientSecret := t.clientCredentials()
+	return clientID, clientID != "" || clientSecret != ""
+}
+
 // Gets fresh access token and prompts for login and saves to store
 func (t Auth) GetFreshAccessTokenOrLogin() (string, error) {
 	token, err := t.GetFreshAccessTokenOrNil()
@@ -101,6 +148,18 @@ func (t Auth) GetFreshAccessTokenOrLogin() (string, error) {
 
 // Gets fresh access token or returns nil and saves to store
 func (t Auth) GetFreshAccessTokenOrNil() (string, error) {
+	// an api key in the env wins over whoever is logged in, so CI never falls back to a prompt
+	if t.clientCredentials != nil {
+		clientID, clientSecret := t.clientCredentials()
+		if clientID != "" || clientSecret != "" {
+			token, err := t.getEnvClientCredentialsAccessToken(clientID, clientSecret)
+			if err != nil {
+				return "", breverrors.WrapAndTrace(err)
+			}
+			return token, nil
+		}
+	}
+
 	tokens, err := t.getSavedTokensOrNil()
 	if err != nil {
 		return "", breverrors.WrapAndTrace(err)
@@ -108,6 +167,13 @@ func (t Auth) GetFreshAccessTokenOrNil() (string, error) {
 	if tokens == nil {
 		return "", nil
 	}
+	if tokens.ClientID != "" {
+		token, err := t.getClientCredentialsAccessToken(tokens.ClientID, tokens.ClientSecret)
+		if err != nil {
+			return "", breverrors.WrapAndTrace(err)
+		}
+		return token, nil
+	}
 
 	// should always at least have access token?
 	if tokens.AccessToken == "" {
@@ -184,6 +250,109 @@ func (t Auth) LoginWithToken(token string) error {
 	return nil
 }
 
+// LoginWithClientCredentials logs in with an api key without a browser or prompt, the key is
+// saved with the tokens so they can be minted again once they expire. That needs a credential
+// store that isn't plain text
+func (t Auth) LoginWithClientCredentials(clientID string, clientSecret string) error {
+	_, err := t.mintWithClientCredentials(clientID, clientSecret)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// CheckClientCredentials mints tokens with an api key without saving anything, for a key that is
+// only in the env and is used from there by every command
+func (t Auth) CheckClientCredentials(clientID string, clientSecret string) error {
+	_, err := t.getEnvClientCredentialsAccessToken(clientID, clientSecret)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// clientCredentialsExpiryLeeway mints tokens a bit before they expire, so a request that is sent
+// right before expiry doesn't fail halfway through a long CI job
+const clientCredentialsExpiryLeeway = time.Minute
+
+// getClientCredentialsAccessToken reuses the saved access token of the same api key while it is
+// valid and mints a new one otherwise
+func (t Auth) getClientCredentialsAccessToken(clientID string, clientSecret string) (string, error) {
+	if clientID == "" || clientSecret == "" {
+		idVar, secretVar := config.ClientCredentialsEnvVars()
+		return "", breverrors.NewValidationError(fmt.Sprintf("both %s and %s must be set to log in with an api key", idVar, secretVar))
+	}
+	tokens, err := t.getSavedTokensOrNil()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	if tokens != nil && tokens.ClientID == clientID && tokens.AccessToken != "" {
+		valid, err := t.accessTokenValidator(tokens.AccessToken)
+		if err != nil {
+			return "", breverrors.WrapAndTrace(err)
+		}
+		if valid && !expiresWithin(tokens.AccessToken, clientCredentialsExpiryLeeway) {
+			return tokens.AccessToken, nil
+		}
+	}
+	tokens, err = t.mintWithClientCredentials(clientID, clientSecret)
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return tokens.AccessToken, nil
+}
+
+// getEnvClientCredentialsAccessToken is getClientCredentialsAccessToken for an api key from the
+// env. The access token and its expiry are cached for the next command, the key is never saved
+func (t Auth) getEnvClientCredentialsAccessToken(clientID string, clientSecret string) (string, error) {
+	if clientID == "" || clientSecret == "" {
+		idVar, secretVar := config.ClientCredentialsEnvVars()
+		return "", breverrors.NewValidationError(fmt.Sprintf("both %s and %s must be set to log in with an api key", idVar, secretVar))
+	}
+	cached, err := t.authStore.GetClientCredentialsToken()
+	if err != nil {
+		// a cache that can't be read is minted over
+		cached = nil
+	}
+	if cached != nil && cached.ClientID == clientID && cached.AccessToken != "" && !cached.ExpiresWithin(clientCredentialsExpiryLeeway) {
+		valid, err := t.accessTokenValidator(cached.AccessToken)
+		if err != nil {
+			return "", breverrors.WrapAndTrace(err)
+		}
+		if valid {
+			return cached.AccessToken, nil
+		}
+	}
+	tokens, err := t.oauth.GetTokensWithClientCredentials(clientID, clientSecret)
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	err = t.authStore.SaveClientCredentialsToken(entity.ClientCredentialsToken{
+		ClientID:    clientID,
+		AccessToken: tokens.AccessToken,
+		ExpiresAt:   tokenExpiry(tokens.AccessToken),
+	})
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return tokens.AccessToken, nil
+}
+
+// mintWithClientCredentials saves the key with the tokens, the store refuses to keep it in plain text
+func (t Auth) mintWithClientCredentials(clientID string, clientSecret string) (*entity.AuthTokens, error) {
+	tokens, err := t.oauth.GetTokensWithClientCredentials(clientID, clientSecret)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	tokens.ClientID = clientID
+	tokens.ClientSecret = clientSecret
+	err = t.authStore.SaveAuthTokens(*tokens)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return tokens, nil
+}
+
 func defaultAuthFunc(url, code string) {
 	codeType := color.New(color.FgWhite, color.Bold).SprintFunc()
 	fmt.Print("\n")
@@ -242,6 +411,23 @@ func (t Auth) Login(skipBrowser bool) (*LoginTokens, error) {
 	return tokens, nil
 }
 
+// RevokeRefreshToken revokes the saved refresh token, it is false when there is none to revoke
+// such as after an api key or token login
+func (t Auth) RevokeRefreshToken() (bool, error) {
+	tokens, err := t.getSavedTokensOrNil()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	if tokens == nil || tokens.RefreshToken == "" || tokens.RefreshToken == "auto-login" {
+		return false, nil
+	}
+	err = t.oauth.RevokeRefreshToken(tokens.RefreshToken)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return true, nil
+}
+
 func (t Auth) Logout() error {
 	err := t.authStore.DeleteAuthTokens()
 	if err != nil {
@@ -321,6 +507,35 @@ func isAccessTokenValid(token string) (bool, error) {
 	return true, nil
 }
 
+// expiresWithin is false for tokens without an expiry it can read
+// tokenExpiry is zero for a token without an expiry
+func tokenExpiry(token string) time.Time {
+	parser := jwt.Parser{}
+	claims := jwt.MapClaims{}
+	_, _, err := parser.ParseUnverified(token, claims)
+	if err != nil {
+		return time.Time{}
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(int64(exp), 0)
+}
+
+func expiresWithin(token string, d time.Duration) bool {
+	parser := jwt.Parser{}
+	claims := jwt.MapClaims{}
+	_, _, err := parser.ParseUnverified(token, claims)
+	if err != nil {
+		return false
+	}
+	if _, ok := claims["exp"]; !ok {
+		return false
+	}
+	return !claims.VerifyExpiresAt(time.Now().Add(d).Unix(), true)
+}
+
 func IsAuthError(err error) bool {
 	return strings.Contains(err.Error(), "403")
 }
@@ -48,6 +48,7 @@ type Authenticator struct {
 	ClientID           string
 	DeviceCodeEndpoint string
 	OauthTokenEndpoint string
+	RevokeEndpoint     string
 }
 
 var _ OAuth = Authenticator{}
@@ -319,6 +320,63 @@ func (a Authenticator) GetNewAuthTokensWithRefresh(refreshToken string) (*entity
 	return &authTokens, nil
 }
 
+// GetTokensWithClientCredentials mints an access token for an api key, there is no refresh token
+// https://auth0.com/docs/get-started/authentication-and-authorization-flow/call-your-api-using-the-client-credentials-flow
+func (a Authenticator) GetTokensWithClientCredentials(clientID string, clientSecret string) (*entity.AuthTokens, error) {
+	payload := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"audience":      {a.Audience},
+	}
+
+	r, err := postFormWithContext(context.TODO(), a.OauthTokenEndpoint, payload)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err, breverrors.NetworkErrorMessage)
+	}
+	defer r.Body.Close() //nolint:errcheck // defer is fine
+	if r.StatusCode == http.StatusUnauthorized || r.StatusCode == http.StatusForbidden {
+		var authError AuthError
+		err = json.NewDecoder(r.Body).Decode(&authError)
+		if err == nil && authError.ErrorDescription != "" {
+			return nil, breverrors.NewValidationError(fmt.Sprintf("api key login failed: %s", authError.ErrorDescription))
+		}
+	}
+	err = ErrorIfBadHTTP(r)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+
+	var authTokens entity.AuthTokens
+	err = json.NewDecoder(r.Body).Decode(&authTokens)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err, "cannot decode response")
+	}
+	if authTokens.AccessToken == "" {
+		return nil, fmt.Errorf("no access token in response")
+	}
+	return &entity.AuthTokens{AccessToken: authTokens.AccessToken}, nil
+}
+
+// RevokeRefreshToken makes the refresh token useless to anyone who copied it
+// https://auth0.com/docs/secure/tokens/refresh-tokens/revoke-refresh-tokens
+func (a Authenticator) RevokeRefreshToken(refreshToken string) error {
+	payload := url.Values{
+		"client_id": {a.ClientID},
+		"token":     {refreshToken},
+	}
+	r, err := postFormWithContext(context.TODO(), a.RevokeEndpoint, payload)
+	if err != nil {
+		return breverrors.WrapAndTrace(err, breverrors.NetworkErrorMessage)
+	}
+	defer r.Body.Close() //nolint:errcheck // defer is fine
+	err = ErrorIfBadHTTP(r)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
 func ErrorIfBadHTTP(r *http.Response, exceptStatus ...int) error {
 	shouldExcept := false
 	for _, s := range exceptStatus {
@@ -1 +1,33 @@
 package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTokensWithClientCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "https://example.com/api/v2/", r.PostForm.Get("audience"))
+		if r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"access_denied","error_description":"Unauthorized"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"at","token_type":"Bearer","expires_in":86400}`))
+	}))
+	defer srv.Close()
+	a := Authenticator{Audience: "https://example.com/api/v2/", OauthTokenEndpoint: srv.URL}
+
+	tokens, err := a.GetTokensWithClientCredentials("id", "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, "at", tokens.AccessToken)
+	assert.Empty(t, tokens.RefreshToken)
+
+	_, err = a.GetTokensWithClientCredentials("id", "wrong")
+	assert.ErrorContains(t, err, "api key login failed: Unauthorized")
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"testing"
+	"time"
 
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
@@ -54,10 +55,20 @@ func (m MockAuthStore) DeleteAuthTokens() error {
 	return nil
 }
 
+func (m MockAuthStore) GetClientCredentialsToken() (*entity.ClientCredentialsToken, error) {
+	return nil, nil
+}
+
+func (m *MockAuthStore) SaveClientCredentialsToken(_ entity.ClientCredentialsToken) error {
+	return nil
+}
+
 type MockOauth struct {
 	authTokens  *entity.AuthTokens
 	loginTokens *LoginTokens
 	flowDone    bool
+	minted      []string
+	revoked     []string
 }
 
 func (m *MockOauth) DoDeviceAuthFlow(_ func(string, string)) (*LoginTokens, error) {
@@ -69,6 +80,20 @@ func (m MockOauth) GetNewAuthTokensWithRefresh(_ string) (*entity.AuthTokens, er
 	return m.authTokens, nil
 }
 
+func (m *MockOauth) GetTokensWithClientCredentials(clientID string, _ string) (*entity.AuthTokens, error) {
+	m.minted = append(m.minted, clientID)
+	return &entity.AuthTokens{AccessToken: "minted-" + clientID}, nil
+}
+
+func (m *MockOauth) RevokeRefreshToken(refreshToken string) error {
+	m.revoked = append(m.revoked, refreshToken)
+	return nil
+}
+
+func noClientCredentials() (string, string) {
+	return "", ""
+}
+
 const validToken = "abc"
 
 func TestSuccessNoRefreshGetFreshAccessTokenOrLogin(t *testing.T) {
@@ -83,7 +108,7 @@ func TestSuccessNoRefreshGetFreshAccessTokenOrLogin(t *testing.T) {
 		},
 		func() (bool, error) {
 			return true, nil
-		},
+		}, noClientCredentials,
 	}
 	res, err := a.GetFreshAccessTokenOrLogin()
 	if !assert.Nil(t, err) {
@@ -113,7 +138,7 @@ func TestSuccessRefreshGetFreshAccessTokenOrLogin(t *testing.T) {
 			return false, nil
 		}, func() (bool, error) {
 			return true, nil
-		},
+		}, noClientCredentials,
 	}
 	res, err := a.GetFreshAccessTokenOrLogin()
 	if !assert.Nil(t, err) {
@@ -146,7 +171,7 @@ func TestTokenDoesNotExistGetFreshAccessTokenOrLogin(t *testing.T) {
 			return false, nil
 		}, func() (bool, error) {
 			return true, nil
-		},
+		}, noClientCredentials,
 	}
 	res, err := a.GetFreshAccessTokenOrLogin()
 	if !assert.Nil(t, err) {
@@ -182,7 +207,7 @@ func TestDenyLoginGetFreshAccessTokenOrLogin(t *testing.T) {
 			return false, nil
 		}, func() (bool, error) {
 			return false, nil
-		},
+		}, noClientCredentials,
 	}
 	res, err := a.GetFreshAccessTokenOrLogin()
 	de := &breverrors.DeclineToLoginError{}
@@ -220,7 +245,7 @@ func TestFailedRefreshGetFreshAccessTokenOrLogin(t *testing.T) {
 			return false, nil
 		}, func() (bool, error) {
 			return true, nil
-		},
+		}, noClientCredentials,
 	}
 	res, err := a.GetFreshAccessTokenOrLogin()
 	if !assert.Nil(t, err) {
@@ -234,3 +259,165 @@ func TestFailedRefreshGetFreshAccessTokenOrLogin(t *testing.T) {
 func TestSSH(t *testing.T) {
 	suite.Run(t, new(BrevAPIAuthTestSuite))
 }
+
+type fakeServiceTokenStore struct {
+	token string
+}
+
+func (f fakeServiceTokenStore) GetCurrentWorkspaceServiceToken() (string, error) {
+	return f.token, nil
+}
+
+func TestServiceTokenAuth(t *testing.T) {
+	token, err := NewServiceTokenAuth(fakeServiceTokenStore{token: "sa-token\n"}).GetAccessToken()
+	assert.Nil(t, err)
+	assert.Equal(t, "sa-token", token)
+
+	_, err = NewServiceTokenAuth(fakeServiceTokenStore{}).GetAccessToken()
+	assert.Error(t, err)
+}
+
+type savingAuthStore struct {
+	authTokens *entity.AuthTokens
+	envToken   *entity.ClientCredentialsToken
+}
+
+func (m savingAuthStore) GetClientCredentialsToken() (*entity.ClientCredentialsToken, error) {
+	return m.envToken, nil
+}
+
+func (m *savingAuthStore) SaveClientCredentialsToken(token entity.ClientCredentialsToken) error {
+	m.envToken = &token
+	return nil
+}
+
+func (m *savingAuthStore) SaveAuthTokens(tokens entity.AuthTokens) error {
+	m.authTokens = &tokens
+	return nil
+}
+
+func (m savingAuthStore) GetAuthTokens() (*entity.AuthTokens, error) {
+	if m.authTokens == nil {
+		return nil, &breverrors.CredentialsFileNotFound{}
+	}
+	return m.authTokens, nil
+}
+
+func (m *savingAuthStore) DeleteAuthTokens() error {
+	m.authTokens = nil
+	return nil
+}
+
+func TestClientCredentialsFromEnvNeverPrompt(t *testing.T) {
+	s := &savingAuthStore{}
+	o := &MockOauth{}
+	valid := true
+	a := Auth{
+		s, o, func(string) (bool, error) {
+			return valid, nil
+		}, func() (bool, error) {
+			t.Fatal("should not prompt")
+			return false, nil
+		}, func() (string, string) {
+			return "ci", "secret"
+		},
+	}
+
+	token, err := a.GetFreshAccessTokenOrLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, "minted-ci", token)
+	clientID, ok := a.UsesEnvClientCredentials()
+	assert.True(t, ok)
+	assert.Equal(t, "ci", clientID)
+	// the key from the env is never saved, only the token minted with it
+	assert.Nil(t, s.authTokens)
+	assert.Equal(t, &entity.ClientCredentialsToken{ClientID: "ci", AccessToken: "minted-ci"}, s.envToken)
+
+	// cached while valid, also for the next command as the cache is in the store
+	_, err = a.GetFreshAccessTokenOrLogin()
+	assert.Nil(t, err)
+	_, err = NewAuth(s, o).WithAccessTokenValidator(func(string) (bool, error) { return true, nil }).getEnvClientCredentialsAccessToken("ci", "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ci"}, o.minted)
+
+	// minted again for another key
+	_, err = a.getEnvClientCredentialsAccessToken("other", "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ci", "other"}, o.minted)
+
+	// once about to expire
+	s.envToken = &entity.ClientCredentialsToken{ClientID: "ci", AccessToken: "cached", ExpiresAt: time.Now().Add(time.Second)}
+	token, err = a.GetFreshAccessTokenOrLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, "minted-ci", token)
+	assert.Equal(t, []string{"ci", "other", "ci"}, o.minted)
+
+	// and once no longer valid
+	valid = false
+	_, err = a.GetFreshAccessTokenOrLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ci", "other", "ci", "ci"}, o.minted)
+
+	a.clientCredentials = func() (string, string) { return "ci", "" }
+	_, err = a.GetFreshAccessTokenOrLogin()
+	assert.Error(t, err)
+}
+
+func TestClientCredentialsFromEnvLeaveSavedLoginAlone(t *testing.T) {
+	saved := entity.AuthTokens{AccessToken: "developer", RefreshToken: "refresh"}
+	s := &savingAuthStore{authTokens: &saved}
+	a := Auth{
+		s, &MockOauth{}, func(string) (bool, error) {
+			return true, nil
+		}, func() (bool, error) {
+			t.Fatal("should not prompt")
+			return false, nil
+		}, func() (string, string) {
+			return "ci", "secret"
+		},
+	}
+
+	token, err := a.GetFreshAccessTokenOrLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, "minted-ci", token)
+	assert.Equal(t, saved, *s.authTokens)
+}
+
+func TestSavedClientCredentialsMintAgain(t *testing.T) {
+	s := &savingAuthStore{authTokens: &entity.AuthTokens{AccessToken: "expired", ClientID: "saved", ClientSecret: "secret"}}
+	o := &MockOauth{}
+	a := Auth{
+		s, o, func(string) (bool, error) {
+			return false, nil
+		}, func() (bool, error) {
+			t.Fatal("should not prompt")
+			return false, nil
+		}, noClientCredentials,
+	}
+
+	token, err := a.GetFreshAccessTokenOrLogin()
+	assert.Nil(t, err)
+	assert.Equal(t, "minted-saved", token)
+	assert.Equal(t, "saved", s.authTokens.ClientID)
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	o := &MockOauth{}
+	s := &savingAuthStore{}
+	a := NewAuth(s, o)
+
+	revoked, err := a.RevokeRefreshToken()
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	s.authTokens = &entity.AuthTokens{AccessToken: "at", RefreshToken: "auto-login"}
+	revoked, err = a.RevokeRefreshToken()
+	assert.Nil(t, err)
+	assert.False(t, revoked)
+
+	s.authTokens = &entity.AuthTokens{AccessToken: "at", RefreshToken: "rt"}
+	revoked, err = a.RevokeRefreshToken()
+	assert.Nil(t, err)
+	assert.True(t, revoked)
+	assert.Equal(t, []string{"rt"}, o.revoked)
+}
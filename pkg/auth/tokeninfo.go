@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/golang-jwt/jwt"
+)
+
+// TokenInfo is what an access token says about itself, it is decoded without checking the
+// signature so it is only fit for showing to the user
+type TokenInfo struct {
+	Subject   string     `json:"subject"`
+	Issuer    string     `json:"issuer"`
+	Audience  []string   `json:"audience"`
+	IssuedAt  *time.Time `json:"issuedAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Scopes    []string   `json:"scopes"`
+}
+
+func IntrospectAccessToken(token string) (*TokenInfo, error) {
+	parser := jwt.Parser{}
+	claims := jwt.MapClaims{}
+	_, _, err := parser.ParseUnverified(token, claims)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	info := TokenInfo{
+		Subject:   stringClaim(claims, "sub"),
+		Issuer:    stringClaim(claims, "iss"),
+		IssuedAt:  timeClaim(claims, "iat"),
+		ExpiresAt: timeClaim(claims, "exp"),
+		Scopes:    strings.Fields(stringClaim(claims, "scope")),
+	}
+	switch aud := claims["aud"].(type) {
+	case string:
+		info.Audience = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				info.Audience = append(info.Audience, s)
+			}
+		}
+	}
+	return &info, nil
+}
+
+func (i TokenInfo) IsExpired(now time.Time) bool {
+	return i.ExpiresAt != nil && !now.Before(*i.ExpiresAt)
+}
+
+// MissingScopes are the RequiredScopes the token was not granted
+func (i TokenInfo) MissingScopes() []string {
+	granted := map[string]bool{}
+	for _, s := range i.Scopes {
+		granted[s] = true
+	}
+	missing := []string{}
+	for _, s := range RequiredScopes() {
+		if !granted[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+func timeClaim(claims jwt.MapClaims, name string) *time.Time {
+	f, ok := claims[name].(float64)
+	if !ok {
+		return nil
+	}
+	t := time.Unix(int64(f), 0)
+	return &t
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntrospectAccessToken(t *testing.T) {
+	exp := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "google-oauth2|123",
+		"iss":   "https://brevdev.us.auth0.com/",
+		"aud":   []string{"https://brevdev.us.auth0.com/api/v2/", "https://brevdev.us.auth0.com/userinfo"},
+		"exp":   exp.Unix(),
+		"scope": "openid profile email",
+	}).SignedString([]byte("key"))
+	assert.Nil(t, err)
+
+	info, err := IntrospectAccessToken(token)
+	assert.Nil(t, err)
+	assert.Equal(t, "google-oauth2|123", info.Subject)
+	assert.Len(t, info.Audience, 2)
+	assert.True(t, exp.Equal(*info.ExpiresAt))
+	assert.Nil(t, info.IssuedAt)
+	assert.False(t, info.IsExpired(exp.Add(-time.Second)))
+	assert.True(t, info.IsExpired(exp))
+	assert.Equal(t, []string{"openid", "profile", "email"}, info.Scopes)
+	assert.Contains(t, info.MissingScopes(), "offline_access")
+	assert.NotContains(t, info.MissingScopes(), "openid")
+
+	_, err = IntrospectAccessToken("auto-login")
+	assert.Error(t, err)
+}
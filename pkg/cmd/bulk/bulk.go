@@ -0,0 +1,350 @@
+// Package bulk selects instances with filters and runs an operation on all of them
+package bulk
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/pflag"
+)
+
+const (
+	CreatorMe  = "me"
+	CreatorAny = "any"
+
+	DefaultParallel = 4
+)
+
+// Selector filters instances, empty fields match everything
+type Selector struct {
+	Name         string
+	Statuses     []string
+	InstanceType string
+	Repo         string
+	Creator      string
+	OlderThan    time.Duration
+	NewerThan    time.Duration
+}
+
+type Options struct {
+	Selector
+	DryRun   bool
+	Yes      bool
+	Parallel int
+}
+
+func (o *Options) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.Name, "match", "", "select instances whose name matches this glob, e.g. 'test-*'")
+	flags.StringSliceVar(&o.Statuses, "status", nil, "select instances with these statuses, e.g. running,stopped")
+	flags.StringVar(&o.InstanceType, "instance-type", "", "select instances whose instance type or class matches this glob")
+	flags.StringVar(&o.Repo, "repo", "", "select instances whose git repo contains this")
+	flags.StringVar(&o.Creator, "creator", CreatorMe, "select instances created by this user id, 'me' or 'any'")
+	flags.DurationVar(&o.OlderThan, "older-than", 0, "select instances created more than this long ago, e.g. 72h")
+	flags.DurationVar(&o.NewerThan, "newer-than", 0, "select instances created less than this long ago")
+	flags.BoolVar(&o.DryRun, "dry-run", false, "print the selected instances without changing them")
+	flags.BoolVarP(&o.Yes, "yes", "y", false, "don't ask for confirmation")
+	flags.IntVar(&o.Parallel, "parallel", DefaultParallel, "how many instances to change at once")
+}
+
+// IsSet is true when any filter was given, commands switch to bulk mode then
+func (s Selector) IsSet() bool {
+	return s.Name != "" || len(s.Statuses) > 0 || s.InstanceType != "" || s.Repo != "" ||
+		(s.Creator != "" && s.Creator != CreatorMe) || s.OlderThan != 0 || s.NewerThan != 0
+}
+
+func (s Selector) Validate() error {
+	for _, g := range []string{s.Name, s.InstanceType} {
+		if _, err := path.Match(g, ""); err != nil {
+			return breverrors.NewValidationError(fmt.Sprintf("invalid glob %q", g))
+		}
+	}
+	if s.OlderThan < 0 || s.NewerThan < 0 {
+		return breverrors.NewValidationError("--older-than and --newer-than can not be negative")
+	}
+	return nil
+}
+
+func (s Selector) Matches(w entity.Workspace, now time.Time) bool {
+	if s.Name != "" && !globMatch(s.Name, w.Name) {
+		return false
+	}
+	if len(s.Statuses) > 0 && !containsFold(s.Statuses, w.Status) {
+		return false
+	}
+	if s.InstanceType != "" && !globMatch(s.InstanceType, w.InstanceType) && !globMatch(s.InstanceType, w.WorkspaceClassID) {
+		return false
+	}
+	if s.Repo != "" && !strings.Contains(w.GitRepo, s.Repo) {
+		return false
+	}
+	if s.Creator != "" && s.Creator != CreatorMe && s.Creator != CreatorAny && w.CreatedByUserID != s.Creator {
+		return false
+	}
+	if s.OlderThan != 0 || s.NewerThan != 0 {
+		createdAt, err := time.Parse(time.RFC3339, w.CreatedAt)
+		if err != nil {
+			// without a creation time the age is unknown, so never select it by age
+			return false
+		}
+		age := now.Sub(createdAt)
+		if s.OlderThan != 0 && age < s.OlderThan {
+			return false
+		}
+		if s.NewerThan != 0 && age > s.NewerThan {
+			return false
+		}
+	}
+	return true
+}
+
+func globMatch(pattern string, s string) bool {
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(strings.TrimSpace(l), s) {
+			return true
+		}
+	}
+	return false
+}
+
+type SelectStore interface {
+	GetCurrentUser() (*entity.User, error)
+	GetActiveOrganizationOrDefault() (*entity.Organization, error)
+	GetWorkspaces(organizationID string, options *store.GetWorkspacesOptions) ([]entity.Workspace, error)
+}
+
+// Select lists the instances in the active org matching the selector, only your own unless
+// another creator is asked for
+func Select(selectStore SelectStore, s Selector, now time.Time) ([]entity.Workspace, error) {
+	err := s.Validate()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	org, err := selectStore.GetActiveOrganizationOrDefault()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if org == nil {
+		return nil, breverrors.NewValidationError("no orgs exist")
+	}
+	options := &store.GetWorkspacesOptions{}
+	if s.Creator == "" || s.Creator == CreatorMe {
+		user, err := selectStore.GetCurrentUser()
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		options.UserID = user.ID
+	}
+	workspaces, err := selectStore.GetWorkspaces(org.ID, options)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	selected := []entity.Workspace{}
+	for _, w := range workspaces {
+		if s.Matches(w, now) {
+			selected = append(selected, w)
+		}
+	}
+	return selected, nil
+}
+
+type Result struct {
+	Name  string `json:"name"`
+	ID    string `json:"id"`
+	Error string `json:"error,omitempty"`
+
+	Workspace entity.Workspace `json:"-"`
+	err       error
+}
+
+func (r Result) OK() bool {
+	return r.err == nil
+}
+
+// RunParallel runs op on every instance with at most parallel at once, results keep the order
+// of the instances
+func RunParallel(workspaces []entity.Workspace, parallel int, op func(entity.Workspace) error) []Result {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]Result, len(workspaces))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel && i < len(workspaces); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				w := workspaces[index]
+				err := op(w)
+				results[index] = Result{Name: w.Name, ID: w.ID, Workspace: w, err: err}
+				if err != nil {
+					results[index].Error = err.Error()
+				}
+			}
+		}()
+	}
+	for i := range workspaces {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// Run selects the instances, previews them, asks for confirmation and then applies op to all
+// of them. Every failure is returned together once all instances were tried
+func Run(t *terminal.Terminal, selectStore SelectStore, opts Options, verb string, format output.Format, op func(entity.Workspace) error) ([]Result, error) {
+	workspaces, err := Select(selectStore, opts.Selector, time.Now())
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if len(workspaces) == 0 {
+		if !format.IsMachineReadable() {
+			t.Vprint(t.Yellow("No instances match\n"))
+		}
+		return []Result{}, nil
+	}
+
+	if opts.DryRun {
+		err = displaySelection(os.Stdout, format, workspaces)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		if !format.IsMachineReadable() {
+			t.Vprintf("\nwould %s %d instances\n", verb, len(workspaces))
+		}
+		return []Result{}, nil
+	}
+
+	if !opts.Yes {
+		err = displaySelection(os.Stdout, output.Table, workspaces)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		ok, err := Confirm(fmt.Sprintf("%s %d instances?", verb, len(workspaces)))
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		if !ok {
+			return []Result{}, nil
+		}
+	}
+
+	results := RunParallel(workspaces, opts.Parallel, op)
+	err = displayResults(t, os.Stdout, format, results)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+
+	var allErr error
+	for _, r := range results {
+		if !r.OK() {
+			allErr = multierror.Append(allErr, fmt.Errorf("%s: %w", r.Name, r.err))
+		}
+	}
+	if allErr != nil {
+		return results, breverrors.WrapAndTrace(allErr)
+	}
+	return results, nil
+}
+
+// Confirm asks a yes/no question, without a terminal to ask on it errors instead of guessing
+func Confirm(label string) (bool, error) {
+	if !IsInteractive() {
+		return false, breverrors.NewValidationError("can not ask for confirmation without a terminal, pass --yes to go ahead")
+	}
+	answer := terminal.PromptSelectInput(terminal.PromptSelectContent{
+		Label:    label,
+		ErrorMsg: "error",
+		Items:    []string{"Yes", "No"},
+	})
+	return answer == "Yes", nil
+}
+
+// IsInteractive is true when stdin is a terminal someone can answer prompts on
+func IsInteractive() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+type selectedInstance struct {
+	Name         string `json:"name"`
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	InstanceType string `json:"instanceType"`
+	GitRepo      string `json:"gitRepo,omitempty"`
+	CreatedAt    string `json:"createdAt,omitempty"`
+}
+
+func displaySelection(w io.Writer, format output.Format, workspaces []entity.Workspace) error {
+	rows := []selectedInstance{}
+	for _, ws := range workspaces {
+		instanceType := ws.InstanceType
+		if instanceType == "" {
+			instanceType = ws.WorkspaceClassID
+		}
+		rows = append(rows, selectedInstance{
+			Name: ws.Name, ID: ws.ID, Status: ws.Status, InstanceType: instanceType, GitRepo: ws.GitRepo, CreatedAt: ws.CreatedAt,
+		})
+	}
+	if format.IsMachineReadable() {
+		err := output.Write(w, format, rows)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	}
+	ta := table.NewWriter()
+	ta.SetOutputMirror(w)
+	ta.Style().Options = util.GetBrevTableOptions()
+	ta.AppendHeader(table.Row{"NAME", "ID", "STATUS", "INSTANCE TYPE", "REPO", "CREATED"})
+	for _, r := range rows {
+		ta.AppendRow(table.Row{r.Name, r.ID, r.Status, r.InstanceType, r.GitRepo, r.CreatedAt})
+	}
+	ta.Render()
+	return nil
+}
+
+func displayResults(t *terminal.Terminal, w io.Writer, format output.Format, results []Result) error {
+	if format.IsMachineReadable() {
+		err := output.Write(w, format, results)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	}
+	ta := table.NewWriter()
+	ta.SetOutputMirror(w)
+	ta.Style().Options = util.GetBrevTableOptions()
+	ta.AppendHeader(table.Row{"NAME", "ID", "RESULT"})
+	for _, r := range results {
+		result := t.Green("ok")
+		if !r.OK() {
+			result = t.Red(r.Error)
+		}
+		ta.AppendRow(table.Row{r.Name, r.ID, result})
+	}
+	ta.Render()
+	return nil
+}
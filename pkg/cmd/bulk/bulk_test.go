@@ -0,0 +1,127 @@
+package bulk
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestSelectorMatches(t *testing.T) {
+	w := entity.Workspace{
+		Name:             "test-gpu",
+		Status:           entity.Running,
+		InstanceType:     "g5.xlarge",
+		GitRepo:          "github.com:brevdev/brev-cli.git",
+		CreatedByUserID:  "u1",
+		CreatedAt:        now.Add(-100 * time.Hour).Format(time.RFC3339),
+		WorkspaceClassID: "",
+	}
+	tests := []struct {
+		name     string
+		selector Selector
+		want     bool
+	}{
+		{"empty", Selector{}, true},
+		{"name glob", Selector{Name: "test-*"}, true},
+		{"name glob miss", Selector{Name: "prod-*"}, false},
+		{"status any case", Selector{Statuses: []string{"stopped", "running"}}, true},
+		{"status miss", Selector{Statuses: []string{"stopped"}}, false},
+		{"instance type", Selector{InstanceType: "g5.*"}, true},
+		{"repo", Selector{Repo: "brevdev/brev-cli"}, true},
+		{"creator", Selector{Creator: "u2"}, false},
+		{"creator any", Selector{Creator: CreatorAny}, true},
+		{"older than", Selector{OlderThan: 72 * time.Hour}, true},
+		{"not older than", Selector{OlderThan: 200 * time.Hour}, false},
+		{"newer than", Selector{NewerThan: 72 * time.Hour}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.selector.Matches(w, now))
+		})
+	}
+
+	// without a creation time age filters never match
+	assert.False(t, Selector{OlderThan: time.Hour}.Matches(entity.Workspace{}, now))
+}
+
+func TestSelectorIsSet(t *testing.T) {
+	assert.False(t, Selector{}.IsSet())
+	assert.False(t, Selector{Creator: CreatorMe}.IsSet())
+	assert.True(t, Selector{Creator: CreatorAny}.IsSet())
+	assert.True(t, Selector{Name: "*"}.IsSet())
+}
+
+type fakeSelectStore struct {
+	gotOptions *store.GetWorkspacesOptions
+}
+
+func (f *fakeSelectStore) GetCurrentUser() (*entity.User, error) {
+	return &entity.User{ID: "me-id"}, nil
+}
+
+func (f *fakeSelectStore) GetActiveOrganizationOrDefault() (*entity.Organization, error) {
+	return &entity.Organization{ID: "org"}, nil
+}
+
+func (f *fakeSelectStore) GetWorkspaces(_ string, options *store.GetWorkspacesOptions) ([]entity.Workspace, error) {
+	f.gotOptions = options
+	return []entity.Workspace{
+		{ID: "1", Name: "a", Status: entity.Running},
+		{ID: "2", Name: "b", Status: entity.Stopped},
+	}, nil
+}
+
+func TestSelect(t *testing.T) {
+	s := &fakeSelectStore{}
+	selected, err := Select(s, Selector{Statuses: []string{"running"}}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, []entity.Workspace{{ID: "1", Name: "a", Status: entity.Running}}, selected)
+	assert.Equal(t, "me-id", s.gotOptions.UserID)
+
+	_, err = Select(s, Selector{Creator: CreatorAny}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "", s.gotOptions.UserID)
+
+	_, err = Select(s, Selector{Name: "["}, now)
+	assert.Error(t, err)
+}
+
+func TestRunParallel(t *testing.T) {
+	workspaces := []entity.Workspace{}
+	for i := 0; i < 10; i++ {
+		workspaces = append(workspaces, entity.Workspace{ID: fmt.Sprint(i), Name: fmt.Sprintf("ws-%d", i)})
+	}
+	var running, maxRunning int32
+	var mu sync.Mutex
+	results := RunParallel(workspaces, 3, func(w entity.Workspace) error {
+		n := atomic.AddInt32(&running, 1)
+		mu.Lock()
+		if n > maxRunning {
+			maxRunning = n
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if w.ID == "4" {
+			return fmt.Errorf("boom")
+		}
+		return nil
+	})
+
+	assert.LessOrEqual(t, maxRunning, int32(3))
+	if assert.Len(t, results, 10) {
+		for i, r := range results {
+			assert.Equal(t, fmt.Sprint(i), r.ID)
+			assert.Equal(t, i != 4, r.OK())
+		}
+		assert.Equal(t, "boom", results[4].Error)
+	}
+}
@@ -3,20 +3,32 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/brevdev/brev-cli/pkg/auth"
+	"github.com/brevdev/brev-cli/pkg/cmd/configcmd"
 	"github.com/brevdev/brev-cli/pkg/cmd/delete"
 	"github.com/brevdev/brev-cli/pkg/cmd/hello"
+	"github.com/brevdev/brev-cli/pkg/cmd/local"
 	"github.com/brevdev/brev-cli/pkg/cmd/login"
 	"github.com/brevdev/brev-cli/pkg/cmd/logout"
+	"github.com/brevdev/brev-cli/pkg/cmd/logs"
 	"github.com/brevdev/brev-cli/pkg/cmd/ls"
+	"github.com/brevdev/brev-cli/pkg/cmd/open"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
 	"github.com/brevdev/brev-cli/pkg/cmd/portforward"
+	"github.com/brevdev/brev-cli/pkg/cmd/profile"
+	"github.com/brevdev/brev-cli/pkg/cmd/protect"
 	"github.com/brevdev/brev-cli/pkg/cmd/refresh"
 	"github.com/brevdev/brev-cli/pkg/cmd/set"
+	"github.com/brevdev/brev-cli/pkg/cmd/shell"
 	"github.com/brevdev/brev-cli/pkg/cmd/sshkeys"
 	"github.com/brevdev/brev-cli/pkg/cmd/start"
 	"github.com/brevdev/brev-cli/pkg/cmd/stop"
+	"github.com/brevdev/brev-cli/pkg/cmd/tasks"
 	"github.com/brevdev/brev-cli/pkg/cmd/upgrade"
+	"github.com/brevdev/brev-cli/pkg/cmd/wait"
+	"github.com/brevdev/brev-cli/pkg/cmd/whoami"
 	"github.com/brevdev/brev-cli/pkg/config"
 	"github.com/brevdev/brev-cli/pkg/featureflag"
 	"github.com/brevdev/brev-cli/pkg/files"
@@ -24,6 +36,7 @@ import (
 	"github.com/brevdev/brev-cli/pkg/store"
 	"github.com/brevdev/brev-cli/pkg/terminal"
 	"github.com/fatih/color"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
@@ -42,13 +55,13 @@ func NewBrevCommand() *cobra.Command { //nolint:funlen,gocognit,gocyclo // defin
 	t := terminal.New()
 	var printVersion bool
 
-	conf := config.NewConstants()
 	fs := files.AppFs
 	authenticator := auth.Authenticator{
 		Audience:           "https://brevdev.us.auth0.com/api/v2/",
 		ClientID:           "JaqJRLEsdat5w7Tb0WqmTxzIeqwqepmk",
 		DeviceCodeEndpoint: "https://brevdev.us.auth0.com/oauth/device/code",
 		OauthTokenEndpoint: "https://brevdev.us.auth0.com/oauth/token",
+		RevokeEndpoint:     "https://brevdev.us.auth0.com/oauth/revoke",
 	}
 	// super annoying. this is needed to make the import stay
 	_ = color.New(color.FgYellow, color.Bold).SprintFunc()
@@ -56,6 +69,15 @@ func NewBrevCommand() *cobra.Command { //nolint:funlen,gocognit,gocyclo // defin
 	fsStore := store.
 		NewBasicStore().
 		WithFileSystem(fs)
+
+	// the profile decides where credentials and config files live, so it has to be known before
+	// the config is read and any store is used
+	// a profile that can't be resolved fails every command in PersistentPreRunE, the stores
+	// below still need one to be built
+	profileName, resolveProfileErr := profile.ResolveProfile(os.Args[1:], os.Getenv, fsStore)
+	files.SetActiveProfile(profileName)
+	conf := config.Global()
+
 	loginAuth := auth.NewLoginAuth(fsStore, authenticator)
 	noLoginAuth := auth.NewNoLoginAuth(fsStore, authenticator)
 
@@ -113,9 +135,18 @@ func NewBrevCommand() *cobra.Command { //nolint:funlen,gocognit,gocyclo // defin
 		},
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			breverrors.GetDefaultErrorReporter().AddTag("command", cmd.Name())
+			err := checkProfileExists(cmd, fsStore, resolveProfileErr)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
 			// version info gets in the way of the output for
 			// configure-env-vars, since shells are going to eval it
-			if featureflag.ShowVersionOnRun() && !printVersion && cmd.Name() != "configure-env-vars" {
+			// same for machine readable output, which scripts are going to parse
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if featureflag.ShowVersionOnRun() && !printVersion && cmd.Name() != "configure-env-vars" && !format.IsMachineReadable() {
 				v, err := remoteversion.BuildCheckLatestVersionString(t, noLoginCmdStore)
 				// todo this should not be fatal when it errors
 				if err != nil {
@@ -188,27 +219,68 @@ func NewBrevCommand() *cobra.Command { //nolint:funlen,gocognit,gocyclo // defin
 	cmds.SetUsageTemplate(usageTemplate)
 
 	cmds.PersistentFlags().BoolVar(&printVersion, "version", false, "Print version output")
+	output.AddFlag(cmds.PersistentFlags())
+	// only declared here so cobra accepts it, ResolveProfile reads it before parsing
+	cmds.PersistentFlags().String(profile.FlagName, "", "profile to use, overrides "+profile.EnvVarName+" and 'brev profile use'")
 
-	createCmdTree(cmds, t, loginCmdStore, noLoginCmdStore, loginAuth)
+	createCmdTree(cmds, t, loginCmdStore, noLoginCmdStore, loginAuth, noLoginAuth)
 
 	return cmds
 }
 
-func createCmdTree(cmd *cobra.Command, t *terminal.Terminal, loginCmdStore *store.AuthHTTPStore, noLoginCmdStore *store.AuthHTTPStore, loginAuth *auth.LoginAuth) { //nolint:funlen,nolintlint // define brev command
+func createCmdTree(cmd *cobra.Command, t *terminal.Terminal, loginCmdStore *store.AuthHTTPStore, noLoginCmdStore *store.AuthHTTPStore, loginAuth *auth.LoginAuth, noLoginAuth *auth.NoLoginAuth) { //nolint:funlen,nolintlint // define brev command
 	cmd.AddCommand(set.NewCmdSet(t, loginCmdStore, noLoginCmdStore))
 	cmd.AddCommand(ls.NewCmdLs(t, loginCmdStore, noLoginCmdStore))
 	cmd.AddCommand(portforward.NewCmdPortForwardSSH(loginCmdStore, t))
 	cmd.AddCommand(login.NewCmdLogin(t, noLoginCmdStore, loginAuth))
-	cmd.AddCommand(logout.NewCmdLogout(loginAuth, noLoginCmdStore))
+	cmd.AddCommand(logout.NewCmdLogout(t, loginAuth, noLoginCmdStore))
+	cmd.AddCommand(whoami.NewCmdWhoami(t, noLoginCmdStore, noLoginAuth))
 	cmd.AddCommand(sshkeys.NewCmdSSHKeys(t, loginCmdStore))
 	cmd.AddCommand(start.NewCmdStart(t, loginCmdStore, noLoginCmdStore))
 	cmd.AddCommand(stop.NewCmdStop(t, loginCmdStore, noLoginCmdStore))
+	cmd.AddCommand(wait.NewCmdWait(t, loginCmdStore, noLoginCmdStore))
 	cmd.AddCommand(delete.NewCmdDelete(t, loginCmdStore, noLoginCmdStore))
+	cmd.AddCommand(protect.NewCmdProtect(t, loginCmdStore, noLoginCmdStore))
+	cmd.AddCommand(protect.NewCmdUnprotect(t, noLoginCmdStore))
+	cmd.AddCommand(shell.NewCmdShell(t, loginCmdStore, noLoginCmdStore))
+	cmd.AddCommand(open.NewCmdOpen(t, loginCmdStore, noLoginCmdStore))
+	cmd.AddCommand(logs.NewCmdLogs(t, loginCmdStore, noLoginCmdStore))
+	cmd.AddCommand(local.NewCmdLocal(t, noLoginCmdStore))
 	cmd.AddCommand(refresh.NewCmdRefresh(t, loginCmdStore))
+	cmd.AddCommand(configcmd.NewCmdConfig(t, noLoginCmdStore))
+	cmd.AddCommand(profile.NewCmdProfile(t, noLoginCmdStore))
+	cmd.AddCommand(tasks.NewCmdTasks(t, loginCmdStore, noLoginCmdStore))
 	cmd.AddCommand(hello.NewCmdHello(t, noLoginCmdStore))
 	cmd.AddCommand(upgrade.NewCmdUpgrade(t, loginCmdStore))
 }
 
+// checkProfileExists stops commands from running with a profile that was never added or could
+// not be resolved, the profile commands themselves are how it gets fixed
+func checkProfileExists(cmd *cobra.Command, fsStore *store.FileStore, resolveErr error) error {
+	name := files.GetActiveProfile()
+	// a --profile or BREV_PROFILE that is not a profile name is never fallen back from
+	var invalidName breverrors.ValidationError
+	if errors.As(resolveErr, &invalidName) {
+		return invalidName
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "profile" {
+			return nil
+		}
+	}
+	if resolveErr != nil {
+		return breverrors.WrapAndTrace(fmt.Errorf("could not read the current profile, pick one with: brev profile use <name>: %v", errors.Cause(resolveErr)))
+	}
+	exists, err := fsStore.ProfileExists(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return breverrors.NewValidationError(fmt.Sprintf("profile %s does not exist, add it with: brev profile add %s", name, name))
+	}
+	return nil
+}
+
 func hasQuickstartCommands(cmd *cobra.Command) bool {
 	return len(quickstartCommands(cmd)) > 0
 }
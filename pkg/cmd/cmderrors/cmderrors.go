@@ -2,6 +2,7 @@ package cmderrors
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/getsentry/sentry-go"
@@ -60,10 +61,11 @@ func DisplayAndHandleError(err error) {
 				prettyErr = (t.Red(errors.Cause(err).Error()))
 			}
 		}
+		// on stderr so it never ends up in machine readable output
 		if featureflag.Debug() || featureflag.IsDev() {
-			fmt.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 		} else {
-			fmt.Println(prettyErr)
+			fmt.Fprintln(os.Stderr, prettyErr)
 		}
 	}
 }
@@ -0,0 +1,248 @@
+// Package configcmd reads and writes brev's layered config files
+package configcmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/config"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configLong = `Read and write brev settings.
+Settings are layered, each layer overrides the ones before it:
+  1. built in defaults
+  2. ` + config.SystemConfigPath + `
+  3. ~/.brev/config.yaml
+  4. the config.yaml of the profile in use, see 'brev profile'
+  5. env vars
+  6. flags of the command being run
+Use --show-origin to see which layer set each value`
+	configExample = `
+  brev config list --show-origin
+  brev config get default_editor
+  brev config set default_cpu 4x16
+  brev config unset default_cpu
+	`
+)
+
+type ConfigStore interface {
+	GetUserConfigPath() (string, error)
+	SetConfigValue(path string, key string, value string) error
+	UnsetConfigValue(path string, key string) (bool, error)
+}
+
+func NewCmdConfig(t *terminal.Terminal, store ConfigStore) *cobra.Command {
+	var showOrigin bool
+
+	cmd := &cobra.Command{
+		Annotations: map[string]string{"housekeeping": ""},
+		Use:         "config",
+		Short:       "Read and write brev settings",
+		Long:        configLong,
+		Example:     configExample,
+		Args:        cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := runList(cmd, showOrigin)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().BoolVar(&showOrigin, "show-origin", false, "show which layer set each value")
+
+	cmd.AddCommand(newCmdList(&showOrigin))
+	cmd.AddCommand(newCmdGet(&showOrigin))
+	cmd.AddCommand(newCmdSet(t, store))
+	cmd.AddCommand(newCmdUnset(t, store))
+	return cmd
+}
+
+func settingKeys() []string {
+	keys := []string{}
+	for _, s := range config.Settings {
+		keys = append(keys, string(s.Key))
+	}
+	return keys
+}
+
+func newCmdList(showOrigin *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List every setting and its value",
+		Args:  cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := runList(cmd, *showOrigin)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func runList(cmd *cobra.Command, showOrigin bool) error {
+	format, err := output.GetFormat(cmd)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	warnSkippedConfig(config.Global().LayeredConfig)
+	err = displayValues(os.Stdout, format, config.Global().List(), showOrigin)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func newCmdGet(showOrigin *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       "get <key>",
+		Short:     "Print the value of a setting",
+		Args:      cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgs: settingKeys(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			_, err = config.LookupSetting(args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			warnSkippedConfig(config.Global().LayeredConfig)
+			v := config.Global().Lookup(config.Key(args[0]))
+			if format.IsMachineReadable() {
+				err = output.Write(os.Stdout, format, v)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				return nil
+			}
+			if *showOrigin {
+				fmt.Printf("%s\t%s\n", v.Origin, v.Value)
+			} else {
+				fmt.Println(v.Value)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func newCmdSet(t *terminal.Terminal, store ConfigStore) *cobra.Command {
+	var system bool
+	cmd := &cobra.Command{
+		Use:       "set <key> <value>",
+		Short:     "Save a setting in the config.yaml of the profile in use",
+		Args:      cmderrors.TransformToValidationError(cobra.ExactArgs(2)),
+		ValidArgs: settingKeys(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := getConfigPath(store, system)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			err = store.SetConfigValue(path, args[0], args[1])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("set %s to %s in %s\n", args[0], t.Green(args[1]), path)
+			warnShadowed(t, config.Key(args[0]))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&system, "system", false, "save in "+config.SystemConfigPath+" for every user of this machine")
+	return cmd
+}
+
+func newCmdUnset(t *terminal.Terminal, store ConfigStore) *cobra.Command {
+	var system bool
+	cmd := &cobra.Command{
+		Use:       "unset <key>",
+		Short:     "Remove a setting from the config.yaml of the profile in use",
+		Args:      cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgs: settingKeys(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := getConfigPath(store, system)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			unset, err := store.UnsetConfigValue(path, args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if !unset {
+				t.Vprintf("%s is not set in %s\n", args[0], path)
+				return nil
+			}
+			t.Vprintf("removed %s from %s\n", args[0], path)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&system, "system", false, "remove from "+config.SystemConfigPath)
+	return cmd
+}
+
+func getConfigPath(store ConfigStore, system bool) (string, error) {
+	if system {
+		return config.SystemConfigPath, nil
+	}
+	path, err := store.GetUserConfigPath()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return path, nil
+}
+
+// warnShadowed points out an env var that will keep overriding what was just saved
+func warnShadowed(t *terminal.Terminal, key config.Key) {
+	v := config.Global().Lookup(key)
+	for _, s := range config.Settings {
+		if s.Key == key && s.EnvVar != "" && v.Origin == "env "+string(s.EnvVar) {
+			t.Vprint(t.Yellow(fmt.Sprintf("%s is set and overrides the saved value\n", s.EnvVar)))
+		}
+	}
+}
+
+func warnSkippedConfig(conf config.LayeredConfig) {
+	err := conf.Err()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "some config files or values were skipped: %v\n", err)
+	}
+}
+
+func displayValues(w io.Writer, format output.Format, values []config.Value, showOrigin bool) error {
+	if format.IsMachineReadable() {
+		err := output.Write(w, format, values)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	}
+	ta := table.NewWriter()
+	ta.SetOutputMirror(w)
+	ta.Style().Options = util.GetBrevTableOptions()
+	if showOrigin {
+		ta.AppendHeader(table.Row{"KEY", "VALUE", "ORIGIN"})
+	} else {
+		ta.AppendHeader(table.Row{"KEY", "VALUE"})
+	}
+	for _, v := range values {
+		if showOrigin {
+			ta.AppendRow(table.Row{v.Key, v.Value, v.Origin})
+		} else {
+			ta.AppendRow(table.Row{v.Key, v.Value})
+		}
+	}
+	ta.Render()
+	return nil
+}
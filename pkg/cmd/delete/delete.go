@@ -4,11 +4,15 @@ import (
 	_ "embed"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/brevdev/brev-cli/pkg/cmd/bulk"
 	"github.com/brevdev/brev-cli/pkg/cmd/completions"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
 	"github.com/brevdev/brev-cli/pkg/cmd/util"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/store"
 	"github.com/brevdev/brev-cli/pkg/terminal"
 	"github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
@@ -18,16 +22,24 @@ import (
 var (
 	//go:embed doc.md
 	deleteLong    string
-	deleteExample = "brev delete <ws_name>"
+	deleteExample = "brev delete <ws_name>\nbrev delete <ws_name> --force\nbrev delete <ws_name> --dry-run\nbrev delete <ws_name> --wait --timeout 10m\nbrev delete --match 'test-*' --status stopped --dry-run"
 )
 
 type DeleteStore interface {
 	completions.CompletionStore
+	util.GetWorkspaceStore
 	DeleteWorkspace(workspaceID string) (*entity.Workspace, error)
 	GetWorkspaceByNameOrID(orgID string, nameOrID string) ([]entity.Workspace, error)
+	GetWorkspaces(organizationID string, options *store.GetWorkspacesOptions) ([]entity.Workspace, error)
+	IsWorkspaceProtected(workspaceID string) (bool, error)
 }
 
 func NewCmdDelete(t *terminal.Terminal, loginDeleteStore DeleteStore, noLoginDeleteStore DeleteStore) *cobra.Command {
+	var wait bool
+	var timeout time.Duration
+	var bulkOpts bulk.Options
+	var force bool
+
 	cmd := &cobra.Command{
 		Annotations:           map[string]string{"workspace": ""},
 		Use:                   "delete",
@@ -37,9 +49,30 @@ func NewCmdDelete(t *terminal.Terminal, loginDeleteStore DeleteStore, noLoginDel
 		Example:               deleteExample,
 		ValidArgsFunction:     completions.GetAllWorkspaceNameCompletionHandler(noLoginDeleteStore, t),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
 			var allError error
+			deletedIDs := []string{}
+			if bulkOpts.IsSet() {
+				if len(args) > 0 {
+					return breverrors.NewValidationError("instance names can not be combined with selector flags")
+				}
+				bulkOpts.Yes = bulkOpts.Yes || force
+				deletedIDs, allError = deleteSelectedWorkspaces(t, loginDeleteStore, bulkOpts, force, format)
+			}
+			opts := deleteOptions{Force: force, Yes: bulkOpts.Yes, DryRun: bulkOpts.DryRun}
 			for _, workspace := range args {
-				err := deleteWorkspace(workspace, t, loginDeleteStore)
+				workspaceID, err := deleteWorkspace(workspace, t, loginDeleteStore, opts)
+				if err != nil {
+					allError = multierror.Append(allError, err)
+				} else if workspaceID != "" {
+					deletedIDs = append(deletedIDs, workspaceID)
+				}
+			}
+			if wait {
+				err = waitForDeleted(t, loginDeleteStore, deletedIDs, timeout, format == output.JSON)
 				if err != nil {
 					allError = multierror.Append(allError, err)
 				}
@@ -50,52 +83,197 @@ func NewCmdDelete(t *terminal.Terminal, loginDeleteStore DeleteStore, noLoginDel
 			return nil
 		},
 	}
+	bulkOpts.AddFlags(cmd.Flags())
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "delete without asking for confirmation, protected instances are still refused")
+	cmd.Flags().BoolVarP(&wait, "wait", "w", false, "wait until the instances are deleted")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "with --wait, give up after this long, e.g. 10m (default no limit)")
 
 	return cmd
 }
 
-func deleteWorkspace(workspaceName string, t *terminal.Terminal, deleteStore DeleteStore) error {
-	workspace, err := util.GetUserWorkspaceByNameOrIDErr(deleteStore, workspaceName)
+type deleteOptions struct {
+	Force bool
+	// Yes only answers the yes/no prompt, instances of other users still need their name typed
+	Yes    bool
+	DryRun bool
+}
+
+// deleteWorkspace returns an empty id if nothing was deleted, because of --dry-run or because
+// the deletion was not confirmed
+func deleteWorkspace(workspaceName string, t *terminal.Terminal, deleteStore DeleteStore, opts deleteOptions) (string, error) {
+	user, err := deleteStore.GetCurrentUser()
 	if err != nil {
-		err1 := handleAdminUser(err, deleteStore)
-		if err1 != nil {
-			return breverrors.WrapAndTrace(err1)
-		}
+		return "", breverrors.WrapAndTrace(err)
+	}
+	workspace, owned, err := getWorkspaceToDelete(deleteStore, user, workspaceName)
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	err = checkNotProtected(deleteStore, *workspace)
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
 	}
 
-	var workspaceID string
-	if workspace != nil {
-		workspaceID = workspace.ID
-	} else {
-		workspaceID = workspaceName
+	if opts.DryRun {
+		displayDeleteSummary(t, *workspace, user)
+		t.Vprintf("would delete instance %s\n", workspace.Name)
+		return "", nil
+	}
+	if !skipConfirm(opts, owned) {
+		confirmed, err := confirmDelete(t, *workspace, user, owned)
+		if err != nil {
+			return "", breverrors.WrapAndTrace(err)
+		}
+		if !confirmed {
+			t.Vprintf("Not deleting instance %s\n", workspace.Name)
+			return "", nil
+		}
 	}
 
-	deletedWorkspace, err := deleteStore.DeleteWorkspace(workspaceID)
+	deletedWorkspace, err := deleteStore.DeleteWorkspace(workspace.ID)
 	if err != nil {
-		return breverrors.WrapAndTrace(err)
+		return "", breverrors.WrapAndTrace(err)
 	}
 
 	t.Vprintf("Deleting instance %s. This can take a few minutes. Run 'brev ls' to check status\n", deletedWorkspace.Name)
 
+	return deletedWorkspace.ID, nil
+}
+
+// getWorkspaceToDelete looks up your own instance, admins can also pick any instance in the
+// active org. owned is false for those
+func getWorkspaceToDelete(deleteStore DeleteStore, user *entity.User, workspaceName string) (*entity.Workspace, bool, error) {
+	workspace, err := util.GetUserWorkspaceByNameOrIDErr(deleteStore, workspaceName)
+	if err == nil {
+		return workspace, true, nil
+	}
+	if !strings.Contains(err.Error(), "not found") || user.GlobalUserType != entity.Admin {
+		return nil, false, breverrors.WrapAndTrace(err)
+	}
+	workspace, err = util.GetAnyWorkspaceByIDOrNameInActiveOrgErr(deleteStore, workspaceName)
+	if err != nil {
+		return nil, false, breverrors.WrapAndTrace(err)
+	}
+	return workspace, false, nil
+}
+
+func checkNotProtected(deleteStore DeleteStore, workspace entity.Workspace) error {
+	protected, err := deleteStore.IsWorkspaceProtected(workspace.ID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if protected {
+		return breverrors.NewValidationError(fmt.Sprintf("instance %s is protected, run 'brev unprotect %s' to allow deleting it", workspace.Name, workspace.Name))
+	}
 	return nil
 }
 
-func handleAdminUser(err error, deleteStore DeleteStore) error {
-	if strings.Contains(err.Error(), "not found") {
-		user, err1 := deleteStore.GetCurrentUser()
-		if err1 != nil {
-			return breverrors.WrapAndTrace(err1)
+func displayDeleteSummary(t *terminal.Terminal, workspace entity.Workspace, user *entity.User) {
+	creator := workspace.CreatedByUserID
+	if creator == user.ID {
+		creator = "you"
+	}
+	repo := workspace.GitRepo
+	if repo == "" {
+		repo = "-"
+	}
+	t.Vprintf("\tname    %s\n", t.Yellow(workspace.Name))
+	t.Vprintf("\tid      %s\n", workspace.ID)
+	t.Vprintf("\tstatus  %s\n", workspace.Status)
+	t.Vprintf("\trepo    %s\n", repo)
+	t.Vprintf("\tcreator %s\n", creator)
+}
+
+// skipConfirm is true for --force, and for --yes on your own instances only
+func skipConfirm(opts deleteOptions, owned bool) bool {
+	return opts.Force || (opts.Yes && owned)
+}
+
+// confirmDelete asks before deleting, instances you don't own have to be confirmed by typing
+// their name
+func confirmDelete(t *terminal.Terminal, workspace entity.Workspace, user *entity.User, owned bool) (bool, error) {
+	if !bulk.IsInteractive() {
+		if !owned {
+			return false, breverrors.NewValidationError(fmt.Sprintf("instance %s is not yours and its name can not be typed without a terminal, pass --force to delete it anyway", workspace.Name))
 		}
-		if user.GlobalUserType != "Admin" {
-			return breverrors.WrapAndTrace(err)
+		return false, breverrors.NewValidationError("can not ask for confirmation without a terminal, pass --yes or --force to delete anyway")
+	}
+	t.Vprint(t.Red("This will permanently delete the instance and everything on it:\n"))
+	displayDeleteSummary(t, workspace, user)
+	if owned {
+		confirmed, err := bulk.Confirm(fmt.Sprintf("Delete instance %s?", workspace.Name))
+		if err != nil {
+			return false, breverrors.WrapAndTrace(err)
+		}
+		return confirmed, nil
+	}
+	typed := terminal.PromptGetInput(terminal.PromptContent{
+		Label:      fmt.Sprintf("This instance is not yours, type %s to delete it:", workspace.Name),
+		ErrorMsg:   "error",
+		AllowEmpty: true,
+	})
+	return typedNameMatches(typed, workspace.Name), nil
+}
+
+func typedNameMatches(typed string, name string) bool {
+	return strings.TrimSpace(typed) == name
+}
+
+// deleteSelectedWorkspaces deletes every selected instance and returns the ids of the ones that
+// were deleted even if others failed. Instances of other users are only deleted with --force,
+// a yes/no prompt or --yes is not enough for those
+func deleteSelectedWorkspaces(t *terminal.Terminal, deleteStore DeleteStore, opts bulk.Options, force bool, format output.Format) ([]string, error) {
+	user, err := deleteStore.GetCurrentUser()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	results, err := bulk.Run(t, deleteStore, opts, "delete", format, func(w entity.Workspace) error {
+		return deleteSelectedWorkspace(deleteStore, user, force, w)
+	})
+	deletedIDs := []string{}
+	for _, r := range results {
+		if r.OK() {
+			deletedIDs = append(deletedIDs, r.ID)
 		}
-		fmt.Println("attempting to delete a workspace you don't own as admin")
-		return nil
 	}
+	if err != nil {
+		return deletedIDs, breverrors.WrapAndTrace(err)
+	}
+	return deletedIDs, nil
+}
 
+func deleteSelectedWorkspace(deleteStore DeleteStore, user *entity.User, force bool, w entity.Workspace) error {
+	if w.CreatedByUserID != user.ID && !force {
+		return breverrors.NewValidationError(fmt.Sprintf("instance %s is not yours, pass --force to delete instances of other users in bulk, or delete it by name to confirm", w.Name))
+	}
+	err := checkNotProtected(deleteStore, w)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
+	_, err = deleteStore.DeleteWorkspace(w.ID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
 
+func waitForDeleted(t *terminal.Terminal, deleteStore DeleteStore, workspaceIDs []string, timeout time.Duration, jsonEvents bool) error {
+	var allError error
+	for _, id := range workspaceIDs {
+		_, err := util.WaitForWorkspaceStatus(deleteStore, id, util.WaitOptions{
+			For:      []string{util.Deleted},
+			Timeout:  timeout,
+			Reporter: util.NewWaitReporter(t, jsonEvents, " waiting for instance to be deleted..."),
+		})
+		if err != nil {
+			allError = multierror.Append(allError, err)
+		}
+	}
+	if allError != nil {
+		return breverrors.WrapAndTrace(allError)
+	}
+	if !jsonEvents && len(workspaceIDs) > 0 {
+		t.Vprint(t.Green("Deleted ✓\n"))
+	}
 	return nil
 }
@@ -1 +1,70 @@
 package delete
+
+import (
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+type protectedOnlyStore struct {
+	DeleteStore
+	protected map[string]bool
+}
+
+func (s protectedOnlyStore) IsWorkspaceProtected(workspaceID string) (bool, error) {
+	return s.protected[workspaceID], nil
+}
+
+type bulkDeleteStore struct {
+	protectedOnlyStore
+	deleted []string
+}
+
+func (s *bulkDeleteStore) DeleteWorkspace(workspaceID string) (*entity.Workspace, error) {
+	s.deleted = append(s.deleted, workspaceID)
+	return &entity.Workspace{ID: workspaceID}, nil
+}
+
+func TestDeleteSelectedWorkspaceRefusesOthersWithoutForce(t *testing.T) {
+	s := &bulkDeleteStore{}
+	me := &entity.User{ID: "me"}
+	mine := entity.Workspace{ID: "ws-1", Name: "mine", CreatedByUserID: "me"}
+	theirs := entity.Workspace{ID: "ws-2", Name: "theirs", CreatedByUserID: "someone"}
+
+	assert.Nil(t, deleteSelectedWorkspace(s, me, false, mine))
+	err := deleteSelectedWorkspace(s, me, false, theirs)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "instance theirs is not yours")
+	}
+	assert.Equal(t, []string{"ws-1"}, s.deleted)
+
+	assert.Nil(t, deleteSelectedWorkspace(s, me, true, theirs))
+	assert.Equal(t, []string{"ws-1", "ws-2"}, s.deleted)
+}
+
+func TestCheckNotProtected(t *testing.T) {
+	s := protectedOnlyStore{protected: map[string]bool{"ws-1": true}}
+
+	err := checkNotProtected(s, entity.Workspace{ID: "ws-1", Name: "keep-me"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "brev unprotect keep-me")
+	}
+	assert.Nil(t, checkNotProtected(s, entity.Workspace{ID: "ws-2", Name: "scratch"}))
+}
+
+func TestTypedNameMatches(t *testing.T) {
+	assert.True(t, typedNameMatches("payments-frontend", "payments-frontend"))
+	assert.True(t, typedNameMatches(" payments-frontend\n", "payments-frontend"))
+	assert.False(t, typedNameMatches("payments", "payments-frontend"))
+	assert.False(t, typedNameMatches("Payments-Frontend", "payments-frontend"))
+	assert.False(t, typedNameMatches("", "payments-frontend"))
+}
+
+func TestSkipConfirm(t *testing.T) {
+	assert.False(t, skipConfirm(deleteOptions{}, true))
+	assert.True(t, skipConfirm(deleteOptions{Yes: true}, true))
+	// --yes doesn't type the name of another user's instance
+	assert.False(t, skipConfirm(deleteOptions{Yes: true}, false))
+	assert.True(t, skipConfirm(deleteOptions{Force: true}, false))
+}
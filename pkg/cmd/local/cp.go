@@ -0,0 +1,176 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/setupworkspace"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/brevdev/brev-cli/pkg/workspacemanagerv2"
+	units "github.com/docker/go-units"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+func newCmdCp(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cp <src> <dst>",
+		Short: "Copy files between this machine and a local workspace",
+		Long: `Copy a file or a directory between this machine and a local workspace, the path in the
+workspace is written <name>:<path> and relative to ` + setupworkspace.DefaultWorkspaceDir + `.
+What is copied is put in the destination directory, which is created on this machine but has
+to exist in the workspace`,
+		Example: `
+  brev local cp ./data dev:
+  brev local cp dev:results/model.pt ./out
+`,
+		Args: cmderrors.TransformToValidationError(cobra.ExactArgs(2)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			err = RunCp(cmd.Context(), localWorkspaces, cm, args[0], args[1])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("copied %s to %s\n", args[0], args[1])
+			return nil
+		},
+	}
+	return cmd
+}
+
+// parseCopyPath splits <name>:<path>, a path without a colon, or with a slash before it, is on this machine
+func parseCopyPath(arg string) (string, string, bool) {
+	i := strings.Index(arg, ":")
+	if i <= 0 || strings.Contains(arg[:i], "/") {
+		return "", arg, false
+	}
+	p := arg[i+1:]
+	if !path.IsAbs(p) {
+		p = path.Join(setupworkspace.DefaultWorkspaceDir, p)
+	}
+	return arg[:i], p, true
+}
+
+func RunCp(ctx context.Context, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, src string, dst string) error {
+	srcName, srcPath, srcInWorkspace := parseCopyPath(src)
+	dstName, dstPath, dstInWorkspace := parseCopyPath(dst)
+	switch {
+	case srcInWorkspace && !dstInWorkspace:
+		err := checkRunning(ctx, localWorkspaces, cm, srcName)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		archive, err := cm.CopyFromContainer(ctx, srcName, srcPath)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		err = workspacemanagerv2.Untar(archive, dstPath)
+		closeErr := archive.Close()
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return breverrors.WrapAndTrace(closeErr)
+	case !srcInWorkspace && dstInWorkspace:
+		err := checkRunning(ctx, localWorkspaces, cm, dstName)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		// fail before streaming, the error of the tar would otherwise only show as a broken stream
+		_, err = os.Stat(srcPath)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			_ = pw.CloseWithError(workspacemanagerv2.TarPath(pw, srcPath))
+		}()
+		err = cm.CopyToContainer(ctx, dstName, dstPath, pr)
+		_ = pr.Close()
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	default:
+		return breverrors.NewValidationError(fmt.Sprintf("one of %s and %s has to be in a local workspace, e.g. dev:path", src, dst))
+	}
+}
+
+func newCmdStats(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "stats <name>",
+		Short:             "Show the cpu, memory and network usage of a local workspace",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			stats, err := RunStats(cmd.Context(), localWorkspaces, cm, args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if format.IsMachineReadable() {
+				err = output.Write(os.Stdout, format, stats)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				return nil
+			}
+			displayStats(t, args[0], stats)
+			return nil
+		},
+	}
+	return cmd
+}
+
+func RunStats(ctx context.Context, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, name string) (*workspacemanagerv2.ContainerStats, error) {
+	err := checkRunning(ctx, localWorkspaces, cm, name)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	stats, err := cm.GetContainerStats(ctx, name)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return stats, nil
+}
+
+func displayStats(_ *terminal.Terminal, name string, stats *workspacemanagerv2.ContainerStats) {
+	ta := table.NewWriter()
+	ta.SetOutputMirror(os.Stdout)
+	ta.Style().Options = util.GetBrevTableOptions()
+	ta.AppendHeader(table.Row{"NAME", "CPU %", "MEM USAGE / LIMIT", "NET I/O", "PIDS"})
+	ta.AppendRow(table.Row{
+		name,
+		fmt.Sprintf("%.2f%%", stats.CPUPercent),
+		fmt.Sprintf("%s / %s", units.BytesSize(float64(stats.MemoryUsage)), units.BytesSize(float64(stats.MemoryLimit))),
+		fmt.Sprintf("%s / %s", units.HumanSize(float64(stats.NetworkRx)), units.HumanSize(float64(stats.NetworkTx))),
+		stats.PIDs,
+	})
+	ta.Render()
+}
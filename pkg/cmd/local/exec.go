@@ -0,0 +1,266 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/setupworkspace"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/brevdev/brev-cli/pkg/workspacemanagerv2"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// the image may not have bash
+var loginShell = []string{"sh", "-c", "if command -v bash >/dev/null; then exec bash -l; else exec sh -l; fi"}
+
+func newCmdShell(_ *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	var user string
+	cmd := &cobra.Command{
+		Use:               "shell <name>",
+		Short:             "Open a shell in a local workspace",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			// the exit code of an interactive shell is whatever ran last, it is not an error of the command
+			_, err = runInTerminal(cmd.Context(), localWorkspaces, cm, args[0], workspacemanagerv2.ExecOptions{
+				Cmd:        loginShell,
+				User:       user,
+				WorkingDir: setupworkspace.DefaultWorkspaceDir,
+			})
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&user, "container-user", "u", "brev", "user in the container to open the shell as")
+	return cmd
+}
+
+func newCmdExec(_ *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	var tty bool
+	options := workspacemanagerv2.ExecOptions{}
+	cmd := &cobra.Command{
+		Use:   "exec <name> -- <command> [args...]",
+		Short: "Run a command in a local workspace",
+		Long: `Run a command in a local workspace. Input is passed to the command and brev fails when
+the command does`,
+		Example: `
+  brev local exec dev -- ls -la
+  brev local exec dev -t -- htop
+  cat data.csv | brev local exec dev -- python3 process.py
+`,
+		Args:              cmderrors.TransformToValidationError(cobra.MinimumNArgs(2)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			options.Cmd = args[1:]
+			var exitCode int
+			if tty {
+				exitCode, err = runInTerminal(cmd.Context(), localWorkspaces, cm, args[0], options)
+			} else {
+				options.Stdin, options.Stdout, options.Stderr = os.Stdin, os.Stdout, os.Stderr
+				exitCode, err = RunExec(cmd.Context(), localWorkspaces, cm, args[0], options)
+			}
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if exitCode != 0 {
+				return breverrors.NewValidationError(fmt.Sprintf("%s exited with code %d", args[1], exitCode))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&tty, "tty", "t", false, "run the command in a terminal, for interactive programs")
+	cmd.Flags().StringVarP(&options.User, "container-user", "u", "brev", "user in the container to run the command as")
+	cmd.Flags().StringVarP(&options.WorkingDir, "workdir", "w", setupworkspace.DefaultWorkspaceDir, "directory to run the command in")
+	cmd.Flags().StringArrayVarP(&options.Env, "env", "e", nil, "KEY=VALUE to set in the environment of the command")
+	return cmd
+}
+
+// RunExec runs a command in the container of a local workspace, which has to be running
+func RunExec(ctx context.Context, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, name string, options workspacemanagerv2.ExecOptions) (int, error) {
+	err := checkRunning(ctx, localWorkspaces, cm, name)
+	if err != nil {
+		return 0, breverrors.WrapAndTrace(err)
+	}
+	exitCode, err := cm.ExecContainer(ctx, name, options)
+	if err != nil {
+		return 0, breverrors.WrapAndTrace(err)
+	}
+	return exitCode, nil
+}
+
+// runInTerminal puts the terminal in raw mode so keys like ctrl-c go to the command, without a
+// terminal, e.g. when input is piped, the command runs without one too
+func runInTerminal(ctx context.Context, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, name string, options workspacemanagerv2.ExecOptions) (int, error) {
+	options.Stdin, options.Stdout, options.Stderr = os.Stdin, os.Stdout, os.Stderr
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		options.TTY = true
+		if width, height, err := term.GetSize(fd); err == nil {
+			options.Width, options.Height = uint(width), uint(height)
+		}
+		if termEnv := os.Getenv("TERM"); termEnv != "" {
+			options.Env = append(options.Env, "TERM="+termEnv)
+		}
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return 0, breverrors.WrapAndTrace(err)
+		}
+		defer term.Restore(fd, state) //nolint:errcheck // defer
+		resizes, stop := watchTerminalSize(fd)
+		defer stop()
+		options.Resizes = resizes
+	}
+	exitCode, err := RunExec(ctx, localWorkspaces, cm, name, options)
+	if err != nil {
+		return 0, breverrors.WrapAndTrace(err)
+	}
+	return exitCode, nil
+}
+
+// watchTerminalSize sends the new size of the terminal whenever its window is resized, until stop
+func watchTerminalSize(fd int) (<-chan workspacemanagerv2.TerminalSize, func()) {
+	resizes := make(chan workspacemanagerv2.TerminalSize)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				width, height, err := term.GetSize(fd)
+				if err != nil {
+					continue
+				}
+				select {
+				case resizes <- workspacemanagerv2.TerminalSize{Width: uint(width), Height: uint(height)}:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return resizes, func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+// checkRunning gives the commands that need the container a hint on how to start it
+func checkRunning(ctx context.Context, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, name string) error {
+	_, err := localWorkspaces.GetLocalWorkspace(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	container, err := workspacemanagerv2.NewLocalWorkspaceManager(cm, localWorkspaces).GetContainer(ctx, name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if container == nil || container.Status != workspacemanagerv2.ContainerRunning {
+		return breverrors.NewValidationError(fmt.Sprintf("local workspace %s is not running, start it with: brev local start %s", name, name))
+	}
+	return nil
+}
+
+func newCmdLogs(_ *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	options := LogsOptions{}
+	cmd := &cobra.Command{
+		Use:   "logs <name>",
+		Short: "Show the logs of a local workspace",
+		Long: `Show the logs of the container of a local workspace, or with --setup the log of its setup,
+` + setupworkspace.WorkspaceLogPath + ` in the container`,
+		Example: `
+  brev local logs dev
+  brev local logs dev --setup -f
+`,
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			options.Stdout, options.Stderr = os.Stdout, os.Stderr
+			err = RunLogs(cmd.Context(), localWorkspaces, cm, args[0], options)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&options.Follow, "follow", "f", false, "keep printing new lines")
+	cmd.Flags().IntVar(&options.Tail, "tail", 0, "only print the last n lines")
+	cmd.Flags().BoolVar(&options.Setup, "setup", false, "print the setup log instead of the container logs")
+	return cmd
+}
+
+type LogsOptions struct {
+	workspacemanagerv2.LogsOptions
+	// Setup reads the setup log, which needs the container to run
+	Setup bool
+}
+
+func RunLogs(ctx context.Context, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, name string, options LogsOptions) error {
+	if !options.Setup {
+		_, err := localWorkspaces.GetLocalWorkspace(name)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		err = cm.ContainerLogs(ctx, name, options.LogsOptions)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	}
+	tail := []string{"tail", "-n", "+1"}
+	if options.Tail > 0 {
+		tail = []string{"tail", "-n", fmt.Sprint(options.Tail)}
+	}
+	if options.Follow {
+		tail = append(tail, "-F")
+	}
+	stderr := &strings.Builder{}
+	exitCode, err := RunExec(ctx, localWorkspaces, cm, name, workspacemanagerv2.ExecOptions{
+		Cmd:    append(tail, setupworkspace.WorkspaceLogPath),
+		Stdout: options.Stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if exitCode != 0 && ctx.Err() == nil {
+		return breverrors.WrapAndTrace(fmt.Errorf("could not read the setup log: %s", strings.TrimSpace(stderr.String())))
+	}
+	return nil
+}
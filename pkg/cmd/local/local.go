@@ -0,0 +1,518 @@
+// Package local runs workspaces in containers on this machine
+package local
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/bulk"
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/config"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/brevdev/brev-cli/pkg/uri"
+	"github.com/brevdev/brev-cli/pkg/workspacemanagerv2"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DefaultImage = "brevdev/ubuntu-proxy:0.3.17"
+	// StatusNotCreated is a local workspace that was never started or whose container was removed
+	StatusNotCreated = "not created"
+	defaultPort      = 22778
+)
+
+var (
+	localLong = `Run a workspace in a container on this machine, with the same image and setup as
+an instance but without paying for one. Useful to iterate on a setup script: change it and
+'brev local reset' runs it again in a fresh container. The setup params, workspace meta and
+volumes and snapshots of each local workspace are kept in ~/.brev/local/<name>.
+
+Containers run on docker by default, 'brev config set container_runtime podman' switches to
+podman and container_host, container_privileged and container_cap_add change how they run`
+	localExample = `
+  brev local start dev --setup-script ./setup.sh
+  brev local ls
+  brev local shell dev
+  brev local logs dev --setup -f
+  brev local cp ./data dev:
+  brev local reset dev --setup-script ./setup.sh
+  brev local snapshot ls dev
+  brev local stop dev
+  brev local rm dev
+	`
+)
+
+type LocalStore interface {
+	GetLocalWorkspaceStore() (*store.LocalWorkspaceStore, error)
+}
+
+// LocalWorkspaceStatus is also the json output of ls, keep field names stable
+type LocalWorkspaceStatus struct {
+	store.LocalWorkspace
+	Status string `json:"status"`
+}
+
+// getContainerManager is called by each command so a bad container setting only breaks "brev local"
+type getContainerManager func() (workspacemanagerv2.ContainerManager, error)
+
+func NewCmdLocal(t *terminal.Terminal, localStore LocalStore) *cobra.Command {
+	return newCmdLocal(t, localStore, NewContainerManager)
+}
+
+// NewContainerManager picks the runtime and how containers are created from the container_* settings
+func NewContainerManager() (workspacemanagerv2.ContainerManager, error) {
+	cfg := config.Global()
+	engineConfig := workspacemanagerv2.EngineConfig{
+		Host:       cfg.GetContainerHost(),
+		Privileged: cfg.GetContainerPrivileged(),
+		CapAdd:     cfg.GetContainerCapAdd(),
+	}
+	switch cfg.GetContainerRuntime() {
+	case config.ContainerRuntimeDockerCLI:
+		if engineConfig.Host != "" {
+			return nil, breverrors.NewValidationError("container_host is not used with container_runtime=docker-cli, set DOCKER_HOST or a docker context instead")
+		}
+		return workspacemanagerv2.NewDockerContainerManager(engineConfig), nil
+	case config.ContainerRuntimePodman:
+		cm, err := workspacemanagerv2.NewPodmanContainerManager(engineConfig)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		return cm, nil
+	default:
+		cm, err := workspacemanagerv2.NewDockerEngineContainerManager(engineConfig)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		return cm, nil
+	}
+}
+
+func newCmdLocal(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	cmd := &cobra.Command{
+		Annotations: map[string]string{"workspace": ""},
+		Use:         "local",
+		Short:       "Run workspaces in containers on this machine",
+		Long:        localLong,
+		Example:     localExample,
+		Args:        cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := runLs(cmd, t, localStore, getCM)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.AddCommand(newCmdStart(t, localStore, getCM))
+	cmd.AddCommand(newCmdStop(t, localStore, getCM))
+	cmd.AddCommand(newCmdReset(t, localStore, getCM))
+	cmd.AddCommand(newCmdRm(t, localStore, getCM))
+	cmd.AddCommand(newCmdLs(t, localStore, getCM))
+	cmd.AddCommand(newCmdShell(t, localStore, getCM))
+	cmd.AddCommand(newCmdExec(t, localStore, getCM))
+	cmd.AddCommand(newCmdLogs(t, localStore, getCM))
+	cmd.AddCommand(newCmdCp(t, localStore, getCM))
+	cmd.AddCommand(newCmdStats(t, localStore, getCM))
+	cmd.AddCommand(newCmdSnapshot(t, localStore, getCM))
+	return cmd
+}
+
+// StartOptions only apply when the local workspace is created
+type StartOptions struct {
+	Image           string
+	SetupScriptPath string
+	SetupParamsPath string
+	Repo            string
+	Branch          string
+}
+
+func (o StartOptions) isSet() bool {
+	return o.Image != "" || o.SetupScriptPath != "" || o.SetupParamsPath != "" || o.Repo != "" || o.Branch != ""
+}
+
+func newCmdStart(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	opts := StartOptions{}
+	cmd := &cobra.Command{
+		Use:               "start <name>",
+		Short:             "Create a local workspace, or start a stopped one",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			created, err := RunStart(cmd.Context(), afero.NewOsFs(), localWorkspaces, cm, args[0], opts)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if created {
+				t.Vprintf("created local workspace %s\n", t.Green(args[0]))
+			}
+			t.Vprintf("started local workspace %s\n", t.Green(args[0]))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.Image, "image", "", "image of the workspace, defaults to "+DefaultImage)
+	cmd.Flags().StringVar(&opts.SetupScriptPath, "setup-script", "", "setup script to run in the workspace")
+	cmd.Flags().StringVar(&opts.SetupParamsPath, "setup-params", "", "setup_v0.json to use as is, e.g. one copied from an instance")
+	cmd.Flags().StringVar(&opts.Repo, "repo", "", "git repo to clone into the workspace")
+	cmd.Flags().StringVar(&opts.Branch, "branch", "", "branch of --repo")
+	return cmd
+}
+
+// RunStart creates the local workspace if it does not exist yet, fs is where the setup files
+// given in opts are read from
+func RunStart(ctx context.Context, fs afero.Fs, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, name string, opts StartOptions) (bool, error) {
+	exists, err := localWorkspaces.LocalWorkspaceExists(name)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	wm := workspacemanagerv2.NewLocalWorkspaceManager(cm, localWorkspaces)
+	if exists && opts.isSet() {
+		return false, breverrors.NewValidationError(fmt.Sprintf("local workspace %s already exists, change its setup script with: brev local reset %s --setup-script <path>", name, name))
+	}
+	if !exists {
+		err = createLocalWorkspace(ctx, fs, localWorkspaces, wm, name, opts)
+		if err != nil {
+			return false, breverrors.WrapAndTrace(err)
+		}
+	}
+	err = wm.Start(ctx, name)
+	if err != nil {
+		return !exists, breverrors.WrapAndTrace(err)
+	}
+	return !exists, nil
+}
+
+func createLocalWorkspace(ctx context.Context, fs afero.Fs, localWorkspaces *store.LocalWorkspaceStore, wm *workspacemanagerv2.WorkspaceManager, name string, opts StartOptions) error {
+	err := store.ValidateLocalWorkspaceName(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	// the container is named after the workspace, don't take over one brev didn't create
+	container, err := wm.GetContainer(ctx, name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if container != nil && !container.IsManagedByBrev() {
+		return breverrors.NewValidationError(fmt.Sprintf("there already is a container named %s, pick another name", name))
+	}
+	params, err := makeSetupParams(fs, name, opts)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	image := opts.Image
+	if image == "" {
+		image = DefaultImage
+	}
+	err = localWorkspaces.CreateLocalWorkspace(store.LocalWorkspace{Name: name, Image: image, CreatedAt: time.Now().UTC()}, *params)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func makeSetupParams(fs afero.Fs, name string, opts StartOptions) (*store.SetupParamsV0, error) {
+	params := store.SetupParamsV0{
+		WorkspaceHost:                    uri.Host(name),
+		WorkspacePort:                    defaultPort,
+		WorkspaceProjectRepo:             opts.Repo,
+		WorkspaceProjectRepoBranch:       opts.Branch,
+		WorkspaceApplicationStartScripts: []string{},
+		WorkspaceUsername:                "brev",
+		WorkspaceKeyPair:                 &store.KeyPair{},
+	}
+	if opts.SetupParamsPath != "" {
+		if opts.Repo != "" || opts.Branch != "" {
+			return nil, breverrors.NewValidationError("--repo and --branch can not be used with --setup-params")
+		}
+		params = store.SetupParamsV0{}
+		err := files.ReadJSON(fs, opts.SetupParamsPath, &params)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+	}
+	if opts.SetupScriptPath != "" {
+		err := setSetupScript(fs, &params, opts.SetupScriptPath)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+	}
+	return &params, nil
+}
+
+// setSetupScript encodes the script the way the api sends it
+func setSetupScript(fs afero.Fs, params *store.SetupParamsV0, path string) error {
+	script, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(script)
+	params.ProjectSetupScript = &encoded
+	return nil
+}
+
+func newCmdStop(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "stop <name>",
+		Short:             "Stop a local workspace, its container and files are kept",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			_, err = localWorkspaces.GetLocalWorkspace(args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			err = workspacemanagerv2.NewLocalWorkspaceManager(cm, localWorkspaces).Stop(cmd.Context(), args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("stopped local workspace %s\n", args[0])
+			return nil
+		},
+	}
+	return cmd
+}
+
+func newCmdReset(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	var setupScriptPath string
+	var noSnapshot bool
+	cmd := &cobra.Command{
+		Use:   "reset <name>",
+		Short: "Recreate the container of a local workspace and run its setup again",
+		Long: `Recreate the container of a local workspace and run its setup again. The workspace
+directory /home/brev/workspace is kept, everything else in the container is lost. The workspace
+directory is snapshot first, see 'brev local snapshot'`,
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			autoSnapshot := SnapshotOptionsFromConfig()
+			if noSnapshot {
+				autoSnapshot = nil
+			}
+			err = RunReset(cmd.Context(), afero.NewOsFs(), localWorkspaces, cm, args[0], setupScriptPath, autoSnapshot)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("reset local workspace %s\n", t.Green(args[0]))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&setupScriptPath, "setup-script", "", "replace the setup script before running the setup again")
+	cmd.Flags().BoolVar(&noSnapshot, "no-snapshot", false, "don't snapshot the workspace directory first")
+	return cmd
+}
+
+// RunReset snapshots the workspace directory first when autoSnapshot is set
+func RunReset(ctx context.Context, fs afero.Fs, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, name string, setupScriptPath string, autoSnapshot *workspacemanagerv2.SnapshotOptions) error {
+	_, err := localWorkspaces.GetLocalWorkspace(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if setupScriptPath != "" {
+		params, err := localWorkspaces.GetWorkspaceSetupParams(name)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		err = setSetupScript(fs, params, setupScriptPath)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		err = localWorkspaces.SaveWorkspaceSetupParams(name, *params)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	wm := workspacemanagerv2.NewLocalWorkspaceManager(cm, localWorkspaces)
+	wm.AutoSnapshot = autoSnapshot
+	err = wm.Rebuild(ctx, name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func newCmdRm(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:               "rm <name>",
+		Short:             "Delete a local workspace with its container and files",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !yes {
+				ok, err := bulk.Confirm(fmt.Sprintf("Delete local workspace %s and everything in its workspace directory?", name))
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				if !ok {
+					return nil
+				}
+			}
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			err = RunRm(cmd.Context(), localWorkspaces, cm, name)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("deleted local workspace %s\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "don't ask for confirmation")
+	return cmd
+}
+
+func RunRm(ctx context.Context, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, name string) error {
+	_, err := localWorkspaces.GetLocalWorkspace(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = workspacemanagerv2.NewLocalWorkspaceManager(cm, localWorkspaces).Delete(ctx, name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = localWorkspaces.DeleteLocalWorkspace(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func newCmdLs(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List local workspaces",
+		Args:  cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := runLs(cmd, t, localStore, getCM)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func runLs(cmd *cobra.Command, t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) error {
+	format, err := output.GetFormat(cmd)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	cm, err := getCM()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	statuses, err := GetLocalWorkspaceStatuses(cmd.Context(), localWorkspaces, cm)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if format.IsMachineReadable() {
+		err = output.Write(os.Stdout, format, statuses)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	}
+	if len(statuses) == 0 {
+		t.Vprint("no local workspaces, create one with: brev local start <name>")
+		return nil
+	}
+	ta := table.NewWriter()
+	ta.SetOutputMirror(os.Stdout)
+	ta.Style().Options = util.GetBrevTableOptions()
+	ta.AppendHeader(table.Row{"NAME", "STATUS", "IMAGE", "CREATED"})
+	for _, s := range statuses {
+		status := s.Status
+		if status == string(workspacemanagerv2.ContainerRunning) {
+			status = t.Green(status)
+		}
+		ta.AppendRow(table.Row{s.Name, status, s.Image, s.CreatedAt.Local().Format(time.RFC822)})
+	}
+	ta.Render()
+	return nil
+}
+
+// GetLocalWorkspaceStatuses reports the status of a container that can't be looked up as unknown
+// rather than failing, so the workspaces are listed without docker running
+func GetLocalWorkspaceStatuses(ctx context.Context, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager) ([]LocalWorkspaceStatus, error) {
+	workspaces, err := localWorkspaces.GetLocalWorkspaces()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	wm := workspacemanagerv2.NewLocalWorkspaceManager(cm, localWorkspaces)
+	statuses := []LocalWorkspaceStatus{}
+	for _, w := range workspaces {
+		status := StatusNotCreated
+		container, err := wm.GetContainer(ctx, w.Name)
+		if err != nil {
+			status = "unknown"
+		} else if container != nil {
+			status = string(container.Status)
+		}
+		statuses = append(statuses, LocalWorkspaceStatus{LocalWorkspace: w, Status: status})
+	}
+	return statuses, nil
+}
+
+func getLocalWorkspaceNameCompletionHandler(localStore LocalStore) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		workspaces, err := localWorkspaces.GetLocalWorkspaces()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		names := []string{}
+		for _, w := range workspaces {
+			names = append(names, w.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
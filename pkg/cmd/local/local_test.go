@@ -0,0 +1,293 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/workspacemanagerv2"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeContainerManager struct {
+	containers map[string]*workspacemanagerv2.Container
+	images     map[string]string
+	down       bool
+	execs      []workspacemanagerv2.ExecOptions
+	exitCode   int
+	archives   map[string][]byte
+}
+
+var _ workspacemanagerv2.ContainerManager = &fakeContainerManager{}
+
+func newFakeContainerManager() *fakeContainerManager {
+	return &fakeContainerManager{containers: map[string]*workspacemanagerv2.Container{}, images: map[string]string{}, archives: map[string][]byte{}}
+}
+
+func (f *fakeContainerManager) GetContainer(_ context.Context, containerID string) (*workspacemanagerv2.Container, error) {
+	if f.down {
+		return nil, fmt.Errorf("Cannot connect to the Docker daemon")
+	}
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, fmt.Errorf("Error: No such container: %s", containerID)
+	}
+	return c, nil
+}
+
+func (f *fakeContainerManager) StopContainer(_ context.Context, containerID string) error {
+	f.containers[containerID].Status = workspacemanagerv2.ContainerStopped
+	return nil
+}
+
+func (f *fakeContainerManager) DeleteContainer(_ context.Context, containerID string) error {
+	delete(f.containers, containerID)
+	return nil
+}
+
+func (f *fakeContainerManager) CreateContainer(_ context.Context, options workspacemanagerv2.CreateContainerOptions, image string) (string, error) {
+	labels := map[string]string{workspacemanagerv2.ManagedByLabel: workspacemanagerv2.ManagedByValue}
+	for k, v := range options.Labels {
+		labels[k] = v
+	}
+	f.containers[options.Name] = &workspacemanagerv2.Container{ID: options.Name, Status: workspacemanagerv2.ContainerStopped, Labels: labels}
+	f.images[options.Name] = image
+	return options.Name, nil
+}
+
+func (f *fakeContainerManager) StartContainer(_ context.Context, containerID string) error {
+	f.containers[containerID].Status = workspacemanagerv2.ContainerRunning
+	return nil
+}
+
+func (f *fakeContainerManager) DeleteVolume(_ context.Context, _ string) error {
+	return nil
+}
+
+// ExecContainer echoes its stdin, or the command when there is none
+func (f *fakeContainerManager) ExecContainer(_ context.Context, _ string, options workspacemanagerv2.ExecOptions) (int, error) {
+	f.execs = append(f.execs, options)
+	if options.Stdin != nil {
+		_, _ = io.Copy(options.Stdout, options.Stdin)
+	} else if options.Stdout != nil {
+		_, _ = fmt.Fprintln(options.Stdout, strings.Join(options.Cmd, " "))
+	}
+	return f.exitCode, nil
+}
+
+func (f *fakeContainerManager) ContainerLogs(_ context.Context, containerID string, options workspacemanagerv2.LogsOptions) error {
+	_, _ = fmt.Fprintf(options.Stdout, "logs of %s\n", containerID)
+	return nil
+}
+
+func (f *fakeContainerManager) CopyToContainer(_ context.Context, _ string, dstDir string, tarStream io.Reader) error {
+	data, err := io.ReadAll(tarStream)
+	f.archives[dstDir] = data
+	return err //nolint:wrapcheck // fake
+}
+
+// CopyFromContainer serves what was copied to the parent directory
+func (f *fakeContainerManager) CopyFromContainer(_ context.Context, _ string, srcPath string) (io.ReadCloser, error) {
+	data, ok := f.archives[path.Dir(srcPath)]
+	if !ok {
+		return nil, &workspacemanagerv2.NotFoundError{Kind: "path", ID: srcPath}
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (f *fakeContainerManager) GetContainerStats(_ context.Context, _ string) (*workspacemanagerv2.ContainerStats, error) {
+	return &workspacemanagerv2.ContainerStats{CPUPercent: 12.5, MemoryUsage: 1 << 20, PIDs: 3}, nil
+}
+
+// the volumes are written with the os, so the store lives in a temp dir
+func newTestLocalWorkspaceStore(t *testing.T) (*store.LocalWorkspaceStore, string) {
+	dir := t.TempDir()
+	return store.NewLocalWorkspaceStore(afero.NewOsFs(), dir), dir
+}
+
+func TestLocalWorkspaceLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s, dir := newTestLocalWorkspaceStore(t)
+	cm := newFakeContainerManager()
+	fs := afero.NewMemMapFs()
+	assert.Nil(t, afero.WriteFile(fs, "/src/setup.sh", []byte("echo one\n"), 0o644))
+
+	created, err := RunStart(ctx, fs, s, cm, "dev", StartOptions{SetupScriptPath: "/src/setup.sh"})
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, workspacemanagerv2.ContainerRunning, cm.containers["dev"].Status)
+	assert.Equal(t, DefaultImage, cm.images["dev"])
+
+	meta, err := os.ReadFile(filepath.Join(dir, "dev", "volumes", "etc", "meta", "setup_v0.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(meta), base64.StdEncoding.EncodeToString([]byte("echo one\n")))
+
+	_, err = RunStart(ctx, fs, s, cm, "dev", StartOptions{Image: "ubuntu"})
+	assert.ErrorContains(t, err, "brev local reset dev --setup-script")
+
+	assert.Nil(t, afero.WriteFile(fs, "/src/setup.sh", []byte("echo two\n"), 0o644))
+	assert.Nil(t, RunReset(ctx, fs, s, cm, "dev", "/src/setup.sh", nil))
+	assert.Equal(t, workspacemanagerv2.ContainerRunning, cm.containers["dev"].Status)
+	meta, err = os.ReadFile(filepath.Join(dir, "dev", "volumes", "etc", "meta", "setup_v0.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(meta), base64.StdEncoding.EncodeToString([]byte("echo two\n")))
+
+	statuses, err := GetLocalWorkspaceStatuses(ctx, s, cm)
+	assert.Nil(t, err)
+	assert.Len(t, statuses, 1)
+	assert.Equal(t, "running", statuses[0].Status)
+
+	assert.Nil(t, RunRm(ctx, s, cm, "dev"))
+	assert.Empty(t, cm.containers)
+	_, err = os.Stat(filepath.Join(dir, "dev"))
+	assert.True(t, os.IsNotExist(err))
+	assert.ErrorContains(t, RunRm(ctx, s, cm, "dev"), "does not exist")
+}
+
+func TestStartRefusesContainerNotCreatedByBrev(t *testing.T) {
+	s, _ := newTestLocalWorkspaceStore(t)
+	cm := newFakeContainerManager()
+	cm.containers["postgres"] = &workspacemanagerv2.Container{ID: "postgres", Status: workspacemanagerv2.ContainerRunning}
+
+	_, err := RunStart(context.Background(), afero.NewMemMapFs(), s, cm, "postgres", StartOptions{})
+	assert.ErrorContains(t, err, "already is a container named postgres")
+	exists, err := s.LocalWorkspaceExists("postgres")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	// one brev made, e.g. before ~/.brev/local was deleted, is started again
+	cm.containers["dev"] = &workspacemanagerv2.Container{
+		ID: "dev", Status: workspacemanagerv2.ContainerStopped,
+		Labels: map[string]string{workspacemanagerv2.ManagedByLabel: workspacemanagerv2.ManagedByValue},
+	}
+	created, err := RunStart(context.Background(), afero.NewMemMapFs(), s, cm, "dev", StartOptions{})
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, workspacemanagerv2.ContainerRunning, cm.containers["dev"].Status)
+}
+
+func TestGetLocalWorkspaceStatuses(t *testing.T) {
+	s, _ := newTestLocalWorkspaceStore(t)
+	assert.Nil(t, s.CreateLocalWorkspace(store.LocalWorkspace{Name: "dev"}, store.SetupParamsV0{}))
+	cm := newFakeContainerManager()
+
+	statuses, err := GetLocalWorkspaceStatuses(context.Background(), s, cm)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusNotCreated, statuses[0].Status)
+
+	cm.down = true
+	statuses, err = GetLocalWorkspaceStatuses(context.Background(), s, cm)
+	assert.Nil(t, err)
+	assert.Equal(t, "unknown", statuses[0].Status)
+}
+
+func TestRunExecAndLogs(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestLocalWorkspaceStore(t)
+	cm := newFakeContainerManager()
+	assert.Nil(t, s.CreateLocalWorkspace(store.LocalWorkspace{Name: "dev"}, store.SetupParamsV0{}))
+
+	_, err := RunExec(ctx, s, cm, "dev", workspacemanagerv2.ExecOptions{Cmd: []string{"ls"}})
+	assert.ErrorContains(t, err, "brev local start dev")
+	_, err = RunExec(ctx, s, cm, "dne", workspacemanagerv2.ExecOptions{Cmd: []string{"ls"}})
+	assert.Error(t, err)
+	assert.Empty(t, cm.execs)
+
+	_, err = RunStart(ctx, afero.NewMemMapFs(), s, cm, "dev", StartOptions{})
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	cm.exitCode = 2
+	code, err := RunExec(ctx, s, cm, "dev", workspacemanagerv2.ExecOptions{Cmd: []string{"wc"}, Stdin: strings.NewReader("hi\n"), Stdout: out})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "hi\n", out.String())
+
+	out.Reset()
+	cm.exitCode = 0
+	assert.Nil(t, RunLogs(ctx, s, cm, "dev", LogsOptions{Setup: true, LogsOptions: workspacemanagerv2.LogsOptions{Follow: true, Tail: 5, Stdout: out}}))
+	assert.Equal(t, "tail -n 5 -F /var/log/brev-workspace.log\n", out.String())
+
+	out.Reset()
+	assert.Nil(t, RunLogs(ctx, s, cm, "dev", LogsOptions{LogsOptions: workspacemanagerv2.LogsOptions{Stdout: out}}))
+	assert.Equal(t, "logs of dev\n", out.String())
+
+	stats, err := RunStats(ctx, s, cm, "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), stats.PIDs)
+}
+
+func TestRunCp(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestLocalWorkspaceStore(t)
+	cm := newFakeContainerManager()
+	_, err := RunStart(ctx, afero.NewMemMapFs(), s, cm, "dev", StartOptions{})
+	assert.Nil(t, err)
+
+	src := filepath.Join(t.TempDir(), "data.csv")
+	assert.Nil(t, os.WriteFile(src, []byte("a,b\n"), 0o644))
+	assert.Nil(t, RunCp(ctx, s, cm, src, "dev:"))
+	assert.Contains(t, cm.archives, "/home/brev/workspace")
+
+	dst := t.TempDir()
+	assert.Nil(t, RunCp(ctx, s, cm, "dev:data.csv", dst))
+	data, err := os.ReadFile(filepath.Join(dst, "data.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a,b\n", string(data))
+
+	assert.ErrorContains(t, RunCp(ctx, s, cm, src, dst), "has to be in a local workspace")
+	assert.ErrorContains(t, RunCp(ctx, s, cm, "dev:a", "dev:b"), "has to be in a local workspace")
+	assert.Error(t, RunCp(ctx, s, cm, filepath.Join(dst, "dne"), "dev:/tmp"))
+}
+
+func TestParseCopyPath(t *testing.T) {
+	name, p, ok := parseCopyPath("dev:src/main.go")
+	assert.True(t, ok)
+	assert.Equal(t, "dev", name)
+	assert.Equal(t, "/home/brev/workspace/src/main.go", p)
+
+	_, p, ok = parseCopyPath("dev:/etc/hosts")
+	assert.True(t, ok)
+	assert.Equal(t, "/etc/hosts", p)
+
+	for _, local := range []string{"./dev:x", "/tmp/a:b", "main.go", ":x"} {
+		_, p, ok = parseCopyPath(local)
+		assert.False(t, ok, local)
+		assert.Equal(t, local, p)
+	}
+}
+
+func TestResetSnapshotsAndRestore(t *testing.T) {
+	ctx := context.Background()
+	s, dir := newTestLocalWorkspaceStore(t)
+	cm := newFakeContainerManager()
+	_, err := RunStart(ctx, afero.NewMemMapFs(), s, cm, "dev", StartOptions{})
+	assert.Nil(t, err)
+	workspaceFile := filepath.Join(dir, "dev", "volumes", "home", "brev", "workspace", "notes.txt")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(workspaceFile), 0o755))
+	assert.Nil(t, os.WriteFile(workspaceFile, []byte("before reset"), 0o644))
+
+	autoSnapshot := &workspacemanagerv2.SnapshotOptions{Compress: true, Retention: workspacemanagerv2.RetentionPolicy{Keep: 1}}
+	assert.Nil(t, RunReset(ctx, afero.NewMemMapFs(), s, cm, "dev", "", autoSnapshot))
+	wm := workspacemanagerv2.NewLocalWorkspaceManager(cm, s)
+	snapshots, err := wm.ListSnapshots("dev")
+	assert.Nil(t, err)
+	assert.Len(t, snapshots, 1)
+	assert.Equal(t, "rebuild", snapshots[0].Reason)
+
+	assert.Nil(t, os.WriteFile(workspaceFile, []byte("broken"), 0o644))
+	assert.ErrorContains(t, RunRestoreSnapshot(ctx, s, cm, "dev", "dne", nil), "brev local snapshot ls dev")
+	assert.Nil(t, RunRestoreSnapshot(ctx, s, cm, "dev", snapshots[0].ID, nil))
+	data, err := os.ReadFile(workspaceFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "before reset", string(data))
+	assert.Equal(t, workspacemanagerv2.ContainerRunning, cm.containers["dev"].Status)
+}
@@ -0,0 +1,249 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/config"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/brevdev/brev-cli/pkg/workspacemanagerv2"
+	units "github.com/docker/go-units"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var snapshotLong = `Snapshots are tarballs of the workspace directory of a local workspace, kept in
+~/.brev/local/<name>/snapshots. One is taken before 'brev local reset' and before a restore,
+older ones are then pruned.
+
+The snapshot_compression, snapshot_keep and snapshot_max_age settings pick the compression and
+how many snapshots are kept and for how long`
+
+// SnapshotOptionsFromConfig are the options of the snapshots taken before destructive operations
+func SnapshotOptionsFromConfig() *workspacemanagerv2.SnapshotOptions {
+	cfg := config.Global()
+	return &workspacemanagerv2.SnapshotOptions{
+		Compress: cfg.GetSnapshotCompression() != config.SnapshotCompressionNone,
+		Retention: workspacemanagerv2.RetentionPolicy{
+			Keep:   cfg.GetSnapshotKeep(),
+			MaxAge: cfg.GetSnapshotMaxAge(),
+		},
+	}
+}
+
+func newCmdSnapshot(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Snapshot, restore and prune the workspace directory of local workspaces",
+		Long:  snapshotLong,
+		Example: `
+  brev local snapshot create dev
+  brev local snapshot ls dev
+  brev local snapshot restore dev 20260101-120000-manual
+  brev local snapshot prune dev --keep 3
+`,
+		Args: cmderrors.TransformToValidationError(cobra.NoArgs),
+	}
+	cmd.AddCommand(newCmdSnapshotCreate(t, localStore, getCM))
+	cmd.AddCommand(newCmdSnapshotLs(t, localStore, getCM))
+	cmd.AddCommand(newCmdSnapshotRestore(t, localStore, getCM))
+	cmd.AddCommand(newCmdSnapshotPrune(t, localStore, getCM))
+	return cmd
+}
+
+// getWorkspaceManager is the manager of an existing local workspace
+func getWorkspaceManager(localStore LocalStore, getCM getContainerManager, name string) (*workspacemanagerv2.WorkspaceManager, error) {
+	localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	_, err = localWorkspaces.GetLocalWorkspace(name)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	cm, err := getCM()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return workspacemanagerv2.NewLocalWorkspaceManager(cm, localWorkspaces), nil
+}
+
+func newCmdSnapshotCreate(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	var noCompress bool
+	cmd := &cobra.Command{
+		Use:               "create <name>",
+		Short:             "Snapshot the workspace directory of a local workspace",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			wm, err := getWorkspaceManager(localStore, getCM, args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			compress := !noCompress && config.Global().GetSnapshotCompression() != config.SnapshotCompressionNone
+			snapshot, err := wm.Snapshot(args[0], "manual", compress)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("created snapshot %s (%s)\n", t.Green(snapshot.ID), units.BytesSize(float64(snapshot.Size)))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&noCompress, "no-compress", false, "write a plain tarball instead of a zstd compressed one")
+	return cmd
+}
+
+func newCmdSnapshotLs(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "ls <name>",
+		Short:             "List the snapshots of a local workspace, newest first",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			wm, err := getWorkspaceManager(localStore, getCM, args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			snapshots, err := wm.ListSnapshots(args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if format.IsMachineReadable() {
+				err = output.Write(os.Stdout, format, snapshots)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				return nil
+			}
+			if len(snapshots) == 0 {
+				t.Vprintf("no snapshots of %s, create one with: brev local snapshot create %s\n", args[0], args[0])
+				return nil
+			}
+			displaySnapshots(snapshots, time.Now())
+			return nil
+		},
+	}
+	return cmd
+}
+
+func displaySnapshots(snapshots []workspacemanagerv2.Snapshot, now time.Time) {
+	ta := table.NewWriter()
+	ta.SetOutputMirror(os.Stdout)
+	ta.Style().Options = util.GetBrevTableOptions()
+	ta.AppendHeader(table.Row{"ID", "REASON", "SIZE", "CREATED"})
+	for _, s := range snapshots {
+		ta.AppendRow(table.Row{s.ID, s.Reason, units.BytesSize(float64(s.Size)), units.HumanDuration(now.Sub(s.CreatedAt)) + " ago"})
+	}
+	ta.Render()
+}
+
+func newCmdSnapshotRestore(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	var noSnapshot bool
+	cmd := &cobra.Command{
+		Use:   "restore <name> <snapshot>",
+		Short: "Restore the workspace directory of a local workspace in a new container",
+		Long: `Restore the workspace directory of a local workspace from a snapshot. The container is
+recreated, so the setup runs again, and the current workspace directory is snapshot first`,
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(2)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			localWorkspaces, err := localStore.GetLocalWorkspaceStore()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			cm, err := getCM()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			autoSnapshot := SnapshotOptionsFromConfig()
+			if noSnapshot {
+				autoSnapshot = nil
+			}
+			err = RunRestoreSnapshot(cmd.Context(), localWorkspaces, cm, args[0], args[1], autoSnapshot)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("restored %s from snapshot %s\n", t.Green(args[0]), args[1])
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&noSnapshot, "no-snapshot", false, "don't snapshot the current workspace directory first")
+	return cmd
+}
+
+func RunRestoreSnapshot(ctx context.Context, localWorkspaces *store.LocalWorkspaceStore, cm workspacemanagerv2.ContainerManager, name string, snapshotID string, autoSnapshot *workspacemanagerv2.SnapshotOptions) error {
+	_, err := localWorkspaces.GetLocalWorkspace(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	wm := workspacemanagerv2.NewLocalWorkspaceManager(cm, localWorkspaces)
+	wm.AutoSnapshot = autoSnapshot
+	snapshots, err := wm.ListSnapshots(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if !hasSnapshot(snapshots, snapshotID) {
+		return breverrors.NewValidationError(fmt.Sprintf("%s has no snapshot %s, list them with: brev local snapshot ls %s", name, snapshotID, name))
+	}
+	err = wm.RestoreSnapshot(ctx, name, snapshotID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func hasSnapshot(snapshots []workspacemanagerv2.Snapshot, id string) bool {
+	for _, s := range snapshots {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func newCmdSnapshotPrune(t *terminal.Terminal, localStore LocalStore, getCM getContainerManager) *cobra.Command {
+	policy := workspacemanagerv2.RetentionPolicy{}
+	cmd := &cobra.Command{
+		Use:               "prune <name>",
+		Short:             "Delete the snapshots of a local workspace the retention policy does not keep",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getLocalWorkspaceNameCompletionHandler(localStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("keep") {
+				policy.Keep = config.Global().GetSnapshotKeep()
+			}
+			if !cmd.Flags().Changed("max-age") {
+				policy.MaxAge = config.Global().GetSnapshotMaxAge()
+			}
+			wm, err := getWorkspaceManager(localStore, getCM, args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			pruned, err := wm.PruneSnapshots(args[0], policy)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			for _, s := range pruned {
+				t.Vprintf("deleted snapshot %s\n", s.ID)
+			}
+			if len(pruned) == 0 {
+				t.Vprint("no snapshots to prune")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&policy.Keep, "keep", 0, "number of newest snapshots to keep, 0 keeps all, defaults to snapshot_keep")
+	cmd.Flags().DurationVar(&policy.MaxAge, "max-age", 0, "delete snapshots older than this, e.g. 72h, defaults to snapshot_max_age")
+	return cmd
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/brevdev/brev-cli/pkg/cmd/hello"
 
 	"github.com/brevdev/brev-cli/pkg/cmd/importideconfig"
+	"github.com/brevdev/brev-cli/pkg/config"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
 	"github.com/brevdev/brev-cli/pkg/store"
@@ -46,6 +47,8 @@ type LoginStore interface {
 type Auth interface {
 	Login(skipBrowser bool) (*auth.LoginTokens, error)
 	LoginWithToken(token string) error
+	LoginWithClientCredentials(clientID string, clientSecret string) error
+	CheckClientCredentials(clientID string, clientSecret string) error
 }
 
 // loginStore must be a no prompt store
@@ -57,6 +60,8 @@ func NewCmdLogin(t *terminal.Terminal, loginStore LoginStore, auth Auth) *cobra.
 
 	var loginToken string
 	var skipBrowser bool
+	var clientID string
+	var clientSecret string
 
 	cmd := &cobra.Command{
 		Annotations:           map[string]string{"housekeeping": ""},
@@ -64,8 +69,13 @@ func NewCmdLogin(t *terminal.Terminal, loginStore LoginStore, auth Auth) *cobra.
 		DisableFlagsInUseLine: true,
 		Short:                 "Log into brev",
 		Long:                  "Log into brev",
-		Example:               "brev login",
+		Example: `  brev login
+  brev login --client-id $BREV_CLIENT_ID --client-secret $BREV_CLIENT_SECRET`,
 		PostRunE: func(cmd *cobra.Command, args []string) error {
+			if clientID != "" {
+				// api keys are for CI, there is no one to onboard
+				return nil
+			}
 			shouldWe := hello.ShouldWeRunOnboarding(loginStore)
 			if shouldWe {
 				user, err := loginStore.GetCurrentUser()
@@ -81,6 +91,16 @@ func NewCmdLogin(t *terminal.Terminal, loginStore LoginStore, auth Auth) *cobra.
 		},
 		Args: cmderrors.TransformToValidationError(cobra.NoArgs),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// only a key passed as a flag is saved, one that is only in the env is used from there
+			save := cmd.Flags().Changed("client-id") || cmd.Flags().Changed("client-secret")
+			clientID, clientSecret = getClientCredentials(clientID, clientSecret)
+			if clientID != "" || clientSecret != "" {
+				err := opts.RunClientCredentialsLogin(t, clientID, clientSecret, save)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				return nil
+			}
 			err := opts.RunLogin(t, loginToken, skipBrowser)
 			if err != nil {
 				// if err is ImportIDEConfigError, log err with sentry but continue
@@ -99,9 +119,53 @@ func NewCmdLogin(t *terminal.Terminal, loginStore LoginStore, auth Auth) *cobra.
 	}
 	cmd.Flags().StringVarP(&loginToken, "token", "", "", "token provided to auto login")
 	cmd.Flags().BoolVar(&skipBrowser, "skip-browser", false, "print url instead of auto opening browser")
+	idVar, secretVar := config.ClientCredentialsEnvVars()
+	cmd.Flags().StringVar(&clientID, "client-id", "", "client id of an api key to log in with without a browser, defaults to "+idVar)
+	cmd.Flags().StringVar(&clientSecret, "client-secret", "", "client secret of the api key, prefer "+secretVar+" so it stays out of the process list")
 	return cmd
 }
 
+// getClientCredentials falls back to the env for whichever of the two wasn't passed as a flag
+func getClientCredentials(clientID string, clientSecret string) (string, string) {
+	if clientID == "" {
+		clientID = config.Global().GetClientID()
+	}
+	if clientSecret == "" {
+		clientSecret = config.Global().GetClientSecret()
+	}
+	return clientID, clientSecret
+}
+
+// RunClientCredentialsLogin logs in with an api key, it never opens a browser or prompts. Unless
+// save is set the key is only checked, and the saved login is left alone
+func (o LoginOptions) RunClientCredentialsLogin(t *terminal.Terminal, clientID string, clientSecret string, save bool) error {
+	if clientID == "" || clientSecret == "" {
+		return breverrors.NewValidationError("both --client-id and --client-secret are needed to log in with an api key")
+	}
+	err := o.checkIfInWorkspace()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if save {
+		err = o.Auth.LoginWithClientCredentials(clientID, clientSecret)
+	} else {
+		err = o.Auth.CheckClientCredentials(clientID, clientSecret)
+	}
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	user, err := o.LoginStore.GetCurrentUser()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	t.Vprintf("logged in as %s with api key %s\n", t.Green(user.Username), clientID)
+	if !save {
+		idVar, secretVar := config.ClientCredentialsEnvVars()
+		t.Vprintf("the api key is read from %s and %s and was not saved\n", idVar, secretVar)
+	}
+	return nil
+}
+
 func (o LoginOptions) checkIfInWorkspace() error {
 	workspaceID, err := o.LoginStore.GetCurrentWorkspaceID()
 	if err != nil {
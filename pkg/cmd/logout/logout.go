@@ -3,30 +3,43 @@ package logout
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
 
 	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/ssh"
+	"github.com/brevdev/brev-cli/pkg/terminal"
 )
 
 type LogoutOptions struct {
-	auth  Auth
-	store LogoutStore
+	auth      Auth
+	store     LogoutStore
+	keepSSH   bool
+	removeKey bool
 }
 
 type Auth interface {
+	RevokeRefreshToken() (bool, error)
 	Logout() error
 }
 
 type LogoutStore interface {
-	ClearDefaultOrganization() error
 	GetCurrentWorkspaceID() (string, error)
+	RemoveLocalState() ([]string, error)
+	ssh.SSHConfigurerV2Store
 }
 
-func NewCmdLogout(auth Auth, store LogoutStore) *cobra.Command {
+// LogoutResult is what logging out got rid of
+type LogoutResult struct {
+	RevokedRefreshToken bool
+	// RevokeError is not fatal, the tokens are removed from this machine either way
+	RevokeError error
+	Removed     []string
+}
+
+func NewCmdLogout(t *terminal.Terminal, auth Auth, store LogoutStore) *cobra.Command {
 	opts := LogoutOptions{
 		auth:  auth,
 		store: store,
@@ -37,45 +50,104 @@ func NewCmdLogout(auth Auth, store LogoutStore) *cobra.Command {
 		Use:                   "logout",
 		DisableFlagsInUseLine: true,
 		Short:                 "Log out of brev",
-		Long:                  "Log out of brev by deleting the credential file",
-		Example:               "brev logout",
-		Args:                  cmderrors.TransformToValidationError(cobra.NoArgs),
+		Long: `Log out of brev: the refresh token is revoked, the credentials, active org and other local
+state are deleted and the ssh hosts of your instances are removed from your ssh config`,
+		Example: "brev logout\nbrev logout --keep-ssh",
+		Args:    cmderrors.TransformToValidationError(cobra.NoArgs),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := opts.RunLogout()
+			res, err := opts.RunLogout()
+			displayLogoutResult(t, res)
 			if err != nil {
 				return breverrors.WrapAndTrace(err)
 			}
+			t.Vprint(t.Green("logged out"))
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&opts.keepSSH, "keep-ssh", false, "leave the ssh config of your instances and the ssh key in place")
+	cmd.Flags().BoolVar(&opts.removeKey, "remove-key", false, "also delete the brev ssh private key, it is downloaded again on login")
 	return cmd
 }
 
-func (o *LogoutOptions) RunLogout() error {
+func (o *LogoutOptions) RunLogout() (*LogoutResult, error) {
+	res := &LogoutResult{Removed: []string{}}
+	if o.keepSSH && o.removeKey {
+		return res, breverrors.NewValidationError("--remove-key can not be combined with --keep-ssh, which keeps the ssh key")
+	}
 	workspaceID, err := o.store.GetCurrentWorkspaceID()
 	if err != nil {
-		return breverrors.WrapAndTrace(err)
+		return res, breverrors.WrapAndTrace(err)
 	}
 	if workspaceID != "" {
-		return fmt.Errorf("can not logout of workspace")
+		return res, fmt.Errorf("can not logout of workspace")
 	}
 
+	// has to happen while the tokens are still saved
+	res.RevokedRefreshToken, res.RevokeError = o.auth.RevokeRefreshToken()
+
 	// best effort
 	var allErr error
 	err = o.auth.Logout()
 	if err != nil {
-		if !strings.Contains(err.Error(), ".brev/credentials.json: no such file or directory") {
-			allErr = multierror.Append(err)
-		}
+		allErr = multierror.Append(allErr, err)
 	}
 
-	err = o.store.ClearDefaultOrganization()
+	removed, err := o.store.RemoveLocalState()
+	res.Removed = append(res.Removed, removed...)
 	if err != nil {
-		allErr = multierror.Append(err)
+		allErr = multierror.Append(allErr, err)
+	}
+
+	if !o.keepSSH {
+		removed, err = ssh.NewSSHConfigurerV2(o.store).Remove()
+		res.Removed = append(res.Removed, removed...)
+		if err != nil {
+			allErr = multierror.Append(allErr, err)
+		}
+		if o.removeKey {
+			removed, err = o.removePrivateKey()
+			res.Removed = append(res.Removed, removed...)
+			if err != nil {
+				allErr = multierror.Append(allErr, err)
+			}
+		}
 	}
 
 	if allErr != nil {
-		return breverrors.WrapAndTrace(allErr)
+		return res, breverrors.WrapAndTrace(allErr)
+	}
+	return res, nil
+}
+
+func (o *LogoutOptions) removePrivateKey() ([]string, error) {
+	keyPath, err := o.store.GetPrivateKeyPath()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	exists, err := o.store.FileExists(keyPath)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	err = o.store.Remove(keyPath)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return []string{keyPath}, nil
+}
+
+func displayLogoutResult(t *terminal.Terminal, res *LogoutResult) {
+	if res == nil {
+		return
+	}
+	if res.RevokeError != nil {
+		t.Vprint(t.Yellow(fmt.Sprintf("could not revoke the refresh token, it stays valid until it expires: %v", res.RevokeError)))
+	} else if res.RevokedRefreshToken {
+		t.Vprint("revoked the refresh token")
+	}
+	for _, r := range res.Removed {
+		t.Vprintf("removed %s\n", r)
 	}
-	return nil
 }
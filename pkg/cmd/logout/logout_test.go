@@ -0,0 +1,105 @@
+package logout
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/brevdev/brev-cli/pkg/ssh"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAuth struct {
+	revokeErr error
+	loggedOut bool
+}
+
+func (f *fakeAuth) RevokeRefreshToken() (bool, error) {
+	return f.revokeErr == nil, f.revokeErr
+}
+
+func (f *fakeAuth) Logout() error {
+	f.loggedOut = true
+	return nil
+}
+
+func setupLoggedIn(t *testing.T) (afero.Fs, *store.FileStore) {
+	fs := afero.NewMemMapFs()
+	s := store.NewBasicStore().WithFileSystem(fs).WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+	for _, p := range []string{
+		"/home/test/.brev/active_org.json",
+		"/home/test/.brev/workspace_cache.json",
+		"/home/test/.brev/onboarding_step.json",
+		"/home/test/.brev/config.bak.1234",
+		"/home/test/.brev/ssh_config",
+		"/home/test/.brev/brev.pem",
+	} {
+		assert.Nil(t, afero.WriteFile(fs, p, []byte("{}"), 0o600))
+	}
+	userConfig, err := ssh.AddIncludeToUserConfig("Host mine\n", files.GetBrevSSHConfigPath("/home/test"))
+	assert.Nil(t, err)
+	assert.Nil(t, afero.WriteFile(fs, "/home/test/.ssh/config", []byte(userConfig), 0o644))
+	return fs, s
+}
+
+func TestRunLogout(t *testing.T) {
+	fs, s := setupLoggedIn(t)
+	a := &fakeAuth{}
+	o := LogoutOptions{auth: a, store: s, removeKey: true}
+
+	res, err := o.RunLogout()
+	assert.Nil(t, err)
+	assert.True(t, a.loggedOut)
+	assert.True(t, res.RevokedRefreshToken)
+	assert.Len(t, res.Removed, 7)
+
+	for _, p := range []string{"active_org.json", "workspace_cache.json", "onboarding_step.json", "config.bak.1234", "ssh_config", "brev.pem"} {
+		exists, err := afero.Exists(fs, "/home/test/.brev/"+p)
+		assert.Nil(t, err)
+		assert.False(t, exists, p)
+	}
+	userConfig, err := afero.ReadFile(fs, "/home/test/.ssh/config")
+	assert.Nil(t, err)
+	assert.Equal(t, "Host mine\n", string(userConfig))
+
+	// logging out again has nothing left to remove
+	res, err = o.RunLogout()
+	assert.Nil(t, err)
+	assert.Empty(t, res.Removed)
+}
+
+func TestRunLogoutKeepSSH(t *testing.T) {
+	fs, s := setupLoggedIn(t)
+	o := LogoutOptions{auth: &fakeAuth{revokeErr: fmt.Errorf("offline")}, store: s, keepSSH: true}
+
+	res, err := o.RunLogout()
+	assert.Nil(t, err)
+	assert.False(t, res.RevokedRefreshToken)
+	assert.Error(t, res.RevokeError)
+
+	for _, p := range []string{"/home/test/.brev/ssh_config", "/home/test/.brev/brev.pem"} {
+		exists, err := afero.Exists(fs, p)
+		assert.Nil(t, err)
+		assert.True(t, exists, p)
+	}
+	exists, err := afero.Exists(fs, "/home/test/.brev/active_org.json")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
+
+func TestRunLogoutKeepSSHRejectsRemoveKey(t *testing.T) {
+	fs, s := setupLoggedIn(t)
+	a := &fakeAuth{}
+	o := LogoutOptions{auth: a, store: s, keepSSH: true, removeKey: true}
+
+	_, err := o.RunLogout()
+	assert.Error(t, err)
+	assert.False(t, a.loggedOut)
+	exists, err := afero.Exists(fs, "/home/test/.brev/brev.pem")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+}
@@ -0,0 +1,217 @@
+// Package logs is for reading the setup logs of a Brev instance
+package logs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/completions"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/refresh"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/setupworkspace"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logsLong    = "Print the setup logs of your instance. By default this is the main setup log, use --list to see the log of each setup exec"
+	logsExample = `
+  brev logs <NAME>
+  brev logs <NAME> --follow
+  brev logs <NAME> --tail 100
+  brev logs <NAME> --list
+  brev logs <NAME> --exec setup.sh
+  brev logs <NAME> --exec setup.sh --archive
+	`
+)
+
+type LogsStore interface {
+	completions.CompletionStore
+	refresh.RefreshStore
+	util.GetWorkspaceByNameOrIDErrStore
+	GetEnvSetupParams(workspaceID string) (*store.SetupParamsV0, error)
+}
+
+type LogsOptions struct {
+	WorkspaceNameOrID string
+	Exec              string
+	Follow            bool
+	Tail              int
+	Archive           bool
+	List              bool
+	Format            output.Format
+}
+
+func NewCmdLogs(t *terminal.Terminal, loginLogsStore LogsStore, noLoginLogsStore LogsStore) *cobra.Command {
+	opts := LogsOptions{}
+
+	cmd := &cobra.Command{
+		Annotations:           map[string]string{"ssh": ""},
+		Use:                   "logs",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print the setup logs of your instance",
+		Long:                  logsLong,
+		Example:               logsExample,
+		Args:                  cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction:     completions.GetAllWorkspaceNameCompletionHandler(noLoginLogsStore, t),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			opts.WorkspaceNameOrID = args[0]
+			opts.Format = format
+			err = RunLogs(t, loginLogsStore, opts)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.Follow, "follow", "f", false, "keep printing the log as it is written")
+	cmd.Flags().IntVar(&opts.Tail, "tail", -1, "only print the last N lines")
+	cmd.Flags().StringVar(&opts.Exec, "exec", "", "print the log of the named setup exec instead of the main setup log")
+	cmd.Flags().BoolVar(&opts.Archive, "archive", false, "print every archived run of the exec log")
+	cmd.Flags().BoolVar(&opts.List, "list", false, "list the available logs")
+
+	return cmd
+}
+
+func RunLogs(t *terminal.Terminal, logsStore LogsStore, opts LogsOptions) error {
+	workspace, err := util.GetUserWorkspaceByNameOrIDErr(logsStore, opts.WorkspaceNameOrID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	sources, err := getLogSources(logsStore, *workspace)
+	if err != nil {
+		if opts.Exec != "" || opts.List {
+			return breverrors.WrapAndTrace(err)
+		}
+		// the main log is always there, so carry on without the exec logs
+		sources = []setupworkspace.LogSource{{Name: setupworkspace.MainLogSourceName, Path: setupworkspace.WorkspaceLogPath}}
+	}
+
+	if opts.List {
+		return displayLogSources(opts.Format, sources)
+	}
+
+	name := opts.Exec
+	if name == "" {
+		name = setupworkspace.MainLogSourceName
+	}
+	source, ok := setupworkspace.FindLogSource(sources, name)
+	if !ok {
+		return breverrors.NewValidationError(fmt.Sprintf("no log for exec %s, run 'brev logs %s --list' to see the available logs", name, workspace.Name))
+	}
+
+	remoteCmd, err := makeLogCommand(*source, opts.Follow, opts.Tail, opts.Archive)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	if workspace.Status != entity.Running {
+		return breverrors.NewValidationError(fmt.Sprintf("instance %s is %s, logs can only be read from a running instance", workspace.Name, workspace.Status))
+	}
+	err = refresh.RunRefreshAsync(logsStore).Await()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	err = runSSH(string(workspace.GetLocalIdentifier()), remoteCmd)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func getLogSources(logsStore LogsStore, workspace entity.Workspace) ([]setupworkspace.LogSource, error) {
+	params, err := logsStore.GetEnvSetupParams(workspace.ID)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err, "could not get the setup params of the instance")
+	}
+	sources, err := setupworkspace.GetLogSourcesFromParams(*params)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return sources, nil
+}
+
+func displayLogSources(format output.Format, sources []setupworkspace.LogSource) error {
+	if format.IsMachineReadable() {
+		err := output.Write(os.Stdout, format, sources)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	}
+	ta := table.NewWriter()
+	ta.SetOutputMirror(os.Stdout)
+	ta.Style().Options = util.GetBrevTableOptions()
+	ta.AppendHeader(table.Row{"NAME", "LOG", "ARCHIVE"})
+	for _, s := range sources {
+		ta.AppendRow(table.Row{s.Name, s.Path, s.ArchiveGlob})
+	}
+	ta.Render()
+	return nil
+}
+
+// makeLogCommand builds the command run on the instance to print the log
+func makeLogCommand(source setupworkspace.LogSource, follow bool, tail int, archive bool) (string, error) {
+	if archive {
+		if source.ArchiveGlob == "" {
+			return "", breverrors.NewValidationError(fmt.Sprintf("the %s log is not archived", source.Name))
+		}
+		if follow {
+			return "", breverrors.NewValidationError("--follow can not be used with --archive")
+		}
+		command := fmt.Sprintf("cat %s", quoteGlob(source.ArchiveGlob))
+		if tail >= 0 {
+			command = fmt.Sprintf("%s | tail -n %d", command, tail)
+		}
+		return command, nil
+	}
+	if follow {
+		lines := "+1"
+		if tail >= 0 {
+			lines = fmt.Sprint(tail)
+		}
+		return fmt.Sprintf("tail -n %s -F %s", lines, util.ShellQuote(source.Path)), nil
+	}
+	if tail >= 0 {
+		return fmt.Sprintf("tail -n %d %s", tail, util.ShellQuote(source.Path)), nil
+	}
+	return fmt.Sprintf("cat %s", util.ShellQuote(source.Path)), nil
+}
+
+// quoteGlob quotes everything but the * so the remote shell still expands it
+func quoteGlob(glob string) string {
+	parts := strings.Split(glob, "*")
+	for i, p := range parts {
+		if p != "" {
+			parts[i] = util.ShellQuote(p)
+		}
+	}
+	return strings.Join(parts, "*")
+}
+
+func runSSH(sshAlias string, remoteCmd string) error {
+	// a command on the command line conflicts with any RemoteCommand in the ssh config
+	sshCmd := exec.Command("ssh", "-o", "RemoteCommand=none", sshAlias, remoteCmd) //nolint:gosec // alias comes from the ssh config we write
+	sshCmd.Stdin = os.Stdin
+	sshCmd.Stdout = os.Stdout
+	sshCmd.Stderr = os.Stderr
+	err := sshCmd.Run()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
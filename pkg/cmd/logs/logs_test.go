@@ -0,0 +1,41 @@
+package logs
+
+import (
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/setupworkspace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeLogCommand(t *testing.T) {
+	source := setupworkspace.LogSource{Name: "build", Path: "/logs/build.log", ArchiveGlob: "/logs/archive/build-*.log"}
+	tests := []struct {
+		name    string
+		follow  bool
+		tail    int
+		archive bool
+		want    string
+	}{
+		{name: "all", tail: -1, want: "cat '/logs/build.log'"},
+		{name: "tail", tail: 10, want: "tail -n 10 '/logs/build.log'"},
+		{name: "follow", follow: true, tail: -1, want: "tail -n +1 -F '/logs/build.log'"},
+		{name: "follow tail", follow: true, tail: 5, want: "tail -n 5 -F '/logs/build.log'"},
+		{name: "archive", tail: -1, archive: true, want: "cat '/logs/archive/build-'*'.log'"},
+		{name: "archive tail", tail: 3, archive: true, want: "cat '/logs/archive/build-'*'.log' | tail -n 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := makeLogCommand(source, tt.follow, tt.tail, tt.archive)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMakeLogCommandErrors(t *testing.T) {
+	_, err := makeLogCommand(setupworkspace.LogSource{Name: "build", Path: "/b.log", ArchiveGlob: "/a-*.log"}, true, -1, true)
+	assert.Error(t, err)
+
+	_, err = makeLogCommand(setupworkspace.LogSource{Name: setupworkspace.MainLogSourceName, Path: setupworkspace.WorkspaceLogPath}, false, -1, true)
+	assert.Error(t, err)
+}
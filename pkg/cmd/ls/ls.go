@@ -9,6 +9,7 @@ import (
 	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
 	"github.com/brevdev/brev-cli/pkg/cmd/completions"
 	"github.com/brevdev/brev-cli/pkg/cmd/hello"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
 	utilities "github.com/brevdev/brev-cli/pkg/cmd/util"
 	"github.com/brevdev/brev-cli/pkg/cmdcontext"
 	"github.com/brevdev/brev-cli/pkg/entity"
@@ -47,8 +48,17 @@ func NewCmdLs(t *terminal.Terminal, loginLsStore LsStore, noLoginLsStore LsStore
   brev ls
   brev ls orgs
   brev ls --org <orgid>
+  brev ls --output json
+  brev ls orgs --output yaml
 		`,
 		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if format.IsMachineReadable() {
+				return nil
+			}
 			if hello.ShouldWeRunOnboardingLSStep(noLoginLsStore) && hello.ShouldWeRunOnboarding(noLoginLsStore) {
 				// Getting the workspaces should go in the hello.go file but then
 				// requires passing in stores and that makes it hard to use in other commands
@@ -93,7 +103,11 @@ func NewCmdLs(t *terminal.Terminal, loginLsStore LsStore, noLoginLsStore LsStore
 		Args:      cmderrors.TransformToValidationError(cobra.MinimumNArgs(0)),
 		ValidArgs: []string{"orgs", "workspaces"},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := RunLs(t, loginLsStore, args, org, showAll)
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			err = RunLs(t, loginLsStore, args, org, showAll, format)
 			if err != nil {
 				return breverrors.WrapAndTrace(err)
 			}
@@ -155,8 +169,8 @@ func getOrgForRunLs(lsStore LsStore, orgflag string) (*entity.Organization, erro
 	return org, nil
 }
 
-func RunLs(t *terminal.Terminal, lsStore LsStore, args []string, orgflag string, showAll bool) error {
-	ls := NewLs(lsStore, t)
+func RunLs(t *terminal.Terminal, lsStore LsStore, args []string, orgflag string, showAll bool, format output.Format) error {
+	ls := NewLs(lsStore, t).WithFormat(format)
 	user, err := lsStore.GetCurrentUser()
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
@@ -220,6 +234,7 @@ func handleLsArg(ls *Ls, arg string, user *entity.User, org *entity.Organization
 type Ls struct {
 	lsStore  LsStore
 	terminal *terminal.Terminal
+	format   output.Format
 }
 
 func NewLs(lsStore LsStore, terminal *terminal.Terminal) *Ls {
@@ -229,12 +244,17 @@ func NewLs(lsStore LsStore, terminal *terminal.Terminal) *Ls {
 	}
 }
 
+func (ls *Ls) WithFormat(format output.Format) *Ls {
+	ls.format = format
+	return ls
+}
+
 func (ls Ls) RunOrgs() error {
 	orgs, err := ls.lsStore.GetOrganizations(nil)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-	if len(orgs) == 0 {
+	if len(orgs) == 0 && !ls.format.IsMachineReadable() {
 		ls.terminal.Vprint(ls.terminal.Yellow("You don't have any orgs. Create one! https://console.brev.dev"))
 		return nil
 	}
@@ -243,6 +263,9 @@ func (ls Ls) RunOrgs() error {
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
+	if ls.format.IsMachineReadable() {
+		return output.Write(os.Stdout, ls.format, OrgsOutput{Organizations: MakeOrgsOutput(orgs, defaultOrg)})
+	}
 	ls.terminal.Vprint(ls.terminal.Yellow("Your organizations:"))
 	displayOrgTable(ls.terminal, orgs, defaultOrg)
 	if len(orgs) > 1 {
@@ -279,9 +302,8 @@ func (ls Ls) RunUser(_ bool) error {
 	return nil
 }
 
-func (ls Ls) ShowAllWorkspaces(org *entity.Organization, otherOrgs []entity.Organization, user *entity.User, allWorkspaces []entity.Workspace) {
+func (ls Ls) ShowAllWorkspaces(org *entity.Organization, otherOrgs []entity.Organization, user *entity.User, allWorkspaces []entity.Workspace) error {
 	userWorkspaces := store.FilterForUserWorkspaces(allWorkspaces, user.ID)
-	ls.displayWorkspacesAndHelp(org, otherOrgs, userWorkspaces, allWorkspaces, user.ID)
 
 	projects := virtualproject.NewVirtualProjects(allWorkspaces)
 
@@ -293,13 +315,29 @@ func (ls Ls) ShowAllWorkspaces(org *entity.Organization, otherOrgs []entity.Orga
 		}
 	}
 
+	if ls.format.IsMachineReadable() {
+		return output.Write(os.Stdout, ls.format, WorkspacesOutput{
+			Workspaces: MakeWorkspacesOutput(userWorkspaces, user.ID),
+			Projects:   MakeProjectsOutput(unjoinedProjects),
+		})
+	}
+
+	ls.displayWorkspacesAndHelp(org, otherOrgs, userWorkspaces, allWorkspaces, user.ID)
 	displayProjects(ls.terminal, org.Name, unjoinedProjects)
+	return nil
 }
 
-func (ls Ls) ShowUserWorkspaces(org *entity.Organization, otherOrgs []entity.Organization, user *entity.User, allWorkspaces []entity.Workspace) {
+func (ls Ls) ShowUserWorkspaces(org *entity.Organization, otherOrgs []entity.Organization, user *entity.User, allWorkspaces []entity.Workspace) error {
 	userWorkspaces := store.FilterForUserWorkspaces(allWorkspaces, user.ID)
 
+	if ls.format.IsMachineReadable() {
+		return output.Write(os.Stdout, ls.format, WorkspacesOutput{
+			Workspaces: MakeWorkspacesOutput(userWorkspaces, user.ID),
+		})
+	}
+
 	ls.displayWorkspacesAndHelp(org, otherOrgs, userWorkspaces, allWorkspaces, user.ID)
+	return nil
 }
 
 func (ls Ls) displayWorkspacesAndHelp(org *entity.Organization, otherOrgs []entity.Organization, userWorkspaces []entity.Workspace, allWorkspaces []entity.Workspace, userID string) {
@@ -319,7 +357,11 @@ func (ls Ls) displayWorkspacesAndHelp(org *entity.Organization, otherOrgs []enti
 		}
 	} else {
 		ls.terminal.Vprintf("You have %d instances in Org "+ls.terminal.Yellow(org.Name)+"\n", len(userWorkspaces))
-		displayWorkspacesTable(ls.terminal, userWorkspaces, userID)
+		if ls.format == output.Wide {
+			displayWorkspacesWideTable(ls.terminal, userWorkspaces, userID)
+		} else {
+			displayWorkspacesTable(ls.terminal, userWorkspaces, userID)
+		}
 
 		fmt.Print("\n")
 
@@ -385,9 +427,12 @@ func (ls Ls) RunWorkspaces(org *entity.Organization, user *entity.User, showAll
 		return breverrors.WrapAndTrace(err)
 	}
 	if showAll {
-		ls.ShowAllWorkspaces(org, orgs, user, allWorkspaces)
+		err = ls.ShowAllWorkspaces(org, orgs, user, allWorkspaces)
 	} else {
-		ls.ShowUserWorkspaces(org, orgs, user, allWorkspaces)
+		err = ls.ShowUserWorkspaces(org, orgs, user, allWorkspaces)
+	}
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
 	}
 	return nil
 }
@@ -428,19 +473,10 @@ func displayProjects(t *terminal.Terminal, orgName string, projects []virtualpro
 
 const enableSSHCol = false
 
-func getBrevTableOptions() table.Options {
-	options := table.OptionsDefault
-	options.DrawBorder = false
-	options.SeparateColumns = false
-	options.SeparateRows = false
-	options.SeparateHeader = false
-	return options
-}
-
 func displayWorkspacesTable(t *terminal.Terminal, workspaces []entity.Workspace, userID string) {
 	ta := table.NewWriter()
 	ta.SetOutputMirror(os.Stdout)
-	ta.Style().Options = getBrevTableOptions()
+	ta.Style().Options = utilities.GetBrevTableOptions()
 	header := table.Row{"Name", "Status", "ID", "Machine"}
 	if enableSSHCol {
 		header = table.Row{"Name", "Status", "SSH", "ID", "Machine"}
@@ -462,6 +498,27 @@ func displayWorkspacesTable(t *terminal.Terminal, workspaces []entity.Workspace,
 	ta.Render()
 }
 
+func displayWorkspacesWideTable(t *terminal.Terminal, workspaces []entity.Workspace, userID string) {
+	ta := table.NewWriter()
+	ta.SetOutputMirror(os.Stdout)
+	ta.Style().Options = utilities.GetBrevTableOptions()
+	header := table.Row{"Name", "Status", "Health", "ID", "Machine", "Instance Type", "SSH", "DNS", "Workspace Group"}
+	ta.AppendHeader(header)
+	for _, w := range workspaces {
+		name := w.Name
+		if w.IsShared(userID) {
+			name = fmt.Sprintf("%s (shared)", w.Name)
+		}
+		status := getWorkspaceDisplayStatus(w)
+		workspaceRow := []table.Row{{
+			name, getStatusColoredText(t, status), w.HealthStatus, w.ID, utilities.GetInstanceString(w),
+			w.InstanceType, w.GetLocalIdentifier(), w.GetHostname(), w.WorkspaceGroupID,
+		}}
+		ta.AppendRows(workspaceRow)
+	}
+	ta.Render()
+}
+
 func getWorkspaceDisplayStatus(w entity.Workspace) string {
 	status := w.Status
 	if w.Status == entity.Running && w.HealthStatus == entity.Unhealthy {
@@ -473,7 +530,7 @@ func getWorkspaceDisplayStatus(w entity.Workspace) string {
 func displayOrgTable(t *terminal.Terminal, orgs []entity.Organization, currentOrg *entity.Organization) {
 	ta := table.NewWriter()
 	ta.SetOutputMirror(os.Stdout)
-	ta.Style().Options = getBrevTableOptions()
+	ta.Style().Options = utilities.GetBrevTableOptions()
 	header := table.Row{"NAME", "ID"}
 	ta.AppendHeader(header)
 	for _, o := range orgs {
@@ -489,7 +546,7 @@ func displayOrgTable(t *terminal.Terminal, orgs []entity.Organization, currentOr
 func displayProjectsTable(projects []virtualproject.VirtualProject) {
 	ta := table.NewWriter()
 	ta.SetOutputMirror(os.Stdout)
-	ta.Style().Options = getBrevTableOptions()
+	ta.Style().Options = utilities.GetBrevTableOptions()
 	header := table.Row{"NAME", "MEMBERS"}
 	ta.AppendHeader(header)
 	for _, p := range projects {
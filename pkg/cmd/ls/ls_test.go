@@ -1 +1,39 @@
 package ls
+
+import (
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeWorkspacesOutput(t *testing.T) {
+	workspaces := []entity.Workspace{
+		{
+			ID:               "ws-1",
+			Name:             "my-ws",
+			Status:           entity.Running,
+			HealthStatus:     entity.Unhealthy,
+			WorkspaceClassID: "2x8",
+			CreatedByUserID:  "me",
+			DNS:              "my-ws.brev.sh",
+		},
+	}
+	res := MakeWorkspacesOutput(workspaces, "me")
+	if !assert.Len(t, res, 1) {
+		return
+	}
+	assert.Equal(t, entity.Unhealthy, res[0].DisplayStatus)
+	assert.Equal(t, "2 cpu | 8 gb ram", res[0].Machine)
+	assert.Equal(t, "my-ws", res[0].SSHAlias)
+	assert.Equal(t, "my-ws-host", res[0].HostSSHAlias)
+	assert.False(t, res[0].IsShared)
+
+	assert.NotNil(t, MakeWorkspacesOutput(nil, "me"))
+}
+
+func TestMakeOrgsOutput(t *testing.T) {
+	orgs := []entity.Organization{{ID: "a", Name: "org-a"}, {ID: "b", Name: "org-b"}}
+	res := MakeOrgsOutput(orgs, &orgs[1])
+	assert.Equal(t, []OrgOutput{{ID: "a", Name: "org-a"}, {ID: "b", Name: "org-b", Active: true}}, res)
+}
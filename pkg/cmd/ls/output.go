@@ -0,0 +1,99 @@
+package ls
+
+import (
+	utilities "github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/brevdev/brev-cli/pkg/entity/virtualproject"
+)
+
+// the types below are the stable schema for `brev ls --output json|yaml`, add
+// fields but don't rename or remove them since scripts depend on them
+
+type WorkspacesOutput struct {
+	Workspaces []WorkspaceOutput `json:"workspaces"`
+	Projects   []ProjectOutput   `json:"projects,omitempty"`
+}
+
+type WorkspaceOutput struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	Status           string `json:"status"`
+	HealthStatus     string `json:"healthStatus"`
+	DisplayStatus    string `json:"displayStatus"`
+	OrganizationID   string `json:"organizationId"`
+	WorkspaceGroupID string `json:"workspaceGroupId"`
+	WorkspaceClassID string `json:"workspaceClassId"`
+	InstanceType     string `json:"instanceType"`
+	Machine          string `json:"machine"`
+	GitRepo          string `json:"gitRepo"`
+	DNS              string `json:"dns"`
+	SSHAlias         string `json:"sshAlias"`
+	HostSSHAlias     string `json:"hostSshAlias"`
+	CreatedByUserID  string `json:"createdByUserId"`
+	IsShared         bool   `json:"isShared"`
+}
+
+type OrgsOutput struct {
+	Organizations []OrgOutput `json:"organizations"`
+}
+
+type OrgOutput struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+type ProjectOutput struct {
+	Name    string `json:"name"`
+	GitURL  string `json:"gitUrl"`
+	Members int    `json:"members"`
+}
+
+func MakeWorkspacesOutput(workspaces []entity.Workspace, userID string) []WorkspaceOutput {
+	res := []WorkspaceOutput{}
+	for _, w := range workspaces {
+		res = append(res, WorkspaceOutput{
+			ID:               w.ID,
+			Name:             w.Name,
+			Status:           w.Status,
+			HealthStatus:     w.HealthStatus,
+			DisplayStatus:    getWorkspaceDisplayStatus(w),
+			OrganizationID:   w.OrganizationID,
+			WorkspaceGroupID: w.WorkspaceGroupID,
+			WorkspaceClassID: w.WorkspaceClassID,
+			InstanceType:     w.InstanceType,
+			Machine:          utilities.GetInstanceString(w),
+			GitRepo:          w.GitRepo,
+			DNS:              w.DNS,
+			SSHAlias:         string(w.GetLocalIdentifier()),
+			HostSSHAlias:     string(w.GetHostIdentifier()),
+			CreatedByUserID:  w.CreatedByUserID,
+			IsShared:         w.IsShared(userID),
+		})
+	}
+	return res
+}
+
+func MakeOrgsOutput(orgs []entity.Organization, activeOrg *entity.Organization) []OrgOutput {
+	res := []OrgOutput{}
+	for _, o := range orgs {
+		res = append(res, OrgOutput{
+			ID:     o.ID,
+			Name:   o.Name,
+			Active: activeOrg != nil && o.ID == activeOrg.ID,
+		})
+	}
+	return res
+}
+
+func MakeProjectsOutput(projects []virtualproject.VirtualProject) []ProjectOutput {
+	res := []ProjectOutput{}
+	for _, p := range projects {
+		res = append(res, ProjectOutput{
+			Name:    p.Name,
+			GitURL:  p.GitURL,
+			Members: p.GetUniqueUserCount(),
+		})
+	}
+	return res
+}
@@ -0,0 +1,228 @@
+// Package open is for opening a Brev instance in a local editor
+package open
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/completions"
+	"github.com/brevdev/brev-cli/pkg/cmd/hello"
+	"github.com/brevdev/brev-cli/pkg/cmd/refresh"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/config"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/ssh"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	allutil "github.com/brevdev/brev-cli/pkg/util"
+	"github.com/pkg/browser"
+	"github.com/spf13/cobra"
+)
+
+const (
+	EditorVSCode    = "code"
+	EditorCursor    = "cursor"
+	EditorJetBrains = "jetbrains"
+)
+
+var (
+	openLong    = "Open your instance in VS Code, Cursor or JetBrains Gateway. The default editor is VS Code and can be changed with --set-default or 'brev config set default_editor'"
+	openExample = `
+  brev open <NAME>
+  brev open <NAME> cursor
+  brev open <NAME> jetbrains --set-default
+	`
+	supportedEditors = []string{EditorVSCode, EditorCursor, EditorJetBrains}
+)
+
+type OpenStore interface {
+	completions.CompletionStore
+	refresh.RefreshStore
+	util.GetWorkspaceByNameOrIDErrStore
+	StartWorkspace(workspaceID string) (*entity.Workspace, error)
+	GetWorkspace(workspaceID string) (*entity.Workspace, error)
+	GetUserConfigPath() (string, error)
+	SetConfigValue(path string, key string, value string) error
+}
+
+func NewCmdOpen(t *terminal.Terminal, loginOpenStore OpenStore, noLoginOpenStore OpenStore) *cobra.Command {
+	var setDefault bool
+	var host bool
+
+	cmd := &cobra.Command{
+		Annotations:           map[string]string{"ssh": ""},
+		Use:                   "open",
+		DisableFlagsInUseLine: true,
+		Short:                 "Open your instance in your preferred editor",
+		Long:                  openLong,
+		Example:               openExample,
+		Args:                  cmderrors.TransformToValidationError(cobra.RangeArgs(1, 2)),
+		ValidArgsFunction:     completions.GetAllWorkspaceNameCompletionHandler(noLoginOpenStore, t),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			editor := ""
+			if len(args) > 1 {
+				editor = args[1]
+			}
+			err := RunOpen(t, loginOpenStore, args[0], editor, setDefault, host)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&setDefault, "set-default", false, "save the given editor as the default for future opens")
+	cmd.Flags().BoolVar(&host, "host", false, "open the host machine instead of the container")
+
+	return cmd
+}
+
+func RunOpen(t *terminal.Terminal, openStore OpenStore, workspaceNameOrID string, editor string, setDefault bool, host bool) error {
+	editor, err := resolveEditor(openStore, editor, setDefault)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	workspace, err := util.GetUserWorkspaceByNameOrIDErr(openStore, workspaceNameOrID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	if workspace.Status == entity.Stopped {
+		t.Vprintf(t.Yellow("Instance %s is stopped, starting it...\n", workspace.Name))
+		_, err = openStore.StartWorkspace(workspace.ID)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	if workspace.Status != entity.Running {
+		workspace, err = util.WaitForWorkspaceRunning(t, openStore, workspace.ID)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+
+	err = refresh.RunRefreshAsync(openStore).Await()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	sshAlias := string(workspace.GetLocalIdentifier())
+	if host {
+		sshAlias = string(workspace.GetHostIdentifier())
+	}
+	projectPath := workspace.GetProjectFolderPath()
+
+	t.Vprintf("Opening %s in %s\n", t.Green(workspace.Name), t.Green(editor))
+	switch editor {
+	case EditorVSCode:
+		err = openVSCode(t, sshAlias, projectPath)
+	case EditorCursor:
+		err = openCursor(sshAlias, projectPath)
+	case EditorJetBrains:
+		err = openJetBrainsGateway(openStore, *workspace, projectPath)
+	}
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	_ = hello.SetHasRunOpen(true)
+	return nil
+}
+
+// resolveEditor picks the editor from the arg, then the default_editor setting, which includes
+// defaults saved before it was a setting
+func resolveEditor(openStore OpenStore, editor string, setDefault bool) (string, error) {
+	if editor == "" {
+		if setDefault {
+			return "", breverrors.NewValidationError("please provide an editor to set as default")
+		}
+		editor = config.Global().GetDefaultEditor()
+	}
+	if editor == "" {
+		editor = EditorVSCode
+	}
+	editor = normalizeEditor(editor)
+	if !isSupportedEditor(editor) {
+		return "", breverrors.NewValidationError(fmt.Sprintf("unsupported editor %s, use one of: %s", editor, strings.Join(supportedEditors, ", ")))
+	}
+	if setDefault {
+		path, err := openStore.GetUserConfigPath()
+		if err != nil {
+			return "", breverrors.WrapAndTrace(err)
+		}
+		err = openStore.SetConfigValue(path, string(config.KeyDefaultEditor), editor)
+		if err != nil {
+			return "", breverrors.WrapAndTrace(err)
+		}
+	}
+	return editor, nil
+}
+
+func normalizeEditor(editor string) string {
+	switch strings.ToLower(editor) {
+	case "vscode", "vs-code", "code":
+		return EditorVSCode
+	case "gateway", "jetbrains-gateway", "jetbrains":
+		return EditorJetBrains
+	default:
+		return strings.ToLower(editor)
+	}
+}
+
+func isSupportedEditor(editor string) bool {
+	for _, e := range supportedEditors {
+		if e == editor {
+			return true
+		}
+	}
+	return false
+}
+
+func makeRemoteFolderURI(sshAlias string, projectPath string) string {
+	return fmt.Sprintf("vscode-remote://ssh-remote+%s%s", sshAlias, projectPath)
+}
+
+func openVSCode(t *terminal.Terminal, sshAlias string, projectPath string) error {
+	extensionID := "ms-vscode-remote.remote-ssh"
+	isInstalled, err := allutil.IsVSCodeExtensionInstalled(extensionID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err, "could not run VS Code, make sure the 'code' command is in your PATH")
+	}
+	if !isInstalled {
+		t.Vprintf(t.Yellow("Installing VS Code extension %s\n", extensionID))
+		err = allutil.InstallVscodeExtension(extensionID)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	_, err = allutil.TryRunVsCodeCommand([]string{"--folder-uri", makeRemoteFolderURI(sshAlias, projectPath)})
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func openCursor(sshAlias string, projectPath string) error {
+	_, err := allutil.TryRunCursorCommand([]string{"--folder-uri", makeRemoteFolderURI(sshAlias, projectPath)})
+	if err != nil {
+		return breverrors.WrapAndTrace(err, "could not run Cursor, make sure the 'cursor' command is in your PATH")
+	}
+	return nil
+}
+
+func openJetBrainsGateway(openStore OpenStore, workspace entity.Workspace, projectPath string) error {
+	// the refresh above already wrote this instance to the gateway ssh configs
+	exists, err := openStore.DoesJetbrainsFilePathExist()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return breverrors.NewValidationError("could not find JetBrains Gateway, install it from https://www.jetbrains.com/remote-development/gateway/")
+	}
+	err = browser.OpenURL(ssh.MakeJetBrainsGatewayConnectURL(workspace, projectPath))
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
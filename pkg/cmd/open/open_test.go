@@ -0,0 +1,19 @@
+package open
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEditor(t *testing.T) {
+	assert.Equal(t, EditorVSCode, normalizeEditor("vscode"))
+	assert.Equal(t, EditorVSCode, normalizeEditor("Code"))
+	assert.Equal(t, EditorCursor, normalizeEditor("cursor"))
+	assert.Equal(t, EditorJetBrains, normalizeEditor("gateway"))
+	assert.False(t, isSupportedEditor(normalizeEditor("emacs")))
+}
+
+func TestMakeRemoteFolderURI(t *testing.T) {
+	assert.Equal(t, "vscode-remote://ssh-remote+my-ws/home/ubuntu/my-repo", makeRemoteFolderURI("my-ws", "/home/ubuntu/my-repo"))
+}
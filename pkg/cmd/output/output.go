@@ -0,0 +1,76 @@
+// Package output handles the global --output flag for commands that can print
+// machine readable results
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+type Format string
+
+const (
+	Table Format = ""
+	Wide  Format = "wide"
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+
+	flagName = "output"
+)
+
+func AddFlag(flags *pflag.FlagSet) {
+	flags.String(flagName, "", "output format, one of: json|yaml|wide")
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case Table, Wide, JSON, YAML:
+		return Format(s), nil
+	default:
+		return Table, breverrors.NewValidationError(fmt.Sprintf("invalid output format %s, use one of: json|yaml|wide", s))
+	}
+}
+
+// GetFormat reads the --output flag inherited from the root command, commands
+// that were not given the flag print tables
+func GetFormat(cmd *cobra.Command) (Format, error) {
+	flag := cmd.Flags().Lookup(flagName)
+	if flag == nil {
+		return Table, nil
+	}
+	return ParseFormat(flag.Value.String())
+}
+
+func (f Format) IsMachineReadable() bool {
+	return f == JSON || f == YAML
+}
+
+// Write serializes v as json or yaml, yaml uses the json field names so both
+// formats share one schema
+func Write(w io.Writer, format Format, v interface{}) error {
+	var out []byte
+	var err error
+	switch format {
+	case JSON:
+		out, err = json.MarshalIndent(v, "", "  ")
+		out = append(out, '\n')
+	case YAML:
+		out, err = yaml.Marshal(v)
+	default:
+		return fmt.Errorf("format %q is not machine readable", format)
+	}
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	_, err = w.Write(out)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testItem struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("json")
+	assert.Nil(t, err)
+	assert.Equal(t, JSON, f)
+
+	f, err = ParseFormat("")
+	assert.Nil(t, err)
+	assert.Equal(t, Table, f)
+
+	_, err = ParseFormat("xml")
+	assert.NotNil(t, err)
+}
+
+func TestWrite(t *testing.T) {
+	items := []testItem{{Name: "my-ws", Status: "RUNNING"}}
+
+	buf := &bytes.Buffer{}
+	err := Write(buf, JSON, items)
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n  {\n    \"name\": \"my-ws\",\n    \"status\": \"RUNNING\"\n  }\n]\n", buf.String())
+
+	buf = &bytes.Buffer{}
+	err = Write(buf, YAML, items)
+	assert.Nil(t, err)
+	assert.Equal(t, "- name: my-ws\n  status: RUNNING\n", buf.String())
+
+	err = Write(buf, Wide, items)
+	assert.NotNil(t, err)
+}
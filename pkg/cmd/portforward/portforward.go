@@ -6,11 +6,13 @@ import (
 	"os/exec"
 	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
 	"github.com/brevdev/brev-cli/pkg/cmd/completions"
 	"github.com/brevdev/brev-cli/pkg/cmd/refresh"
 	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
 
 	"github.com/brevdev/brev-cli/pkg/terminal"
@@ -74,19 +76,23 @@ func RunPortforward(pfStore PortforwardStore, nameOrID string, portString string
 		return breverrors.NewValidationError("port format invalid, use local_port:remote_port")
 	}
 
-	res := refresh.RunRefreshAsync(pfStore)
+	sshName, ok := getSSHNameFromCache(pfStore, nameOrID, time.Now())
+	if !ok {
+		res := refresh.RunRefreshAsync(pfStore)
 
-	sshName, err := ConvertNametoSSHName(pfStore, nameOrID)
-	if err != nil {
-		return breverrors.WrapAndTrace(err)
-	}
+		var err error
+		sshName, err = ConvertNametoSSHName(pfStore, nameOrID)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
 
-	err = res.Await()
-	if err != nil {
-		return breverrors.WrapAndTrace(err)
+		err = res.Await()
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
 	}
 
-	_, err = RunSSHPortForward("-L", portSplit[0], portSplit[1], sshName)
+	_, err := RunSSHPortForward("-L", portSplit[0], portSplit[1], sshName)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
@@ -102,6 +108,37 @@ func ConvertNametoSSHName(store PortforwardStore, workspaceNameOrID string) (str
 	return sshName, nil
 }
 
+// getSSHNameFromCache skips the api when the ssh config was written recently for a running
+// instance matching nameOrID, anything less certain goes through a refresh
+func getSSHNameFromCache(pfStore PortforwardStore, nameOrID string, now time.Time) (string, bool) {
+	cache, err := pfStore.GetWorkspaceCache()
+	if err != nil || cache == nil || !cache.IsFresh(now) {
+		return "", false
+	}
+	org, err := pfStore.GetActiveOrganizationOrDefault()
+	if err != nil || org == nil || org.ID != cache.OrgID {
+		return "", false
+	}
+	var match *entity.Workspace
+	for i, w := range cache.Workspaces {
+		if w.ID == nameOrID {
+			match = &cache.Workspaces[i]
+			break
+		}
+		if w.Name == nameOrID {
+			if match != nil {
+				// ambiguous, let the api lookup explain it
+				return "", false
+			}
+			match = &cache.Workspaces[i]
+		}
+	}
+	if match == nil || match.Status != entity.Running {
+		return "", false
+	}
+	return string(match.GetLocalIdentifier()), true
+}
+
 func RunSSHPortForward(forwardType string, localPort string, remotePort string, sshName string) (*os.Process, error) {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt)
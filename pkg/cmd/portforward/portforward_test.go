@@ -1 +1,62 @@
 package portforward
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type cacheOnlyStore struct {
+	PortforwardStore
+	cache *store.WorkspaceCache
+}
+
+func (s cacheOnlyStore) GetWorkspaceCache() (*store.WorkspaceCache, error) {
+	return s.cache, nil
+}
+
+func (s cacheOnlyStore) GetActiveOrganizationOrDefault() (*entity.Organization, error) {
+	return &entity.Organization{ID: "org-1"}, nil
+}
+
+func TestGetSSHNameFromCache(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	running := entity.Workspace{ID: "ws-1", Name: "api", Status: entity.Running}
+	stopped := entity.Workspace{ID: "ws-2", Name: "db", Status: entity.Stopped}
+	dupA := entity.Workspace{ID: "ws-3", Name: "dup", Status: entity.Running}
+	dupB := entity.Workspace{ID: "ws-4", Name: "dup", Status: entity.Running}
+	cache := &store.WorkspaceCache{
+		OrgID:      "org-1",
+		UpdatedAt:  now.Add(-10 * time.Second),
+		Workspaces: []entity.Workspace{running, stopped, dupA, dupB},
+	}
+	s := cacheOnlyStore{cache: cache}
+
+	name, ok := getSSHNameFromCache(s, "api", now)
+	assert.True(t, ok)
+	assert.Equal(t, string(running.GetLocalIdentifier()), name)
+
+	_, ok = getSSHNameFromCache(s, "ws-1", now)
+	assert.True(t, ok)
+
+	_, ok = getSSHNameFromCache(s, "db", now)
+	assert.False(t, ok, "stopped instances need a refresh")
+
+	_, ok = getSSHNameFromCache(s, "dup", now)
+	assert.False(t, ok, "ambiguous names go to the api")
+
+	_, ok = getSSHNameFromCache(s, "missing", now)
+	assert.False(t, ok)
+
+	_, ok = getSSHNameFromCache(s, "api", now.Add(store.WorkspaceCacheMaxAge))
+	assert.False(t, ok, "stale cache")
+
+	_, ok = getSSHNameFromCache(cacheOnlyStore{cache: &store.WorkspaceCache{OrgID: "org-2", UpdatedAt: now, Workspaces: cache.Workspaces}}, "api", now)
+	assert.False(t, ok, "cache of another org")
+
+	_, ok = getSSHNameFromCache(cacheOnlyStore{}, "api", now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,311 @@
+// Package profile switches between brev environments and accounts
+package profile
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/bulk"
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/brevdev/brev-cli/pkg/ssh"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+const (
+	FlagName   = "profile"
+	EnvVarName = "BREV_PROFILE"
+)
+
+var (
+	profileLong = `Profiles keep separate credentials, active org, ssh config and settings for each brev
+environment or account. The default profile lives in ~/.brev, others in ~/.brev/profiles/<name>.
+Pick one for a single command with --profile or ` + EnvVarName + `, or for every command with 'brev profile use'`
+	profileExample = `
+  brev profile add staging --api-url https://brevapi.staging.example.com
+  brev login --profile staging
+  brev profile use staging
+  brev profile list
+  brev profile remove staging
+	`
+)
+
+type CurrentProfileStore interface {
+	GetCurrentProfile() (string, error)
+}
+
+type ProfileStore interface {
+	CurrentProfileStore
+	GetProfiles() ([]store.Profile, error)
+	AddProfile(name string, apiURL string) error
+	RemoveProfile(name string) error
+	SetCurrentProfile(name string) error
+	UserHomeDir() (string, error)
+	GetUserSSHConfigPath() (string, error)
+	GetUserSSHConfig() (string, error)
+	WriteUserSSHConfig(config string) error
+	FileExists(path string) (bool, error)
+}
+
+// ResolveProfile picks the profile brev runs with: --profile, then BREV_PROFILE, then the one
+// chosen with 'brev profile use'. The flag is read from args since the paths of every store
+// depend on it before cobra parses anything
+func ResolveProfile(args []string, getenv func(string) string, profileStore CurrentProfileStore) (string, error) {
+	// the name ends up in the path of every store, so it has to be a profile name
+	name := getProfileFlag(args)
+	if name == "" {
+		name = getenv(EnvVarName)
+	}
+	if name != "" {
+		err := store.ValidateProfileName(name)
+		if err != nil {
+			return files.DefaultProfile, breverrors.WrapAndTrace(err)
+		}
+		return name, nil
+	}
+	current, err := profileStore.GetCurrentProfile()
+	if err != nil {
+		return files.DefaultProfile, breverrors.WrapAndTrace(err)
+	}
+	// not a validation error, so brev profile use still works to pick a valid one
+	if store.ValidateProfileName(current) != nil {
+		return files.DefaultProfile, breverrors.WrapAndTrace(fmt.Errorf("the current profile %q is not a valid profile name", current))
+	}
+	return current, nil
+}
+
+func getProfileFlag(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			return ""
+		}
+		if arg == "--"+FlagName && i+1 < len(args) {
+			return args[i+1]
+		}
+		if v, ok := strings.CutPrefix(arg, "--"+FlagName+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func NewCmdProfile(t *terminal.Terminal, profileStore ProfileStore) *cobra.Command {
+	cmd := &cobra.Command{
+		Annotations: map[string]string{"context": ""},
+		Use:         "profile",
+		Short:       "Manage profiles for brev environments and accounts",
+		Long:        profileLong,
+		Example:     profileExample,
+		Args:        cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := runList(cmd, t, profileStore)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.AddCommand(newCmdAdd(t, profileStore))
+	cmd.AddCommand(newCmdUse(t, profileStore))
+	cmd.AddCommand(newCmdList(t, profileStore))
+	cmd.AddCommand(newCmdRemove(t, profileStore))
+	return cmd
+}
+
+func newCmdAdd(t *terminal.Terminal, profileStore ProfileStore) *cobra.Command {
+	var apiURL string
+	var use bool
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add a profile",
+		Args:  cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			err := profileStore.AddProfile(name, apiURL)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("added profile %s\n", t.Green(name))
+			if use {
+				err = profileStore.SetCurrentProfile(name)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				t.Vprintf("now using profile %s\n", t.Green(name))
+			}
+			t.Vprintf("log in with: brev login --profile %s\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&apiURL, "api-url", "", "brev api of this profile, defaults to the one of the default profile")
+	cmd.Flags().BoolVar(&use, "use", false, "switch to the profile after adding it")
+	return cmd
+}
+
+func newCmdUse(t *terminal.Terminal, profileStore ProfileStore) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "use <name>",
+		Short:             "Use a profile for every command",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getProfileNameCompletionHandler(profileStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := profileStore.SetCurrentProfile(args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("now using profile %s\n", t.Green(args[0]))
+			if env := os.Getenv(EnvVarName); env != "" && env != args[0] {
+				t.Vprint(t.Yellow(fmt.Sprintf("%s=%s is set and overrides it in this shell\n", EnvVarName, env)))
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func newCmdList(t *terminal.Terminal, profileStore ProfileStore) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List profiles",
+		Args:  cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := runList(cmd, t, profileStore)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func runList(cmd *cobra.Command, t *terminal.Terminal, profileStore ProfileStore) error {
+	format, err := output.GetFormat(cmd)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	profiles, err := profileStore.GetProfiles()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if format.IsMachineReadable() {
+		err = output.Write(os.Stdout, format, profiles)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	}
+	ta := table.NewWriter()
+	ta.SetOutputMirror(os.Stdout)
+	ta.Style().Options = util.GetBrevTableOptions()
+	ta.AppendHeader(table.Row{"", "NAME", "API URL", "LOGGED IN"})
+	for _, p := range profiles {
+		marker := ""
+		name := p.Name
+		if p.Name == files.GetActiveProfile() {
+			marker = "*"
+			name = t.Green(p.Name)
+		}
+		loggedIn := "no"
+		if p.LoggedIn {
+			loggedIn = "yes"
+		}
+		ta.AppendRow(table.Row{marker, name, p.APIURL, loggedIn})
+	}
+	ta.Render()
+	return nil
+}
+
+func newCmdRemove(t *terminal.Terminal, profileStore ProfileStore) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:               "remove <name>",
+		Short:             "Remove a profile with its credentials and ssh config",
+		Args:              cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction: getProfileNameCompletionHandler(profileStore),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if name == files.DefaultProfile {
+				return breverrors.NewValidationError("the default profile can not be removed")
+			}
+			err := store.ValidateProfileName(name)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if !yes {
+				ok, err := bulk.Confirm(fmt.Sprintf("Remove profile %s and its credentials?", name))
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				if !ok {
+					return nil
+				}
+			}
+			err = removeProfile(profileStore, name)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			t.Vprintf("removed profile %s\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "don't ask for confirmation")
+	return cmd
+}
+
+// removeProfile also drops the Include of the profile's ssh config from ~/.ssh/config
+func removeProfile(profileStore ProfileStore, name string) error {
+	home, err := profileStore.UserHomeDir()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = profileStore.RemoveProfile(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	userSSHConfigPath, err := profileStore.GetUserSSHConfigPath()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	exists, err := profileStore.FileExists(userSSHConfigPath)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return nil
+	}
+	conf, err := profileStore.GetUserSSHConfig()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	newConf := ssh.RemoveIncludeFromUserConfig(conf, files.GetProfileBrevSSHConfigPath(home, name))
+	if newConf == conf {
+		return nil
+	}
+	err = profileStore.WriteUserSSHConfig(newConf)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func getProfileNameCompletionHandler(profileStore ProfileStore) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		profiles, err := profileStore.GetProfiles()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		names := []string{}
+		for _, p := range profiles {
+			names = append(names, p.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
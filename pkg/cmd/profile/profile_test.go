@@ -0,0 +1,99 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCurrentProfileStore struct {
+	current string
+}
+
+func (f fakeCurrentProfileStore) GetCurrentProfile() (string, error) {
+	return f.current, nil
+}
+
+func TestResolveProfile(t *testing.T) {
+	noEnv := func(string) string { return "" }
+	env := func(k string) string {
+		if k == EnvVarName {
+			return "from-env"
+		}
+		return ""
+	}
+	s := fakeCurrentProfileStore{current: "from-file"}
+
+	cases := []struct {
+		name   string
+		args   []string
+		getenv func(string) string
+		want   string
+	}{
+		{"flag", []string{"ls", "--profile", "from-flag"}, env, "from-flag"},
+		{"flag with =", []string{"--profile=from-flag", "ls"}, env, "from-flag"},
+		{"env", []string{"ls"}, env, "from-env"},
+		{"current", []string{"ls"}, noEnv, "from-file"},
+		{"after --", []string{"shell", "--", "--profile", "x"}, noEnv, "from-file"},
+		{"flag without value", []string{"ls", "--profile"}, noEnv, "from-file"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ResolveProfile(c.args, c.getenv, s)
+			assert.Nil(t, err)
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestResolveProfileRejectsPaths(t *testing.T) {
+	noEnv := func(string) string { return "" }
+	env := func(k string) string {
+		if k == EnvVarName {
+			return ".."
+		}
+		return ""
+	}
+
+	cases := []struct {
+		name   string
+		args   []string
+		getenv func(string) string
+		store  fakeCurrentProfileStore
+	}{
+		{"flag", []string{"ls", "--profile", "../.."}, noEnv, fakeCurrentProfileStore{current: "staging"}},
+		{"env", []string{"ls"}, env, fakeCurrentProfileStore{current: "staging"}},
+		{"current", []string{"ls"}, noEnv, fakeCurrentProfileStore{current: "a/b"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ResolveProfile(c.args, c.getenv, c.store)
+			assert.Error(t, err)
+			assert.Equal(t, files.DefaultProfile, got)
+		})
+	}
+}
+
+func TestRemoveProfileDropsSSHInclude(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	s := store.NewBasicStore().WithFileSystem(fs).WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+	err := s.AddProfile("staging", "")
+	assert.Nil(t, err)
+
+	stagingInclude := "Include \"" + files.GetProfileBrevSSHConfigPath("/home/test", "staging") + "\"\n"
+	defaultInclude := "Include \"" + files.GetProfileBrevSSHConfigPath("/home/test", files.DefaultProfile) + "\"\n"
+	err = afero.WriteFile(fs, "/home/test/.ssh/config", []byte(defaultInclude+stagingInclude+"Host foo\n"), 0o644)
+	assert.Nil(t, err)
+
+	err = removeProfile(s, "staging")
+	assert.Nil(t, err)
+
+	conf, err := afero.ReadFile(fs, "/home/test/.ssh/config")
+	assert.Nil(t, err)
+	assert.Equal(t, defaultInclude+"Host foo\n", string(conf))
+}
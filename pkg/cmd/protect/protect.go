@@ -0,0 +1,166 @@
+// Package protect labels instances so brev delete refuses them
+package protect
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/completions"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	protectLong = `Protect instances from 'brev delete'.
+The label is stored in your brev home, so it only guards deletes run from this machine`
+	protectExample = `
+  brev protect <NAME>...
+  brev protect --list
+	`
+	unprotectExample = `
+  brev unprotect <NAME>...
+	`
+)
+
+type ProtectStore interface {
+	completions.CompletionStore
+	util.GetWorkspaceByNameOrIDErrStore
+	ProtectWorkspace(workspace entity.Workspace) error
+	UnprotectWorkspace(workspaceID string) (bool, error)
+	GetProtectedWorkspaces() ([]store.ProtectedWorkspace, error)
+}
+
+func NewCmdProtect(t *terminal.Terminal, loginProtectStore ProtectStore, noLoginProtectStore ProtectStore) *cobra.Command {
+	var list bool
+
+	cmd := &cobra.Command{
+		Annotations:           map[string]string{"workspace": ""},
+		Use:                   "protect",
+		DisableFlagsInUseLine: true,
+		Short:                 "Protect instances from being deleted",
+		Long:                  protectLong,
+		Example:               protectExample,
+		ValidArgsFunction:     completions.GetAllWorkspaceNameCompletionHandler(noLoginProtectStore, t),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if list || len(args) == 0 {
+				format, err := output.GetFormat(cmd)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				err = listProtected(t, noLoginProtectStore, format)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				return nil
+			}
+			var allErr error
+			for _, arg := range args {
+				err := protectWorkspace(t, loginProtectStore, arg)
+				if err != nil {
+					allErr = multierror.Append(allErr, err)
+				}
+			}
+			if allErr != nil {
+				return breverrors.WrapAndTrace(allErr)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&list, "list", "l", false, "list protected instances")
+
+	return cmd
+}
+
+func NewCmdUnprotect(t *terminal.Terminal, noLoginProtectStore ProtectStore) *cobra.Command {
+	cmd := &cobra.Command{
+		Annotations:           map[string]string{"workspace": ""},
+		Use:                   "unprotect",
+		DisableFlagsInUseLine: true,
+		Short:                 "Allow protected instances to be deleted again",
+		Example:               unprotectExample,
+		Args:                  cobra.MinimumNArgs(1),
+		ValidArgsFunction:     completions.GetAllWorkspaceNameCompletionHandler(noLoginProtectStore, t),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var allErr error
+			for _, arg := range args {
+				err := unprotectWorkspace(t, noLoginProtectStore, arg)
+				if err != nil {
+					allErr = multierror.Append(allErr, err)
+				}
+			}
+			if allErr != nil {
+				return breverrors.WrapAndTrace(allErr)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func protectWorkspace(t *terminal.Terminal, protectStore ProtectStore, workspaceNameOrID string) error {
+	workspace, err := util.GetUserWorkspaceByNameOrIDErr(protectStore, workspaceNameOrID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = protectStore.ProtectWorkspace(*workspace)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	t.Vprintf("%s is protected, 'brev delete' will refuse it\n", t.Green(workspace.Name))
+	return nil
+}
+
+// unprotectWorkspace matches the local label by name or id, so instances that are already
+// gone can still be cleaned up
+func unprotectWorkspace(t *terminal.Terminal, protectStore ProtectStore, workspaceNameOrID string) error {
+	protected, err := protectStore.GetProtectedWorkspaces()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	for _, p := range protected {
+		if p.ID != workspaceNameOrID && p.Name != workspaceNameOrID {
+			continue
+		}
+		_, err = protectStore.UnprotectWorkspace(p.ID)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		t.Vprintf("%s is no longer protected\n", t.Green(p.Name))
+		return nil
+	}
+	return breverrors.NewValidationError(fmt.Sprintf("instance %s is not protected", workspaceNameOrID))
+}
+
+func listProtected(t *terminal.Terminal, protectStore ProtectStore, format output.Format) error {
+	protected, err := protectStore.GetProtectedWorkspaces()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if format.IsMachineReadable() {
+		err = output.Write(os.Stdout, format, protected)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	}
+	if len(protected) == 0 {
+		t.Vprint("No instances are protected, protect one with: brev protect <NAME>\n")
+		return nil
+	}
+	ta := table.NewWriter()
+	ta.SetOutputMirror(os.Stdout)
+	ta.Style().Options = util.GetBrevTableOptions()
+	ta.AppendHeader(table.Row{"NAME", "ID", "PROTECTED AT"})
+	for _, p := range protected {
+		ta.AppendRow(table.Row{p.Name, p.ID, p.ProtectedAt.Local().Format("2006-01-02 15:04")})
+	}
+	ta.Render()
+	return nil
+}
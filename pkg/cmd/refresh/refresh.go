@@ -38,7 +38,7 @@ func NewCmdRefresh(t *terminal.Terminal, store RefreshStore) *cobra.Command {
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Println("refreshing brev...")
-			err := RunRefresh(store)
+			err := RunForceRefresh(store)
 			if err != nil {
 				return breverrors.WrapAndTrace(err)
 			}
@@ -64,6 +64,22 @@ func RunRefresh(store RefreshStore) error {
 	return nil
 }
 
+// RunForceRefresh rewrites the ssh config even if no instance changed since the last refresh
+func RunForceRefresh(store RefreshStore) error {
+	cu, err := GetConfigUpdater(store)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	cu.Force = true
+
+	err = cu.Run()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	return nil
+}
+
 type RefreshRes struct {
 	wg *sync.WaitGroup
 	er error
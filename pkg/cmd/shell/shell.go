@@ -0,0 +1,172 @@
+// Package shell is for opening an ssh session into a Brev instance
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/completions"
+	"github.com/brevdev/brev-cli/pkg/cmd/hello"
+	"github.com/brevdev/brev-cli/pkg/cmd/refresh"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	shellLong    = "Open an ssh session into your instance, starting it first if it is stopped"
+	shellExample = `
+  brev shell <NAME>
+  brev shell <NAME> --host
+  brev shell <NAME> --dir /home/ubuntu/my-repo
+  brev shell <NAME> -- nvidia-smi
+	`
+)
+
+type ShellStore interface {
+	completions.CompletionStore
+	refresh.RefreshStore
+	util.GetWorkspaceByNameOrIDErrStore
+	StartWorkspace(workspaceID string) (*entity.Workspace, error)
+	GetWorkspace(workspaceID string) (*entity.Workspace, error)
+}
+
+func NewCmdShell(t *terminal.Terminal, loginShellStore ShellStore, noLoginShellStore ShellStore) *cobra.Command {
+	var host bool
+	var directory string
+	var noStart bool
+
+	cmd := &cobra.Command{
+		Annotations:           map[string]string{"ssh": ""},
+		Use:                   "shell",
+		Aliases:               []string{"ssh"},
+		DisableFlagsInUseLine: true,
+		Short:                 "Open a shell in your instance",
+		Long:                  shellLong,
+		Example:               shellExample,
+		Args:                  cmderrors.TransformToValidationError(cobra.MinimumNArgs(1)),
+		ValidArgsFunction:     completions.GetAllWorkspaceNameCompletionHandler(noLoginShellStore, t),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var remoteCmd []string
+			if dash := cmd.ArgsLenAtDash(); dash > -1 {
+				if dash != 1 {
+					return breverrors.NewValidationError("please provide exactly one instance before --")
+				}
+				remoteCmd = args[dash:]
+			} else if len(args) > 1 {
+				return breverrors.NewValidationError("too many args provided, use -- to separate a remote command")
+			}
+			err := RunShell(t, loginShellStore, ShellOptions{
+				WorkspaceNameOrID: args[0],
+				Host:              host,
+				Directory:         directory,
+				StartIfStopped:    !noStart,
+				Command:           remoteCmd,
+			})
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&host, "host", false, "ssh into the host machine instead of the container")
+	cmd.Flags().StringVarP(&directory, "dir", "d", "", "directory to open the shell in")
+	cmd.Flags().BoolVar(&noStart, "no-start", false, "fail instead of starting the instance if it is stopped")
+
+	return cmd
+}
+
+type ShellOptions struct {
+	WorkspaceNameOrID string
+	Host              bool
+	Directory         string
+	StartIfStopped    bool
+	Command           []string
+}
+
+func RunShell(t *terminal.Terminal, shellStore ShellStore, options ShellOptions) error {
+	workspace, err := util.GetUserWorkspaceByNameOrIDErr(shellStore, options.WorkspaceNameOrID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	if workspace.Status == entity.Stopped {
+		if !options.StartIfStopped {
+			return breverrors.NewValidationError(fmt.Sprintf("instance %s is stopped, run 'brev start %s'", workspace.Name, workspace.Name))
+		}
+		t.Vprintf(t.Yellow("Instance %s is stopped, starting it...\n", workspace.Name))
+		_, err = shellStore.StartWorkspace(workspace.ID)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+
+	if workspace.Status != entity.Running {
+		workspace, err = util.WaitForWorkspaceRunning(t, shellStore, workspace.ID)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+
+	// the instance may have just come up, so make sure the ssh config knows about it
+	err = refresh.RunRefreshAsync(shellStore).Await()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	sshAlias := string(workspace.GetLocalIdentifier())
+	if options.Host {
+		sshAlias = string(workspace.GetHostIdentifier())
+	}
+
+	_ = hello.SetHasRunShell(true)
+
+	err = runSSH(makeSSHArgs(sshAlias, options.Directory, options.Command))
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func makeSSHArgs(sshAlias string, directory string, remoteCmd []string) []string {
+	if len(remoteCmd) > 0 {
+		command := strings.Join(remoteCmd, " ")
+		if directory != "" {
+			command = fmt.Sprintf("cd %s && %s", quoteDir(directory), command)
+		}
+		// a command on the command line conflicts with any RemoteCommand in the ssh config
+		return []string{"-o", "RemoteCommand=none", sshAlias, command}
+	}
+	if directory != "" {
+		return []string{"-t", "-o", "RemoteCommand=none", sshAlias, fmt.Sprintf("cd %s && exec $SHELL -l", quoteDir(directory))}
+	}
+	return []string{sshAlias}
+}
+
+// quoteDir quotes directory for the remote shell but leaves a leading ~ for it to expand
+func quoteDir(directory string) string {
+	if directory == "~" {
+		return directory
+	}
+	if rest, ok := strings.CutPrefix(directory, "~/"); ok {
+		return "~/" + util.ShellQuote(rest)
+	}
+	return util.ShellQuote(directory)
+}
+
+func runSSH(args []string) error {
+	sshCmd := exec.Command("ssh", args...) //nolint:gosec // args are the ssh alias and user input
+	sshCmd.Stdin = os.Stdin
+	sshCmd.Stdout = os.Stdout
+	sshCmd.Stderr = os.Stderr
+	err := sshCmd.Run()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeSSHArgs(t *testing.T) {
+	assert.Equal(t, []string{"my-ws"}, makeSSHArgs("my-ws", "", nil))
+	assert.Equal(t,
+		[]string{"-o", "RemoteCommand=none", "my-ws-host", "nvidia-smi -L"},
+		makeSSHArgs("my-ws-host", "", []string{"nvidia-smi", "-L"}),
+	)
+	assert.Equal(t,
+		[]string{"-o", "RemoteCommand=none", "my-ws", "cd '/home/ubuntu/repo' && make test"},
+		makeSSHArgs("my-ws", "/home/ubuntu/repo", []string{"make", "test"}),
+	)
+	assert.Equal(t,
+		[]string{"-t", "-o", "RemoteCommand=none", "my-ws", "cd '/home/ubuntu/repo' && exec $SHELL -l"},
+		makeSSHArgs("my-ws", "/home/ubuntu/repo", nil),
+	)
+	assert.Equal(t,
+		[]string{"-t", "-o", "RemoteCommand=none", "my-ws", `cd '/tmp/my repo; rm -rf ~' && exec $SHELL -l`},
+		makeSSHArgs("my-ws", "/tmp/my repo; rm -rf ~", nil),
+	)
+	assert.Equal(t,
+		[]string{"-o", "RemoteCommand=none", "my-ws", `cd ~/'it'"'"'s' && ls`},
+		makeSSHArgs("my-ws", "~/it's", []string{"ls"}),
+	)
+}
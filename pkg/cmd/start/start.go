@@ -8,7 +8,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/brevdev/brev-cli/pkg/cmd/bulk"
 	"github.com/brevdev/brev-cli/pkg/cmd/completions"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
 	"github.com/brevdev/brev-cli/pkg/cmd/util"
 	"github.com/brevdev/brev-cli/pkg/config"
 	"github.com/brevdev/brev-cli/pkg/entity"
@@ -18,6 +20,7 @@ import (
 	"github.com/brevdev/brev-cli/pkg/store"
 	"github.com/brevdev/brev-cli/pkg/terminal"
 	allutil "github.com/brevdev/brev-cli/pkg/util"
+	"github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
 
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
@@ -29,6 +32,7 @@ var (
   brev start <existing_ws_name>
   brev start <git url>
   brev start <git url> --org myFancyOrg
+  brev start --match 'test-*' --yes
 	`
 )
 
@@ -55,6 +59,8 @@ func NewCmdStart(t *terminal.Terminal, startStore StartStore, noLoginStartStore
 	var setupPath string
 	var gpu string
 	var cpu string
+	var timeout time.Duration
+	var bulkOpts bulk.Options
 
 	cmd := &cobra.Command{
 		Annotations:           map[string]string{"workspace": ""},
@@ -70,6 +76,10 @@ func NewCmdStart(t *terminal.Terminal, startStore StartStore, noLoginStartStore
 				repoOrPathOrNameOrID = args[0]
 			}
 
+			cpu, gpu = resolveInstanceTypes(config.Global().WithFlags(cmd.Flags(), map[config.Key]string{
+				config.KeyDefaultCPU: "cpu",
+				config.KeyDefaultGPU: "gpu",
+			}))
 			if gpu != "" {
 				isValid := instancetypes.ValidateInstanceType(gpu)
 				if !isValid {
@@ -78,7 +88,23 @@ func NewCmdStart(t *terminal.Terminal, startStore StartStore, noLoginStartStore
 				}
 			}
 
-			err := runStartWorkspace(t, StartOptions{
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+
+			if bulkOpts.IsSet() {
+				if len(args) > 0 {
+					return breverrors.NewValidationError("an instance name or url can not be combined with selector flags")
+				}
+				err = startSelectedWorkspaces(t, startStore, bulkOpts, format, detached, timeout)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				return nil
+			}
+
+			err = runStartWorkspace(t, StartOptions{
 				RepoOrPathOrNameOrID: repoOrPathOrNameOrID,
 				Name:                 name,
 				OrgName:              org,
@@ -88,6 +114,8 @@ func NewCmdStart(t *terminal.Terminal, startStore StartStore, noLoginStartStore
 				WorkspaceClass:       cpu,
 				Detached:             detached,
 				InstanceType:         gpu,
+				Timeout:              timeout,
+				JSONEvents:           format == output.JSON,
 			}, startStore)
 			if err != nil {
 				if strings.Contains(err.Error(), "duplicate instance with name") {
@@ -101,15 +129,17 @@ func NewCmdStart(t *terminal.Terminal, startStore StartStore, noLoginStartStore
 		},
 	}
 	cmd.Flags().BoolVarP(&detached, "detached", "d", false, "run the command in the background instead of blocking the shell")
+	bulkOpts.AddFlags(cmd.Flags())
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "give up waiting for the instance to be running after this long, e.g. 10m (default no limit)")
 	cmd.Flags().BoolVarP(&empty, "empty", "e", false, "create an empty workspace")
 	cmd.Flags().StringVarP(&name, "name", "n", "", "name your workspace when creating a new one")
-	cmd.Flags().StringVarP(&cpu, "cpu", "c", "", "CPU instance type. Defaults to 2x8 [2x8, 4x16, 8x32, 16x32]. See docs.brev.dev/cpu for details")
+	cmd.Flags().StringVarP(&cpu, "cpu", "c", "", "CPU instance type [2x8, 4x16, 8x32, 16x32], defaults to 'brev config get default_cpu'. See docs.brev.dev/cpu for details")
 	cmd.Flags().StringVarP(&setupScript, "setup-script", "s", "", "takes a raw gist url to an env setup script")
 	cmd.Flags().StringVarP(&setupRepo, "setup-repo", "r", "", "repo that holds env setup script. you must pass in --setup-path if you use this argument")
 	cmd.Flags().StringVarP(&setupPath, "setup-path", "p", "", "path to env setup script. If you include --setup-repo we will apply this argument to that repo")
 	cmd.Flags().StringVarP(&org, "org", "o", "", "organization (will override active org if creating a workspace)")
 	// GPU options
-	cmd.Flags().StringVarP(&gpu, "gpu", "g", "n1-highmem-4:nvidia-tesla-t4:1", "GPU instance type. See https://brev.dev/docs/reference/gpu for details")
+	cmd.Flags().StringVarP(&gpu, "gpu", "g", "n1-highmem-4:nvidia-tesla-t4:1", "GPU instance type, 'brev config set default_gpu' changes the default. See https://brev.dev/docs/reference/gpu for details")
 	err := cmd.RegisterFlagCompletionFunc("org", completions.GetOrgsNameCompletionHandler(noLoginStartStore, t))
 	if err != nil {
 		breverrors.GetDefaultErrorReporter().ReportError(breverrors.WrapAndTrace(err))
@@ -118,6 +148,11 @@ func NewCmdStart(t *terminal.Terminal, startStore StartStore, noLoginStartStore
 	return cmd
 }
 
+// resolveInstanceTypes picks the CPU and GPU types from the flags, then the config
+func resolveInstanceTypes(conf *config.FlagsConfig) (string, string) {
+	return conf.Get(config.KeyDefaultCPU), conf.Get(config.KeyDefaultGPU)
+}
+
 type StartOptions struct {
 	RepoOrPathOrNameOrID string // todo make invidual options
 	Name                 string
@@ -128,6 +163,38 @@ type StartOptions struct {
 	WorkspaceClass       string
 	Detached             bool
 	InstanceType         string
+	Timeout              time.Duration
+	JSONEvents           bool
+}
+
+// startSelectedWorkspaces starts every selected instance, stopped ones unless a status is given,
+// and waits for them to be running unless detached
+func startSelectedWorkspaces(t *terminal.Terminal, startStore StartStore, opts bulk.Options, format output.Format, detached bool, timeout time.Duration) error {
+	if len(opts.Statuses) == 0 {
+		opts.Statuses = []string{entity.Stopped}
+	}
+	results, startErr := bulk.Run(t, startStore, opts, "start", format, func(w entity.Workspace) error {
+		_, err := startStore.StartWorkspace(w.ID)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	})
+	if !detached {
+		for _, r := range results {
+			if !r.OK() {
+				continue
+			}
+			err := pollUntilRunning(t, r.ID, startStore, StartOptions{Timeout: timeout, JSONEvents: format == output.JSON})
+			if err != nil {
+				startErr = multierror.Append(startErr, err)
+			}
+		}
+	}
+	if startErr != nil {
+		return breverrors.WrapAndTrace(startErr)
+	}
+	return nil
 }
 
 func runStartWorkspace(t *terminal.Terminal, options StartOptions, startStore StartStore) error {
@@ -337,7 +404,7 @@ func createEmptyWorkspace(user *entity.User, t *terminal.Terminal, options Start
 		}
 	}
 
-	clusterID := config.GlobalConfig.GetDefaultClusterID()
+	clusterID := config.Global().GetDefaultClusterID()
 	cwOptions := store.NewCreateWorkspacesOptions(clusterID, options.Name)
 
 	if options.WorkspaceClass != "" {
@@ -375,7 +442,7 @@ func createEmptyWorkspace(user *entity.User, t *terminal.Terminal, options Start
 	if options.Detached {
 		return nil
 	} else {
-		err = pollUntil(t, w.ID, entity.Running, startStore, true)
+		err = pollUntilRunning(t, w.ID, startStore, options)
 		if err != nil {
 			return breverrors.WrapAndTrace(err)
 		}
@@ -430,7 +497,7 @@ func startStopppedWorkspace(workspace *entity.Workspace, startStore StartStore,
 		return nil
 	}
 
-	err = pollUntil(t, workspace.ID, entity.Running, startStore, true)
+	err = pollUntilRunning(t, workspace.ID, startStore, startOptions)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
@@ -446,7 +513,7 @@ func startStopppedWorkspace(workspace *entity.Workspace, startStore StartStore,
 // "https://github.com/brevdev/microservices-demo.git"
 // "git@github.com:brevdev/microservices-demo.git"
 func joinProjectWithNewWorkspace(t *terminal.Terminal, templateWorkspace entity.Workspace, orgID string, startStore StartStore, user *entity.User, startOptions StartOptions) error {
-	clusterID := config.GlobalConfig.GetDefaultClusterID()
+	clusterID := config.Global().GetDefaultClusterID()
 	if startOptions.WorkspaceClass == "" {
 		startOptions.WorkspaceClass = templateWorkspace.WorkspaceClassID
 	}
@@ -478,7 +545,7 @@ func joinProjectWithNewWorkspace(t *terminal.Terminal, templateWorkspace entity.
 	}
 	s.Stop()
 
-	err = pollUntil(t, w.ID, entity.Running, startStore, true)
+	err = pollUntilRunning(t, w.ID, startStore, startOptions)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
@@ -604,7 +671,7 @@ func MakeNewWorkspaceFromURL(url string) NewWorkspace {
 }
 
 func createWorkspace(user *entity.User, t *terminal.Terminal, workspace NewWorkspace, orgID string, startStore StartStore, startOptions StartOptions) error {
-	clusterID := config.GlobalConfig.GetDefaultClusterID()
+	clusterID := config.Global().GetDefaultClusterID()
 
 	options := store.NewCreateWorkspacesOptions(clusterID, workspace.Name).WithGitRepo(workspace.GitRepo)
 
@@ -646,7 +713,7 @@ func createWorkspace(user *entity.User, t *terminal.Terminal, workspace NewWorks
 	}
 	s.Stop()
 
-	err = pollUntil(t, w.ID, entity.Running, startStore, true)
+	err = pollUntilRunning(t, w.ID, startStore, startOptions)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
@@ -665,26 +732,19 @@ func displayConnectBreadCrumb(t *terminal.Terminal, workspace *entity.Workspace)
 	// t.Vprintf(t.Yellow(fmt.Sprintf("\tssh %s\t# ssh <SSH-NAME> -> ssh directly to instance\n", workspace.GetLocalIdentifier())))
 }
 
-func pollUntil(t *terminal.Terminal, wsid string, state string, startStore StartStore, canSafelyExit bool) error { //nolint:unparam // TODO refactor
-	s := t.NewSpinner()
-	isReady := false
-	if canSafelyExit {
+// pollUntilRunning blocks until the instance is running, failing on terminal states or once
+// the --timeout passes
+func pollUntilRunning(t *terminal.Terminal, wsid string, startStore StartStore, options StartOptions) error {
+	if !options.JSONEvents {
 		t.Vprintf("You can safely ctrl+c to exit\n")
 	}
-	s.Suffix = " hang tight 🤙"
-	s.Start()
-	for !isReady {
-		time.Sleep(5 * time.Second)
-		ws, err := startStore.GetWorkspace(wsid)
-		if err != nil {
-			return breverrors.WrapAndTrace(err)
-		}
-		s.Suffix = "  instance is " + strings.ToLower(ws.Status)
-		if ws.Status == state {
-			s.Suffix = "Instance is ready!"
-			s.Stop()
-			isReady = true
-		}
+	_, err := util.WaitForWorkspaceStatus(startStore, wsid, util.WaitOptions{
+		For:      []string{entity.Running},
+		Timeout:  options.Timeout,
+		Reporter: util.NewWaitReporter(t, options.JSONEvents, " hang tight 🤙"),
+	})
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
 	}
 	return nil
 }
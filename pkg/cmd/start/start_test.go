@@ -3,8 +3,10 @@ package start
 import (
 	"testing"
 
+	"github.com/brevdev/brev-cli/pkg/config"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -60,3 +62,33 @@ func Test_DisplayBC(t *testing.T) {
 		NetworkID:         "",
 	})
 }
+
+func TestResolveInstanceTypes(t *testing.T) {
+	resolve := func(args ...string) (string, string) {
+		flags := pflag.NewFlagSet("start", pflag.ContinueOnError)
+		flags.String("cpu", "", "")
+		flags.String("gpu", "n1-highmem-4:nvidia-tesla-t4:1", "")
+		assert.Nil(t, flags.Parse(args))
+		conf := config.NewConstants().WithFileConfig().WithEnvVars()
+		return resolveInstanceTypes(conf.WithFlags(flags, map[config.Key]string{
+			config.KeyDefaultCPU: "cpu",
+			config.KeyDefaultGPU: "gpu",
+		}))
+	}
+	t.Setenv("DEFAULT_WORKSPACE_CLASS", "")
+	t.Setenv("BREV_DEFAULT_GPU", "")
+
+	cpu, gpu := resolve()
+	assert.Equal(t, "", cpu)
+	assert.Equal(t, "n1-highmem-4:nvidia-tesla-t4:1", gpu)
+
+	// the GPU default is kept alongside a CPU, as it was before it was a setting
+	cpu, gpu = resolve("--cpu", "4x16")
+	assert.Equal(t, "4x16", cpu)
+	assert.Equal(t, "n1-highmem-4:nvidia-tesla-t4:1", gpu)
+
+	t.Setenv("DEFAULT_WORKSPACE_CLASS", "2x8")
+	cpu, gpu = resolve("--gpu", "g5.xlarge")
+	assert.Equal(t, "2x8", cpu)
+	assert.Equal(t, "g5.xlarge", gpu)
+}
@@ -0,0 +1,354 @@
+package stop
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/auth"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/setupworkspace"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/sevlyar/go-daemon"
+)
+
+const (
+	DefaultIdleFor = 30 * time.Minute
+
+	idleCheckInterval = time.Minute
+	// a 1 minute load average below this counts as nothing running
+	idleLoadThreshold = 0.5
+	sshPort           = 22
+)
+
+type StopSelfOptions struct {
+	After      time.Duration
+	WhenIdle   bool
+	IdleFor    time.Duration
+	Foreground bool
+	Cancel     bool
+}
+
+func (o StopSelfOptions) IsScheduled() bool {
+	return o.After > 0 || o.WhenIdle
+}
+
+type workspaceStopper interface {
+	StopWorkspace(workspaceID string) (*entity.Workspace, error)
+}
+
+// getStopSelfStore authenticates with the instance's service token so stopping itself never
+// needs a user login, instances without one fall back to the saved credentials
+func getStopSelfStore(stopStore StopStore) (workspaceStopper, error) {
+	token, err := stopStore.GetCurrentWorkspaceServiceToken()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if strings.TrimSpace(token) == "" {
+		return stopStore, nil
+	}
+	serviceStore := stopStore.WithAuth(auth.NewServiceTokenAuth(stopStore))
+	workspaceGroupID, err := stopStore.GetCurrentWorkspaceGroupID()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if workspaceGroupID != "" {
+		serviceStore.WithStaticHeader("X-Workspace-Group-ID", workspaceGroupID)
+	}
+	return serviceStore, nil
+}
+
+// StopThisWorkspace stops the instance this is run on
+func StopThisWorkspace(stopStore StopStore, t *terminal.Terminal) error {
+	workspaceID, err := getThisWorkspaceID(stopStore)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	stopper, err := getStopSelfStore(stopStore)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	_, err = stopper.StopWorkspace(workspaceID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	t.Vprintf(t.Green("Stopping this instance\n") +
+		"Note: this can take a few seconds. Run 'brev ls' to check status\n")
+	return nil
+}
+
+func getThisWorkspaceID(stopStore StopStore) (string, error) {
+	isWorkspace, err := stopStore.IsWorkspace()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	if !isWorkspace {
+		return "", breverrors.NewValidationError("this is not an instance, run 'brev stop self' from inside the instance you want to stop")
+	}
+	workspaceID, err := stopStore.GetCurrentWorkspaceID()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return workspaceID, nil
+}
+
+func getStopSelfPidFile(brevHome string) string {
+	return filepath.Join(brevHome, "stop-self.pid")
+}
+
+func getStopSelfLogFile(brevHome string) string {
+	return filepath.Join(brevHome, "stop-self.log")
+}
+
+// ScheduleStopSelf stops this instance once the time is up or it has been idle long enough.
+// Unless run in the foreground it detaches so the shell can be closed in the meantime
+func ScheduleStopSelf(t *terminal.Terminal, stopStore StopStore, opts StopSelfOptions) error {
+	workspaceID, err := getThisWorkspaceID(stopStore)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = stopStore.BuildBrevHome()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	brevHome, err := stopStore.GetBrevHomePath()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+
+	if !opts.Foreground {
+		cntxt := &daemon.Context{
+			PidFileName: getStopSelfPidFile(brevHome),
+			PidFilePerm: 0o644,
+			LogFileName: getStopSelfLogFile(brevHome),
+			LogFilePerm: 0o640,
+			WorkDir:     brevHome,
+			Umask:       0o27,
+		}
+		d, err := cntxt.Reborn()
+		if err != nil {
+			if errors.Is(err, daemon.ErrWouldBlock) {
+				return breverrors.NewValidationError("a stop is already scheduled, cancel it with: brev stop self --cancel")
+			}
+			return breverrors.WrapAndTrace(err)
+		}
+		if d != nil {
+			t.Vprint(scheduledMessage(t, opts))
+			t.Vprintf("Cancel with: brev stop self --cancel\n")
+			return nil
+		}
+		defer cntxt.Release() //nolint:errcheck // pid file is best effort
+	}
+
+	noteSetupLog(fmt.Sprintf("brev stop self: scheduled to stop %s", describeSchedule(opts)))
+	reason := waitForStopCondition(opts, newProcIdleChecker("/proc"), time.Now, time.Sleep)
+
+	stopper, err := getStopSelfStore(stopStore)
+	if err != nil {
+		noteSetupLog(fmt.Sprintf("brev stop self: could not stop: %v", err))
+		return breverrors.WrapAndTrace(err)
+	}
+	noteSetupLog(fmt.Sprintf("brev stop self: stopping instance, %s", reason))
+	_, err = stopper.StopWorkspace(workspaceID)
+	if err != nil {
+		noteSetupLog(fmt.Sprintf("brev stop self: could not stop: %v", err))
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func scheduledMessage(t *terminal.Terminal, opts StopSelfOptions) string {
+	return t.Green(fmt.Sprintf("Scheduled this instance to stop %s", describeSchedule(opts)))
+}
+
+// CancelStopSelf cancels a scheduled stop, it is not an error if none is scheduled
+func CancelStopSelf(t *terminal.Terminal, stopStore StopStore) error {
+	brevHome, err := stopStore.GetBrevHomePath()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	pid, err := daemon.ReadPidFile(getStopSelfPidFile(brevHome))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			t.Vprint("No stop is scheduled\n")
+			return nil
+		}
+		return breverrors.WrapAndTrace(err)
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = p.Signal(syscall.SIGTERM)
+	if err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			t.Vprint("No stop is scheduled\n")
+			return nil
+		}
+		return breverrors.WrapAndTrace(err)
+	}
+	noteSetupLog("brev stop self: scheduled stop canceled")
+	t.Vprint(t.Green("Canceled the scheduled stop\n"))
+	return nil
+}
+
+func describeSchedule(opts StopSelfOptions) string {
+	parts := []string{}
+	if opts.After > 0 {
+		parts = append(parts, fmt.Sprintf("in %s", opts.After))
+	}
+	if opts.WhenIdle {
+		parts = append(parts, fmt.Sprintf("once idle for %s", opts.IdleFor))
+	}
+	return strings.Join(parts, ", then ")
+}
+
+// noteSetupLog leaves a note in the setup log so 'brev logs' shows why the instance stopped,
+// the log may not be writable by this user so failing is not fatal
+func noteSetupLog(note string) {
+	err := setupworkspace.AppendSetupLogNote(setupworkspace.WorkspaceLogPath, note)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not write to setup log: %v\n", err)
+	}
+	fmt.Println(note)
+}
+
+type idleChecker interface {
+	// IsIdle reports whether nothing is using the instance and if not, why
+	IsIdle() (bool, string, error)
+}
+
+// waitForStopCondition blocks for opts.After and then, with opts.WhenIdle, until the instance
+// has been idle for opts.IdleFor. It returns why the instance should stop now
+func waitForStopCondition(opts StopSelfOptions, checker idleChecker, now func() time.Time, sleep func(time.Duration)) string {
+	if opts.After > 0 {
+		sleep(opts.After)
+	}
+	if !opts.WhenIdle {
+		return fmt.Sprintf("%s passed", opts.After)
+	}
+	var idleSince *time.Time
+	for {
+		idle, busyReason, err := checker.IsIdle()
+		if err != nil {
+			// not knowing is treated as busy so a broken check never stops a working instance
+			fmt.Fprintf(os.Stderr, "could not check if idle: %v\n", err)
+			idle = false
+		}
+		if idle {
+			if idleSince == nil {
+				n := now()
+				idleSince = &n
+			}
+			if now().Sub(*idleSince) >= opts.IdleFor {
+				return fmt.Sprintf("idle for %s", opts.IdleFor)
+			}
+		} else {
+			if idleSince != nil && busyReason != "" {
+				fmt.Printf("no longer idle: %s\n", busyReason)
+			}
+			idleSince = nil
+		}
+		sleep(idleCheckInterval)
+	}
+}
+
+// procIdleChecker counts an instance as idle when nobody is connected over ssh and the load is low
+type procIdleChecker struct {
+	procDir string
+}
+
+func newProcIdleChecker(procDir string) procIdleChecker {
+	return procIdleChecker{procDir: procDir}
+}
+
+func (p procIdleChecker) IsIdle() (bool, string, error) {
+	sessions, err := p.countSSHConnections()
+	if err != nil {
+		return false, "", breverrors.WrapAndTrace(err)
+	}
+	if sessions > 0 {
+		return false, fmt.Sprintf("%d ssh connections", sessions), nil
+	}
+	load, err := p.loadAverage()
+	if err != nil {
+		return false, "", breverrors.WrapAndTrace(err)
+	}
+	if load >= idleLoadThreshold {
+		return false, fmt.Sprintf("load average is %.2f", load), nil
+	}
+	return true, "", nil
+}
+
+func (p procIdleChecker) loadAverage() (float64, error) {
+	b, err := os.ReadFile(filepath.Join(p.procDir, "loadavg"))
+	if err != nil {
+		return 0, breverrors.WrapAndTrace(err)
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty loadavg")
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, breverrors.WrapAndTrace(err)
+	}
+	return load, nil
+}
+
+// countSSHConnections counts established tcp connections to the local ssh port
+func (p procIdleChecker) countSSHConnections() (int, error) {
+	count := 0
+	for _, name := range []string{"tcp", "tcp6"} {
+		n, err := countEstablishedOnPort(filepath.Join(p.procDir, "net", name), sshPort)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return 0, breverrors.WrapAndTrace(err)
+		}
+		count += n
+	}
+	return count, nil
+}
+
+const tcpEstablished = "01"
+
+func countEstablishedOnPort(path string, port int) (int, error) {
+	f, err := os.Open(path) //nolint:gosec // proc file
+	if err != nil {
+		return 0, breverrors.WrapAndTrace(err)
+	}
+	defer f.Close() //nolint:errcheck // read only
+
+	wantPort := fmt.Sprintf("%04X", port)
+	count := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		local := strings.Split(fields[1], ":")
+		if len(local) != 2 {
+			continue
+		}
+		if local[1] == wantPort && fields[3] == tcpEstablished {
+			count++
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return 0, breverrors.WrapAndTrace(err)
+	}
+	return count, nil
+}
@@ -0,0 +1,113 @@
+package stop
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+type scriptedIdleChecker struct {
+	idle  []bool
+	calls int
+}
+
+func (s *scriptedIdleChecker) IsIdle() (bool, string, error) {
+	i := s.calls
+	if i >= len(s.idle) {
+		i = len(s.idle) - 1
+	}
+	s.calls++
+	if !s.idle[i] {
+		return false, "busy", nil
+	}
+	return true, "", nil
+}
+
+type fakeSleeper struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (f *fakeSleeper) Now() time.Time { return f.now }
+
+func (f *fakeSleeper) Sleep(d time.Duration) {
+	f.slept = append(f.slept, d)
+	f.now = f.now.Add(d)
+}
+
+func TestWaitForStopConditionAfter(t *testing.T) {
+	clock := &fakeSleeper{}
+	reason := waitForStopCondition(StopSelfOptions{After: 2 * time.Hour}, nil, clock.Now, clock.Sleep)
+	assert.Equal(t, "2h0m0s passed", reason)
+	assert.Equal(t, []time.Duration{2 * time.Hour}, clock.slept)
+}
+
+func TestWaitForStopConditionWhenIdle(t *testing.T) {
+	clock := &fakeSleeper{}
+	// busy, idle, busy again resets the count, then idle for good
+	checker := &scriptedIdleChecker{idle: []bool{false, true, false, true}}
+	reason := waitForStopCondition(StopSelfOptions{WhenIdle: true, IdleFor: 3 * time.Minute}, checker, clock.Now, clock.Sleep)
+	assert.Equal(t, "idle for 3m0s", reason)
+	// idle from the 4th check, so it takes 3 more minutes to stop
+	assert.Equal(t, 7, checker.calls)
+}
+
+func writeProcFile(t *testing.T, dir string, name string, content string) {
+	path := filepath.Join(dir, name)
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	err = os.WriteFile(path, []byte(content), 0o600)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+}
+
+const procNetTCPHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+func TestProcIdleChecker(t *testing.T) {
+	dir := t.TempDir()
+	writeProcFile(t, dir, "loadavg", "0.05 0.10 0.20 1/100 1234\n")
+	writeProcFile(t, dir, "net/tcp", procNetTCPHeader+
+		// listening on 22, established on 8080
+		"   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1\n"+
+		"   1: 0100007F:1F90 0100007F:C350 01 00000000:00000000 00:00000000 00000000     0        0 2 1\n")
+	checker := newProcIdleChecker(dir)
+
+	idle, _, err := checker.IsIdle()
+	assert.Nil(t, err)
+	assert.True(t, idle)
+
+	writeProcFile(t, dir, "net/tcp6", procNetTCPHeader+
+		"   0: 00000000000000000000000001000000:0016 00000000000000000000000001000000:D431 01 00000000:00000000 00:00000000 00000000     0        0 3 1\n")
+	idle, reason, err := checker.IsIdle()
+	assert.Nil(t, err)
+	assert.False(t, idle)
+	assert.Equal(t, "1 ssh connections", reason)
+
+	os.Remove(filepath.Join(dir, "net/tcp6")) //nolint:errcheck // test
+	writeProcFile(t, dir, "loadavg", "1.50 0.10 0.20 1/100 1234\n")
+	idle, reason, err = checker.IsIdle()
+	assert.Nil(t, err)
+	assert.False(t, idle)
+	assert.Equal(t, "load average is 1.50", reason)
+}
+
+func TestDescribeSchedule(t *testing.T) {
+	assert.Equal(t, "in 1h0m0s", describeSchedule(StopSelfOptions{After: time.Hour}))
+	assert.Equal(t, "in 1h0m0s, then once idle for 30m0s", describeSchedule(StopSelfOptions{After: time.Hour, WhenIdle: true, IdleFor: DefaultIdleFor}))
+}
+
+func TestScheduledMessage(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+	assert.Equal(t, "Scheduled this instance to stop in 2h0m0s", scheduledMessage(terminal.New(), StopSelfOptions{After: 2 * time.Hour}))
+	assert.Equal(t, "Scheduled this instance to stop in 1h0m0s, then once idle for 30m0s", scheduledMessage(terminal.New(), StopSelfOptions{After: time.Hour, WhenIdle: true, IdleFor: DefaultIdleFor}))
+}
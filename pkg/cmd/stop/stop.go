@@ -4,8 +4,11 @@ package stop
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/brevdev/brev-cli/pkg/cmd/bulk"
 	"github.com/brevdev/brev-cli/pkg/cmd/completions"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
 	"github.com/brevdev/brev-cli/pkg/cmd/util"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
@@ -17,21 +20,31 @@ import (
 
 var (
 	stopLong    = "Stop a Brev machine that's in a running state"
-	stopExample = "brev stop <ws_name>... \nbrev stop --all"
+	stopExample = "brev stop <ws_name>... \nbrev stop --all\nbrev stop <ws_name> --wait --timeout 10m\nbrev stop --match 'test-*' --dry-run\nbrev stop --older-than 72h --yes\nbrev stop self --after 2h\nbrev stop self --when-idle --idle-for 1h"
 )
 
 type StopStore interface {
 	completions.CompletionStore
 	util.GetWorkspaceByNameOrIDErrStore
+	util.GetWorkspaceStore
 	StopWorkspace(workspaceID string) (*entity.Workspace, error)
 	GetCurrentUser() (*entity.User, error)
 	IsWorkspace() (bool, error)
 	GetWorkspaces(organizationID string, options *store.GetWorkspacesOptions) ([]entity.Workspace, error)
 	GetCurrentWorkspaceID() (string, error)
+	GetCurrentWorkspaceGroupID() (string, error)
+	GetCurrentWorkspaceServiceToken() (string, error)
+	GetBrevHomePath() (string, error)
+	BuildBrevHome() error
+	WithAuth(auth store.Auth, options ...store.Option) *store.AuthHTTPStore
 }
 
 func NewCmdStop(t *terminal.Terminal, loginStopStore StopStore, noLoginStopStore StopStore) *cobra.Command {
 	var all bool
+	var wait bool
+	var timeout time.Duration
+	var bulkOpts bulk.Options
+	stopSelfOpts := StopSelfOptions{}
 
 	cmd := &cobra.Command{
 		Annotations:           map[string]string{"workspace": ""},
@@ -43,121 +56,167 @@ func NewCmdStop(t *terminal.Terminal, loginStopStore StopStore, noLoginStopStore
 		// Args:                  cmderrors.TransformToValidationError(cobra.ExactArgs()),
 		ValidArgsFunction: completions.GetAllWorkspaceNameCompletionHandler(noLoginStopStore, t),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if all {
-				return stopAllWorkspaces(t, loginStopStore)
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if stopSelfOpts.Cancel {
+				return CancelStopSelf(t, loginStopStore)
+			}
+			if stopSelfOpts.IsScheduled() {
+				if len(args) != 1 || args[0] != "self" {
+					return breverrors.NewValidationError("--after and --when-idle only work with: brev stop self")
+				}
+				err = ScheduleStopSelf(t, loginStopStore, stopSelfOpts)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				return nil
+			}
+
+			var stoppedIDs []string
+			var stopErr error
+			if all || bulkOpts.IsSet() {
+				if len(args) > 0 {
+					return breverrors.NewValidationError("instance names can not be combined with --all or selector flags")
+				}
+				stoppedIDs, stopErr = stopSelectedWorkspaces(t, loginStopStore, confirmOnlySelectors(all, bulkOpts), format)
 			} else {
 				if len(args) == 0 {
 					return breverrors.NewValidationError("please provide a workspace to stop")
 				}
-				var allErr error
+				if wait && stringsContain(args, "self") {
+					return breverrors.NewValidationError("--wait can not be used when stopping self")
+				}
 				for _, arg := range args {
-					err := stopWorkspace(arg, t, loginStopStore)
+					if arg == "self" {
+						err = StopThisWorkspace(loginStopStore, t)
+						if err != nil {
+							stopErr = multierror.Append(stopErr, err)
+						}
+						continue
+					}
+					workspaceID, err := stopWorkspace(arg, t, loginStopStore)
 					if err != nil {
-						allErr = multierror.Append(allErr, err)
+						stopErr = multierror.Append(stopErr, err)
+					} else {
+						stoppedIDs = append(stoppedIDs, workspaceID)
 					}
 				}
-				if allErr != nil {
-					return breverrors.WrapAndTrace(allErr)
+			}
+			if wait {
+				err = waitForStopped(t, loginStopStore, stoppedIDs, timeout, format == output.JSON)
+				if err != nil {
+					stopErr = multierror.Append(stopErr, err)
 				}
 			}
+			if stopErr != nil {
+				return breverrors.WrapAndTrace(stopErr)
+			}
 			return nil
 		},
 	}
-	cmd.Flags().BoolVarP(&all, "all", "a", false, "stop all workspaces")
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "stop all of your running instances")
+	bulkOpts.AddFlags(cmd.Flags())
+	cmd.Flags().BoolVarP(&wait, "wait", "w", false, "wait until the instances are stopped")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "with --wait, give up after this long, e.g. 10m (default no limit)")
+	cmd.Flags().DurationVar(&stopSelfOpts.After, "after", 0, "with self, stop this instance after this long, e.g. 2h")
+	cmd.Flags().BoolVar(&stopSelfOpts.WhenIdle, "when-idle", false, "with self, stop this instance once nobody is connected and nothing is running")
+	cmd.Flags().DurationVar(&stopSelfOpts.IdleFor, "idle-for", DefaultIdleFor, "with --when-idle, how long the instance has to be idle")
+	cmd.Flags().BoolVar(&stopSelfOpts.Foreground, "foreground", false, "with --after or --when-idle, wait in this shell instead of in the background")
+	cmd.Flags().BoolVar(&stopSelfOpts.Cancel, "cancel", false, "cancel a stop scheduled with --after or --when-idle")
 
 	return cmd
 }
 
-func stopAllWorkspaces(t *terminal.Terminal, stopStore StopStore) error {
-	user, err := stopStore.GetCurrentUser()
-	if err != nil {
-		return breverrors.WrapAndTrace(err)
+// confirmOnlySelectors keeps a plain --all stopping your running instances without asking, as it
+// always has, only the selector flags ask for confirmation
+func confirmOnlySelectors(all bool, opts bulk.Options) bulk.Options {
+	if all && !opts.IsSet() {
+		opts.Yes = true
 	}
-	org, err := stopStore.GetActiveOrganizationOrDefault()
-	if err != nil {
-		return breverrors.WrapAndTrace(err)
+	return opts
+}
+
+// stopSelectedWorkspaces stops every selected instance, running ones unless a status is given,
+// and returns the ids of the ones that were stopped even if others failed
+func stopSelectedWorkspaces(t *terminal.Terminal, stopStore StopStore, opts bulk.Options, format output.Format) ([]string, error) {
+	if len(opts.Statuses) == 0 {
+		opts.Statuses = []string{entity.Running}
+	}
+	results, err := bulk.Run(t, stopStore, opts, "stop", format, func(w entity.Workspace) error {
+		_, err := stopStore.StopWorkspace(w.ID)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	})
+	stoppedIDs := []string{}
+	for _, r := range results {
+		if r.OK() {
+			stoppedIDs = append(stoppedIDs, r.ID)
+		}
 	}
-	workspaces, err := stopStore.GetWorkspaces(org.ID, &store.GetWorkspacesOptions{UserID: user.ID})
 	if err != nil {
-		return breverrors.WrapAndTrace(err)
+		return stoppedIDs, breverrors.WrapAndTrace(err)
 	}
-	t.Vprintf("Turning off all of your instances")
-	for _, v := range workspaces {
-		if v.Status == entity.Running {
-			_, err = stopStore.StopWorkspace(v.ID)
-			if err != nil {
-				return breverrors.WrapAndTrace(err)
-			} else {
-				t.Vprintf(t.Green("\n%s stopped ✓", v.Name))
-			}
+	return stoppedIDs, nil
+}
+
+func waitForStopped(t *terminal.Terminal, stopStore StopStore, workspaceIDs []string, timeout time.Duration, jsonEvents bool) error {
+	var allErr error
+	for _, id := range workspaceIDs {
+		_, err := util.WaitForWorkspaceStatus(stopStore, id, util.WaitOptions{
+			For:      []string{entity.Stopped},
+			Timeout:  timeout,
+			Reporter: util.NewWaitReporter(t, jsonEvents, " waiting for instance to stop..."),
+		})
+		if err != nil {
+			allErr = multierror.Append(allErr, err)
 		}
 	}
+	if allErr != nil {
+		return breverrors.WrapAndTrace(allErr)
+	}
+	if !jsonEvents && len(workspaceIDs) > 0 {
+		t.Vprint(t.Green("\nStopped ✓\n"))
+	}
 	return nil
 }
 
-func stopWorkspace(workspaceName string, t *terminal.Terminal, stopStore StopStore) error {
+func stringsContain(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func stopWorkspace(workspaceName string, t *terminal.Terminal, stopStore StopStore) (string, error) {
 	user, err := stopStore.GetCurrentUser()
 	if err != nil {
-		return breverrors.WrapAndTrace(err)
+		return "", breverrors.WrapAndTrace(err)
 	}
 
-	var workspaceID string
-
-	if workspaceName == "self" {
-		wsID, err2 := stopStore.GetCurrentWorkspaceID()
-		if err2 != nil {
-			t.Vprintf("\n Error: %s", t.Red(err2.Error()))
-			return breverrors.WrapAndTrace(err2)
+	workspace, err := util.GetUserWorkspaceByNameOrIDErr(stopStore, workspaceName)
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") || user.GlobalUserType != entity.Admin {
+			return "", breverrors.WrapAndTrace(err)
 		}
-		workspaceID = wsID
-	} else {
-		workspace, err3 := util.GetUserWorkspaceByNameOrIDErr(stopStore, workspaceName)
-		if err3 != nil {
-			if !strings.Contains(err3.Error(), "not found") {
-				return breverrors.WrapAndTrace(err3)
-			} else {
-				if user.GlobalUserType == entity.Admin {
-					fmt.Println("admin trying to stop any instance")
-					workspace, err = util.GetAnyWorkspaceByIDOrNameInActiveOrgErr(stopStore, workspaceName)
-					if err != nil {
-						return breverrors.WrapAndTrace(err)
-					}
-				} else {
-					return breverrors.WrapAndTrace(err)
-				}
-			}
+		fmt.Println("admin trying to stop any instance")
+		workspace, err = util.GetAnyWorkspaceByIDOrNameInActiveOrgErr(stopStore, workspaceName)
+		if err != nil {
+			return "", breverrors.WrapAndTrace(err)
 		}
-		workspaceID = workspace.ID
 	}
 
-	_, err = stopStore.StopWorkspace(workspaceID)
+	_, err = stopStore.StopWorkspace(workspace.ID)
 	if err != nil {
-		return breverrors.WrapAndTrace(err)
-	} else {
-		if workspaceName == "self" {
-			t.Vprintf(t.Green("Stopping this instance\n") +
-				"Note: this can take a few seconds. Run 'brev ls' to check status\n")
-		} else {
-			t.Vprintf(t.Green("Stopping instance "+workspaceName+".\n") +
-				"Note: this can take a few seconds. Run 'brev ls' to check status\n")
-		}
+		return "", breverrors.WrapAndTrace(err)
 	}
+	t.Vprintf(t.Green("Stopping instance "+workspaceName+".\n") +
+		"Note: this can take a few seconds. Run 'brev ls' to check status\n")
 
-	return nil
-}
-
-func StopThisWorkspace(store StopStore, _ *terminal.Terminal) error {
-	isWorkspace, err := store.IsWorkspace()
-	if err != nil {
-		return breverrors.WrapAndTrace(err)
-	}
-	if isWorkspace {
-		_ = 0
-		// get current workspace
-		// stopWorkspace("")
-		// stop the workspace
-	} else {
-		return breverrors.NewValidationError("this is not a workspace -- please provide a workspace id")
-	}
-	return nil
+	return workspace.ID, nil
 }
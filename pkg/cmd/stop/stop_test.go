@@ -2,9 +2,26 @@ package stop
 
 import (
 	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/bulk"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestStopWorkspaceSelf(_ *testing.T) {
 	// err := stopThisWorkspace(nil, nil)
 	// assert.Nil(t, err)
 }
+
+func TestConfirmOnlySelectors(t *testing.T) {
+	opts := confirmOnlySelectors(true, bulk.Options{Selector: bulk.Selector{Creator: bulk.CreatorMe}})
+	assert.True(t, opts.Yes, "plain --all never asked")
+
+	opts = confirmOnlySelectors(true, bulk.Options{Selector: bulk.Selector{Name: "test-*"}})
+	assert.False(t, opts.Yes, "--all with a selector asks")
+
+	opts = confirmOnlySelectors(false, bulk.Options{Selector: bulk.Selector{Name: "test-*"}})
+	assert.False(t, opts.Yes)
+
+	opts = confirmOnlySelectors(false, bulk.Options{Selector: bulk.Selector{Name: "test-*"}, Yes: true})
+	assert.True(t, opts.Yes)
+}
@@ -0,0 +1,316 @@
+// Package tasks runs the background tasks installed as system services
+package tasks
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/ssh"
+	brevtasks "github.com/brevdev/brev-cli/pkg/tasks"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+const daemonStopTimeout = 10 * time.Second
+
+var (
+	tasksLong    = "Run the background tasks that keep brev configured, such as updating your ssh config as instances start and stop"
+	tasksExample = `
+  brev tasks run sshcd
+  brev tasks start
+  brev tasks status
+  brev tasks trigger sshcd
+  brev tasks logs
+  brev tasks stop
+	`
+)
+
+type TaskStore interface {
+	ssh.SSHConfigurerTaskStore
+	brevtasks.RunTaskAsDaemonStore
+}
+
+// NewTaskRegistry holds every task that can be run by name, the names are used by
+// the services installed by autostartconf. The vpn and rpc daemons are not part of this
+// tree, so the vpnd and rpcd services fail with a hint to disable them
+func NewTaskRegistry(store TaskStore) *brevtasks.Registry {
+	return brevtasks.NewRegistry().
+		Register("sshcd", ssh.NewSSHConfigurerTask(store)).
+		Unsupported("vpnd", "there is no vpn daemon, disable the brevvpnd service that runs it").
+		Unsupported("rpcd", "there is no rpc daemon, disable the brevrpcd service that runs it")
+}
+
+func NewCmdTasks(t *terminal.Terminal, loginTaskStore TaskStore, noLoginTaskStore TaskStore) *cobra.Command {
+	cmd := &cobra.Command{
+		Annotations: map[string]string{"housekeeping": ""},
+		Use:         "tasks",
+		Short:       "Run and manage brev background tasks",
+		Long:        tasksLong,
+		Example:     tasksExample,
+		Args:        cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := cmd.Usage()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.AddCommand(newCmdRun(loginTaskStore))
+	cmd.AddCommand(newCmdStart(loginTaskStore))
+	cmd.AddCommand(newCmdTrigger(t, noLoginTaskStore))
+	cmd.AddCommand(newCmdStop(t, noLoginTaskStore))
+	cmd.AddCommand(newCmdStatus(t, noLoginTaskStore))
+	cmd.AddCommand(newCmdLogs(t, noLoginTaskStore))
+	return cmd
+}
+
+func newCmdRun(store TaskStore) *cobra.Command {
+	registry := NewTaskRegistry(store)
+	cmd := &cobra.Command{
+		Use:       "run <name>",
+		Short:     "Run a task in the foreground until interrupted",
+		Long:      "Run a task in the foreground until interrupted, this is what the installed services run. It can be managed with brev tasks status, trigger and stop like the daemon",
+		Args:      cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgs: registry.Names(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selected, err := registry.Select(args)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			err = store.BuildBrevHome()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			err = brevtasks.RunTasksWithControlSocket(selected, store.GetServerSockFile())
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	// the rpcd services pass the user to run as, accepted so they get the hint of the registry
+	cmd.Flags().String("user", "", "")
+	_ = cmd.Flags().MarkHidden("user")
+	return cmd
+}
+
+func newCmdStart(store TaskStore) *cobra.Command {
+	registry := NewTaskRegistry(store)
+	cmd := &cobra.Command{
+		Use:       "start [name...]",
+		Short:     "Run tasks in a background daemon, all tasks if none are given",
+		Args:      cmderrors.TransformToValidationError(cobra.ArbitraryArgs),
+		ValidArgs: registry.Names(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selected, err := registry.Select(args)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			err = brevtasks.RunTaskAsDaemon(selected, store)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func newCmdTrigger(t *terminal.Terminal, store TaskStore) *cobra.Command {
+	registry := NewTaskRegistry(store)
+	cmd := &cobra.Command{
+		Use:       "trigger <name>",
+		Short:     "Run a task in the daemon now instead of waiting for its schedule",
+		Args:      cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgs: registry.Names(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := brevtasks.NewControlClient(store.GetServerSockFile()).Trigger(args[0])
+			if err != nil {
+				return breverrors.WrapAndTrace(err, "is the task daemon running? start it with: brev tasks start")
+			}
+			t.Vprintf(t.Green("triggered %s\n", args[0]))
+			return nil
+		},
+	}
+	return cmd
+}
+
+func newCmdStop(t *terminal.Terminal, store TaskStore) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the background task daemon",
+		Args:  cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stopped, err := stopDaemon(store)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if !stopped {
+				t.Vprintf("task daemon is not running\n")
+				return nil
+			}
+			t.Vprintf(t.Green("task daemon stopped\n"))
+			return nil
+		},
+	}
+	return cmd
+}
+
+// stopDaemon asks the daemon to stop over its control socket so running tasks can finish,
+// falling back to a signal if the socket is not there
+func stopDaemon(store TaskStore) (bool, error) {
+	brevHome, err := store.GetBrevHomePath()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	err = brevtasks.NewControlClient(store.GetServerSockFile()).Stop()
+	if err != nil {
+		stopped, err := brevtasks.StopDaemon(brevHome, daemonStopTimeout)
+		if err != nil {
+			return false, breverrors.WrapAndTrace(err)
+		}
+		return stopped, nil
+	}
+	deadline := time.Now().Add(daemonStopTimeout)
+	for time.Now().Before(deadline) {
+		p, err := brevtasks.GetRunningDaemon(brevHome)
+		if err != nil {
+			return false, breverrors.WrapAndTrace(err)
+		}
+		if p == nil {
+			return true, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false, breverrors.NewValidationError("timed out waiting for the task daemon to stop")
+}
+
+func newCmdStatus(t *terminal.Terminal, store TaskStore) *cobra.Command {
+	registry := NewTaskRegistry(store)
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the task daemon and when each task last ran",
+		Args:  cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			status, running, err := getDaemonStatus(store, registry)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if format.IsMachineReadable() {
+				err = output.Write(os.Stdout, format, status)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				return nil
+			}
+			displayDaemonStatus(t, status, running)
+			return nil
+		},
+	}
+	return cmd
+}
+
+// getDaemonStatus asks the daemon for its status, if it is not reachable the registered
+// tasks are listed without any run information
+func getDaemonStatus(store TaskStore, registry *brevtasks.Registry) (*brevtasks.DaemonStatus, bool, error) {
+	status, err := brevtasks.NewControlClient(store.GetServerSockFile()).Status()
+	if err == nil {
+		return status, true, nil
+	}
+	brevHome, err := store.GetBrevHomePath()
+	if err != nil {
+		return nil, false, breverrors.WrapAndTrace(err)
+	}
+	p, err := brevtasks.GetRunningDaemon(brevHome)
+	if err != nil {
+		return nil, false, breverrors.WrapAndTrace(err)
+	}
+	status = &brevtasks.DaemonStatus{Tasks: []brevtasks.TaskStatus{}}
+	if p != nil {
+		status.Pid = p.Pid
+	}
+	for _, name := range registry.Names() {
+		task, _ := registry.Get(name)
+		status.Tasks = append(status.Tasks, brevtasks.TaskStatus{Name: name, Cron: task.GetTaskSpec().Cron})
+	}
+	return status, p != nil, nil
+}
+
+func displayDaemonStatus(t *terminal.Terminal, status *brevtasks.DaemonStatus, running bool) {
+	switch {
+	case running && !status.StartedAt.IsZero():
+		t.Vprintf("task daemon: %s (pid %d, since %s)\n\n", t.Green("running"), status.Pid, status.StartedAt.Local().Format(time.RFC1123))
+	case running:
+		t.Vprintf("task daemon: %s (pid %d, control socket unavailable)\n\n", t.Yellow("running"), status.Pid)
+	default:
+		t.Vprintf("task daemon: %s\n\n", t.Yellow("not running"))
+	}
+
+	ta := table.NewWriter()
+	ta.SetOutputMirror(os.Stdout)
+	ta.Style().Options = util.GetBrevTableOptions()
+	ta.AppendHeader(table.Row{"NAME", "SCHEDULE", "LAST RUN", "NEXT RUN", "LAST ERROR"})
+	for _, s := range status.Tasks {
+		lastRun := formatTime(s.LastRun)
+		if s.Running {
+			lastRun = t.Green("running")
+		}
+		ta.AppendRow(table.Row{s.Name, formatCron(s.Cron), lastRun, formatTime(s.NextRun), t.Red(s.LastError)})
+	}
+	ta.Render()
+}
+
+func formatTime(ti *time.Time) string {
+	if ti == nil {
+		return "-"
+	}
+	return ti.Local().Format(time.TimeOnly)
+}
+
+func newCmdLogs(t *terminal.Terminal, store TaskStore) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Print the task daemon log",
+		Args:  cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			brevHome, err := store.GetBrevHomePath()
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			logFile := brevtasks.GetDaemonLogFilePath(brevHome)
+			exists, err := store.FileExists(logFile)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if !exists {
+				t.Vprintf("no task daemon log at %s, start the daemon with: brev tasks start\n", logFile)
+				return nil
+			}
+			logs, err := store.GetFileAsString(logFile)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			fmt.Print(logs)
+			return nil
+		},
+	}
+	return cmd
+}
+
+func formatCron(cron string) string {
+	if cron == "" {
+		return "once"
+	}
+	return cron
+}
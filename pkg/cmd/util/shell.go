@@ -0,0 +1,8 @@
+package util
+
+import "strings"
+
+// ShellQuote single quotes s so a remote shell takes it as one word, whatever it contains
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,14 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'/home/ubuntu/repo'`, ShellQuote("/home/ubuntu/repo"))
+	assert.Equal(t, `'my repo; rm -rf ~'`, ShellQuote("my repo; rm -rf ~"))
+	assert.Equal(t, `'it'"'"'s'`, ShellQuote("it's"))
+	assert.Equal(t, `''`, ShellQuote(""))
+}
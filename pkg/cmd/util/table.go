@@ -0,0 +1,13 @@
+package util
+
+import "github.com/jedib0t/go-pretty/v6/table"
+
+// GetBrevTableOptions is the borderless style of the tables brev prints
+func GetBrevTableOptions() table.Options {
+	options := table.OptionsDefault
+	options.DrawBorder = false
+	options.SeparateColumns = false
+	options.SeparateRows = false
+	options.SeparateHeader = false
+	return options
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
 	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
 )
 
 type GetWorkspaceByNameOrIDErrStore interface {
@@ -60,6 +61,23 @@ func GetAnyWorkspaceByIDOrNameInActiveOrgErr(storeQ GetWorkspaceByNameOrIDErrSto
 	return &workspaces[0], nil
 }
 
+type GetWorkspaceStore interface {
+	GetWorkspace(workspaceID string) (*entity.Workspace, error)
+}
+
+// WaitForWorkspaceRunning polls until the instance is running and errors out
+// if it ends up failed or deleting instead
+func WaitForWorkspaceRunning(t *terminal.Terminal, storeQ GetWorkspaceStore, workspaceID string) (*entity.Workspace, error) {
+	workspace, err := WaitForWorkspaceStatus(storeQ, workspaceID, WaitOptions{
+		For:      []string{entity.Running},
+		Reporter: NewSpinnerWaitReporter(t, " waiting for instance to be ready..."),
+	})
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return workspace, nil
+}
+
 type MakeWorkspaceWithMetaStore interface {
 	GetWorkspaceMetaData(workspaceID string) (*entity.WorkspaceMetaData, error)
 }
@@ -0,0 +1,267 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/briandowns/spinner"
+)
+
+// Deleted is never reported by the API, an instance counts as deleted once it can no longer be found
+const Deleted = "DELETED"
+
+const DefaultWaitInterval = 5 * time.Second
+
+var waitableStatuses = []string{
+	entity.Running, entity.Starting, entity.Stopping, entity.Deploying,
+	entity.Stopped, entity.Deleting, entity.Failure, Deleted,
+}
+
+// ParseWaitStatuses turns user input like "running,stopped" into instance statuses
+func ParseWaitStatuses(in []string) ([]string, error) {
+	statuses := []string{}
+	for _, s := range in {
+		status := strings.ToUpper(strings.TrimSpace(s))
+		if status == "" {
+			continue
+		}
+		known := false
+		for _, w := range waitableStatuses {
+			if status == w {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, breverrors.NewValidationError(fmt.Sprintf("unknown instance status %s, use one of: %s", s, strings.ToLower(strings.Join(waitableStatuses, ", "))))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// DefaultFailureStatuses are the statuses an instance can't come back from on its own
+func DefaultFailureStatuses(target []string) []string {
+	failures := []string{}
+	for _, f := range []string{entity.Failure, entity.Deleting, Deleted} {
+		if containsStatus(target, f) {
+			continue
+		}
+		// an instance passes through deleting on its way to deleted
+		if f == entity.Deleting && containsStatus(target, Deleted) {
+			continue
+		}
+		failures = append(failures, f)
+	}
+	return failures
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+type WaitEventType string
+
+const (
+	WaitEventStatus  WaitEventType = "status"
+	WaitEventReady   WaitEventType = "ready"
+	WaitEventFailed  WaitEventType = "failed"
+	WaitEventTimeout WaitEventType = "timeout"
+)
+
+// WaitEvent is emitted on every status change and once when the wait ends
+type WaitEvent struct {
+	Type           WaitEventType `json:"type"`
+	Time           time.Time     `json:"time"`
+	WorkspaceID    string        `json:"workspaceId"`
+	Name           string        `json:"name,omitempty"`
+	Status         string        `json:"status"`
+	PreviousStatus string        `json:"previousStatus,omitempty"`
+}
+
+type WaitReporter interface {
+	Report(e WaitEvent)
+	Close()
+}
+
+type spinnerWaitReporter struct {
+	once sync.Once
+	s    *spinner.Spinner
+}
+
+// NewSpinnerWaitReporter shows the current status next to a spinner
+func NewSpinnerWaitReporter(t *terminal.Terminal, suffix string) WaitReporter {
+	s := t.NewSpinner()
+	s.Suffix = suffix
+	s.Start()
+	return &spinnerWaitReporter{s: s}
+}
+
+func (r *spinnerWaitReporter) Report(e WaitEvent) {
+	if e.Type == WaitEventStatus {
+		r.s.Suffix = "  instance is " + strings.ToLower(e.Status)
+	}
+}
+
+func (r *spinnerWaitReporter) Close() {
+	r.once.Do(r.s.Stop)
+}
+
+type jsonWaitReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONWaitReporter writes every event as a line of json, for scripts and CI
+func NewJSONWaitReporter(w io.Writer) WaitReporter {
+	return &jsonWaitReporter{enc: json.NewEncoder(w)}
+}
+
+func (r *jsonWaitReporter) Report(e WaitEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(e)
+}
+
+func (r *jsonWaitReporter) Close() {}
+
+// NewWaitReporter picks json lines for machine readable output and a spinner otherwise
+func NewWaitReporter(t *terminal.Terminal, jsonEvents bool, suffix string) WaitReporter {
+	if jsonEvents {
+		return NewJSONWaitReporter(os.Stdout)
+	}
+	return NewSpinnerWaitReporter(t, suffix)
+}
+
+type WaitOptions struct {
+	// For are the statuses that end the wait successfully
+	For []string
+	// FailOn are the statuses that end the wait with an error, defaults to DefaultFailureStatuses
+	FailOn []string
+	// Timeout of 0 waits forever
+	Timeout  time.Duration
+	Interval time.Duration
+	Reporter WaitReporter
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+type WaitTimeoutError struct {
+	Name    string
+	Status  string
+	For     []string
+	Timeout time.Duration
+}
+
+func (e WaitTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for instance %s to be %s, it is %s",
+		e.Timeout, e.Name, strings.ToLower(strings.Join(e.For, " or ")), strings.ToLower(e.Status))
+}
+
+type WaitFailedError struct {
+	Name   string
+	Status string
+}
+
+func (e WaitFailedError) Error() string {
+	return fmt.Sprintf("instance %s is %s", e.Name, strings.ToLower(e.Status))
+}
+
+// WaitForWorkspaceStatus polls the instance until it reaches one of opts.For, reporting every
+// status change. The returned workspace is nil if the instance was deleted
+func WaitForWorkspaceStatus(storeQ GetWorkspaceStore, workspaceID string, opts WaitOptions) (*entity.Workspace, error) {
+	if len(opts.For) == 0 {
+		return nil, breverrors.NewValidationError("no status to wait for")
+	}
+	if opts.FailOn == nil {
+		opts.FailOn = DefaultFailureStatuses(opts.For)
+	}
+	if opts.Interval == 0 {
+		opts.Interval = DefaultWaitInterval
+	}
+	if opts.now == nil {
+		opts.now = time.Now
+	}
+	if opts.sleep == nil {
+		opts.sleep = time.Sleep
+	}
+	report := func(e WaitEvent) {
+		if opts.Reporter != nil {
+			e.Time = opts.now()
+			opts.Reporter.Report(e)
+		}
+	}
+	if opts.Reporter != nil {
+		defer opts.Reporter.Close()
+	}
+
+	deadline := opts.now().Add(opts.Timeout)
+	name := workspaceID
+	previous := ""
+	for {
+		workspace, status, err := getWorkspaceStatus(storeQ, workspaceID)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		if workspace != nil {
+			name = workspace.Name
+		}
+		event := WaitEvent{WorkspaceID: workspaceID, Name: name, Status: status, PreviousStatus: previous}
+		if status != previous {
+			event.Type = WaitEventStatus
+			report(event)
+			previous = status
+		}
+
+		switch {
+		case containsStatus(opts.For, status):
+			event.Type = WaitEventReady
+			report(event)
+			return workspace, nil
+		case containsStatus(opts.FailOn, status):
+			event.Type = WaitEventFailed
+			report(event)
+			return nil, breverrors.WrapAndTrace(WaitFailedError{Name: name, Status: status})
+		}
+
+		wait := opts.Interval
+		if opts.Timeout > 0 {
+			remaining := deadline.Sub(opts.now())
+			if remaining <= 0 {
+				event.Type = WaitEventTimeout
+				report(event)
+				return nil, breverrors.WrapAndTrace(WaitTimeoutError{Name: name, Status: status, For: opts.For, Timeout: opts.Timeout})
+			}
+			if remaining < wait {
+				wait = remaining
+			}
+		}
+		opts.sleep(wait)
+	}
+}
+
+func getWorkspaceStatus(storeQ GetWorkspaceStore, workspaceID string) (*entity.Workspace, string, error) {
+	workspace, err := storeQ.GetWorkspace(workspaceID)
+	if err != nil {
+		if store.IsNetworkErrorWithStatus(err, []int{404}) {
+			return nil, Deleted, nil
+		}
+		return nil, "", breverrors.WrapAndTrace(err)
+	}
+	return workspace, workspace.Status, nil
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// statusSequenceStore returns the statuses in order, repeating the last one
+type statusSequenceStore struct {
+	statuses []string
+	calls    int
+}
+
+func (s *statusSequenceStore) GetWorkspace(workspaceID string) (*entity.Workspace, error) {
+	i := s.calls
+	if i >= len(s.statuses) {
+		i = len(s.statuses) - 1
+	}
+	s.calls++
+	if s.statuses[i] == Deleted {
+		return nil, store.NewHTTPResponseError(&resty.Response{RawResponse: &http.Response{StatusCode: 404}})
+	}
+	return &entity.Workspace{ID: workspaceID, Name: "my-instance", Status: s.statuses[i]}, nil
+}
+
+type recordingReporter struct {
+	events []WaitEvent
+	closed bool
+}
+
+func (r *recordingReporter) Report(e WaitEvent) { r.events = append(r.events, e) }
+
+func (r *recordingReporter) Close() { r.closed = true }
+
+func (r *recordingReporter) types() []WaitEventType {
+	types := []WaitEventType{}
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+// fakeTime moves forward only when slept on
+type fakeTime struct {
+	now time.Time
+}
+
+func (f *fakeTime) options(opts WaitOptions) WaitOptions {
+	opts.now = func() time.Time { return f.now }
+	opts.sleep = func(d time.Duration) { f.now = f.now.Add(d) }
+	return opts
+}
+
+func TestWaitForWorkspaceStatusReady(t *testing.T) {
+	s := &statusSequenceStore{statuses: []string{entity.Deploying, entity.Deploying, entity.Starting, entity.Running}}
+	r := &recordingReporter{}
+	clock := &fakeTime{}
+
+	ws, err := WaitForWorkspaceStatus(s, "id", clock.options(WaitOptions{For: []string{entity.Running}, Reporter: r}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, entity.Running, ws.Status)
+	assert.Equal(t, []WaitEventType{WaitEventStatus, WaitEventStatus, WaitEventStatus, WaitEventReady}, r.types())
+	assert.Equal(t, entity.Starting, r.events[1].Status)
+	assert.Equal(t, entity.Deploying, r.events[1].PreviousStatus)
+	assert.True(t, r.closed)
+	assert.Equal(t, 3*DefaultWaitInterval, clock.now.Sub(time.Time{}))
+}
+
+func TestWaitForWorkspaceStatusFailure(t *testing.T) {
+	s := &statusSequenceStore{statuses: []string{entity.Deploying, entity.Failure}}
+	r := &recordingReporter{}
+
+	_, err := WaitForWorkspaceStatus(s, "id", (&fakeTime{}).options(WaitOptions{For: []string{entity.Running}, Reporter: r}))
+	if assert.Error(t, err) {
+		var failed WaitFailedError
+		assert.True(t, errors.As(err, &failed))
+		assert.Equal(t, entity.Failure, failed.Status)
+	}
+	assert.Equal(t, WaitEventFailed, r.events[len(r.events)-1].Type)
+}
+
+func TestWaitForWorkspaceStatusTimeout(t *testing.T) {
+	s := &statusSequenceStore{statuses: []string{entity.Starting}}
+	clock := &fakeTime{}
+
+	_, err := WaitForWorkspaceStatus(s, "id", clock.options(WaitOptions{For: []string{entity.Running}, Timeout: 12 * time.Second}))
+	if assert.Error(t, err) {
+		var timeout WaitTimeoutError
+		assert.True(t, errors.As(err, &timeout))
+		assert.Contains(t, err.Error(), "timed out after 12s waiting for instance my-instance to be running, it is starting")
+	}
+	// slept 5s, 5s, then the last 2s before giving up
+	assert.Equal(t, 12*time.Second, clock.now.Sub(time.Time{}))
+	assert.Equal(t, 4, s.calls)
+}
+
+func TestWaitForWorkspaceStatusDeleted(t *testing.T) {
+	s := &statusSequenceStore{statuses: []string{entity.Running, entity.Deleting, Deleted}}
+
+	ws, err := WaitForWorkspaceStatus(s, "id", (&fakeTime{}).options(WaitOptions{For: []string{Deleted}}))
+	assert.Nil(t, err)
+	assert.Nil(t, ws)
+}
+
+func TestDefaultFailureStatuses(t *testing.T) {
+	assert.Equal(t, []string{entity.Failure, entity.Deleting, Deleted}, DefaultFailureStatuses([]string{entity.Running}))
+	assert.Equal(t, []string{entity.Failure}, DefaultFailureStatuses([]string{Deleted}))
+}
+
+func TestParseWaitStatuses(t *testing.T) {
+	statuses, err := ParseWaitStatuses([]string{"running", " Stopped", ""})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{entity.Running, entity.Stopped}, statuses)
+
+	_, err = ParseWaitStatuses([]string{"sleeping"})
+	assert.Error(t, err)
+}
+
+func TestJSONWaitReporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := NewJSONWaitReporter(buf)
+	r.Report(WaitEvent{Type: WaitEventStatus, WorkspaceID: "id", Status: entity.Starting})
+	r.Report(WaitEvent{Type: WaitEventReady, WorkspaceID: "id", Status: entity.Running})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Contains(t, lines[0], `"type":"status"`)
+		assert.Contains(t, lines[1], `"status":"RUNNING"`)
+	}
+}
@@ -0,0 +1,98 @@
+// Package wait is for blocking until a Brev instance reaches a status
+package wait
+
+import (
+	"strings"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/completions"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/cmd/util"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	waitLong = `Wait until an instance reaches one of the given statuses.
+Exits with an error if the instance fails, is deleted, or the timeout passes first.
+Use --output json to get every status change as a line of json`
+	waitExample = `
+  brev wait <NAME> --for=running --timeout=10m
+  brev wait <NAME> --for=stopped
+  brev wait <NAME> --for=deleted --output json
+	`
+)
+
+type WaitStore interface {
+	completions.CompletionStore
+	util.GetWorkspaceByNameOrIDErrStore
+	util.GetWorkspaceStore
+}
+
+func NewCmdWait(t *terminal.Terminal, loginWaitStore WaitStore, noLoginWaitStore WaitStore) *cobra.Command {
+	var forStatuses []string
+	var failOn []string
+	var timeout time.Duration
+	var interval time.Duration
+
+	cmd := &cobra.Command{
+		Annotations:           map[string]string{"workspace": ""},
+		Use:                   "wait",
+		DisableFlagsInUseLine: true,
+		Short:                 "Wait for an instance to reach a status",
+		Long:                  waitLong,
+		Example:               waitExample,
+		Args:                  cmderrors.TransformToValidationError(cobra.ExactArgs(1)),
+		ValidArgsFunction:     completions.GetAllWorkspaceNameCompletionHandler(noLoginWaitStore, t),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			target, err := util.ParseWaitStatuses(forStatuses)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			opts := util.WaitOptions{For: target, Timeout: timeout, Interval: interval}
+			if cmd.Flags().Changed("fail-on") {
+				opts.FailOn, err = util.ParseWaitStatuses(failOn)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+			}
+			err = RunWait(t, loginWaitStore, args[0], opts, format == output.JSON)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&forStatuses, "for", []string{"running"}, "statuses to wait for: running, stopped, deleted, ...")
+	cmd.Flags().StringSliceVar(&failOn, "fail-on", nil, "statuses that end the wait with an error (default failure, deleting and deleted unless waited for)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "give up after this long, e.g. 10m (default no limit)")
+	cmd.Flags().DurationVar(&interval, "interval", util.DefaultWaitInterval, "how often to check the instance status")
+
+	return cmd
+}
+
+func RunWait(t *terminal.Terminal, waitStore WaitStore, workspaceNameOrID string, opts util.WaitOptions, jsonEvents bool) error {
+	workspace, err := util.GetUserWorkspaceByNameOrIDErr(waitStore, workspaceNameOrID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	opts.Reporter = util.NewWaitReporter(t, jsonEvents, " waiting for instance "+workspace.Name)
+	final, err := util.WaitForWorkspaceStatus(waitStore, workspace.ID, opts)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if !jsonEvents {
+		status := util.Deleted
+		if final != nil {
+			status = final.Status
+		}
+		t.Vprint(t.Green("Instance " + workspace.Name + " is " + strings.ToLower(status) + "\n"))
+	}
+	return nil
+}
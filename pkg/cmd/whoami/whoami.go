@@ -0,0 +1,237 @@
+// Package whoami shows who brev is logged in as
+package whoami
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/auth"
+	"github.com/brevdev/brev-cli/pkg/cmd/cmderrors"
+	"github.com/brevdev/brev-cli/pkg/cmd/output"
+	"github.com/brevdev/brev-cli/pkg/config"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/terminal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	whoamiLong = `Show the user and org brev is logged in as, when the access token expires, the scopes it
+was granted and where it comes from: an api key in the env, or the login saved in a credentials
+file or credential helper. Nothing is changed, an expired token is only refreshed to look up the
+user`
+	whoamiExample = `
+  brev whoami
+  brev whoami --output json | jq -r .token.expiresAt
+	`
+)
+
+type WhoamiStore interface {
+	GetCurrentUser() (*entity.User, error)
+	GetActiveOrganizationOrDefault() (*entity.Organization, error)
+	GetAuthTokens() (*entity.AuthTokens, error)
+	GetCredentialStore() (store.CredentialStore, error)
+}
+
+// WhoamiAuth is the auth the store makes its requests with, so whoami reports the token they use
+type WhoamiAuth interface {
+	// GetAccessToken is empty when not logged in
+	GetAccessToken() (string, error)
+	UsesEnvClientCredentials() (clientID string, ok bool)
+}
+
+// SourceEnv is the Source of an api key from the env, a saved login has the credential_store
+// backend it is kept in
+const SourceEnv = "env"
+
+// Whoami is also the json output, keep field names stable
+type Whoami struct {
+	User            *User           `json:"user"`
+	UserError       string          `json:"userError,omitempty"`
+	Org             *Org            `json:"org"`
+	Profile         string          `json:"profile"`
+	Token           *auth.TokenInfo `json:"token"`
+	TokenExpired    bool            `json:"tokenExpired"`
+	MissingScopes   []string        `json:"missingScopes"`
+	HasRefreshToken bool            `json:"hasRefreshToken"`
+	APIKeyClientID  string          `json:"apiKeyClientId,omitempty"`
+	Source          string          `json:"source"`
+	Credentials     string          `json:"credentials"`
+}
+
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+}
+
+type Org struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func NewCmdWhoami(t *terminal.Terminal, whoamiStore WhoamiStore, whoamiAuth WhoamiAuth) *cobra.Command {
+	cmd := &cobra.Command{
+		Annotations:           map[string]string{"housekeeping": ""},
+		Use:                   "whoami",
+		DisableFlagsInUseLine: true,
+		Short:                 "Show who you are logged in as",
+		Long:                  whoamiLong,
+		Example:               whoamiExample,
+		Args:                  cmderrors.TransformToValidationError(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := output.GetFormat(cmd)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			w, err := GetWhoami(whoamiStore, whoamiAuth, time.Now())
+			if _, ok := err.(*breverrors.CredentialsFileNotFound); ok { //nolint:errorlint // returned unwrapped
+				return breverrors.NewValidationError("not logged in, log in with: brev login")
+			}
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+			if format.IsMachineReadable() {
+				err = output.Write(os.Stdout, format, w)
+				if err != nil {
+					return breverrors.WrapAndTrace(err)
+				}
+				return nil
+			}
+			displayWhoami(os.Stdout, t, w, time.Now())
+			return nil
+		},
+	}
+	return cmd
+}
+
+// GetWhoami returns *breverrors.CredentialsFileNotFound when not logged in. A user that can't be
+// looked up is reported in UserError so the token can still be inspected
+func GetWhoami(whoamiStore WhoamiStore, whoamiAuth WhoamiAuth, now time.Time) (*Whoami, error) {
+	// the token every other command would use, refreshed or minted if needed
+	token, err := whoamiAuth.GetAccessToken()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if token == "" {
+		return nil, &breverrors.CredentialsFileNotFound{}
+	}
+	w := Whoami{Profile: files.GetActiveProfile(), MissingScopes: []string{}}
+
+	user, err := whoamiStore.GetCurrentUser()
+	if err != nil {
+		w.UserError = err.Error()
+	} else {
+		w.User = &User{ID: user.ID, Username: user.Username, Name: user.Name, Email: user.Email}
+		org, err := whoamiStore.GetActiveOrganizationOrDefault()
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		if org != nil {
+			w.Org = &Org{ID: org.ID, Name: org.Name}
+		}
+	}
+
+	info, err := auth.IntrospectAccessToken(token)
+	if err == nil {
+		w.Token = info
+		w.TokenExpired = info.IsExpired(now)
+		w.MissingScopes = info.MissingScopes()
+	}
+
+	credentialStore, err := whoamiStore.GetCredentialStore()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	w.Credentials = credentialStore.Location()
+
+	if clientID, ok := whoamiAuth.UsesEnvClientCredentials(); ok {
+		w.Source = SourceEnv
+		w.APIKeyClientID = clientID
+		return &w, nil
+	}
+	w.Source = credentialStoreSource(credentialStore)
+	tokens, err := whoamiStore.GetAuthTokens()
+	if err != nil {
+		return nil, err //nolint:wrapcheck // CredentialsFileNotFound has the directive to log in
+	}
+	// a token login saves this placeholder in place of the token it didn't get
+	w.HasRefreshToken = tokens.RefreshToken != "" && tokens.RefreshToken != "auto-login"
+	w.APIKeyClientID = tokens.ClientID
+	return &w, nil
+}
+
+func credentialStoreSource(credentialStore store.CredentialStore) string {
+	switch credentialStore.(type) {
+	case store.EncryptedFileCredentialStore:
+		return config.CredentialStoreEncryptedFile
+	case store.HelperCredentialStore:
+		return config.CredentialStoreHelper
+	default:
+		return config.CredentialStoreFile
+	}
+}
+
+func displayWhoami(out io.Writer, t *terminal.Terminal, w *Whoami, now time.Time) {
+	row := func(label string, value string) {
+		fmt.Fprintf(out, "%-15s %s\n", label+":", value)
+	}
+	if w.User != nil {
+		row("user", fmt.Sprintf("%s <%s> (%s)", w.User.Username, w.User.Email, w.User.ID))
+	} else {
+		row("user", t.Red("could not be looked up: "+w.UserError))
+	}
+	if w.Org != nil {
+		row("org", fmt.Sprintf("%s (%s)", w.Org.Name, w.Org.ID))
+	} else {
+		row("org", "none")
+	}
+	row("profile", w.Profile)
+	switch w.Source {
+	case SourceEnv:
+		idVar, _ := config.ClientCredentialsEnvVars()
+		row("auth", "api key from "+idVar)
+	case config.CredentialStoreEncryptedFile:
+		row("auth", "encrypted credentials file")
+	case config.CredentialStoreHelper:
+		row("auth", "credential helper")
+	default:
+		row("auth", "credentials file")
+	}
+	if w.APIKeyClientID != "" {
+		row("api key", w.APIKeyClientID)
+	}
+	switch {
+	case w.Token == nil:
+		row("token", t.Yellow("not a jwt, can't tell when it expires"))
+	case w.Token.ExpiresAt == nil:
+		row("token", "never expires")
+	case w.TokenExpired:
+		row("token", t.Red(fmt.Sprintf("expired %s ago", now.Sub(*w.Token.ExpiresAt).Round(time.Second))))
+	default:
+		row("token", fmt.Sprintf("expires %s (in %s)", w.Token.ExpiresAt.Local().Format(time.RFC1123), w.Token.ExpiresAt.Sub(now).Round(time.Second)))
+	}
+	if w.Token != nil {
+		if len(w.MissingScopes) == 0 {
+			row("scopes", fmt.Sprintf("%d, all required scopes granted", len(w.Token.Scopes)))
+		} else {
+			row("scopes", fmt.Sprintf("%d, missing %s", len(w.Token.Scopes), t.Yellow(strings.Join(w.MissingScopes, " "))))
+		}
+	}
+	if w.Source == SourceEnv {
+		// the saved login is not used while the env has an api key
+		return
+	}
+	if w.HasRefreshToken {
+		row("refresh token", "yes")
+	} else {
+		row("refresh token", "no")
+	}
+	row("credentials", w.Credentials)
+}
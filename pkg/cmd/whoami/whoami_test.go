@@ -0,0 +1,98 @@
+package whoami
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/golang-jwt/jwt"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeWhoamiStore struct {
+	tokens  *entity.AuthTokens
+	userErr error
+}
+
+func (f fakeWhoamiStore) GetCurrentUser() (*entity.User, error) {
+	if f.userErr != nil {
+		return nil, f.userErr
+	}
+	return &entity.User{ID: "u-1", Username: "ada", Email: "ada@example.com"}, nil
+}
+
+func (f fakeWhoamiStore) GetActiveOrganizationOrDefault() (*entity.Organization, error) {
+	return &entity.Organization{ID: "o-1", Name: "ada-hq"}, nil
+}
+
+func (f fakeWhoamiStore) GetAuthTokens() (*entity.AuthTokens, error) {
+	if f.tokens == nil {
+		return nil, &breverrors.CredentialsFileNotFound{}
+	}
+	return f.tokens, nil
+}
+
+func (f fakeWhoamiStore) GetCredentialStore() (store.CredentialStore, error) {
+	return store.NewFileCredentialStore(afero.NewMemMapFs(), "/home/ada/.brev/credentials.json"), nil
+}
+
+type fakeWhoamiAuth struct {
+	token       string
+	envClientID string
+}
+
+func (f fakeWhoamiAuth) GetAccessToken() (string, error) {
+	return f.token, nil
+}
+
+func (f fakeWhoamiAuth) UsesEnvClientCredentials() (string, bool) {
+	return f.envClientID, f.envClientID != ""
+}
+
+func TestGetWhoami(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "u-1",
+		"exp":   now.Add(-time.Minute).Unix(),
+		"scope": "openid",
+	}).SignedString([]byte("key"))
+	assert.Nil(t, err)
+
+	_, err = GetWhoami(fakeWhoamiStore{}, fakeWhoamiAuth{}, now)
+	assert.IsType(t, &breverrors.CredentialsFileNotFound{}, err)
+
+	w, err := GetWhoami(fakeWhoamiStore{tokens: &entity.AuthTokens{AccessToken: token, RefreshToken: "rt"}}, fakeWhoamiAuth{token: token}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "ada", w.User.Username)
+	assert.Equal(t, "ada-hq", w.Org.Name)
+	assert.True(t, w.TokenExpired)
+	assert.True(t, w.HasRefreshToken)
+	assert.Contains(t, w.MissingScopes, "offline_access")
+	assert.Equal(t, "/home/ada/.brev/credentials.json", w.Credentials)
+	assert.Equal(t, "file", w.Source)
+
+	w, err = GetWhoami(fakeWhoamiStore{
+		tokens:  &entity.AuthTokens{AccessToken: "auto-login", RefreshToken: "auto-login"},
+		userErr: fmt.Errorf("401"),
+	}, fakeWhoamiAuth{token: "auto-login"}, now)
+	assert.Nil(t, err)
+	assert.Nil(t, w.User)
+	assert.Nil(t, w.Org)
+	assert.Equal(t, "401", w.UserError)
+	assert.Nil(t, w.Token)
+	assert.False(t, w.HasRefreshToken)
+}
+
+func TestGetWhoamiWithEnvAPIKey(t *testing.T) {
+	// nothing saved, the api key in the env is what every command uses
+	w, err := GetWhoami(fakeWhoamiStore{}, fakeWhoamiAuth{token: "minted", envClientID: "ci"}, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, "ada", w.User.Username)
+	assert.Equal(t, SourceEnv, w.Source)
+	assert.Equal(t, "ci", w.APIKeyClientID)
+	assert.False(t, w.HasRefreshToken)
+}
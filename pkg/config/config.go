@@ -2,6 +2,10 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type EnvVarName string // should be caps with underscore
@@ -16,49 +20,164 @@ const (
 	sentryURL                EnvVarName = "DEFAULT_SENTRY_URL"
 	debugHTTP                EnvVarName = "DEBUG_HTTP"
 	ollamaAPIURL             EnvVarName = "OLLAMA_API_URL"
+	defaultOrg               EnvVarName = "BREV_DEFAULT_ORG"
+	defaultGPU               EnvVarName = "BREV_DEFAULT_GPU"
+	defaultEditor            EnvVarName = "BREV_DEFAULT_EDITOR"
+	diskSize                 EnvVarName = "BREV_DISK_SIZE"
+	credentialStore          EnvVarName = "BREV_CREDENTIAL_STORE"
+	credentialHelper         EnvVarName = "BREV_CREDENTIAL_HELPER"
+	credentialsPassphrase    EnvVarName = "BREV_CREDENTIALS_PASSPHRASE"
+	clientID                 EnvVarName = "BREV_CLIENT_ID"
+	clientSecret             EnvVarName = "BREV_CLIENT_SECRET"
+	containerRuntime         EnvVarName = "BREV_CONTAINER_RUNTIME"
+	containerHost            EnvVarName = "BREV_CONTAINER_HOST"
+	containerPrivileged      EnvVarName = "BREV_CONTAINER_PRIVILEGED"
+	containerCapAdd          EnvVarName = "BREV_CONTAINER_CAP_ADD"
+	snapshotCompression      EnvVarName = "BREV_SNAPSHOT_COMPRESSION"
+	snapshotKeep             EnvVarName = "BREV_SNAPSHOT_KEEP"
+	snapshotMaxAge           EnvVarName = "BREV_SNAPSHOT_MAX_AGE"
 )
 
-type ConstantsConfig struct{}
+// LayeredConfig resolves every setting from its layers, later layers override earlier ones
+type LayeredConfig struct {
+	layers []Layer
+}
 
-func NewConstants() *ConstantsConfig {
-	return &ConstantsConfig{}
+func (c LayeredConfig) with(layer Layer) LayeredConfig {
+	layers := make([]Layer, len(c.layers), len(c.layers)+1)
+	copy(layers, c.layers)
+	return LayeredConfig{layers: append(layers, layer)}
 }
 
-func (c ConstantsConfig) GetBrevAPIURl() string {
-	return getEnvOrDefault(brevAPIURL, "https://brevapi.us-west-2-prod.control-plane.brev.dev")
+func (c LayeredConfig) GetBrevAPIURl() string {
+	return c.Get(KeyAPIURL)
 }
 
-func (c ConstantsConfig) GetOllamaAPIURL() string {
+func (c LayeredConfig) GetOllamaAPIURL() string {
 	return getEnvOrDefault(ollamaAPIURL, "https://registry.ollama.ai")
 }
 
-func (c ConstantsConfig) GetServiceMeshCoordServerURL() string {
+func (c LayeredConfig) GetServiceMeshCoordServerURL() string {
 	return getEnvOrDefault(coordURL, "")
 }
 
-func (c ConstantsConfig) GetVersion() string {
+func (c LayeredConfig) GetVersion() string {
 	return getEnvOrDefault(version, "unknown")
 }
 
-func (c ConstantsConfig) GetDefaultClusterID() string {
+func (c LayeredConfig) GetDefaultClusterID() string {
 	return getEnvOrDefault(clusterID, "devplane-brev-1")
 }
 
-func (c ConstantsConfig) GetDefaultWorkspaceClass() string {
-	return getEnvOrDefault(defaultWorkspaceClass, "")
+// GetDefaultWorkspaceClass is the default CPU instance type
+func (c LayeredConfig) GetDefaultWorkspaceClass() string {
+	return c.Get(KeyDefaultCPU)
+}
+
+func (c LayeredConfig) GetDefaultGPUInstanceType() string {
+	return c.Get(KeyDefaultGPU)
 }
 
-func (c ConstantsConfig) GetDefaultWorkspaceTemplate() string {
+func (c LayeredConfig) GetDefaultWorkspaceTemplate() string {
 	// "test-template-aws"
 	return getEnvOrDefault(defaultWorkspaceTemplate, "")
 }
 
-func (c ConstantsConfig) GetSentryURL() string {
+func (c LayeredConfig) GetDefaultOrg() string {
+	return c.Get(KeyDefaultOrg)
+}
+
+func (c LayeredConfig) GetDefaultEditor() string {
+	return c.Get(KeyDefaultEditor)
+}
+
+func (c LayeredConfig) GetDiskSize() string {
+	return c.Get(KeyDiskSize)
+}
+
+func (c LayeredConfig) GetCredentialStore() string {
+	return c.Get(KeyCredentialStore)
+}
+
+func (c LayeredConfig) GetCredentialHelper() string {
+	return c.Get(KeyCredentialHelper)
+}
+
+// GetCredentialsPassphrase is only read from the env so it never ends up in a config file
+func (c LayeredConfig) GetCredentialsPassphrase() string {
+	return getEnvOrDefault(credentialsPassphrase, "")
+}
+
+// CredentialsPassphraseEnvVar is named in errors about encrypted credentials
+func CredentialsPassphraseEnvVar() string {
+	return string(credentialsPassphrase)
+}
+
+// GetClientID and GetClientSecret are the api key to log in with in CI, env only like the passphrase
+func (c LayeredConfig) GetClientID() string {
+	return getEnvOrDefault(clientID, "")
+}
+
+func (c LayeredConfig) GetClientSecret() string {
+	return getEnvOrDefault(clientSecret, "")
+}
+
+// ClientCredentialsEnvVars are named in errors about api key logins
+func ClientCredentialsEnvVars() (string, string) {
+	return string(clientID), string(clientSecret)
+}
+
+func (c LayeredConfig) GetContainerRuntime() string {
+	return c.Get(KeyContainerRuntime)
+}
+
+// GetContainerHost is empty when the runtime's own default socket should be used
+func (c LayeredConfig) GetContainerHost() string {
+	return c.Get(KeyContainerHost)
+}
+
+func (c LayeredConfig) GetContainerPrivileged() bool {
+	b, _ := strconv.ParseBool(c.Get(KeyContainerPrivileged))
+	return b
+}
+
+func (c LayeredConfig) GetContainerCapAdd() []string {
+	caps := []string{}
+	for _, capability := range strings.Split(c.Get(KeyContainerCapAdd), ",") {
+		if capability = strings.TrimSpace(capability); capability != "" {
+			caps = append(caps, capability)
+		}
+	}
+	return caps
+}
+
+func (c LayeredConfig) GetSnapshotCompression() string {
+	return c.Get(KeySnapshotCompression)
+}
+
+// GetSnapshotKeep is 0 when every snapshot is kept
+func (c LayeredConfig) GetSnapshotKeep() int {
+	n, _ := strconv.Atoi(c.Get(KeySnapshotKeep))
+	return n
+}
+
+// GetSnapshotMaxAge is 0 when snapshots are kept regardless of their age
+func (c LayeredConfig) GetSnapshotMaxAge() time.Duration {
+	d, _ := time.ParseDuration(c.Get(KeySnapshotMaxAge))
+	return d
+}
+
+func (c LayeredConfig) GetSentryURL() string {
 	return getEnvOrDefault(sentryURL, "https://4f3dca96f17e4c7995588dda4a31b37f@o410659.ingest.sentry.io/6383105")
 }
 
-func (c ConstantsConfig) GetDebugHTTP() bool {
-	return getEnvOrDefault(debugHTTP, "") != ""
+func (c LayeredConfig) GetDebugHTTP() bool {
+	b, err := strconv.ParseBool(c.Get(KeyDebugHTTP))
+	if err != nil {
+		// any other non empty value turned it on before it was a bool setting
+		return c.Get(KeyDebugHTTP) != ""
+	}
+	return b
 }
 
 func getEnvOrDefault(envVarName EnvVarName, defaultVal string) string {
@@ -69,30 +188,65 @@ func getEnvOrDefault(envVarName EnvVarName, defaultVal string) string {
 	return val
 }
 
-var GlobalConfig = NewConstants()
+var (
+	globalConfig     *EnvVarConfig
+	globalConfigOnce sync.Once
+)
 
-type EnvVarConfig struct {
-	ConstantsConfig
+// Global is read the first time it is used, which has to be after the active profile is set as
+// that decides which config files apply. Flags are layered on per command with WithFlags
+func Global() *EnvVarConfig {
+	globalConfigOnce.Do(func() {
+		globalConfig = Load()
+	})
+	return globalConfig
 }
 
-func (c *ConstantsConfig) WithEnvVars() *EnvVarConfig {
-	return &EnvVarConfig{*c}
+// Load reads the defaults, the default editor 'brev open' saved before it was a setting, the
+// system and user config files and then the env vars
+func Load() *EnvVarConfig {
+	return NewConstants().withLegacySettings(defaultLegacySettingsPath()).WithFileConfig(DefaultConfigPaths()...).WithEnvVars()
+}
+
+// ConstantsConfig only has the built in defaults
+type ConstantsConfig struct {
+	LayeredConfig
+}
+
+func NewConstants() *ConstantsConfig {
+	return &ConstantsConfig{LayeredConfig{layers: []Layer{defaultsLayer{}}}}
+}
+
+func (c *ConstantsConfig) withLegacySettings(path string) *ConstantsConfig {
+	if path == "" {
+		return c
+	}
+	return &ConstantsConfig{c.with(newLegacySettingsLayer(configFs, path))}
 }
 
 type FileConfig struct {
-	EnvVarConfig
+	LayeredConfig
 }
 
-func (c *EnvVarConfig) WithFileConfig() *FileConfig {
-	return &FileConfig{*c}
+// WithFileConfig layers the config files on top, later paths override earlier ones
+func (c *ConstantsConfig) WithFileConfig(paths ...string) *FileConfig {
+	l := c.LayeredConfig
+	for _, p := range paths {
+		l = l.with(newFileLayer(configFs, p))
+	}
+	return &FileConfig{l}
 }
 
-type FlagsConfig struct {
-	FileConfig
+type EnvVarConfig struct {
+	LayeredConfig
 }
 
-func (c *FileConfig) WithFlags() *FlagsConfig {
-	return &FlagsConfig{*c}
+func (c *FileConfig) WithEnvVars() *EnvVarConfig {
+	return &EnvVarConfig{c.with(envLayer{getenv: os.Getenv})}
+}
+
+type FlagsConfig struct {
+	LayeredConfig
 }
 
 type InitConfig interface{}
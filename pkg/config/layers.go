@@ -0,0 +1,514 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// SystemConfigPath applies to every user of the machine, ~/.brev/config.yaml overrides it
+const SystemConfigPath = "/etc/brev/config.yaml"
+
+var configFs = afero.NewOsFs()
+
+type Key string
+
+const (
+	KeyAPIURL           Key = "api_url"
+	KeyDefaultOrg       Key = "default_org"
+	KeyDefaultCPU       Key = "default_cpu"
+	KeyDefaultGPU       Key = "default_gpu"
+	KeyDefaultEditor    Key = "default_editor"
+	KeyDebugHTTP        Key = "debug_http"
+	KeyDiskSize         Key = "disk_size"
+	KeyCredentialStore  Key = "credential_store"
+	KeyCredentialHelper Key = "credential_helper"
+
+	KeyContainerRuntime    Key = "container_runtime"
+	KeyContainerHost       Key = "container_host"
+	KeyContainerPrivileged Key = "container_privileged"
+	KeyContainerCapAdd     Key = "container_cap_add"
+
+	KeySnapshotCompression Key = "snapshot_compression"
+	KeySnapshotKeep        Key = "snapshot_keep"
+	KeySnapshotMaxAge      Key = "snapshot_max_age"
+)
+
+// credential stores, see store.NewCredentialStore
+const (
+	CredentialStoreFile          = "file"
+	CredentialStoreEncryptedFile = "encrypted-file"
+	CredentialStoreHelper        = "helper"
+)
+
+// compressions of the snapshots of local workspaces
+const (
+	SnapshotCompressionZstd = "zstd"
+	SnapshotCompressionNone = "none"
+)
+
+// container runtimes of "brev local"
+const (
+	ContainerRuntimeDocker    = "docker"
+	ContainerRuntimePodman    = "podman"
+	ContainerRuntimeDockerCLI = "docker-cli"
+)
+
+type Setting struct {
+	Key         Key
+	EnvVar      EnvVarName
+	Default     string
+	Description string
+	validate    func(string) error
+}
+
+var diskSizeRegex = regexp.MustCompile(`^[1-9][0-9]*Gi$`)
+
+// Settings are all the values that can be set in a config file
+var Settings = []Setting{
+	{
+		Key: KeyAPIURL, EnvVar: brevAPIURL, Default: "https://brevapi.us-west-2-prod.control-plane.brev.dev",
+		Description: "brev api to talk to",
+	},
+	{
+		Key: KeyDefaultOrg, EnvVar: defaultOrg,
+		Description: "org name or id to use when no org was chosen with 'brev set'",
+	},
+	{
+		Key: KeyDefaultCPU, EnvVar: defaultWorkspaceClass,
+		Description: "CPU instance type for new instances, e.g. 4x16",
+	},
+	{
+		Key: KeyDefaultGPU, EnvVar: defaultGPU, Default: "n1-highmem-4:nvidia-tesla-t4:1",
+		Description: "GPU instance type for new instances",
+	},
+	{
+		Key: KeyDefaultEditor, EnvVar: defaultEditor, Default: "vscode",
+		Description: "editor for 'brev open', one of vscode, cursor, jetbrains",
+	},
+	{
+		Key: KeyDebugHTTP, EnvVar: debugHTTP, Default: "false",
+		Description: "print every request to the brev api",
+		validate: func(s string) error {
+			_, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("must be true or false")
+			}
+			return nil
+		},
+	},
+	{
+		Key: KeyDiskSize, EnvVar: diskSize, Default: "120Gi",
+		Description: "disk size for new instances, e.g. 256Gi",
+		validate: func(s string) error {
+			if !diskSizeRegex.MatchString(s) {
+				return fmt.Errorf("must be a size in Gi, e.g. 256Gi")
+			}
+			return nil
+		},
+	},
+	{
+		Key: KeyCredentialStore, EnvVar: credentialStore, Default: CredentialStoreFile,
+		Description: "where login tokens are kept, one of file, encrypted-file, helper",
+		validate: func(s string) error {
+			switch s {
+			case CredentialStoreFile, CredentialStoreEncryptedFile, CredentialStoreHelper:
+				return nil
+			}
+			return fmt.Errorf("must be one of %s, %s, %s", CredentialStoreFile, CredentialStoreEncryptedFile, CredentialStoreHelper)
+		},
+	},
+	{
+		Key: KeyCredentialHelper, EnvVar: credentialHelper,
+		Description: "git credential helper for credential_store helper, e.g. osxkeychain, libsecret or an absolute path",
+	},
+	{
+		Key: KeyContainerRuntime, EnvVar: containerRuntime, Default: ContainerRuntimeDocker,
+		Description: "what runs local workspaces, one of docker, podman, docker-cli",
+		validate: func(s string) error {
+			switch s {
+			case ContainerRuntimeDocker, ContainerRuntimePodman, ContainerRuntimeDockerCLI:
+				return nil
+			}
+			return fmt.Errorf("must be one of %s, %s, %s", ContainerRuntimeDocker, ContainerRuntimePodman, ContainerRuntimeDockerCLI)
+		},
+	},
+	{
+		Key: KeyContainerHost, EnvVar: containerHost,
+		Description: "socket of the container engine, e.g. unix:///run/user/1000/podman/podman.sock, defaults to the runtime's own",
+		validate: func(s string) error {
+			if !strings.HasPrefix(s, "unix://") && !strings.HasPrefix(s, "tcp://") {
+				return fmt.Errorf("must start with unix:// or tcp://")
+			}
+			return nil
+		},
+	},
+	{
+		Key: KeyContainerPrivileged, EnvVar: containerPrivileged, Default: "false",
+		Description: "run local workspaces as privileged containers",
+		validate: func(s string) error {
+			_, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("must be true or false")
+			}
+			return nil
+		},
+	},
+	{
+		Key: KeyContainerCapAdd, EnvVar: containerCapAdd,
+		Description: "comma separated capabilities to add to local workspaces, e.g. SYS_ADMIN,NET_ADMIN",
+	},
+	{
+		Key: KeySnapshotCompression, EnvVar: snapshotCompression, Default: SnapshotCompressionZstd,
+		Description: "compression of local workspace snapshots, zstd or none",
+		validate: func(s string) error {
+			if s != SnapshotCompressionZstd && s != SnapshotCompressionNone {
+				return fmt.Errorf("must be %s or %s", SnapshotCompressionZstd, SnapshotCompressionNone)
+			}
+			return nil
+		},
+	},
+	{
+		Key: KeySnapshotKeep, EnvVar: snapshotKeep, Default: "5",
+		Description: "number of snapshots kept per local workspace when they are pruned, 0 keeps all",
+		validate: func(s string) error {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return fmt.Errorf("must be a number of snapshots")
+			}
+			return nil
+		},
+	},
+	{
+		Key: KeySnapshotMaxAge, EnvVar: snapshotMaxAge,
+		Description: "age after which local workspace snapshots are pruned, e.g. 168h, unset keeps them regardless of age",
+		validate: func(s string) error {
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("must be a duration like 72h")
+			}
+			return nil
+		},
+	},
+}
+
+func LookupSetting(key string) (Setting, error) {
+	for _, s := range Settings {
+		if string(s.Key) == key {
+			return s, nil
+		}
+	}
+	keys := []string{}
+	for _, s := range Settings {
+		keys = append(keys, string(s.Key))
+	}
+	return Setting{}, breverrors.NewValidationError(fmt.Sprintf("unknown setting %s, use one of: %s", key, strings.Join(keys, ", ")))
+}
+
+func (s Setting) Validate(value string) error {
+	if s.validate == nil {
+		return nil
+	}
+	err := s.validate(value)
+	if err != nil {
+		return breverrors.NewValidationError(fmt.Sprintf("invalid %s %q: %v", s.Key, value, err))
+	}
+	return nil
+}
+
+// OriginDefault is the origin of values no layer set
+const OriginDefault = "default"
+
+// Value is a resolved setting and the layer it came from
+type Value struct {
+	Key    Key    `json:"key"`
+	Value  string `json:"value"`
+	Origin string `json:"origin"`
+}
+
+func (v Value) IsDefault() bool {
+	return v.Origin == OriginDefault
+}
+
+// Layer is one source of settings, such as a config file or the env
+type Layer interface {
+	// Lookup returns where the value came from, ok is false if this layer doesn't set it
+	Lookup(s Setting) (value string, origin string, ok bool)
+}
+
+type defaultsLayer struct{}
+
+func (defaultsLayer) Lookup(s Setting) (string, string, bool) {
+	return s.Default, OriginDefault, true
+}
+
+type envLayer struct {
+	getenv func(string) string
+}
+
+func (e envLayer) Lookup(s Setting) (string, string, bool) {
+	if s.EnvVar == "" {
+		return "", "", false
+	}
+	val := e.getenv(string(s.EnvVar))
+	if val == "" {
+		return "", "", false
+	}
+	return val, "env " + string(s.EnvVar), true
+}
+
+type fileLayer struct {
+	path   string
+	values map[string]string
+	err    error
+}
+
+// newFileLayer keeps a file that can't be read as an empty layer, its error shows up in Err
+func newFileLayer(fs afero.Fs, path string) fileLayer {
+	values, err := ReadConfigFile(fs, path)
+	return validatedFileLayer(path, values, err)
+}
+
+// validatedFileLayer drops values that were edited into the file by hand and don't validate, so
+// the layers below apply instead. They show up in Err like a file that can't be read
+func validatedFileLayer(path string, values map[string]string, err error) fileLayer {
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		setting, lookupErr := LookupSetting(k)
+		if lookupErr != nil {
+			// settings from newer versions of brev are left alone
+			continue
+		}
+		validateErr := setting.Validate(values[k])
+		if validateErr != nil {
+			delete(values, k)
+			err = multierror.Append(err, fmt.Errorf("%s: %w", path, validateErr))
+		}
+	}
+	return fileLayer{path: path, values: values, err: err}
+}
+
+// legacySettings is the file 'brev open --set-default' saved the default editor in before it was
+// the default_editor setting
+type legacySettings struct {
+	DefaultEditor string `json:"defaultEditor,omitempty"`
+}
+
+func newLegacySettingsLayer(fs afero.Fs, path string) fileLayer {
+	values := map[string]string{}
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fileLayer{path: path, values: values}
+		}
+		return fileLayer{path: path, values: values, err: breverrors.WrapAndTrace(err)}
+	}
+	var settings legacySettings
+	err = json.Unmarshal(data, &settings)
+	if err != nil {
+		return fileLayer{path: path, values: values, err: breverrors.WrapAndTrace(err, path)}
+	}
+	if settings.DefaultEditor != "" {
+		values[string(KeyDefaultEditor)] = settings.DefaultEditor
+	}
+	return validatedFileLayer(path, values, nil)
+}
+
+func defaultLegacySettingsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return files.GetPersonalSettingsCachePath(home)
+}
+
+func (f fileLayer) Lookup(s Setting) (string, string, bool) {
+	val, ok := f.values[string(s.Key)]
+	if !ok {
+		return "", "", false
+	}
+	return val, "file " + f.path, true
+}
+
+type flagsLayer struct {
+	flags *pflag.FlagSet
+	names map[Key]string
+}
+
+func (f flagsLayer) Lookup(s Setting) (string, string, bool) {
+	name, ok := f.names[s.Key]
+	if !ok {
+		return "", "", false
+	}
+	flag := f.flags.Lookup(name)
+	if flag == nil || !flag.Changed {
+		return "", "", false
+	}
+	return flag.Value.String(), "flag --" + name, true
+}
+
+// WithFlags layers the flags a command was run with on top, names maps each setting to the flag
+// that overrides it. Flags that weren't passed leave the setting alone
+func (c LayeredConfig) WithFlags(flags *pflag.FlagSet, names map[Key]string) *FlagsConfig {
+	return &FlagsConfig{c.with(flagsLayer{flags: flags, names: names})}
+}
+
+// Lookup resolves a known setting
+func (c LayeredConfig) Lookup(key Key) Value {
+	setting, err := LookupSetting(string(key))
+	if err != nil {
+		return Value{Key: key}
+	}
+	v := Value{Key: key}
+	for _, l := range c.layers {
+		val, origin, ok := l.Lookup(setting)
+		if ok {
+			v.Value = val
+			v.Origin = origin
+		}
+	}
+	return v
+}
+
+func (c LayeredConfig) Get(key Key) string {
+	return c.Lookup(key).Value
+}
+
+// List resolves every setting, sorted by key
+func (c LayeredConfig) List() []Value {
+	values := []Value{}
+	for _, s := range Settings {
+		values = append(values, c.Lookup(s.Key))
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return values
+}
+
+// Err reports config files that could not be read and values in them that are not valid, brev
+// keeps going without them
+func (c LayeredConfig) Err() error {
+	var allErr error
+	for _, l := range c.layers {
+		if f, ok := l.(fileLayer); ok && f.err != nil {
+			allErr = multierror.Append(allErr, f.err)
+		}
+	}
+	return allErr
+}
+
+// DefaultConfigPaths are the config files in the order they are layered, a profile's own config
+// file goes over the one in ~/.brev
+func DefaultConfigPaths() []string {
+	paths := []string{SystemConfigPath}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return paths
+	}
+	paths = append(paths, files.GetRootUserConfigPath(home))
+	if profilePath := files.GetUserConfigPath(home); profilePath != paths[len(paths)-1] {
+		paths = append(paths, profilePath)
+	}
+	return paths
+}
+
+func newConfigViper(fs afero.Fs, path string) *viper.Viper {
+	v := viper.New()
+	v.SetFs(fs)
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	return v
+}
+
+// ReadConfigFile returns no values for a file that doesn't exist
+func ReadConfigFile(fs afero.Fs, path string) (map[string]string, error) {
+	values := map[string]string{}
+	exists, err := afero.Exists(fs, path)
+	if err != nil {
+		return values, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return values, nil
+	}
+	v := newConfigViper(fs, path)
+	err = v.ReadInConfig()
+	if err != nil {
+		return values, breverrors.WrapAndTrace(err, path)
+	}
+	for _, k := range v.AllKeys() {
+		values[k] = v.GetString(k)
+	}
+	return values, nil
+}
+
+// SetConfigFileValue validates the value and writes it to the config file at path, keeping the
+// other values in it
+func SetConfigFileValue(fs afero.Fs, path string, key string, value string) error {
+	setting, err := LookupSetting(key)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = setting.Validate(value)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	values, err := ReadConfigFile(fs, path)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	values[key] = value
+	return writeConfigFile(fs, path, values)
+}
+
+// UnsetConfigFileValue returns false if the config file at path did not set key
+func UnsetConfigFileValue(fs afero.Fs, path string, key string) (bool, error) {
+	_, err := LookupSetting(key)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	values, err := ReadConfigFile(fs, path)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	if _, ok := values[key]; !ok {
+		return false, nil
+	}
+	delete(values, key)
+	err = writeConfigFile(fs, path, values)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return true, nil
+}
+
+func writeConfigFile(fs afero.Fs, path string, values map[string]string) error {
+	err := fs.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	v := newConfigViper(fs, path)
+	for k, val := range values {
+		v.Set(k, val)
+	}
+	err = v.WriteConfigAs(path)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
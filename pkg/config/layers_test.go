@@ -0,0 +1,119 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func withMemFs(t *testing.T) afero.Fs {
+	fs := afero.NewMemMapFs()
+	old := configFs
+	configFs = fs
+	t.Cleanup(func() { configFs = old })
+	return fs
+}
+
+func TestLayerPrecedence(t *testing.T) {
+	fs := withMemFs(t)
+	assert.Nil(t, SetConfigFileValue(fs, "/etc/brev/config.yaml", "default_cpu", "2x8"))
+	assert.Nil(t, SetConfigFileValue(fs, "/etc/brev/config.yaml", "disk_size", "200Gi"))
+	assert.Nil(t, SetConfigFileValue(fs, "/home/test/.brev/config.yaml", "default_cpu", "4x16"))
+	t.Setenv("BREV_DISK_SIZE", "300Gi")
+
+	conf := NewConstants().WithFileConfig("/etc/brev/config.yaml", "/home/test/.brev/config.yaml").WithEnvVars()
+	assert.Nil(t, conf.Err())
+
+	assert.Equal(t, Value{Key: KeyDefaultCPU, Value: "4x16", Origin: "file /home/test/.brev/config.yaml"}, conf.Lookup(KeyDefaultCPU))
+	assert.Equal(t, Value{Key: KeyDiskSize, Value: "300Gi", Origin: "env BREV_DISK_SIZE"}, conf.Lookup(KeyDiskSize))
+	assert.True(t, conf.Lookup(KeyDefaultEditor).IsDefault())
+	assert.Equal(t, "vscode", conf.GetDefaultEditor())
+
+	flags := pflag.NewFlagSet("start", pflag.ContinueOnError)
+	flags.String("cpu", "", "")
+	withFlags := conf.WithFlags(flags, map[Key]string{KeyDefaultCPU: "cpu"})
+	// a flag that wasn't passed leaves the setting alone
+	assert.Equal(t, "4x16", withFlags.GetDefaultWorkspaceClass())
+	assert.Nil(t, flags.Parse([]string{"--cpu", "8x32"}))
+	assert.Equal(t, Value{Key: KeyDefaultCPU, Value: "8x32", Origin: "flag --cpu"}, withFlags.Lookup(KeyDefaultCPU))
+	// the layers below are not changed by adding flags
+	assert.Equal(t, "4x16", conf.GetDefaultWorkspaceClass())
+}
+
+func TestUnreadableFileIsSkipped(t *testing.T) {
+	fs := withMemFs(t)
+	assert.Nil(t, afero.WriteFile(fs, "/etc/brev/config.yaml", []byte("disk_size: [oops"), 0o644))
+
+	conf := NewConstants().WithFileConfig("/etc/brev/config.yaml")
+	assert.Error(t, conf.Err())
+	assert.Equal(t, "120Gi", conf.GetDiskSize())
+}
+
+func TestInvalidFileValueIsSkipped(t *testing.T) {
+	fs := withMemFs(t)
+	assert.Nil(t, afero.WriteFile(fs, "/etc/brev/config.yaml", []byte("disk_size: 200Gi\n"), 0o644))
+	assert.Nil(t, afero.WriteFile(fs, "/home/test/.brev/config.yaml", []byte("disk_size: lots\nnot_a_setting: x\n"), 0o644))
+
+	conf := NewConstants().WithFileConfig("/etc/brev/config.yaml", "/home/test/.brev/config.yaml")
+	if assert.Error(t, conf.Err()) {
+		assert.Contains(t, conf.Err().Error(), `invalid disk_size "lots"`)
+	}
+	assert.Equal(t, Value{Key: KeyDiskSize, Value: "200Gi", Origin: "file /etc/brev/config.yaml"}, conf.Lookup(KeyDiskSize))
+}
+
+func TestLegacySettingsLayer(t *testing.T) {
+	fs := withMemFs(t)
+	legacyPath := "/home/test/.brev/personal_settings.json"
+	userPath := "/home/test/.brev/config.yaml"
+
+	// no legacy file leaves the built in default
+	conf := NewConstants().withLegacySettings(legacyPath).WithFileConfig(userPath)
+	assert.Nil(t, conf.Err())
+	assert.Equal(t, "vscode", conf.GetDefaultEditor())
+
+	assert.Nil(t, afero.WriteFile(fs, legacyPath, []byte(`{"defaultEditor":"cursor"}`), 0o644))
+	conf = NewConstants().withLegacySettings(legacyPath).WithFileConfig(userPath)
+	assert.Equal(t, Value{Key: KeyDefaultEditor, Value: "cursor", Origin: "file " + legacyPath}, conf.Lookup(KeyDefaultEditor))
+
+	// setting it in the config file takes over from the legacy one
+	assert.Nil(t, SetConfigFileValue(fs, userPath, "default_editor", "jetbrains"))
+	conf = NewConstants().withLegacySettings(legacyPath).WithFileConfig(userPath)
+	assert.Equal(t, "jetbrains", conf.GetDefaultEditor())
+}
+
+func TestSetAndUnsetConfigFileValue(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	path := "/home/test/.brev/config.yaml"
+
+	assert.Error(t, SetConfigFileValue(fs, path, "not_a_setting", "x"))
+	assert.Error(t, SetConfigFileValue(fs, path, "debug_http", "maybe"))
+	assert.Error(t, SetConfigFileValue(fs, path, "disk_size", "100"))
+	assert.Error(t, SetConfigFileValue(fs, path, "credential_store", "keychain"))
+
+	assert.Nil(t, SetConfigFileValue(fs, path, "debug_http", "true"))
+	assert.Nil(t, SetConfigFileValue(fs, path, "default_org", "my-org"))
+	values, err := ReadConfigFile(fs, path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"debug_http": "true", "default_org": "my-org"}, values)
+
+	unset, err := UnsetConfigFileValue(fs, path, "debug_http")
+	assert.Nil(t, err)
+	assert.True(t, unset)
+	unset, err = UnsetConfigFileValue(fs, path, "debug_http")
+	assert.Nil(t, err)
+	assert.False(t, unset)
+
+	values, err = ReadConfigFile(fs, path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"default_org": "my-org"}, values)
+}
+
+func TestGetDebugHTTP(t *testing.T) {
+	withMemFs(t)
+	for val, want := range map[string]bool{"": false, "false": false, "true": true, "1": true, "yes": true} {
+		t.Setenv("DEBUG_HTTP", val)
+		assert.Equal(t, want, NewConstants().WithFileConfig().WithEnvVars().GetDebugHTTP(), val)
+	}
+}
@@ -21,6 +21,22 @@ var LegacyWorkspaceGroups = map[string]bool{
 type AuthTokens struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	// set when logged in with an api key, which mints new tokens instead of refreshing
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// ClientCredentialsToken is an access token minted with an api key from the env, cached between
+// commands without the key itself
+type ClientCredentialsToken struct {
+	ClientID    string    `json:"client_id"`
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+}
+
+// ExpiresWithin is false for a token without an expiry
+func (t ClientCredentialsToken) ExpiresWithin(d time.Duration) bool {
+	return !t.ExpiresAt.IsZero() && time.Now().Add(d).After(t.ExpiresAt)
 }
 
 type IDEConfig struct {
@@ -310,9 +326,9 @@ type Workspace struct {
 	HostSSHUser       string            `json:"hostSshUser"`
 	VerbBuildStatus   VerbBuildStatus   `json:"verbBuildStatus"`
 	VerbYaml          string            `json:"verbYaml"`
+	CreatedAt         string            `json:"createdAt,omitempty"`
 	// PrimaryApplicationId         string `json:"primaryApplicationId,omitempty"`
 	// LastOnlineAt         string `json:"lastOnlineAt,omitempty"`
-	// CreatedAt         string `json:"createdAt,omitempty"`
 	// UpdatedAt         string `json:"updatedAt,omitempty"`
 	HealthStatus    string        `json:"healthStatus"`
 	IsStoppable     bool          `json:"isStoppable"` // used for autopstop only
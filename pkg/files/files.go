@@ -18,12 +18,24 @@ import (
 
 const (
 	brevDirectory = ".brev"
+	// named profiles live in their own directory under the brev home, the default one is the
+	// brev home itself
+	profilesDirectory = "profiles"
+	// which profile "brev profile use" picked, shared by all profiles
+	profilesFile = "profiles.json"
 	// This might be better as a context.json??
-	activeOrgFile      = "active_org.json"
-	orgCacheFile       = "org_cache.json"
+	activeOrgFile = "active_org.json"
+	orgCacheFile  = "org_cache.json"
+	// the last seen instances, so the ssh config is only rewritten when they change
 	workspaceCacheFile = "workspace_cache.json"
-	// WIP: This will be used to let people "brev open" with editors other than VS Code
-	personalSettingsCache         = "personal_settings.json"
+	// settings layered over the defaults and /etc/brev/config.yaml, see "brev config"
+	configFile = "config.yaml"
+	// local preferences such as the default editor for "brev open"
+	personalSettingsCache = "personal_settings.json"
+	// workspaces "brev local" runs in containers on this machine, each in its own directory
+	localWorkspacesDirectory = "local"
+	// instances "brev delete" refuses to delete, only known to this machine
+	protectedWorkspacesFile       = "protected_instances.json"
 	kubeCertFileName              = "brev.crt"
 	sshPrivateKeyFileName         = "brev.pem"
 	backupSSHConfigFileNamePrefix = "config.bak"
@@ -34,6 +46,24 @@ const (
 
 var AppFs = afero.NewOsFs()
 
+// DefaultProfile keeps its files directly in ~/.brev, as before there were profiles
+const DefaultProfile = "default"
+
+var activeProfile = DefaultProfile
+
+// SetActiveProfile scopes every path under the brev home to the profile, it is set once when
+// brev starts
+func SetActiveProfile(profile string) {
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	activeProfile = profile
+}
+
+func GetActiveProfile() string {
+	return activeProfile
+}
+
 func GetBrevDirectory() string {
 	return brevDirectory
 }
@@ -84,10 +114,41 @@ func makeBrevFilePath(filename string, userHome string) string {
 	return fpath
 }
 
+// GetBrevHome is the brev home of the active profile
 func GetBrevHome(userHome string) string {
+	return GetProfileHome(userHome, activeProfile)
+}
+
+// GetBrevRoot is the brev home of the default profile, which holds the other profiles
+func GetBrevRoot(userHome string) string {
 	return filepath.Join(userHome, brevDirectory)
 }
 
+func GetProfilesDirectory(userHome string) string {
+	return filepath.Join(GetBrevRoot(userHome), profilesDirectory)
+}
+
+func GetProfileHome(userHome string, profile string) string {
+	if profile == "" || profile == DefaultProfile {
+		return GetBrevRoot(userHome)
+	}
+	return filepath.Join(GetProfilesDirectory(userHome), profile)
+}
+
+func GetProfilesPath(userHome string) string {
+	return filepath.Join(GetBrevRoot(userHome), profilesFile)
+}
+
+// GetRootUserConfigPath is the config file of the default profile, the other profiles layer
+// their own config file over it
+func GetRootUserConfigPath(home string) string {
+	return GetProfileConfigPath(home, DefaultProfile)
+}
+
+func GetProfileConfigPath(home string, profile string) string {
+	return filepath.Join(GetProfileHome(home, profile), configFile)
+}
+
 func GetActiveOrgsPath(home string) string {
 	fpath := makeBrevFilePath(activeOrgFile, home)
 	return fpath
@@ -98,6 +159,29 @@ func GetPersonalSettingsCachePath(home string) string {
 	return fpath
 }
 
+func GetUserConfigPath(home string) string {
+	fpath := makeBrevFilePath(configFile, home)
+	return fpath
+}
+
+func GetWorkspaceCachePath(home string) string {
+	fpath := makeBrevFilePath(workspaceCacheFile, home)
+	return fpath
+}
+
+func GetProtectedWorkspacesPath(home string) string {
+	fpath := makeBrevFilePath(protectedWorkspacesFile, home)
+	return fpath
+}
+
+func GetLocalWorkspacesPath(home string) string {
+	return makeBrevFilePath(localWorkspacesDirectory, home)
+}
+
+func GetLocalWorkspacePath(home string, name string) string {
+	return filepath.Join(GetLocalWorkspacesPath(home), name)
+}
+
 func GetSSHPrivateKeyPath(home string) string {
 	fpath := makeBrevFilePath(GetSSHPrivateKeyFileName(), home)
 	return fpath
@@ -109,7 +193,11 @@ func GetUserSSHConfigPath(home string) (string, error) {
 }
 
 func GetBrevSSHConfigPath(home string) string {
-	path := GetBrevHome(home)
+	return GetProfileBrevSSHConfigPath(home, activeProfile)
+}
+
+func GetProfileBrevSSHConfigPath(home string, profile string) string {
+	path := GetProfileHome(home, profile)
 	brevSSHConfigPath := filepath.Join(path, "ssh_config")
 	return brevSSHConfigPath
 }
@@ -120,6 +208,11 @@ func GetOnboardingStepPath(home string) string {
 	return brevOnboardingFilePath
 }
 
+// GetBackupSSHConfigFilesGlob matches every backup GetNewBackupSSHConfigFilePath made
+func GetBackupSSHConfigFilesGlob(home string) string {
+	return makeBrevFilePath(backupSSHConfigFileNamePrefix+".*", home)
+}
+
 func GetNewBackupSSHConfigFilePath(home string) string {
 	fp := makeBrevFilePath(GetNewBackupSSHConfigFileName(), home)
 
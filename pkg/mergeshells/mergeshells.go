@@ -56,14 +56,23 @@ func GetDependencies(path string) []string {
 	supportedDependencyMap := map[string][]func(string) *string{
 		"node":   {nodeVersion},
 		"gatsby": {gatsbyVersion},
-		"rust":   {rustVersion},
+		"rust":   {rustToolchainVersion, rustVersion},
 		"golang": {goVersion},
+		"python": {pythonVersionFile, pipfilePythonVersion, pyprojectPythonVersion, pythonProject},
+		"conda":  {condaPythonVersion, condaProject},
+		"ruby":   {rubyVersionFile, gemfileRubyVersion, rubyProject},
+		"java":   {sdkmanJavaVersion, mavenJavaVersion, gradleJavaVersion, javaProject},
+		"maven":  {mavenProject},
 	}
 
 	// these will be applied, in left-to-right order, to the version string returned by your version function
 	// before passing it to the finder / splicer of the install shell script for your dependency
 	processVersionMap := map[string][]func(string) string{
 		"golang":  {transformGoVersion},
+		"python":  {versionNumber},
+		"conda":   {versionNumber},
+		"ruby":    {versionNumber},
+		"java":    {javaMajorVersion},
 		"default": {transformVersion},
 	}
 
@@ -186,18 +195,18 @@ func transformGoVersion(version string) string {
 
 func transformVersion(version string) string {
 	if len(version) > 0 {
-		switch version[0:0] {
+		switch version[0:1] {
 		case "~":
 			return version[1:]
 		case "^":
 			return version[1:]
 		case ">":
-			if version[0:1] == ">=" {
+			if strings.HasPrefix(version, ">=") {
 				return version[2:]
 			}
 			return version[1:]
 		case "<":
-			if version[0:1] == "<=" {
+			if strings.HasPrefix(version, "<=") {
 				return version[2:]
 			}
 			return version[1:]
@@ -318,7 +327,8 @@ func importFile(nameVersion string) ([]ShellFragment, error) {
 	// split the name string into two with - -- left hand side is package, right hand side is version
 	// read from the generated path
 	// generate ShellFragment from it (fromSh) and return it
-	subPaths := strings.Split(nameVersion, "-")
+	// versions like nightly-2024-01-01 have dashes too, so only the first one splits
+	subPaths := strings.SplitN(nameVersion, "-", 2)
 	noversion := false
 	if len(subPaths) == 1 {
 		noversion = true
@@ -553,7 +563,7 @@ func IsRuby(path string) bool {
 }
 
 func IsPython(path string) bool {
-	paths := recursivelyFindFile([]string{"Gemfile.lock", "Gemfile"}, path)
+	paths := recursivelyFindFile(pythonFiles, path)
 
 	return len(paths) > 0
 }
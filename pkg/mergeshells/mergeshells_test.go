@@ -0,0 +1,105 @@
+package mergeshells
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeProject lays files out in a temp dir and returns it with a trailing slash, as
+// recursivelyFindFile expects
+func writeProject(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if !assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755)) {
+			t.FailNow()
+		}
+		if !assert.Nil(t, os.WriteFile(path, []byte(contents), 0o600)) {
+			t.FailNow()
+		}
+	}
+	return dir + "/"
+}
+
+func sortedDependencies(path string) []string {
+	deps := GetDependencies(path)
+	sort.Strings(deps)
+	return deps
+}
+
+func TestTransformVersion(t *testing.T) {
+	tests := map[string]string{
+		"":      "",
+		"14":    "14",
+		"~14":   "14",
+		"^14":   "14",
+		">14":   "14",
+		">=14":  "14",
+		"<14":   "14",
+		"<=14":  "14",
+		"=14":   "14",
+		"14.x":  "14.x",
+		"lts/*": "lts/*",
+	}
+	for version, expected := range tests {
+		assert.Equal(t, expected, transformVersion(version), version)
+	}
+}
+
+func TestGetDependenciesNodeAndGatsby(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			name:     "node without engines",
+			files:    map[string]string{"package.json": `{"name": "app"}`},
+			expected: []string{"node"},
+		},
+		{
+			name:     "node engines constraint",
+			files:    map[string]string{"package.json": `{"engines": {"node": ">=14"}}`},
+			expected: []string{"node-14"},
+		},
+		{
+			name:     "node caret engines",
+			files:    map[string]string{"package.json": `{"engines": {"node": "^16"}}`},
+			expected: []string{"node-16"},
+		},
+		{
+			name: "gatsby",
+			files: map[string]string{"package.json": `{
+				"engines": {"node": "~14"},
+				"dependencies": {"gatsby": "^4.0.0"}
+			}`},
+			expected: []string{"gatsby", "node-14"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, sortedDependencies(writeProject(t, tt.files)))
+		})
+	}
+}
+
+func TestImportFileVersionWithDashes(t *testing.T) {
+	fragments, err := importFile("rust-nightly-2024-01-01")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Contains(t, toSh(fragments), `rust_toolchain="nightly-2024-01-01"`)
+}
+
+func TestImportFileVersionedTemplate(t *testing.T) {
+	fragments, err := importFile("node-14")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, strings.Contains(toSh(fragments), "setup_14.x"))
+}
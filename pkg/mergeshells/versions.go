@@ -0,0 +1,221 @@
+package mergeshells
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/brevdev/brev-cli/pkg/files"
+)
+
+// the files that make a project a python, conda, ruby, java or maven one, without a pinned version
+var (
+	pythonFiles = []string{`^requirements.*\.txt$`, `^pyproject\.toml$`, `^Pipfile$`, `^setup\.py$`, `^\.python-version$`}
+	condaFiles  = []string{`^environment\.ya?ml$`}
+	rubyFiles   = []string{`^Gemfile$`, `^Gemfile\.lock$`, `^\.ruby-version$`}
+	javaFiles   = []string{`^pom\.xml$`, `^build\.gradle(\.kts)?$`, `^settings\.gradle(\.kts)?$`}
+	mavenFiles  = []string{`^pom\.xml$`}
+)
+
+var (
+	condaPythonRegex = regexp.MustCompile(`(?m)^\s*-\s*python\s*[=<>~]+\s*([0-9][0-9.*]*)`)
+	gemfileRubyRegex = regexp.MustCompile(`(?m)^\s*ruby\s+["']([^"']+)["']`)
+	sdkmanJavaRegex  = regexp.MustCompile(`(?m)^\s*java\s*=\s*(\S+)`)
+	mavenJavaRegex   = regexp.MustCompile(`<(?:maven\.compiler\.release|maven\.compiler\.source|java\.version|release)>\s*([0-9._]+)\s*<`)
+	gradleJavaRegexs = []*regexp.Regexp{
+		regexp.MustCompile(`JavaLanguageVersion\.of\(\s*([0-9]+)\s*\)`),
+		regexp.MustCompile(`jvmToolchain\(\s*([0-9]+)\s*\)`),
+		regexp.MustCompile(`JavaVersion\.VERSION_([0-9_]+)`),
+		regexp.MustCompile(`sourceCompatibility\s*=\s*['"]?([0-9.]+)`),
+	}
+	versionNumberRegex = regexp.MustCompile(`[0-9]+(\.[0-9]+)*`)
+)
+
+func pythonVersionFile(path string) *string {
+	return pinnedVersion([]string{`^\.python-version$`}, path, firstLine)
+}
+
+func pipfilePythonVersion(path string) *string {
+	return pinnedVersion([]string{`^Pipfile$`}, path, firstOf(
+		tomlString("requires", "python_full_version"),
+		tomlString("requires", "python_version"),
+	))
+}
+
+func pyprojectPythonVersion(path string) *string {
+	return pinnedVersion([]string{`^pyproject\.toml$`}, path, firstOf(
+		tomlString("project", "requires-python"),
+		tomlString("tool.poetry.dependencies", "python"),
+	))
+}
+
+func pythonProject(path string) *string {
+	return projectFiles(pythonFiles, path)
+}
+
+func condaPythonVersion(path string) *string {
+	return pinnedVersion(condaFiles, path, submatch(condaPythonRegex))
+}
+
+func condaProject(path string) *string {
+	return projectFiles(condaFiles, path)
+}
+
+func rubyVersionFile(path string) *string {
+	return pinnedVersion([]string{`^\.ruby-version$`}, path, firstLine)
+}
+
+func gemfileRubyVersion(path string) *string {
+	return pinnedVersion([]string{`^Gemfile$`}, path, submatch(gemfileRubyRegex))
+}
+
+func rubyProject(path string) *string {
+	return projectFiles(rubyFiles, path)
+}
+
+func sdkmanJavaVersion(path string) *string {
+	return pinnedVersion([]string{`^\.sdkmanrc$`}, path, submatch(sdkmanJavaRegex))
+}
+
+func mavenJavaVersion(path string) *string {
+	return pinnedVersion(mavenFiles, path, submatch(mavenJavaRegex))
+}
+
+func gradleJavaVersion(path string) *string {
+	reads := []func(string) string{}
+	for _, re := range gradleJavaRegexs {
+		reads = append(reads, submatch(re))
+	}
+	return pinnedVersion([]string{`^build\.gradle(\.kts)?$`}, path, firstOf(reads...))
+}
+
+func javaProject(path string) *string {
+	return projectFiles(javaFiles, path)
+}
+
+func mavenProject(path string) *string {
+	return projectFiles(mavenFiles, path)
+}
+
+// rustToolchainVersion is the channel of rust-toolchain.toml, or of the older rust-toolchain
+// which is either toml or only the channel
+func rustToolchainVersion(path string) *string {
+	return pinnedVersion([]string{`^rust-toolchain(\.toml)?$`}, path, func(contents string) string {
+		if strings.Contains(contents, "[toolchain]") {
+			return tomlString("toolchain", "channel")(contents)
+		}
+		return firstLine(contents)
+	})
+}
+
+// javaMajorVersion turns 1.8, 1_8, 17.0.9 and sdkman's 17.0.9-tem into the major version
+func javaMajorVersion(version string) string {
+	version = strings.TrimPrefix(strings.ReplaceAll(version, "_", "."), "1.")
+	major, _, _ := strings.Cut(version, ".")
+	major, _, _ = strings.Cut(major, "-")
+	return major
+}
+
+// versionNumber is the first version number of a constraint, e.g. 3.9 for >=3.9,<4 or ruby-3.2.2
+func versionNumber(version string) string {
+	number := versionNumberRegex.FindString(version)
+	if number == "" {
+		return version
+	}
+	return number
+}
+
+// pinnedVersion is what read finds in the file closest to path, nil when there is no such file or
+// it does not pin a version, so the next recognizer gets a chance
+func pinnedVersion(filenames []string, path string, read func(string) string) *string {
+	paths := recursivelyFindFile(filenames, path)
+	if len(paths) == 0 {
+		return nil
+	}
+	// the files at the root of the project win over the ones of its subprojects
+	sort.Slice(paths, func(i, j int) bool {
+		depthI, depthJ := strings.Count(paths[i], "/"), strings.Count(paths[j], "/")
+		if depthI != depthJ {
+			return depthI < depthJ
+		}
+		return paths[i] < paths[j]
+	})
+	contents, err := files.CatFile(paths[0])
+	if err != nil {
+		return nil
+	}
+	version := strings.TrimSpace(read(contents))
+	if version == "" {
+		return nil
+	}
+	return &version
+}
+
+// projectFiles recognizes a dependency without a pinned version, so it goes last in its list
+func projectFiles(filenames []string, path string) *string {
+	paths := recursivelyFindFile(filenames, path)
+	if len(paths) > 0 {
+		retval := ""
+		return &retval
+	}
+	return nil
+}
+
+func firstOf(reads ...func(string) string) func(string) string {
+	return func(contents string) string {
+		for _, read := range reads {
+			if value := read(contents); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+}
+
+func submatch(re *regexp.Regexp) func(string) string {
+	return func(contents string) string {
+		match := re.FindStringSubmatch(contents)
+		if len(match) < 2 {
+			return ""
+		}
+		return match[1]
+	}
+}
+
+// firstLine skips blank lines and comments, as in .python-version
+func firstLine(contents string) string {
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// tomlString is the value of key in section, which is enough for the one line versions of
+// Pipfile, pyproject.toml and rust-toolchain.toml
+func tomlString(section string, key string) func(string) string {
+	return func(contents string) string {
+		current := ""
+		for _, line := range strings.Split(contents, "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "[") {
+				current = strings.Trim(line, "[] ")
+				continue
+			}
+			k, v, found := strings.Cut(line, "=")
+			if !found || current != section || strings.TrimSpace(k) != key {
+				continue
+			}
+			v = strings.TrimSpace(v)
+			if len(v) > 0 && (v[0] == '"' || v[0] == '\'') {
+				if end := strings.IndexByte(v[1:], v[0]); end >= 0 {
+					return v[1 : end+1]
+				}
+			}
+			return v
+		}
+		return ""
+	}
+}
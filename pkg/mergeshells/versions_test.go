@@ -0,0 +1,160 @@
+package mergeshells
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDependenciesVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{
+			name:     "python-version file",
+			files:    map[string]string{".python-version": "# pinned\n3.11.4\n"},
+			expected: []string{"python-3.11.4"},
+		},
+		{
+			name:     "python requirements only",
+			files:    map[string]string{"requirements-dev.txt": "pytest\n"},
+			expected: []string{"python"},
+		},
+		{
+			name:     "pipfile",
+			files:    map[string]string{"Pipfile": "[packages]\nrequests = \"*\"\n\n[requires]\npython_version = \"3.8\"\n"},
+			expected: []string{"python-3.8"},
+		},
+		{
+			name:     "pyproject requires-python",
+			files:    map[string]string{"pyproject.toml": "[project]\nname = \"app\"\nrequires-python = \">=3.9,<4\"\n"},
+			expected: []string{"python-3.9"},
+		},
+		{
+			name:     "pyproject poetry",
+			files:    map[string]string{"pyproject.toml": "[tool.poetry.dependencies]\npython = \"^3.10\"\n"},
+			expected: []string{"python-3.10"},
+		},
+		{
+			name:     "python-version wins over pyproject",
+			files:    map[string]string{".python-version": "3.12\n", "pyproject.toml": "[project]\nrequires-python = \">=3.9\"\n"},
+			expected: []string{"python-3.12"},
+		},
+		{
+			name:     "root project wins over subprojects",
+			files:    map[string]string{".python-version": "3.12\n", "sub/.python-version": "3.7\n"},
+			expected: []string{"python-3.12"},
+		},
+		{
+			name:     "conda environment",
+			files:    map[string]string{"environment.yml": "name: app\ndependencies:\n  - python=3.10\n  - numpy\n"},
+			expected: []string{"conda-3.10"},
+		},
+		{
+			name:     "conda environment without python",
+			files:    map[string]string{"environment.yaml": "name: app\ndependencies:\n  - numpy\n"},
+			expected: []string{"conda"},
+		},
+		{
+			name:     "gemfile",
+			files:    map[string]string{"Gemfile": "source \"https://rubygems.org\"\nruby \"3.2.2\"\n"},
+			expected: []string{"ruby-3.2.2"},
+		},
+		{
+			name:     "ruby-version file",
+			files:    map[string]string{".ruby-version": "ruby-3.1.4\n", "Gemfile": "ruby '3.2.2'\n"},
+			expected: []string{"ruby-3.1.4"},
+		},
+		{
+			name:     "gemfile lock only",
+			files:    map[string]string{"Gemfile.lock": "GEM\n"},
+			expected: []string{"ruby"},
+		},
+		{
+			name:     "sdkmanrc",
+			files:    map[string]string{".sdkmanrc": "java=17.0.9-tem\n", "build.gradle": "plugins { id 'java' }\n"},
+			expected: []string{"java-17"},
+		},
+		{
+			name:     "pom release",
+			files:    map[string]string{"pom.xml": "<project><properties><maven.compiler.release>21</maven.compiler.release></properties></project>"},
+			expected: []string{"java-21", "maven"},
+		},
+		{
+			name:     "pom legacy java version",
+			files:    map[string]string{"pom.xml": "<project><properties><java.version>1.8</java.version></properties></project>"},
+			expected: []string{"java-8", "maven"},
+		},
+		{
+			name:     "pom without a version",
+			files:    map[string]string{"pom.xml": "<project></project>"},
+			expected: []string{"java", "maven"},
+		},
+		{
+			name:     "gradle toolchain",
+			files:    map[string]string{"build.gradle.kts": "java { toolchain { languageVersion.set(JavaLanguageVersion.of(17)) } }\n"},
+			expected: []string{"java-17"},
+		},
+		{
+			name:     "gradle source compatibility",
+			files:    map[string]string{"build.gradle": "sourceCompatibility = JavaVersion.VERSION_11\n"},
+			expected: []string{"java-11"},
+		},
+		{
+			name:     "gradle settings only",
+			files:    map[string]string{"settings.gradle": "rootProject.name = 'app'\n"},
+			expected: []string{"java"},
+		},
+		{
+			name:     "rust-toolchain.toml",
+			files:    map[string]string{"rust-toolchain.toml": "[toolchain]\nchannel = \"nightly-2024-01-01\"\ncomponents = [\"rustfmt\"]\n", "Cargo.toml": "[package]\n"},
+			expected: []string{"rust-nightly-2024-01-01"},
+		},
+		{
+			name:     "legacy rust-toolchain",
+			files:    map[string]string{"rust-toolchain": "1.75.0\n"},
+			expected: []string{"rust-1.75.0"},
+		},
+		{
+			name:     "cargo only",
+			files:    map[string]string{"Cargo.toml": "[package]\n"},
+			expected: []string{"rust"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, sortedDependencies(writeProject(t, tt.files)))
+		})
+	}
+}
+
+func TestJavaMajorVersion(t *testing.T) {
+	tests := map[string]string{
+		"1.8":        "8",
+		"1_8":        "8",
+		"8":          "8",
+		"11":         "11",
+		"17.0.9":     "17",
+		"17.0.9-tem": "17",
+		"21-open":    "21",
+	}
+	for version, expected := range tests {
+		assert.Equal(t, expected, javaMajorVersion(version), version)
+	}
+}
+
+func TestVersionNumber(t *testing.T) {
+	tests := map[string]string{
+		">=3.9,<4":   "3.9",
+		"^3.10":      "3.10",
+		"ruby-3.2.2": "3.2.2",
+		"3.11.4":     "3.11.4",
+		"3.*":        "3",
+		"system":     "system",
+	}
+	for version, expected := range tests {
+		assert.Equal(t, expected, versionNumber(version), version)
+	}
+}
@@ -0,0 +1,419 @@
+package setupworkspace
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/hashicorp/go-multierror"
+)
+
+// setupNode is a repo or exec that is run once all of its dependencies succeeded
+type setupNode struct {
+	Name string
+	// Field is where the node is defined in the setup params, used in validation errors
+	Field     string
+	DependsOn []string
+	Run       func() error
+}
+
+type setupNodeStatus string
+
+const (
+	nodeSucceeded setupNodeStatus = "success"
+	nodeFailed    setupNodeStatus = "failed"
+	nodeSkipped   setupNodeStatus = "skipped"
+)
+
+type setupNodeResult struct {
+	Name   string
+	Status setupNodeStatus
+	Err    error
+	// the failed or skipped dependency that caused this node to be skipped
+	SkippedBecause string
+}
+
+type setupGraph struct {
+	// kind is "repo" or "exec" and is only used in messages
+	kind  string
+	nodes map[string]setupNode
+}
+
+func newSetupGraph(kind string, nodes ...setupNode) (*setupGraph, error) {
+	g := &setupGraph{kind: kind, nodes: map[string]setupNode{}}
+	var dupErr error
+	for _, n := range nodes {
+		if other, ok := g.nodes[n.Name]; ok {
+			dupErr = multierror.Append(dupErr, SetupFieldError{Field: n.Field, Message: fmt.Sprintf("%s name %s is also used by %s", kind, n.Name, other.Field)})
+			continue
+		}
+		g.nodes[n.Name] = n
+	}
+	if dupErr != nil {
+		return nil, breverrors.WrapAndTrace(dupErr)
+	}
+	return g, nil
+}
+
+func (g setupGraph) sortedNames() []string {
+	names := make([]string, 0, len(g.nodes))
+	for n := range g.nodes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that every dependency exists and that there are no cycles
+func (g setupGraph) Validate() error {
+	var graphErr error
+	for _, name := range g.sortedNames() {
+		for _, dep := range g.nodes[name].DependsOn {
+			if _, ok := g.nodes[dep]; !ok {
+				graphErr = multierror.Append(graphErr, SetupFieldError{Field: g.nodes[name].Field + ".dependsOn", Message: fmt.Sprintf("%s %s does not exist", g.kind, dep)})
+			}
+		}
+	}
+	if graphErr != nil {
+		return breverrors.WrapAndTrace(graphErr)
+	}
+	if cycle := g.findCycle(); cycle != nil {
+		return breverrors.WrapAndTrace(SetupFieldError{Field: g.nodes[cycle[0]].Field + ".dependsOn", Message: fmt.Sprintf("%s dependency cycle %s", g.kind, strings.Join(cycle, " -> "))})
+	}
+	return nil
+}
+
+// findCycle returns the names making up a cycle including the repeated start, or nil
+func (g setupGraph) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	stack := []string{}
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		deps := append([]string{}, g.nodes[name].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, ok := g.nodes[dep]; !ok {
+				continue
+			}
+			switch state[dep] {
+			case visiting:
+				for i, s := range stack {
+					if s == dep {
+						return append(append([]string{}, stack[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range g.sortedNames() {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// TopologicalOrder returns the node names so that every node comes after its dependencies,
+// breaking ties alphabetically so the order is stable
+func (g setupGraph) TopologicalOrder() ([]string, error) {
+	err := g.Validate()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	inDegree := map[string]int{}
+	dependents := map[string][]string{}
+	for _, name := range g.sortedNames() {
+		for _, dep := range uniqStrings(g.nodes[name].DependsOn) {
+			inDegree[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+	ready := []string{}
+	for _, name := range g.sortedNames() {
+		if inDegree[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	order := []string{}
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, d := range dependents[name] {
+			inDegree[d]--
+			if inDegree[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	return order, nil
+}
+
+// Run runs every node as soon as its dependencies have succeeded, running independent
+// nodes in parallel. Dependents of a failed node are skipped. Results are in topological order.
+func (g setupGraph) Run() ([]setupNodeResult, error) {
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+
+	var mu sync.Mutex
+	results := map[string]setupNodeResult{}
+	done := map[string]chan struct{}{}
+	for _, name := range order {
+		done[name] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range order {
+		wg.Add(1)
+		go func(node setupNode) {
+			defer wg.Done()
+			defer close(done[node.Name])
+			for _, dep := range node.DependsOn {
+				<-done[dep]
+			}
+
+			mu.Lock()
+			blockedBy := ""
+			for _, dep := range node.DependsOn {
+				if results[dep].Status != nodeSucceeded {
+					blockedBy = dep
+					break
+				}
+			}
+			mu.Unlock()
+
+			res := setupNodeResult{Name: node.Name}
+			if blockedBy != "" {
+				res.Status = nodeSkipped
+				res.SkippedBecause = blockedBy
+			} else if runErr := node.Run(); runErr != nil {
+				res.Status = nodeFailed
+				res.Err = runErr
+			} else {
+				res.Status = nodeSucceeded
+			}
+
+			mu.Lock()
+			results[node.Name] = res
+			mu.Unlock()
+		}(g.nodes[name])
+	}
+	wg.Wait()
+
+	orderedResults := make([]setupNodeResult, 0, len(order))
+	for _, name := range order {
+		orderedResults = append(orderedResults, results[name])
+	}
+	return orderedResults, nil
+}
+
+func uniqStrings(xs []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, x := range xs {
+		if !seen[x] {
+			seen[x] = true
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func printSetupSummary(kind string, results []setupNodeResult) {
+	fmt.Printf("------ %s summary ------\n", kind)
+	for _, r := range results {
+		switch r.Status {
+		case nodeSucceeded:
+			fmt.Printf("%s %s: success\n", kind, r.Name)
+		case nodeFailed:
+			fmt.Printf("%s %s: failed: %v\n", kind, r.Name, r.Err)
+		case nodeSkipped:
+			fmt.Printf("%s %s: skipped, dependency %s did not succeed\n", kind, r.Name, r.SkippedBecause)
+		}
+	}
+}
+
+// resultsToError collects the failures and skips into a single error, or nil if everything succeeded
+func resultsToError(kind string, results []setupNodeResult) error {
+	var allErr error
+	for _, r := range results {
+		switch r.Status {
+		case nodeFailed:
+			allErr = multierror.Append(allErr, breverrors.WrapAndTrace(r.Err, fmt.Sprintf("%s failed %s", kind, r.Name)))
+		case nodeSkipped:
+			allErr = multierror.Append(allErr, fmt.Errorf("%s skipped %s: dependency %s did not succeed", kind, r.Name, r.SkippedBecause))
+		case nodeSucceeded:
+		}
+	}
+	if allErr != nil {
+		return breverrors.WrapAndTrace(allErr)
+	}
+	return nil
+}
+
+// versionedNames keeps a V0 and a V1 repo or exec with the same name apart so that both
+// still run. Names used by only one version are left as they are.
+type versionedNames map[string]bool
+
+func newVersionedNames(namesV0, namesV1 []string) versionedNames {
+	inV0 := map[string]bool{}
+	for _, n := range namesV0 {
+		inV0[n] = true
+	}
+	shared := versionedNames{}
+	for _, n := range namesV1 {
+		if inV0[n] {
+			shared[n] = true
+		}
+	}
+	return shared
+}
+
+// name is the graph name of the node called name in the given version ("v0" or "v1")
+func (v versionedNames) name(name, version string) string {
+	if v[name] {
+		return fmt.Sprintf("%s (%s)", name, version)
+	}
+	return name
+}
+
+// dependsOn resolves a dependency on a shared name to both versions of it, except for self
+func (v versionedNames) dependsOn(self string, deps []string) []string {
+	out := []string{}
+	for _, d := range deps {
+		if !v[d] {
+			out = append(out, d)
+			continue
+		}
+		for _, versioned := range []string{v.name(d, "v0"), v.name(d, "v1")} {
+			if versioned != self {
+				out = append(out, versioned)
+			}
+		}
+	}
+	return out
+}
+
+func execNodes(execsV0 entity.ExecsV0, execsV1 entity.ExecsV1, runV0 func(entity.ExecName, entity.ExecV0) error, runV1 func(entity.ExecName, entity.ExecV1) error) []setupNode {
+	namesV0, namesV1 := []string{}, []string{}
+	for n := range execsV0 {
+		namesV0 = append(namesV0, string(n))
+	}
+	for n := range execsV1 {
+		namesV1 = append(namesV1, string(n))
+	}
+	versioned := newVersionedNames(namesV0, namesV1)
+
+	nodes := []setupNode{}
+	for n, e := range execsV0 {
+		name, exec := n, e
+		nodeName := versioned.name(string(name), "v0")
+		nodes = append(nodes, setupNode{
+			Name:      nodeName,
+			Field:     fmt.Sprintf("execs[%s]", name),
+			DependsOn: versioned.dependsOn(nodeName, exec.DependsOn),
+			Run:       func() error { return runV0(name, exec) },
+		})
+	}
+	for n, e := range execsV1 {
+		name, exec := n, e
+		deps := []string{}
+		for _, d := range exec.DependsOn {
+			deps = append(deps, string(d))
+		}
+		nodeName := versioned.name(string(name), "v1")
+		nodes = append(nodes, setupNode{
+			Name:      nodeName,
+			Field:     fmt.Sprintf("execsV1[%s]", name),
+			DependsOn: versioned.dependsOn(nodeName, deps),
+			Run:       func() error { return runV1(name, exec) },
+		})
+	}
+	return nodes
+}
+
+func repoNodes(reposV0 entity.ReposV0, reposV1 entity.ReposV1, runV0 func(entity.RepoName, entity.RepoV0) error, runV1 func(entity.RepoName, entity.RepoV1) error) []setupNode {
+	namesV0, namesV1 := []string{}, []string{}
+	for n := range reposV0 {
+		namesV0 = append(namesV0, string(n))
+	}
+	for n := range reposV1 {
+		namesV1 = append(namesV1, string(n))
+	}
+	versioned := newVersionedNames(namesV0, namesV1)
+
+	nodes := []setupNode{}
+	for n, r := range reposV1 {
+		name, repo := n, r
+		nodes = append(nodes, setupNode{
+			Name:  versioned.name(string(name), "v1"),
+			Field: fmt.Sprintf("reposV1[%s]", name),
+			Run:   func() error { return runV1(name, repo) },
+		})
+	}
+	for n, r := range reposV0 {
+		name, repo := n, r
+		nodeName := versioned.name(string(name), "v0")
+		nodes = append(nodes, setupNode{
+			Name:      nodeName,
+			Field:     fmt.Sprintf("repos[%s]", name),
+			DependsOn: versioned.dependsOn(nodeName, repo.DependsOn),
+			Run:       func() error { return runV0(name, repo) },
+		})
+	}
+	return nodes
+}
+
+// validateDependencies checks the repo and exec dependency graphs without running anything
+func validateDependencies(params store.SetupParamsV0) error {
+	var allErr error
+	repos := repoNodes(params.ReposV0, params.ReposV1,
+		func(entity.RepoName, entity.RepoV0) error { return nil },
+		func(entity.RepoName, entity.RepoV1) error { return nil },
+	)
+	execs := execNodes(params.ExecsV0, params.ExecsV1,
+		func(entity.ExecName, entity.ExecV0) error { return nil },
+		func(entity.ExecName, entity.ExecV1) error { return nil },
+	)
+	for _, g := range []struct {
+		kind  string
+		nodes []setupNode
+	}{{"repo", repos}, {"exec", execs}} {
+		graph, err := newSetupGraph(g.kind, g.nodes...)
+		if err != nil {
+			allErr = multierror.Append(allErr, err)
+			continue
+		}
+		err = graph.Validate()
+		if err != nil {
+			allErr = multierror.Append(allErr, err)
+		}
+	}
+	if allErr != nil {
+		return breverrors.WrapAndTrace(allErr)
+	}
+	return nil
+}
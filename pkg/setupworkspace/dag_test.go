@@ -0,0 +1,184 @@
+package setupworkspace
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func noopNode(name string, deps ...string) setupNode {
+	return setupNode{Name: name, Field: name, DependsOn: deps, Run: func() error { return nil }}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	g, err := newSetupGraph("exec",
+		noopNode("c", "b"),
+		noopNode("b", "a"),
+		noopNode("a"),
+		noopNode("d", "a"),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+	order, err := g.TopologicalOrder()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, order)
+}
+
+func TestValidateGraphMissingDependency(t *testing.T) {
+	g, err := newSetupGraph("exec", noopNode("a", "nope"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	err = g.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "a.dependsOn: exec nope does not exist")
+	}
+}
+
+func TestValidateGraphCycle(t *testing.T) {
+	g, err := newSetupGraph("repo",
+		noopNode("a", "c"),
+		noopNode("b", "a"),
+		noopNode("c", "b"),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+	err = g.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "a.dependsOn: repo dependency cycle a -> c -> b -> a")
+	}
+}
+
+func TestNewSetupGraphDuplicate(t *testing.T) {
+	_, err := newSetupGraph("exec", noopNode("a"), noopNode("a"))
+	assert.Error(t, err)
+}
+
+func TestRunGraphSkipsDependentsOfFailure(t *testing.T) {
+	var mu sync.Mutex
+	ran := []string{}
+	record := func(name string, fail bool) setupNode {
+		return setupNode{Name: name, Run: func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, name)
+			if fail {
+				return fmt.Errorf("boom")
+			}
+			return nil
+		}}
+	}
+	a := record("a", true)
+	b := record("b", false)
+	b.DependsOn = []string{"a"}
+	c := record("c", false)
+	c.DependsOn = []string{"b"}
+	d := record("d", false)
+
+	g, err := newSetupGraph("exec", a, b, c, d)
+	if !assert.Nil(t, err) {
+		return
+	}
+	results, err := g.Run()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.ElementsMatch(t, []string{"a", "d"}, ran)
+	assert.Equal(t, []setupNodeStatus{nodeFailed, nodeSkipped, nodeSkipped, nodeSucceeded},
+		[]setupNodeStatus{results[0].Status, results[1].Status, results[2].Status, results[3].Status})
+	assert.Equal(t, "a", results[1].SkippedBecause)
+	assert.Equal(t, "b", results[2].SkippedBecause)
+	assert.Error(t, resultsToError("exec", results))
+}
+
+func TestRunGraphRunsDependenciesFirst(t *testing.T) {
+	var mu sync.Mutex
+	finished := map[string]bool{}
+	node := func(name string, deps ...string) setupNode {
+		return setupNode{Name: name, DependsOn: deps, Run: func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, d := range deps {
+				if !finished[d] {
+					return fmt.Errorf("%s ran before %s", name, d)
+				}
+			}
+			finished[name] = true
+			return nil
+		}}
+	}
+	g, err := newSetupGraph("exec", node("d", "b", "c"), node("c", "a"), node("b", "a"), node("a"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	results, err := g.Run()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, resultsToError("exec", results))
+}
+
+func TestValidateSetupDependencies(t *testing.T) {
+	err := ValidateSetup(store.SetupParamsV0{
+		ExecsV0: entity.ExecsV0{"a": {DependsOn: []string{"b"}}},
+		ExecsV1: entity.ExecsV1{"b": {ExecOptions: entity.ExecOptions{DependsOn: []entity.ExecName{"a"}}}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "execs[a].dependsOn: exec dependency cycle a -> b -> a")
+	}
+
+	err = ValidateSetup(store.SetupParamsV0{
+		ReposV0: entity.ReposV0{"a": {DependsOn: []string{"missing"}}},
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "repos[a].dependsOn: repo missing does not exist")
+	}
+}
+
+func TestExecNodesSameNameInV0AndV1(t *testing.T) {
+	var mu sync.Mutex
+	ran := []string{}
+	record := func(version string, name entity.ExecName) error {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, version+":"+string(name))
+		return nil
+	}
+	nodes := execNodes(
+		entity.ExecsV0{"setup": {}, "after": {DependsOn: []string{"setup"}}},
+		entity.ExecsV1{"setup": {ExecOptions: entity.ExecOptions{DependsOn: []entity.ExecName{"setup"}}}},
+		func(name entity.ExecName, _ entity.ExecV0) error { return record("v0", name) },
+		func(name entity.ExecName, _ entity.ExecV1) error { return record("v1", name) },
+	)
+	g, err := newSetupGraph("exec", nodes...)
+	if !assert.Nil(t, err) {
+		return
+	}
+	order, err := g.TopologicalOrder()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"setup (v0)", "setup (v1)", "after"}, order)
+
+	results, err := g.Run()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, resultsToError("exec", results))
+	assert.ElementsMatch(t, []string{"v0:setup", "v1:setup", "v0:after"}, ran)
+
+	assert.Nil(t, validateDependencies(store.SetupParamsV0{
+		ExecsV0: entity.ExecsV0{"setup": {}},
+		ExecsV1: entity.ExecsV1{"setup": {}},
+		ReposV0: entity.ReposV0{"repo": {}},
+		ReposV1: entity.ReposV1{"repo": {}},
+	}))
+}
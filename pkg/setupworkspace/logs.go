@@ -0,0 +1,109 @@
+package setupworkspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/brev-cli/pkg/util"
+)
+
+const MainLogSourceName = "setup"
+
+// LogSource is a log written on the instance during setup, Path is the latest log and
+// ArchiveGlob matches every archived run of it
+type LogSource struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	ArchiveGlob string `json:"archiveGlob,omitempty"`
+}
+
+func getLogNamePrefix(setupExecPath string) string {
+	return util.RemoveFileExtenstion(filepath.Base(setupExecPath))
+}
+
+func makeExecLogSource(name string, setupExecPath string, logsPath string, archivePath string) LogSource {
+	namePrefix := getLogNamePrefix(setupExecPath)
+	if archivePath == "" {
+		archivePath = filepath.Join(logsPath, "archive")
+	}
+	return LogSource{
+		Name:        name,
+		Path:        filepath.Join(logsPath, fmt.Sprintf("%s.log", namePrefix)),
+		ArchiveGlob: filepath.Join(archivePath, fmt.Sprintf("%s-*.log", namePrefix)),
+	}
+}
+
+// GetLogSources lists where RunSetupScript writes the logs of every exec and repo setup
+// script, the main setup log comes first
+func (w WorkspaceIniter) GetLogSources() ([]LogSource, error) {
+	sources := []LogSource{}
+	dotBrev := filepath.Join(w.BuildWorkspacePath(), ".brev")
+	for n := range w.ExecsV0 {
+		sources = append(sources, makeExecLogSource(string(n), filepath.Join(dotBrev, string(n)), filepath.Join(dotBrev, "logs"), ""))
+	}
+	for n, e := range w.ExecsV1 {
+		execPath, err := w.GetExecPath(n, e)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		logPath, err := w.GetLogPath(n, e)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		logArchPath, err := w.GetLogArchivePath(n, e)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		sources = append(sources, makeExecLogSource(string(n), execPath, logPath, logArchPath))
+	}
+	for n, r := range w.ReposV0 {
+		repoPath := filepath.Join(w.BuildWorkspacePath(), r.Directory)
+		logsPath := filepath.Join(repoPath, r.BrevPath, "logs")
+		sources = append(sources, makeExecLogSource(string(n), filepath.Join(repoPath, r.SetupExecPath), logsPath, ""))
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return append([]LogSource{{Name: MainLogSourceName, Path: WorkspaceLogPath}}, sources...), nil
+}
+
+// GetLogSourcesFromParams works out the log sources of an instance from its setup params
+func GetLogSourcesFromParams(params store.SetupParamsV0) ([]LogSource, error) {
+	wi := NewWorkspaceIniter(DefaultWorkspaceDir, nil, &params)
+	sources, err := wi.GetLogSources()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return sources, nil
+}
+
+func FindLogSource(sources []LogSource, name string) (*LogSource, bool) {
+	for i := range sources {
+		if sources[i].Name == name {
+			return &sources[i], true
+		}
+	}
+	return nil, false
+}
+
+// AppendSetupLogNote adds a timestamped line to the main setup log and flushes it to disk,
+// so the note survives the instance being stopped right after
+func AppendSetupLogNote(logPath string, note string) error {
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644) //nolint:gosec // log is world readable like the rest of setup
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	defer f.Close() //nolint:errcheck // synced below
+	_, err = fmt.Fprintf(f, "%s %s\n", time.Now().UTC().Format(time.RFC3339), note)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = f.Sync()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package setupworkspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/collections"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLogSourcesFromParams(t *testing.T) {
+	sources, err := GetLogSourcesFromParams(store.SetupParamsV0{
+		ExecsV0: entity.ExecsV0{"setup.sh": {Exec: "echo hi"}},
+		ExecsV1: entity.ExecsV1{
+			"build": {
+				Type:        entity.StringExecType,
+				ExecOptions: entity.ExecOptions{LogPath: collections.Ptr("/tmp/build-logs")},
+			},
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !assert.NotEmpty(t, sources) {
+		return
+	}
+	assert.Equal(t, LogSource{Name: MainLogSourceName, Path: WorkspaceLogPath}, sources[0])
+
+	build, ok := FindLogSource(sources, "build")
+	if assert.True(t, ok) {
+		assert.Equal(t, "/tmp/build-logs/build.log", build.Path)
+		assert.Equal(t, "/tmp/build-logs/archive/build-*.log", build.ArchiveGlob)
+	}
+	setup, ok := FindLogSource(sources, "setup.sh")
+	if assert.True(t, ok) {
+		assert.Equal(t, "/home/brev/workspace/.brev/logs/setup.log", setup.Path)
+		assert.Equal(t, "/home/brev/workspace/.brev/logs/archive/setup-*.log", setup.ArchiveGlob)
+	}
+	_, ok = FindLogSource(sources, "missing")
+	assert.False(t, ok)
+}
+
+func TestAppendSetupLogNote(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "brev-workspace.log")
+	err := os.WriteFile(logPath, []byte("setup output\n"), 0o600)
+	if !assert.Nil(t, err) {
+		return
+	}
+	err = AppendSetupLogNote(logPath, "brev stop self: stopping instance")
+	assert.Nil(t, err)
+
+	b, err := os.ReadFile(logPath)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "setup output", lines[0])
+		assert.True(t, strings.HasSuffix(lines[1], " brev stop self: stopping instance"))
+	}
+}
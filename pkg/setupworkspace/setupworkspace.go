@@ -28,15 +28,20 @@ import (
 	"github.com/hashicorp/go-multierror"
 )
 
+const (
+	DefaultWorkspaceDir = "/home/brev/workspace"
+	// WorkspaceLogPath gets everything printed during setup
+	WorkspaceLogPath = "/var/log/brev-workspace.log"
+)
+
 func SetupWorkspace(params *store.SetupParamsV0) error {
 	user, err := GetUserFromUserStr("brev")
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
 
-	workspaceDir := "/home/brev/workspace"
-	wi := NewWorkspaceIniter(workspaceDir, user, params)
-	logFilePath := "/var/log/brev-workspace.log"
+	wi := NewWorkspaceIniter(DefaultWorkspaceDir, user, params)
+	logFilePath := WorkspaceLogPath
 	done, err := mirrorPipesToFile(logFilePath)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
@@ -44,6 +49,12 @@ func SetupWorkspace(params *store.SetupParamsV0) error {
 	defer done()
 	fmt.Printf("brev %s\n", version.Version)
 
+	err = ValidateSetup(*params)
+	if err != nil {
+		fmt.Println("------ Invalid Setup ------")
+		return breverrors.WrapAndTrace(err)
+	}
+
 	fmt.Println("------ Setup Begin ------")
 	err = wi.Setup()
 	fmt.Println("------ Setup End ------")
@@ -272,6 +283,9 @@ func InitRepos(repos entity.ReposV0) entity.ReposV0 {
 
 func getDefaultProjectFolderNameFromHost(host uri.Host) string {
 	slugSplitOnDash := strings.Split(host.GetSlug(), "-")
+	if len(slugSplitOnDash) < 3 {
+		return host.GetSlug()
+	}
 	nameSplitOnDash := slugSplitOnDash[:len(slugSplitOnDash)-2]
 	return strings.Join(nameSplitOnDash, "-")
 }
@@ -396,29 +410,19 @@ func (w WorkspaceIniter) Setup() error {
 }
 
 func (w WorkspaceIniter) SetupRepos() error {
-	var setupErr error
-	for n, r := range w.ReposV1 {
-		fmt.Printf("setting up repo v1 %s\n", n)
-		err := w.setupRepoV1(r)
-		if err != nil {
-			fmt.Printf("setup failed %s\n", n)
-			setupErr = multierror.Append(breverrors.WrapAndTrace(err, fmt.Sprintf("setup failed %s", n)))
-		} else {
-			fmt.Printf("setup success %s\n", n)
-		}
-	}
-	for n, r := range w.ReposV0 {
-		fmt.Printf("setting up repo v0 %s\n", n)
-		err := w.setupRepoV0(r)
-		if err != nil {
-			fmt.Printf("setup failed %s\n", n)
-			setupErr = multierror.Append(breverrors.WrapAndTrace(err, fmt.Sprintf("setup failed %s", n)))
-		} else {
-			fmt.Printf("setup success %s\n", n)
-		}
-	}
-	if setupErr != nil {
-		return breverrors.WrapAndTrace(setupErr)
+	nodes := repoNodes(w.ReposV0, w.ReposV1,
+		func(n entity.RepoName, r entity.RepoV0) error {
+			fmt.Printf("setting up repo v0 %s\n", n)
+			return w.setupRepoV0(r)
+		},
+		func(n entity.RepoName, r entity.RepoV1) error {
+			fmt.Printf("setting up repo v1 %s\n", n)
+			return w.setupRepoV1(r)
+		},
+	)
+	err := runSetupGraph("repo", nodes)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
 	}
 	return nil
 }
@@ -429,28 +433,29 @@ func (w WorkspaceIniter) RunExecs() error {
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-	var execErr error
-	for n, e := range w.ExecsV0 {
-		err := w.runExecV0(n, e)
-		if err != nil {
-			fmt.Printf("exec failed %s\n", n)
-			execErr = multierror.Append(breverrors.WrapAndTrace(err, fmt.Sprintf("exec failed %s", n)))
-		} else {
-			fmt.Printf("exec success %s\n", n)
-		}
+	nodes := execNodes(w.ExecsV0, w.ExecsV1, w.runExecV0, w.runExecV1)
+	err = runSetupGraph("exec", nodes)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
 	}
-	for n, e := range w.ExecsV1 {
-		_ = e
-		err := w.runExecV1(n, e)
-		if err != nil {
-			fmt.Printf("exec failed %s\n", n)
-			execErr = multierror.Append(breverrors.WrapAndTrace(err, fmt.Sprintf("exec failed %s", n)))
-		} else {
-			fmt.Printf("exec success %s\n", n)
-		}
+	return nil
+}
+
+// runSetupGraph validates the dependencies before running anything, then runs the nodes
+// in dependency order and prints a summary
+func runSetupGraph(kind string, nodes []setupNode) error {
+	graph, err := newSetupGraph(kind, nodes...)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	results, err := graph.Run()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
 	}
-	if execErr != nil {
-		return breverrors.WrapAndTrace(execErr)
+	printSetupSummary(kind, results)
+	err = resultsToError(kind, results)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
 	}
 	return nil
 }
@@ -609,8 +614,8 @@ func (w WorkspaceIniter) GetLogArchivePath(name entity.ExecName, exec entity.Exe
 		logArchPath = path.Join(logPath, "archive")
 	} else {
 		logArchPath = filepath.Join(w.BuildWorkspacePath(), *exec.LogArchivePath)
-		if path.IsAbs(*exec.LogPath) {
-			logArchPath = *exec.LogPath
+		if path.IsAbs(*exec.LogArchivePath) {
+			logArchPath = *exec.LogArchivePath
 		}
 	}
 	return logArchPath, nil
@@ -1279,7 +1284,7 @@ func (w WorkspaceIniter) GitCloneIfDNE(url string, dirPath string, branch string
 }
 
 func RunSetupScript(logsPath string, workingDir string, setupExecPath string, user *user.User, archivePath string) error {
-	namePrefix := util.RemoveFileExtenstion(filepath.Base(setupExecPath))
+	namePrefix := getLogNamePrefix(setupExecPath)
 	setupLogPath := filepath.Join(logsPath, fmt.Sprintf("%s.log", namePrefix))
 	if archivePath == "" {
 		archivePath = filepath.Join(logsPath, "archive")
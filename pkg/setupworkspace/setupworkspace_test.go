@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/brevdev/brev-cli/pkg/entity"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -86,3 +87,43 @@ func Test_getDefaultProjectFolderNameFromHost(t *testing.T) {
 	res = getDefaultProjectFolderNameFromHost("brevcli-zdud-brevdev.brev.sh")
 	assert.Equal(t, "brevcli", res)
 }
+
+func TestGetLogArchivePath(t *testing.T) {
+	wi := WorkspaceIniter{WorkspaceDir: "/home/ubuntu/workspace"}
+	strPtr := func(s string) *string { return &s }
+	tests := []struct {
+		name     string
+		exec     entity.ExecV1
+		expected string
+	}{
+		{
+			name:     "default next to the logs",
+			exec:     entity.ExecV1{Type: entity.StringExecType},
+			expected: "/home/ubuntu/workspace/.brev/logs/archive",
+		},
+		{
+			name:     "relative to the workspace",
+			exec:     entity.ExecV1{Type: entity.StringExecType, ExecOptions: entity.ExecOptions{LogArchivePath: strPtr("logs/old")}},
+			expected: "/home/ubuntu/workspace/logs/old",
+		},
+		{
+			name:     "absolute without a log path",
+			exec:     entity.ExecV1{Type: entity.StringExecType, ExecOptions: entity.ExecOptions{LogArchivePath: strPtr("/var/log/setup-archive")}},
+			expected: "/var/log/setup-archive",
+		},
+		{
+			name:     "absolute with a relative log path",
+			exec:     entity.ExecV1{Type: entity.StringExecType, ExecOptions: entity.ExecOptions{LogPath: strPtr("logs"), LogArchivePath: strPtr("/var/log/setup-archive")}},
+			expected: "/var/log/setup-archive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logArchPath, err := wi.GetLogArchivePath("setup", tt.exec)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tt.expected, logArchPath)
+		})
+	}
+}
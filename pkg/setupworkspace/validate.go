@@ -1,7 +1,221 @@
 package setupworkspace
 
-import "github.com/brevdev/brev-cli/pkg/store"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
-func ValidateSetup(_ store.SetupParamsV0) error {
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/brevdev/parse/pkg/parse"
+	"github.com/hashicorp/go-multierror"
+)
+
+// SetupFieldError is a single problem found in the setup params, Field is the
+// json path of the offending value e.g. execsV1[build].logPath
+type SetupFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e SetupFieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+type setupValidator struct {
+	errs error
+}
+
+func (v *setupValidator) addf(field string, format string, args ...interface{}) {
+	v.errs = multierror.Append(v.errs, SetupFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *setupValidator) add(err error) {
+	v.errs = multierror.Append(v.errs, err)
+}
+
+// ValidateSetup checks everything about the setup params that can be known before
+// running any of it, and returns all of the problems at once
+func ValidateSetup(params store.SetupParamsV0) error {
+	v := &setupValidator{}
+	v.validateReposV0(params.ReposV0)
+	v.validateReposV1(params.ReposV1)
+	v.validateRepoDirs(params.ReposV0, params.ReposV1)
+	v.validateExecsV1(params.ExecsV1)
+	err := validateDependencies(params)
+	if err != nil {
+		v.add(err)
+	}
+	if v.errs != nil {
+		return breverrors.WrapAndTrace(v.errs)
+	}
 	return nil
 }
+
+func (v *setupValidator) validateReposV0(repos entity.ReposV0) {
+	for _, n := range sortedRepoNames(repos) {
+		repo := initRepo(repos[n])
+		field := fmt.Sprintf("repos[%s]", n)
+		if repo.Repository != "" && !isParsableRepoURL(repo.Repository) {
+			v.addf(field+".repository", "could not parse git url %q", repo.Repository)
+		}
+		v.validateExecPath(field+".setupExecPath", repo.SetupExecPath)
+	}
+}
+
+func (v *setupValidator) validateReposV1(repos entity.ReposV1) {
+	for _, n := range sortedRepoNames(repos) {
+		repo := repos[n]
+		field := fmt.Sprintf("reposV1[%s]", n)
+		switch repo.Type {
+		case entity.GitRepoType:
+			if repo.GitRepo.Repository == "" {
+				v.addf(field+".repository", "git repo is missing a url")
+			} else if !isParsableRepoURL(repo.GitRepo.Repository) {
+				v.addf(field+".repository", "could not parse git url %q", repo.GitRepo.Repository)
+			}
+		case entity.EmptyRepoType:
+			if repo.EmptyDirectory == nil || *repo.EmptyDirectory == "" {
+				v.addf(field+".emptyRepoDirectory", "empty repo is missing a directory")
+			}
+		default:
+			v.addf(field+".type", "unknown repo type %q, must be one of: %s, %s", repo.Type, entity.GitRepoType, entity.EmptyRepoType)
+		}
+	}
+}
+
+// validateRepoDirs makes sure no two repos are set up in the same directory
+func (v *setupValidator) validateRepoDirs(reposV0 entity.ReposV0, reposV1 entity.ReposV1) {
+	dirs := map[string]string{}
+	addDir := func(field string, dir string) {
+		if dir == "" {
+			return
+		}
+		dir = filepath.Clean(dir)
+		if other, ok := dirs[dir]; ok {
+			v.addf(field, "directory %s is already used by %s", dir, other)
+			return
+		}
+		dirs[dir] = field
+	}
+	for _, n := range sortedRepoNames(reposV1) {
+		repo := reposV1[n]
+		if repo.Type == entity.EmptyRepoType && repo.EmptyDirectory == nil {
+			continue // already reported
+		}
+		dir, err := repo.GetDir()
+		if err != nil {
+			continue // already reported as an unknown type
+		}
+		addDir(fmt.Sprintf("reposV1[%s]", n), dir)
+	}
+	for _, n := range sortedRepoNames(reposV0) {
+		addDir(fmt.Sprintf("repos[%s].directory", n), initRepo(reposV0[n]).Directory)
+	}
+}
+
+func (v *setupValidator) validateExecsV1(execs entity.ExecsV1) {
+	for _, n := range sortedExecNames(execs) {
+		exec := execs[n]
+		field := fmt.Sprintf("execsV1[%s]", n)
+		if exec.Stage != nil && *exec.Stage != entity.StartStage && *exec.Stage != entity.BuildStage {
+			v.addf(field+".stage", "unknown exec stage %q, must be one of: %s, %s", *exec.Stage, entity.StartStage, entity.BuildStage)
+		}
+		switch exec.Type {
+		case entity.PathExecType:
+			if exec.ExecPath == "" {
+				v.addf(field+".execPath", "path exec is missing a path")
+			} else if !exec.IsDisabled {
+				v.validateExecPath(field+".execPath", exec.ExecPath)
+			}
+		case entity.StringExecType:
+		default:
+			v.addf(field+".type", "unknown exec type %q, must be one of: %s, %s", exec.Type, entity.StringExecType, entity.PathExecType)
+		}
+		if exec.IsDisabled {
+			continue
+		}
+		if exec.LogPath != nil && *exec.LogPath != "" {
+			v.validateLogDir(field+".logPath", *exec.LogPath)
+		}
+		if exec.LogArchivePath != nil && *exec.LogArchivePath != "" {
+			v.validateLogDir(field+".logArchivePath", *exec.LogArchivePath)
+		}
+	}
+}
+
+// validateExecPath accepts paths that do not exist yet since they may come from a
+// repo that has not been cloned, but an existing path must be a file
+func (v *setupValidator) validateExecPath(field string, execPath string) {
+	if strings.ContainsRune(execPath, 0) {
+		v.addf(field, "path contains a null byte")
+		return
+	}
+	if !filepath.IsAbs(execPath) {
+		return
+	}
+	info, err := os.Stat(execPath)
+	if err == nil && info.IsDir() {
+		v.addf(field, "%s is a directory, not a file", execPath)
+	}
+}
+
+// validateLogDir checks that an absolute log directory can be created and written to,
+// relative ones live in the workspace which is always writable
+func (v *setupValidator) validateLogDir(field string, logDir string) {
+	if strings.ContainsRune(logDir, 0) {
+		v.addf(field, "path contains a null byte")
+		return
+	}
+	if !filepath.IsAbs(logDir) {
+		return
+	}
+	dir := filepath.Clean(logDir)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				v.addf(field, "%s is not a directory", dir)
+				return
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".brev-write-check-")
+	if err != nil {
+		v.addf(field, "%s is not writable: %v", logDir, err)
+		return
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+func isParsableRepoURL(repoURL string) bool {
+	return parse.GetRepoNameFromOrigin(repoURL) != ""
+}
+
+func sortedRepoNames[R any](repos map[entity.RepoName]R) []entity.RepoName {
+	names := []entity.RepoName{}
+	for n := range repos {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func sortedExecNames(execs entity.ExecsV1) []entity.ExecName {
+	names := []entity.ExecName{}
+	for n := range execs {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
@@ -0,0 +1,71 @@
+package setupworkspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/collections"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/brevdev/brev-cli/pkg/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSetupValid(t *testing.T) {
+	logDir := filepath.Join(t.TempDir(), "logs", "not-yet-created")
+	err := ValidateSetup(store.SetupParamsV0{
+		ReposV0: entity.ReposV0{
+			"project": {Repository: "git@github.com:brevdev/brev-cli.git"},
+			"user":    {Repository: "https://github.com/brevdev/dotbrev", DependsOn: []string{"project"}},
+		},
+		ReposV1: entity.ReposV1{
+			"scratch": {Type: entity.EmptyRepoType, EmptyRepo: entity.EmptyRepo{EmptyDirectory: collections.Ptr("scratch")}},
+		},
+		ExecsV0: entity.ExecsV0{"setup.sh": {Exec: "echo hi"}},
+		ExecsV1: entity.ExecsV1{
+			"build": {
+				Type:        entity.PathExecType,
+				Stage:       collections.Ptr(entity.BuildStage),
+				PathExec:    entity.PathExec{ExecPath: "brev-cli/.brev/build.sh"},
+				ExecOptions: entity.ExecOptions{LogPath: &logDir, DependsOn: []entity.ExecName{"setup.sh"}},
+			},
+		},
+	})
+	assert.Nil(t, err)
+}
+
+func TestValidateSetupReportsAllProblems(t *testing.T) {
+	notADir := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(notADir, []byte{}, 0o600)
+	if !assert.Nil(t, err) {
+		return
+	}
+	err = ValidateSetup(store.SetupParamsV0{
+		ReposV0: entity.ReposV0{
+			"bad-url": {Repository: "not a url"},
+			"project": {Repository: "https://github.com/brevdev/brev-cli"},
+		},
+		ReposV1: entity.ReposV1{
+			"dup":     {Type: entity.GitRepoType, GitRepo: entity.GitRepo{Repository: "https://github.com/someone/brev-cli"}},
+			"unknown": {Type: "svn"},
+		},
+		ExecsV1: entity.ExecsV1{
+			"a": {Type: "python", Stage: collections.Ptr(entity.ExecStage("later"))},
+			"b": {Type: entity.PathExecType, PathExec: entity.PathExec{ExecPath: t.TempDir()}},
+			"c": {Type: entity.StringExecType, ExecOptions: entity.ExecOptions{LogPath: collections.Ptr(filepath.Join(notADir, "logs"))}},
+		},
+	})
+	if !assert.Error(t, err) {
+		return
+	}
+	msg := err.Error()
+	assert.Contains(t, msg, `repos[bad-url].repository: could not parse git url "not a url"`)
+	assert.Contains(t, msg, "repos[project].directory: directory brev-cli is already used by reposV1[dup]")
+	assert.Contains(t, msg, `reposV1[unknown].type: unknown repo type "svn"`)
+	assert.Contains(t, msg, `execsV1[a].type: unknown exec type "python"`)
+	assert.Contains(t, msg, `execsV1[a].stage: unknown exec stage "later"`)
+	assert.Contains(t, msg, "execsV1[b].execPath:")
+	assert.Contains(t, msg, "is a directory, not a file")
+	assert.Contains(t, msg, "execsV1[c].logPath:")
+	assert.Contains(t, msg, "is not a directory")
+}
@@ -5,31 +5,46 @@ import (
 	"encoding/xml"
 	"fmt"
 	"log"
+	"net/url"
 	"strings"
 	"text/template"
 
 	"github.com/brevdev/brev-cli/pkg/autostartconf"
+	"github.com/brevdev/brev-cli/pkg/cmd/version"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
 	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/brevdev/brev-cli/pkg/store"
 	"github.com/brevdev/brev-cli/pkg/tasks"
 	"github.com/hashicorp/go-multierror"
 )
 
 type ConfigUpdaterStore interface {
 	autostartconf.AutoStartStore
+	GetActiveOrganizationOrDefault() (*entity.Organization, error)
 	GetContextWorkspaces() ([]entity.Workspace, error)
 	WritePrivateKey(pem string) error
+	GetWorkspaceCache() (*store.WorkspaceCache, error)
+	WriteWorkspaceCache(orgID string, workspaces []entity.Workspace) (*store.WorkspaceCache, error)
 }
 
 type Config interface {
 	Update(workspaces []entity.Workspace) error
 }
 
+// StaleConfig is a Config that can tell it has to be rewritten even though no instance changed,
+// e.g. because its file was deleted or edited by hand
+type StaleConfig interface {
+	Config
+	IsStale(workspaces []entity.Workspace) (bool, error)
+}
+
 type ConfigUpdater struct {
 	Store      ConfigUpdaterStore
 	Configs    []Config
 	PrivateKey string
+	// Force rewrites the configs even if no instance changed since the last run
+	Force bool
 }
 
 func NewConfigUpdater(store ConfigUpdaterStore, configs []Config, privateKey string) *ConfigUpdater {
@@ -42,36 +57,96 @@ func NewConfigUpdater(store ConfigUpdaterStore, configs []Config, privateKey str
 
 var _ tasks.Task = ConfigUpdater{}
 
+// Run rewrites the configs when a running instance was added, removed or changed since the last
+// run, which is remembered in the workspace cache, or when the cli was upgraded. Otherwise only
+// the configs that are stale are rewritten
 func (c ConfigUpdater) Run() error {
 	err := c.Store.WritePrivateKey(c.PrivateKey)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-	workspaces, err := c.Store.GetContextWorkspaces()
+	org, err := c.Store.GetActiveOrganizationOrDefault()
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-	var runningWorkspaces []entity.Workspace
-	for _, workspace := range workspaces {
-		if workspace.Status == entity.Running {
-			runningWorkspaces = append(runningWorkspaces, workspace)
-		}
+	orgID := ""
+	if org != nil {
+		orgID = org.ID
 	}
+	workspaces, err := c.Store.GetContextWorkspaces()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	runningWorkspaces := filterRunning(workspaces)
 
+	changed, err := c.runningWorkspacesChanged(orgID, runningWorkspaces)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
 	var res error
 	for _, c := range c.Configs {
+		update := changed
+		if stale, ok := c.(StaleConfig); ok && !changed {
+			update, err = stale.IsStale(runningWorkspaces)
+			if err != nil {
+				// it can't be checked, so rewrite it to be safe
+				update = true
+			}
+		}
+		if !update {
+			continue
+		}
 		err := c.Update(runningWorkspaces)
 		if err != nil {
 			res = multierror.Append(res, err)
 		}
 	}
-
 	if res != nil {
+		// the cache is left alone so the next run tries again
 		return breverrors.WrapAndTrace(res)
 	}
+
+	_, err = c.Store.WriteWorkspaceCache(orgID, workspaces)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
 	return nil
 }
 
+func (c ConfigUpdater) runningWorkspacesChanged(orgID string, runningWorkspaces []entity.Workspace) (bool, error) {
+	if c.Force {
+		return true, nil
+	}
+	cache, err := c.Store.GetWorkspaceCache()
+	if err != nil {
+		// an unreadable cache only costs a full rewrite
+		return true, nil //nolint:nilerr // see above
+	}
+	if cache == nil || cache.OrgID != orgID || cache.CLIVersion != version.Version {
+		// a new cli may write the configs differently
+		return true, nil
+	}
+	cachedHash, err := store.HashWorkspaces(filterRunning(cache.Workspaces))
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	hash, err := store.HashWorkspaces(runningWorkspaces)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return cachedHash != hash, nil
+}
+
+func filterRunning(workspaces []entity.Workspace) []entity.Workspace {
+	var runningWorkspaces []entity.Workspace
+	for _, workspace := range workspaces {
+		if workspace.Status == entity.Running {
+			runningWorkspaces = append(runningWorkspaces, workspace)
+		}
+	}
+	return runningWorkspaces
+}
+
 func (c ConfigUpdater) GetTaskSpec() tasks.TaskSpec {
 	return tasks.TaskSpec{RunCronImmediately: true, Cron: "@every 3s"}
 }
@@ -110,9 +185,10 @@ type SSHConfigurerV2Store interface {
 	GetWSLHostBrevSSHConfigPath() (string, error)
 	GetWSLUserSSHConfig() (string, error)
 	WriteWSLUserSSHConfig(config string) error
+	Remove(path string) error
 }
 
-var _ Config = SSHConfigurerV2{}
+var _ StaleConfig = SSHConfigurerV2{}
 
 func NewSSHConfigurerV2(store SSHConfigurerV2Store) *SSHConfigurerV2 {
 	return &SSHConfigurerV2{
@@ -157,6 +233,130 @@ func (s SSHConfigurerV2) Update(workspaces []entity.Workspace) error {
 	return nil
 }
 
+// IsStale is true when the brev ssh config is missing or not what Update would write, or the
+// user's ssh config does not include it
+func (s SSHConfigurerV2) IsStale(workspaces []entity.Workspace) (bool, error) {
+	brevConfigPath, err := s.store.GetBrevSSHConfigPath()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	exists, err := s.store.FileExists(brevConfigPath)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return true, nil
+	}
+	current, err := s.store.GetFileAsString(brevConfigPath)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	newConfig, err := s.CreateNewSSHConfig(workspaces)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	if current != newConfig {
+		return true, nil
+	}
+
+	userConfigPath, err := s.store.GetUserSSHConfigPath()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	exists, err = s.store.FileExists(userConfigPath)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return true, nil
+	}
+	conf, err := s.store.GetUserSSHConfig()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return !doesUserSSHConfigIncludeBrevConfig(conf, brevConfigPath), nil
+}
+
+// Remove undoes Update: the brev ssh config with the host of every instance is deleted and its
+// Include is dropped from the user's ssh config, on WSL the ones on the windows host too. It
+// returns what it removed
+func (s SSHConfigurerV2) Remove() ([]string, error) {
+	brevConfigPath, err := s.store.GetBrevSSHConfigPath()
+	if err != nil {
+		return []string{}, breverrors.WrapAndTrace(err)
+	}
+	userConfigPath, err := s.store.GetUserSSHConfigPath()
+	if err != nil {
+		return []string{}, breverrors.WrapAndTrace(err)
+	}
+	removed, err := s.removeConfigAndInclude(brevConfigPath, userConfigPath, brevConfigPath,
+		s.store.GetUserSSHConfig, s.store.WriteUserSSHConfig)
+	if err != nil {
+		return removed, breverrors.WrapAndTrace(err)
+	}
+
+	// not a fatal error, off WSL there is nothing to remove, as in Update
+	wslRemoved, _ := s.removeWSLConfig()
+	return append(removed, wslRemoved...), nil
+}
+
+func (s SSHConfigurerV2) removeWSLConfig() ([]string, error) {
+	brevConfigPath, err := s.store.GetWSLHostBrevSSHConfigPath()
+	if err != nil {
+		return []string{}, breverrors.WrapAndTrace(err)
+	}
+	userConfigPath, err := s.store.GetWSLHostUserSSHConfigPath()
+	if err != nil {
+		return []string{}, breverrors.WrapAndTrace(err)
+	}
+	removed, err := s.removeConfigAndInclude(brevConfigPath, userConfigPath, toWindowsPath(brevConfigPath),
+		s.store.GetWSLUserSSHConfig, s.store.WriteWSLUserSSHConfig)
+	if err != nil {
+		return removed, breverrors.WrapAndTrace(err)
+	}
+	return removed, nil
+}
+
+// removeConfigAndInclude deletes brevConfigPath and drops the Include of includePath from the
+// user ssh config at userConfigPath
+func (s SSHConfigurerV2) removeConfigAndInclude(brevConfigPath string, userConfigPath string, includePath string,
+	getUserConfig func() (string, error), writeUserConfig func(string) error,
+) ([]string, error) {
+	removed := []string{}
+	exists, err := s.store.FileExists(brevConfigPath)
+	if err != nil {
+		return removed, breverrors.WrapAndTrace(err)
+	}
+	if exists {
+		err = s.store.Remove(brevConfigPath)
+		if err != nil {
+			return removed, breverrors.WrapAndTrace(err)
+		}
+		removed = append(removed, brevConfigPath)
+	}
+
+	exists, err = s.store.FileExists(userConfigPath)
+	if err != nil {
+		return removed, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return removed, nil
+	}
+	conf, err := getUserConfig()
+	if err != nil {
+		return removed, breverrors.WrapAndTrace(err)
+	}
+	newConf := RemoveIncludeFromUserConfig(conf, includePath)
+	if newConf != conf {
+		err = writeUserConfig(newConf)
+		if err != nil {
+			return removed, breverrors.WrapAndTrace(err)
+		}
+		removed = append(removed, fmt.Sprintf("Include of %s in %s", includePath, userConfigPath))
+	}
+	return removed, nil
+}
+
 func (s SSHConfigurerV2) CreateWSLConfig(workspaces []entity.Workspace) (string, error) {
 	configPath, err := s.store.GetWSLHostBrevSSHConfigPath()
 	if err != nil {
@@ -463,6 +663,12 @@ func makeIncludeBrevStr(brevSSHConfigPath string) string {
 	return fmt.Sprintf("Include \"%s\"\n", brevSSHConfigPath)
 }
 
+// RemoveIncludeFromUserConfig drops the Include line AddIncludeToUserConfig added for
+// brevConfigPath and leaves everything else as it was
+func RemoveIncludeFromUserConfig(conf string, brevConfigPath string) string {
+	return strings.ReplaceAll(conf, makeIncludeBrevStr(brevConfigPath), "")
+}
+
 func doesUserSSHConfigIncludeBrevConfig(conf string, brevConfigPath string) bool {
 	return strings.Contains(conf, makeIncludeBrevStr(brevConfigPath))
 }
@@ -591,7 +797,7 @@ type SSHConfigurerJetBrains struct {
 	store SSHConfigurerV2Store
 }
 
-var _ Config = SSHConfigurerJetBrains{}
+var _ StaleConfig = SSHConfigurerJetBrains{}
 
 func NewSSHConfigurerJetBrains(store SSHConfigurerV2Store) (*SSHConfigurerJetBrains, error) {
 	return &SSHConfigurerJetBrains{
@@ -612,6 +818,14 @@ func (s SSHConfigurerJetBrains) Update(workspaces []entity.Workspace) error {
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
+	written, err := s.isWritten(newConfig)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if written {
+		// gateway reloads the file when it changes, so it is only touched when it has to be
+		return nil
+	}
 
 	err = s.store.WriteJetBrainsConfig(newConfig)
 	if err != nil {
@@ -621,6 +835,46 @@ func (s SSHConfigurerJetBrains) Update(workspaces []entity.Workspace) error {
 	return nil
 }
 
+// IsStale is true when gateway is installed and its ssh configs are missing or not what Update
+// would write
+func (s SSHConfigurerJetBrains) IsStale(workspaces []entity.Workspace) (bool, error) {
+	doesJbPathExist, err := s.store.DoesJetbrainsFilePathExist()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	if !doesJbPathExist {
+		return false, nil
+	}
+	newConfig, err := s.CreateNewSSHConfig(workspaces)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	written, err := s.isWritten(newConfig)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return !written, nil
+}
+
+func (s SSHConfigurerJetBrains) isWritten(config string) (bool, error) {
+	path, err := s.store.GetJetBrainsConfigPath()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	exists, err := s.store.FileExists(path)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return false, nil
+	}
+	current, err := s.store.GetFileAsString(path)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return current == config, nil
+}
+
 func (s SSHConfigurerJetBrains) CreateNewSSHConfig(workspaces []entity.Workspace) (string, error) {
 	log.Print("creating new ssh config")
 
@@ -683,3 +937,17 @@ func makeJetbrainsConfigEntry(workspace entity.Workspace, privateKeyPath string)
 		// },
 	}
 }
+
+// MakeJetBrainsGatewayConnectURL points gateway at the same host, port and user
+// that SSHConfigurerJetBrains writes to the gateway ssh configs
+func MakeJetBrainsGatewayConnectURL(workspace entity.Workspace, projectPath string) string {
+	entry := makeJetbrainsConfigEntry(workspace, "")
+	params := url.Values{}
+	params.Set("type", "ssh")
+	params.Set("deploy", "false")
+	params.Set("host", entry.Host)
+	params.Set("port", entry.Port)
+	params.Set("user", entry.Username)
+	params.Set("projectPath", projectPath)
+	return fmt.Sprintf("jetbrains-gateway://connect#%s", params.Encode())
+}
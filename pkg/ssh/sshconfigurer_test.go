@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/brevdev/brev-cli/pkg/cmd/version"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	"github.com/brevdev/brev-cli/pkg/store"
 	"github.com/google/go-cmp/cmp"
@@ -110,6 +111,10 @@ func (d DummySSHConfigurerV2Store) GetFileAsString(_ string) (string, error) {
 	return "", nil
 }
 
+func (d DummySSHConfigurerV2Store) Remove(_ string) error {
+	return nil
+}
+
 // cannot use (DummySSHConfigurerV2Store literal) (value of type DummySSHConfigurerV2Store) as SSHConfigurerV2Store value in argument to NewSSHConfigurerV2: DummySSHConfigurerV2Store does not implement SSHConfigurerV2Store (missing method GetWSLHostBrevSSHConfigPath)
 func (d DummySSHConfigurerV2Store) GetWSLHostBrevSSHConfigPath() (string, error) {
 	return "", nil
@@ -260,6 +265,21 @@ blaksdf;asdf;
 	assert.Equal(t, correct, newConf)
 }
 
+func TestRemoveIncludeFromUserConfig(t *testing.T) {
+	userConf := `Host foo
+  HostName bar
+`
+	withInclude, err := AddIncludeToUserConfig(userConf, "/my/brev/config")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, userConf, RemoveIncludeFromUserConfig(withInclude, "/my/brev/config"))
+	// includes of other profiles stay
+	other := makeIncludeBrevStr("/my/brev/profiles/staging/ssh_config") + withInclude
+	assert.Equal(t, makeIncludeBrevStr("/my/brev/profiles/staging/ssh_config")+userConf, RemoveIncludeFromUserConfig(other, "/my/brev/config"))
+	assert.Equal(t, userConf, RemoveIncludeFromUserConfig(userConf, "/my/brev/config"))
+}
+
 func Test_makeSSHConfigEntryV2(t *testing.T) { //nolint:funlen // test
 	type args struct {
 		workspace      entity.Workspace
@@ -763,3 +783,180 @@ Host testName1-host
 		})
 	}
 }
+
+func TestMakeJetBrainsGatewayConnectURL(t *testing.T) {
+	w := entity.Workspace{
+		Name:    "my-ws",
+		DNS:     "my-ws.brev.sh",
+		SSHPort: 2222,
+	}
+	res := MakeJetBrainsGatewayConnectURL(w, "/home/ubuntu/my-repo")
+	assert.Equal(t, "jetbrains-gateway://connect#deploy=false&host=my-ws.brev.sh&port=2222&projectPath=%2Fhome%2Fubuntu%2Fmy-repo&type=ssh&user=ubuntu", res)
+}
+
+type countingConfig struct {
+	updates [][]entity.Workspace
+}
+
+func (c *countingConfig) Update(workspaces []entity.Workspace) error {
+	c.updates = append(c.updates, workspaces)
+	return nil
+}
+
+type fakeConfigUpdaterStore struct {
+	*store.FileStore
+	workspaces []entity.Workspace
+}
+
+func (f *fakeConfigUpdaterStore) GetActiveOrganizationOrDefault() (*entity.Organization, error) {
+	return &entity.Organization{ID: "oi"}, nil
+}
+
+func (f *fakeConfigUpdaterStore) GetContextWorkspaces() ([]entity.Workspace, error) {
+	return f.workspaces, nil
+}
+
+func (f *fakeConfigUpdaterStore) WritePrivateKey(_ string) error {
+	return nil
+}
+
+func TestConfigUpdaterOnlyUpdatesOnChange(t *testing.T) {
+	fs := store.NewBasicStore().WithFileSystem(afero.NewMemMapFs()).WithUserHomeDirGetter(
+		func() (string, error) {
+			return "/home/test", nil
+		},
+	)
+	workspaces := make([]entity.Workspace, len(somePlainWorkspaces))
+	copy(workspaces, somePlainWorkspaces)
+	s := &fakeConfigUpdaterStore{FileStore: fs, workspaces: workspaces}
+	config := &countingConfig{}
+	cu := NewConfigUpdater(s, []Config{config}, "key")
+
+	assert.Nil(t, cu.Run())
+	assert.Len(t, config.updates, 1)
+
+	// nothing changed
+	assert.Nil(t, cu.Run())
+	assert.Len(t, config.updates, 1)
+
+	// stopped instances are not in the config, so one stopping is a change
+	s.workspaces[1].Status = entity.Stopped
+	assert.Nil(t, cu.Run())
+	if assert.Len(t, config.updates, 2) {
+		assert.Len(t, config.updates[1], 1)
+	}
+
+	cu.Force = true
+	assert.Nil(t, cu.Run())
+	assert.Len(t, config.updates, 3)
+}
+
+func TestConfigUpdaterRepairsStaleSSHConfig(t *testing.T) {
+	fs := makeMockFS().(*store.FileStore)
+	workspaces := make([]entity.Workspace, len(somePlainWorkspaces))
+	copy(workspaces, somePlainWorkspaces)
+	s := &fakeConfigUpdaterStore{FileStore: fs, workspaces: workspaces}
+	configurer := NewSSHConfigurerV2(fs)
+	counter := &countingConfig{}
+	cu := NewConfigUpdater(s, []Config{configurer, counter}, "key")
+	assert.Nil(t, cu.Run())
+	assert.Len(t, counter.updates, 1)
+
+	brevConfigPath, err := fs.GetBrevSSHConfigPath()
+	assert.Nil(t, err)
+	written, err := fs.GetFileAsString(brevConfigPath)
+	assert.Nil(t, err)
+
+	// deleted by hand, the instances did not change
+	assert.Nil(t, fs.Remove(brevConfigPath))
+	assert.Nil(t, cu.Run())
+	regenerated, err := fs.GetFileAsString(brevConfigPath)
+	assert.Nil(t, err)
+	assert.Equal(t, written, regenerated)
+	// the configs that are not stale are left alone
+	assert.Len(t, counter.updates, 1)
+
+	// the include was dropped from ~/.ssh/config
+	assert.Nil(t, fs.WriteUserSSHConfig("Host other\n"))
+	assert.Nil(t, cu.Run())
+	conf, err := fs.GetUserSSHConfig()
+	assert.Nil(t, err)
+	assert.True(t, doesUserSSHConfigIncludeBrevConfig(conf, brevConfigPath))
+	assert.Len(t, counter.updates, 1)
+}
+
+func TestConfigUpdaterRewritesAfterCLIUpgrade(t *testing.T) {
+	fs := makeMockFS().(*store.FileStore)
+	s := &fakeConfigUpdaterStore{FileStore: fs, workspaces: somePlainWorkspaces}
+	counter := &countingConfig{}
+	cu := NewConfigUpdater(s, []Config{counter}, "key")
+	assert.Nil(t, cu.Run())
+
+	old := version.Version
+	version.Version = "v99.0.0"
+	defer func() { version.Version = old }()
+	assert.Nil(t, cu.Run())
+	assert.Len(t, counter.updates, 2)
+	assert.Nil(t, cu.Run())
+	assert.Len(t, counter.updates, 2)
+}
+
+func TestSSHConfigurerV2RemoveUndoesWSLUpdate(t *testing.T) {
+	fs := makeMockWSLFS()
+	s := NewSSHConfigurerV2(fs)
+	assert.Nil(t, fs.WriteWSLUserSSHConfig("Host other\n"))
+	assert.Nil(t, s.Update(somePlainWorkspaces))
+
+	removed, err := s.Remove()
+	assert.Nil(t, err)
+	assert.Len(t, removed, 4)
+
+	for _, path := range []string{"/home/test/.brev/ssh_config", "/mnt/c/Users/15854/.brev/ssh_config"} {
+		exists, err := fs.FileExists(path)
+		assert.Nil(t, err)
+		assert.False(t, exists, path)
+	}
+	linuxConfig, err := fs.GetFileAsString("/home/test/.ssh/config")
+	assert.Nil(t, err)
+	assert.Equal(t, "", linuxConfig)
+	windowsConfig, err := fs.GetFileAsString("/mnt/c/Users/15854/.ssh/config")
+	assert.Nil(t, err)
+	assert.Equal(t, "Host other\n", windowsConfig)
+}
+
+type countingJetBrainsStore struct {
+	*store.FileStore
+	writes int
+}
+
+func (c *countingJetBrainsStore) WriteJetBrainsConfig(config string) error {
+	c.writes++
+	return c.FileStore.WriteJetBrainsConfig(config) //nolint:wrapcheck // test
+}
+
+func TestSSHConfigurerJetBrainsOnlyWritesOnChange(t *testing.T) {
+	// the gateway directory is created under the real home by the init in ssh_test.go
+	s := &countingJetBrainsStore{FileStore: makeMockJetBrainsGateWayStore()}
+	configurer, err := NewSSHConfigurerJetBrains(s)
+	assert.Nil(t, err)
+
+	stale, err := configurer.IsStale(somePlainWorkspaces)
+	assert.Nil(t, err)
+	assert.True(t, stale)
+	assert.Nil(t, configurer.Update(somePlainWorkspaces))
+	assert.Equal(t, 1, s.writes)
+
+	stale, err = configurer.IsStale(somePlainWorkspaces)
+	assert.Nil(t, err)
+	assert.False(t, stale)
+	assert.Nil(t, configurer.Update(somePlainWorkspaces))
+	assert.Equal(t, 1, s.writes)
+
+	// edited by hand
+	assert.Nil(t, s.FileStore.WriteJetBrainsConfig("<application></application>"))
+	stale, err = configurer.IsStale(somePlainWorkspaces)
+	assert.Nil(t, err)
+	assert.True(t, stale)
+	assert.Nil(t, configurer.Update(somePlainWorkspaces[:1]))
+	assert.Equal(t, 2, s.writes)
+}
@@ -1,6 +1,8 @@
 package ssh
 
 import (
+	"time"
+
 	"github.com/brevdev/brev-cli/pkg/autostartconf"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
@@ -20,7 +22,15 @@ type SSHConfigurerTask struct {
 var _ tasks.Task = SSHConfigurerTask{}
 
 func (sct SSHConfigurerTask) GetTaskSpec() tasks.TaskSpec {
-	return tasks.TaskSpec{RunCronImmediately: true, Cron: "@every 3s"}
+	return tasks.TaskSpec{
+		RunCronImmediately: true,
+		Cron:               "@every 3s",
+		// retry transient api errors sooner than the next tick
+		MaxAttempts: 3,
+		Backoff:     500 * time.Millisecond,
+		// no timeout, the api calls can't be canceled so a hung run is skipped over instead
+		Overlap: tasks.OverlapSkip,
+	}
 }
 
 func (sct SSHConfigurerTask) Run() error {
@@ -34,8 +44,9 @@ func (sct SSHConfigurerTask) Run() error {
 		return breverrors.WrapAndTrace(err)
 	}
 
+	// a single pass, the task runner calls this again on every tick of the cron spec
 	cu := NewConfigUpdater(sct.Store, configs, keys.PrivateKey)
-	err = tasks.RunTasks([]tasks.Task{cu})
+	err = cu.Run()
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
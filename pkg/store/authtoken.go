@@ -1,10 +1,12 @@
 package store
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"path"
+	"path/filepath"
 
+	"github.com/brevdev/brev-cli/pkg/config"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
 	"github.com/brevdev/brev-cli/pkg/files"
@@ -16,25 +18,60 @@ import (
 const (
 	brevCredentialsFile = "credentials.json"
 	brevDirectory       = ".brev"
+	// the access token minted with an api key from the env, the key itself is never saved
+	brevClientCredentialsTokenFile = "client_credentials_token.json"
 )
 
 func GetBrevDirectory() string {
 	return brevDirectory
 }
 
+// GetCredentialStore is the backend set with credential_store unless one was given with
+// WithCredentialStore
+func (f FileStore) GetCredentialStore() (CredentialStore, error) {
+	if f.credentialStore != nil {
+		return f.credentialStore, nil
+	}
+	home, err := f.GetBrevHomePath()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	credentialStore, err := NewCredentialStore(f.fs, config.Global(), home)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return credentialStore, nil
+}
+
 func (f FileStore) SaveAuthTokens(token entity.AuthTokens) error {
 	if token.AccessToken == "" {
 		return fmt.Errorf("access token is empty")
 	}
-	brevCredentialsFile, err := f.getBrevCredentialsFile()
+	credentialStore, err := f.GetCredentialStore()
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-	err = files.OverwriteJSON(f.fs, *brevCredentialsFile, token)
+	// the secret of an api key doesn't expire, so it is only kept by a backend that isn't plain text
+	if _, ok := credentialStore.(FileCredentialStore); ok && token.ClientSecret != "" {
+		idVar, secretVar := config.ClientCredentialsEnvVars()
+		return breverrors.NewValidationError(fmt.Sprintf("saving an api key login needs credential_store %s or %s, the %s backend keeps it in plain text. Run 'brev config set credential_store %s', or set %s and %s instead of saving the key",
+			config.CredentialStoreEncryptedFile, config.CredentialStoreHelper, config.CredentialStoreFile, config.CredentialStoreEncryptedFile, idVar, secretVar))
+	}
+	err = credentialStore.Save(token)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-
+	// don't leave plain tokens behind once another backend has them
+	plain, err := f.getPlainCredentialStore()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if plain.Location() != credentialStore.Location() {
+		err = plain.Delete()
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
 	return nil
 }
 
@@ -46,25 +83,39 @@ func (f FileStore) GetAuthTokens() (*entity.AuthTokens, error) {
 		}, nil
 	}
 
-	brevCredentialsFile, err := f.getBrevCredentialsFile()
+	credentialStore, err := f.GetCredentialStore()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	token, err := credentialStore.Get()
+	if _, ok := err.(*breverrors.CredentialsFileNotFound); ok { //nolint:errorlint // returned unwrapped
+		return f.migratePlainCredentials(credentialStore)
+	}
 	if err != nil {
 		return nil, breverrors.WrapAndTrace(err)
 	}
+	return token, nil
+}
 
-	exists, err := afero.Exists(f.fs, *brevCredentialsFile)
+// migratePlainCredentials moves tokens saved before credential_store was changed into the new
+// backend, so switching doesn't log anyone out
+func (f FileStore) migratePlainCredentials(credentialStore CredentialStore) (*entity.AuthTokens, error) {
+	plain, err := f.getPlainCredentialStore()
 	if err != nil {
 		return nil, breverrors.WrapAndTrace(err)
 	}
-	if !exists {
+	if plain.Location() == credentialStore.Location() {
 		return nil, &breverrors.CredentialsFileNotFound{}
 	}
-
-	var token entity.AuthTokens
-	err = files.ReadJSON(f.fs, *brevCredentialsFile, &token)
+	token, err := plain.Get()
+	if err != nil {
+		return nil, err //nolint:wrapcheck // CredentialsFileNotFound is checked by type
+	}
+	err = f.SaveAuthTokens(*token)
 	if err != nil {
 		return nil, breverrors.WrapAndTrace(err)
 	}
-	return &token, nil
+	return token, nil
 }
 
 func (f FileStore) GetCurrentWorkspaceServiceToken() (string, error) {
@@ -94,22 +145,83 @@ func getServiceTokenFilePath() string {
 }
 
 func (f FileStore) DeleteAuthTokens() error {
-	brevCredentialsFile, err := f.getBrevCredentialsFile()
+	credentialStore, err := f.GetCredentialStore()
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-	err = files.DeleteFile(f.fs, *brevCredentialsFile)
+	err = credentialStore.Delete()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	path, err := f.getClientCredentialsTokenPath()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = deleteIfExists(f.fs, path)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
 	return nil
 }
 
+func (f FileStore) getClientCredentialsTokenPath() (string, error) {
+	home, err := f.GetBrevHomePath()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return filepath.Join(home, brevClientCredentialsTokenFile), nil
+}
+
+// GetClientCredentialsToken returns nil when no token was cached
+func (f FileStore) GetClientCredentialsToken() (*entity.ClientCredentialsToken, error) {
+	path, err := f.getClientCredentialsTokenPath()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	exists, err := afero.Exists(f.fs, path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	var token entity.ClientCredentialsToken
+	err = files.ReadJSON(f.fs, path, &token)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &token, nil
+}
+
+func (f FileStore) SaveClientCredentialsToken(token entity.ClientCredentialsToken) error {
+	path, err := f.getClientCredentialsTokenPath()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	data, err := json.MarshalIndent(token, "", " ")
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = writePrivateFile(f.fs, path, data)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (f FileStore) getPlainCredentialStore() (CredentialStore, error) {
+	brevCredentialsFile, err := f.getBrevCredentialsFile()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return NewFileCredentialStore(f.fs, *brevCredentialsFile), nil
+}
+
 func (f FileStore) getBrevCredentialsFile() (*string, error) {
 	home, err := f.UserHomeDir()
 	if err != nil {
 		return nil, breverrors.WrapAndTrace(err)
 	}
-	brevCredentialsFile := path.Join(home, brevDirectory, brevCredentialsFile)
+	brevCredentialsFile := filepath.Join(files.GetBrevHome(home), brevCredentialsFile)
 	return &brevCredentialsFile, nil
 }
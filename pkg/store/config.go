@@ -0,0 +1,33 @@
+package store
+
+import (
+	"github.com/brevdev/brev-cli/pkg/config"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+)
+
+func (f FileStore) GetUserConfigPath() (string, error) {
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return files.GetUserConfigPath(home), nil
+}
+
+// SetConfigValue writes a setting to the config file at path, see config.Settings
+func (f FileStore) SetConfigValue(path string, key string, value string) error {
+	err := config.SetConfigFileValue(f.fs, path, key, value)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// UnsetConfigValue returns false if the config file at path did not set key
+func (f FileStore) UnsetConfigValue(path string, key string) (bool, error) {
+	unset, err := config.UnsetConfigFileValue(f.fs, path, key)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return unset, nil
+}
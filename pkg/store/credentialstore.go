@@ -0,0 +1,424 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/brevdev/brev-cli/pkg/config"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/scrypt"
+)
+
+const brevEncryptedCredentialsFile = "credentials.enc"
+
+// CredentialStore keeps the tokens brev is logged in with
+type CredentialStore interface {
+	// Get returns *breverrors.CredentialsFileNotFound when nothing was saved
+	Get() (*entity.AuthTokens, error)
+	Save(tokens entity.AuthTokens) error
+	Delete() error
+	// Location says where the tokens are kept, for humans
+	Location() string
+}
+
+type CredentialStoreConfig interface {
+	GetCredentialStore() string
+	GetCredentialHelper() string
+	GetCredentialsPassphrase() string
+	GetBrevAPIURl() string
+}
+
+// NewCredentialStore picks the backend set with credential_store, brevHome is the home of the
+// profile in use
+func NewCredentialStore(fs afero.Fs, conf CredentialStoreConfig, brevHome string) (CredentialStore, error) {
+	switch conf.GetCredentialStore() {
+	case config.CredentialStoreEncryptedFile:
+		return NewEncryptedFileCredentialStore(fs, filepath.Join(brevHome, brevEncryptedCredentialsFile), conf.GetCredentialsPassphrase()), nil
+	case config.CredentialStoreHelper:
+		if strings.TrimSpace(conf.GetCredentialHelper()) == "" {
+			return nil, breverrors.NewValidationError("credential_store is helper but no credential_helper is set, set one with: brev config set credential_helper <helper>")
+		}
+		host := conf.GetBrevAPIURl()
+		u, err := url.Parse(host)
+		if err == nil && u.Host != "" {
+			host = u.Host
+		}
+		return NewHelperCredentialStore(conf.GetCredentialHelper(), host, files.GetActiveProfile()), nil
+	case config.CredentialStoreFile, "":
+		return NewFileCredentialStore(fs, filepath.Join(brevHome, brevCredentialsFile)), nil
+	default:
+		return nil, breverrors.NewValidationError(fmt.Sprintf("unknown credential_store %s", conf.GetCredentialStore()))
+	}
+}
+
+// FileCredentialStore keeps the tokens as plain json only the user can read
+type FileCredentialStore struct {
+	fs   afero.Fs
+	path string
+}
+
+var _ CredentialStore = FileCredentialStore{}
+
+func NewFileCredentialStore(fs afero.Fs, path string) FileCredentialStore {
+	return FileCredentialStore{fs: fs, path: path}
+}
+
+func (s FileCredentialStore) Location() string {
+	return s.path
+}
+
+func (s FileCredentialStore) Get() (*entity.AuthTokens, error) {
+	exists, err := afero.Exists(s.fs, s.path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return nil, &breverrors.CredentialsFileNotFound{}
+	}
+	// older versions wrote the file readable by everyone
+	err = restrictPermissions(s.fs, s.path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	var tokens entity.AuthTokens
+	err = files.ReadJSON(s.fs, s.path, &tokens)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &tokens, nil
+}
+
+func (s FileCredentialStore) Save(tokens entity.AuthTokens) error {
+	data, err := json.MarshalIndent(tokens, "", " ")
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = writePrivateFile(s.fs, s.path, data)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (s FileCredentialStore) Delete() error {
+	return deleteIfExists(s.fs, s.path)
+}
+
+// EncryptedFileCredentialStore encrypts the tokens with a key derived from a passphrase, or from
+// the machine id when there is none, so a copied file is of no use elsewhere
+type EncryptedFileCredentialStore struct {
+	fs         afero.Fs
+	path       string
+	passphrase string
+	machineID  func() (string, error)
+}
+
+var _ CredentialStore = EncryptedFileCredentialStore{}
+
+func NewEncryptedFileCredentialStore(fs afero.Fs, path string, passphrase string) EncryptedFileCredentialStore {
+	return EncryptedFileCredentialStore{fs: fs, path: path, passphrase: passphrase, machineID: getMachineID}
+}
+
+func (s EncryptedFileCredentialStore) WithMachineID(machineID func() (string, error)) EncryptedFileCredentialStore {
+	s.machineID = machineID
+	return s
+}
+
+const (
+	keySourcePassphrase = "passphrase"
+	keySourceMachine    = "machine"
+)
+
+type encryptedCredentials struct {
+	Version    int    `json:"version"`
+	KeySource  string `json:"keySource"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (s EncryptedFileCredentialStore) Location() string {
+	return s.path
+}
+
+func (s EncryptedFileCredentialStore) Get() (*entity.AuthTokens, error) {
+	exists, err := afero.Exists(s.fs, s.path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return nil, &breverrors.CredentialsFileNotFound{}
+	}
+	var enc encryptedCredentials
+	err = files.ReadJSON(s.fs, s.path, &enc)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if enc.KeySource == keySourcePassphrase && s.passphrase == "" {
+		return nil, breverrors.NewValidationError(fmt.Sprintf("%s is encrypted with a passphrase, set %s", s.path, config.CredentialsPassphraseEnvVar()))
+	}
+	gcm, err := s.newGCM(enc.KeySource, enc.Salt)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	plain, err := gcm.Open(nil, enc.Nonce, enc.Ciphertext, nil)
+	if err != nil {
+		return nil, breverrors.NewValidationError(fmt.Sprintf("could not decrypt %s, the passphrase is wrong or the file is from another machine, run brev login", s.path))
+	}
+	var tokens entity.AuthTokens
+	err = json.Unmarshal(plain, &tokens)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &tokens, nil
+}
+
+func (s EncryptedFileCredentialStore) Save(tokens entity.AuthTokens) error {
+	plain, err := json.Marshal(tokens)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	keySource := keySourceMachine
+	if s.passphrase != "" {
+		keySource = keySourcePassphrase
+	}
+	enc := encryptedCredentials{Version: 1, KeySource: keySource, Salt: make([]byte, 16)}
+	_, err = rand.Read(enc.Salt)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	gcm, err := s.newGCM(keySource, enc.Salt)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	enc.Nonce = make([]byte, gcm.NonceSize())
+	_, err = rand.Read(enc.Nonce)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	enc.Ciphertext = gcm.Seal(nil, enc.Nonce, plain, nil)
+	data, err := json.MarshalIndent(enc, "", " ")
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = writePrivateFile(s.fs, s.path, data)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (s EncryptedFileCredentialStore) Delete() error {
+	return deleteIfExists(s.fs, s.path)
+}
+
+func (s EncryptedFileCredentialStore) newGCM(keySource string, salt []byte) (cipher.AEAD, error) {
+	secret := s.passphrase
+	if keySource == keySourceMachine {
+		id, err := s.machineID()
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err, fmt.Sprintf("no machine id to encrypt credentials with, set %s", config.CredentialsPassphraseEnvVar()))
+		}
+		secret = "brev-cli:" + id
+	}
+	key, err := scrypt.Key([]byte(secret), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return gcm, nil
+}
+
+var ioPlatformUUIDRegex = regexp.MustCompile(`"IOPlatformUUID" = "([^"]+)"`)
+
+func getMachineID() (string, error) {
+	if runtime.GOOS == "darwin" {
+		out, err := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output() //nolint:gosec // constant command
+		if err != nil {
+			return "", breverrors.WrapAndTrace(err)
+		}
+		m := ioPlatformUUIDRegex.FindSubmatch(out)
+		if m == nil {
+			return "", fmt.Errorf("IOPlatformUUID not found")
+		}
+		return string(m[1]), nil
+	}
+	for _, p := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		id, err := os.ReadFile(p) //nolint:gosec // constant paths
+		if err == nil && len(bytes.TrimSpace(id)) > 0 {
+			return string(bytes.TrimSpace(id)), nil
+		}
+	}
+	return "", fmt.Errorf("machine id not found")
+}
+
+// HelperCredentialStore hands the tokens to a git credential helper, such as osxkeychain or
+// libsecret, so they end up in the OS keychain. The tokens are the password of the host of the
+// brev api, the path tells profiles apart
+type HelperCredentialStore struct {
+	helper  string
+	host    string
+	profile string
+	run     func(command string, input string) (string, error)
+}
+
+var _ CredentialStore = HelperCredentialStore{}
+
+func NewHelperCredentialStore(helper string, host string, profile string) HelperCredentialStore {
+	return HelperCredentialStore{helper: helper, host: host, profile: profile, run: runCredentialHelper}
+}
+
+func (s HelperCredentialStore) WithRunner(run func(command string, input string) (string, error)) HelperCredentialStore {
+	s.run = run
+	return s
+}
+
+func (s HelperCredentialStore) Location() string {
+	return fmt.Sprintf("credential helper %s for %s", s.helper, s.host)
+}
+
+// helperCommand follows git: "!cmd" is run by the shell, an absolute path as is and any other
+// name as git-credential-<name>
+func (s HelperCredentialStore) helperCommand(action string) string {
+	switch {
+	case strings.HasPrefix(s.helper, "!"):
+		return s.helper[1:] + " " + action
+	case filepath.IsAbs(strings.Fields(s.helper)[0]):
+		return s.helper + " " + action
+	default:
+		return "git-credential-" + s.helper + " " + action
+	}
+}
+
+func (s HelperCredentialStore) request(password string) string {
+	req := fmt.Sprintf("protocol=https\nhost=%s\npath=brev-cli/%s\nusername=brev-cli\n", s.host, s.profile)
+	if password != "" {
+		req += "password=" + password + "\n"
+	}
+	return req + "\n"
+}
+
+func (s HelperCredentialStore) Get() (*entity.AuthTokens, error) {
+	out, err := s.run(s.helperCommand("get"), s.request(""))
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	password := ""
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "password="); ok {
+			password = v
+		}
+	}
+	if password == "" {
+		return nil, &breverrors.CredentialsFileNotFound{}
+	}
+	var tokens entity.AuthTokens
+	err = json.Unmarshal([]byte(password), &tokens)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err, "credential helper returned a password that is not brev tokens")
+	}
+	return &tokens, nil
+}
+
+func (s HelperCredentialStore) Save(tokens entity.AuthTokens) error {
+	// compact json has no newlines, which would end the value in the helper protocol
+	password, err := json.Marshal(tokens)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	_, err = s.run(s.helperCommand("store"), s.request(string(password)))
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (s HelperCredentialStore) Delete() error {
+	_, err := s.run(s.helperCommand("erase"), s.request(""))
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func runCredentialHelper(command string, input string) (string, error) {
+	cmd := exec.Command("sh", "-c", command) //nolint:gosec // the helper is set by the user
+	cmd.Stdin = strings.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err, fmt.Sprintf("credential helper %q failed: %s", command, strings.TrimSpace(stderr.String())))
+	}
+	return string(out), nil
+}
+
+func writePrivateFile(fs afero.Fs, path string, data []byte) error {
+	err := fs.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = afero.WriteFile(fs, path, data, 0o600)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	// WriteFile keeps the mode of a file that already exists
+	err = fs.Chmod(path, 0o600)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func restrictPermissions(fs afero.Fs, path string) error {
+	info, err := fs.Stat(path)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if info.Mode().Perm()&0o077 == 0 {
+		return nil
+	}
+	err = fs.Chmod(path, 0o600)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func deleteIfExists(fs afero.Fs, path string) error {
+	exists, err := afero.Exists(fs, path)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return nil
+	}
+	err = files.DeleteFile(fs, path)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
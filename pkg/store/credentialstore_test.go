@@ -0,0 +1,181 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTokens = entity.AuthTokens{AccessToken: "access", RefreshToken: "refresh"}
+
+func TestFileCredentialStore(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	s := NewFileCredentialStore(fs, "/home/test/.brev/credentials.json")
+
+	_, err := s.Get()
+	assert.IsType(t, &breverrors.CredentialsFileNotFound{}, err)
+
+	// older versions wrote the file readable by everyone
+	assert.Nil(t, afero.WriteFile(fs, s.path, []byte(`{"access_token":"old"}`), 0o644))
+	tokens, err := s.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, "old", tokens.AccessToken)
+	info, err := fs.Stat(s.path)
+	assert.Nil(t, err)
+	assert.Equal(t, "-rw-------", info.Mode().Perm().String())
+
+	assert.Nil(t, s.Save(testTokens))
+	tokens, err = s.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, testTokens, *tokens)
+
+	assert.Nil(t, s.Delete())
+	assert.Nil(t, s.Delete())
+	_, err = s.Get()
+	assert.IsType(t, &breverrors.CredentialsFileNotFound{}, err)
+}
+
+func TestEncryptedFileCredentialStore(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	path := "/home/test/.brev/credentials.enc"
+	machine := func() (string, error) { return "machine-1", nil }
+
+	s := NewEncryptedFileCredentialStore(fs, path, "").WithMachineID(machine)
+	assert.Nil(t, s.Save(testTokens))
+	data, err := afero.ReadFile(fs, path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "refresh")
+	tokens, err := s.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, testTokens, *tokens)
+
+	otherMachine := NewEncryptedFileCredentialStore(fs, path, "").WithMachineID(func() (string, error) { return "machine-2", nil })
+	_, err = otherMachine.Get()
+	assert.Error(t, err)
+
+	withPassphrase := NewEncryptedFileCredentialStore(fs, path, "hunter2").WithMachineID(machine)
+	assert.Nil(t, withPassphrase.Save(testTokens))
+	tokens, err = withPassphrase.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, testTokens, *tokens)
+
+	_, err = s.Get()
+	assert.ErrorContains(t, err, "BREV_CREDENTIALS_PASSPHRASE")
+	_, err = NewEncryptedFileCredentialStore(fs, path, "wrong").Get()
+	assert.ErrorContains(t, err, "could not decrypt")
+}
+
+type fakeCredentialHelper struct {
+	commands []string
+	password string
+}
+
+func (f *fakeCredentialHelper) run(command string, input string) (string, error) {
+	f.commands = append(f.commands, command)
+	if !strings.Contains(input, "host=api.example.com\npath=brev-cli/default\n") {
+		return "", fmt.Errorf("unexpected input %q", input)
+	}
+	switch {
+	case strings.HasSuffix(command, " store"):
+		for _, l := range strings.Split(input, "\n") {
+			if v, ok := strings.CutPrefix(l, "password="); ok {
+				f.password = v
+			}
+		}
+	case strings.HasSuffix(command, " erase"):
+		f.password = ""
+	case strings.HasSuffix(command, " get") && f.password != "":
+		return input[:len(input)-1] + "password=" + f.password + "\n", nil
+	}
+	return "", nil
+}
+
+func TestHelperCredentialStore(t *testing.T) {
+	helper := &fakeCredentialHelper{}
+	s := NewHelperCredentialStore("osxkeychain", "api.example.com", "default").WithRunner(helper.run)
+
+	_, err := s.Get()
+	assert.IsType(t, &breverrors.CredentialsFileNotFound{}, err)
+	assert.Nil(t, s.Save(testTokens))
+	tokens, err := s.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, testTokens, *tokens)
+	assert.Nil(t, s.Delete())
+	_, err = s.Get()
+	assert.IsType(t, &breverrors.CredentialsFileNotFound{}, err)
+
+	assert.Equal(t, "git-credential-osxkeychain get", helper.commands[0])
+	assert.Equal(t, "/usr/bin/helper --flag get", HelperCredentialStore{helper: "/usr/bin/helper --flag"}.helperCommand("get"))
+	assert.Equal(t, "pass show brev get", HelperCredentialStore{helper: "!pass show brev"}.helperCommand("get"))
+}
+
+func TestGetAuthTokensMigratesPlainCredentials(t *testing.T) {
+	fs := MakeMockFileStore().WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+	plainPath := "/home/test/.brev/credentials.json"
+	assert.Nil(t, NewFileCredentialStore(fs.fs, plainPath).Save(testTokens))
+
+	encrypted := NewEncryptedFileCredentialStore(fs.fs, "/home/test/.brev/credentials.enc", "hunter2")
+	fs.WithCredentialStore(encrypted)
+
+	tokens, err := fs.GetAuthTokens()
+	assert.Nil(t, err)
+	assert.Equal(t, testTokens, *tokens)
+
+	exists, err := afero.Exists(fs.fs, plainPath)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	tokens, err = encrypted.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, testTokens, *tokens)
+}
+
+func TestSaveAuthTokensKeepsAPIKeysOutOfPlainText(t *testing.T) {
+	fs := MakeMockFileStore().WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+	apiKeyTokens := entity.AuthTokens{AccessToken: "at", ClientID: "id", ClientSecret: "secret"}
+
+	fs.WithCredentialStore(NewFileCredentialStore(fs.fs, "/home/test/.brev/credentials.json"))
+	err := fs.SaveAuthTokens(apiKeyTokens)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "plain text")
+	}
+	assert.Nil(t, fs.SaveAuthTokens(testTokens))
+
+	fs.WithCredentialStore(NewEncryptedFileCredentialStore(fs.fs, "/home/test/.brev/credentials.enc", "hunter2"))
+	assert.Nil(t, fs.SaveAuthTokens(apiKeyTokens))
+}
+
+func TestClientCredentialsToken(t *testing.T) {
+	fs := MakeMockFileStore().WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+	token, err := fs.GetClientCredentialsToken()
+	assert.Nil(t, err)
+	assert.Nil(t, token)
+
+	saved := entity.ClientCredentialsToken{ClientID: "id", AccessToken: "at", ExpiresAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	assert.Nil(t, fs.SaveClientCredentialsToken(saved))
+	token, err = fs.GetClientCredentialsToken()
+	assert.Nil(t, err)
+	assert.Equal(t, saved, *token)
+	info, err := fs.fs.Stat("/home/test/.brev/client_credentials_token.json")
+	if assert.Nil(t, err) {
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	// logging out forgets it too
+	assert.Nil(t, fs.DeleteAuthTokens())
+	token, err = fs.GetClientCredentialsToken()
+	assert.Nil(t, err)
+	assert.Nil(t, token)
+}
@@ -29,6 +29,7 @@ type FileStore struct {
 	fs                afero.Fs
 	User              *user.User
 	userHomeDirGetter func() (string, error)
+	credentialStore   CredentialStore
 }
 
 func (f *FileStore) GetWindowsDir() (string, error) {
@@ -40,6 +41,12 @@ func (f *FileStore) WithUserHomeDirGetter(userHomeDirGetter func() (string, erro
 	return f
 }
 
+// WithCredentialStore keeps tokens in credentialStore instead of the one set with credential_store
+func (f *FileStore) WithCredentialStore(credentialStore CredentialStore) *FileStore {
+	f.credentialStore = credentialStore
+	return f
+}
+
 func (b *BasicStore) WithFileSystem(fs afero.Fs) *FileStore {
 	f := &FileStore{
 		b:    *b,
@@ -418,8 +425,14 @@ func (f FileStore) GetOSUser() string {
 	}
 }
 
+// GetServerSockFile is the control socket of the task daemon, which runs as the
+// user so it lives in the brev home when there is one
 func (f FileStore) GetServerSockFile() string {
-	return "/var/run/brev.sock"
+	brevHome, err := f.GetBrevHomePath()
+	if err != nil {
+		return "/var/run/brev.sock"
+	}
+	return filepath.Join(brevHome, "brev.sock")
 }
 
 func (f FileStore) Remove(target string) error {
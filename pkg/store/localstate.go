@@ -0,0 +1,44 @@
+package store
+
+import (
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/spf13/afero"
+)
+
+// RemoveLocalState deletes what brev keeps about the logged in user besides the tokens, the ssh
+// config and the ssh key: the active org, the instance cache, the onboarding progress and the
+// backups of the ssh config. It returns the paths it removed
+func (f FileStore) RemoveLocalState() ([]string, error) {
+	removed := []string{}
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return removed, breverrors.WrapAndTrace(err)
+	}
+	paths := []string{
+		files.GetActiveOrgsPath(home),
+		// without it the next refresh would think the ssh config is up to date
+		files.GetWorkspaceCachePath(home),
+		files.GetOnboardingStepPath(home),
+	}
+	backups, err := afero.Glob(f.fs, files.GetBackupSSHConfigFilesGlob(home))
+	if err != nil {
+		return removed, breverrors.WrapAndTrace(err)
+	}
+	paths = append(paths, backups...)
+	for _, p := range paths {
+		exists, err := afero.Exists(f.fs, p)
+		if err != nil {
+			return removed, breverrors.WrapAndTrace(err)
+		}
+		if !exists {
+			continue
+		}
+		err = f.fs.Remove(p)
+		if err != nil {
+			return removed, breverrors.WrapAndTrace(err)
+		}
+		removed = append(removed, p)
+	}
+	return removed, nil
+}
@@ -0,0 +1,249 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/spf13/afero"
+)
+
+const (
+	localWorkspaceFile       = "workspace.json"
+	localSetupParamsFile     = "setup_v0.json"
+	localWorkspaceMetaFile   = "meta.json"
+	localSecretsConfigFile   = "config.hcl"
+	localWorkspaceVolumesDir = "volumes"
+	localSnapshotsDir        = "snapshots"
+)
+
+// the name is also the name of the container, so it has to be a valid docker name
+var localWorkspaceNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+// LocalWorkspace is a workspace "brev local" runs in a container on this machine, it is never
+// sent to the brev api. Its name is its id
+type LocalWorkspace struct {
+	Name      string    `json:"name"`
+	Image     string    `json:"image"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LocalWorkspaceStore keeps each local workspace in its own directory: the workspace, its setup
+// params, workspace meta, an optional config.hcl, the volumes mounted into the container and the
+// snapshots of the volumes
+type LocalWorkspaceStore struct {
+	fs   afero.Fs
+	path string
+}
+
+func NewLocalWorkspaceStore(fs afero.Fs, path string) *LocalWorkspaceStore {
+	return &LocalWorkspaceStore{fs: fs, path: path}
+}
+
+func (f FileStore) GetLocalWorkspaceStore() (*LocalWorkspaceStore, error) {
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return NewLocalWorkspaceStore(f.fs, files.GetLocalWorkspacesPath(home)), nil
+}
+
+func ValidateLocalWorkspaceName(name string) error {
+	if !localWorkspaceNameRegex.MatchString(name) {
+		return breverrors.NewValidationError(fmt.Sprintf("invalid local workspace name %q, use lower case letters, digits, -, _ and .", name))
+	}
+	return nil
+}
+
+func (s LocalWorkspaceStore) workspacePath(name string, file string) string {
+	return filepath.Join(s.path, name, file)
+}
+
+func (s LocalWorkspaceStore) LocalWorkspaceExists(name string) (bool, error) {
+	exists, err := afero.Exists(s.fs, s.workspacePath(name, localWorkspaceFile))
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return exists, nil
+}
+
+// CreateLocalWorkspace only writes the files, the container is created on start
+func (s LocalWorkspaceStore) CreateLocalWorkspace(workspace LocalWorkspace, params SetupParamsV0) error {
+	err := ValidateLocalWorkspaceName(workspace.Name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	exists, err := s.LocalWorkspaceExists(workspace.Name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if exists {
+		return breverrors.NewValidationError(fmt.Sprintf("local workspace %s already exists", workspace.Name))
+	}
+	err = s.writeJSON(workspace.Name, localSetupParamsFile, params)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = s.writeJSON(workspace.Name, localWorkspaceMetaFile, WorkspaceMeta{WorkspaceID: workspace.Name})
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	// written last, the workspace only exists once it is complete
+	err = s.writeJSON(workspace.Name, localWorkspaceFile, workspace)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (s LocalWorkspaceStore) GetLocalWorkspace(name string) (*LocalWorkspace, error) {
+	exists, err := s.LocalWorkspaceExists(name)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return nil, breverrors.NewValidationError(fmt.Sprintf("local workspace %s does not exist", name))
+	}
+	var workspace LocalWorkspace
+	err = files.ReadJSON(s.fs, s.workspacePath(name, localWorkspaceFile), &workspace)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &workspace, nil
+}
+
+// GetLocalWorkspaces is sorted by name, directories without a workspace file are skipped
+func (s LocalWorkspaceStore) GetLocalWorkspaces() ([]LocalWorkspace, error) {
+	exists, err := afero.DirExists(s.fs, s.path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return []LocalWorkspace{}, nil
+	}
+	entries, err := afero.ReadDir(s.fs, s.path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	workspaces := []LocalWorkspace{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		exists, err := s.LocalWorkspaceExists(e.Name())
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		if !exists {
+			continue
+		}
+		workspace, err := s.GetLocalWorkspace(e.Name())
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		workspaces = append(workspaces, *workspace)
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].Name < workspaces[j].Name })
+	return workspaces, nil
+}
+
+// DeleteLocalWorkspace also deletes the volumes, including the workspace directory, and the snapshots
+func (s LocalWorkspaceStore) DeleteLocalWorkspace(name string) error {
+	err := s.fs.RemoveAll(filepath.Join(s.path, name))
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (s LocalWorkspaceStore) SaveWorkspaceSetupParams(name string, params SetupParamsV0) error {
+	_, err := s.GetLocalWorkspace(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = s.writeJSON(name, localSetupParamsFile, params)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (s LocalWorkspaceStore) GetWorkspace(id string) (*entity.Workspace, error) {
+	workspace, err := s.GetLocalWorkspace(id)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &entity.Workspace{
+		ID:                workspace.Name,
+		Name:              workspace.Name,
+		CreatedAt:         workspace.CreatedAt.Format(time.RFC3339),
+		WorkspaceTemplate: entity.WorkspaceTemplate{Image: workspace.Image},
+	}, nil
+}
+
+func (s LocalWorkspaceStore) GetWorkspaceSetupParams(id string) (*SetupParamsV0, error) {
+	var params SetupParamsV0
+	err := files.ReadJSON(s.fs, s.workspacePath(id, localSetupParamsFile), &params)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &params, nil
+}
+
+func (s LocalWorkspaceStore) GetWorkspaceMeta(id string) (*WorkspaceMeta, error) {
+	var meta WorkspaceMeta
+	err := files.ReadJSON(s.fs, s.workspacePath(id, localWorkspaceMetaFile), &meta)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &meta, nil
+}
+
+// GetWorkspaceSecretsConfig is empty unless a config.hcl was put next to the setup params
+func (s LocalWorkspaceStore) GetWorkspaceSecretsConfig(id string) (string, error) {
+	path := s.workspacePath(id, localSecretsConfigFile)
+	exists, err := afero.Exists(s.fs, path)
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return "", nil
+	}
+	data, err := afero.ReadFile(s.fs, path)
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return string(data), nil
+}
+
+func (s LocalWorkspaceStore) GetWorkspaceVolumesPath(id string) (string, error) {
+	return s.workspacePath(id, localWorkspaceVolumesDir), nil
+}
+
+func (s LocalWorkspaceStore) GetWorkspaceSnapshotsPath(id string) (string, error) {
+	return s.workspacePath(id, localSnapshotsDir), nil
+}
+
+func (s LocalWorkspaceStore) writeJSON(name string, file string, v interface{}) error {
+	path := s.workspacePath(name, file)
+	err := s.fs.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	data, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	// the setup params carry the workspace password and key pair
+	err = afero.WriteFile(s.fs, path, data, 0o600)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalWorkspaceStore(t *testing.T) {
+	fs := MakeMockFileStore().WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+	s, err := fs.GetLocalWorkspaceStore()
+	assert.Nil(t, err)
+
+	workspaces, err := s.GetLocalWorkspaces()
+	assert.Nil(t, err)
+	assert.Empty(t, workspaces)
+
+	assert.Error(t, s.CreateLocalWorkspace(LocalWorkspace{Name: "Not Valid"}, SetupParamsV0{}))
+
+	script := "ZWNobyBoaQo="
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	err = s.CreateLocalWorkspace(LocalWorkspace{Name: "dev", Image: "ubuntu", CreatedAt: created}, SetupParamsV0{ProjectSetupScript: &script})
+	assert.Nil(t, err)
+	assert.ErrorContains(t, s.CreateLocalWorkspace(LocalWorkspace{Name: "dev"}, SetupParamsV0{}), "already exists")
+	// a directory left behind without a workspace file is not a workspace
+	assert.Nil(t, fs.fs.MkdirAll("/home/test/.brev/local/broken", 0o755))
+
+	workspaces, err = s.GetLocalWorkspaces()
+	assert.Nil(t, err)
+	assert.Equal(t, []LocalWorkspace{{Name: "dev", Image: "ubuntu", CreatedAt: created}}, workspaces)
+
+	workspace, err := s.GetWorkspace("dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", workspace.ID)
+	assert.Equal(t, "ubuntu", workspace.WorkspaceTemplate.Image)
+	params, err := s.GetWorkspaceSetupParams("dev")
+	assert.Nil(t, err)
+	assert.Equal(t, script, *params.ProjectSetupScript)
+	meta, err := s.GetWorkspaceMeta("dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", meta.WorkspaceID)
+	secrets, err := s.GetWorkspaceSecretsConfig("dev")
+	assert.Nil(t, err)
+	assert.Empty(t, secrets)
+	volumes, err := s.GetWorkspaceVolumesPath("dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "/home/test/.brev/local/dev/volumes", volumes)
+
+	info, err := fs.fs.Stat("/home/test/.brev/local/dev/setup_v0.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "-rw-------", info.Mode().Perm().String())
+
+	assert.Nil(t, s.DeleteLocalWorkspace("dev"))
+	exists, err := afero.DirExists(fs.fs, "/home/test/.brev/local/dev")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	_, err = s.GetLocalWorkspace("dev")
+	assert.ErrorContains(t, err, "does not exist")
+}
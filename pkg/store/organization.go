@@ -3,6 +3,7 @@ package store
 import (
 	"fmt"
 
+	"github.com/brevdev/brev-cli/pkg/config"
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
 	"github.com/brevdev/brev-cli/pkg/files"
@@ -101,9 +102,27 @@ func (s AuthHTTPStore) GetActiveOrganizationOrDefault() (*entity.Organization, e
 		return nil, breverrors.WrapAndTrace(err)
 	}
 
+	defaultOrg := config.Global().GetDefaultOrg()
+	if defaultOrg != "" {
+		org, err := findOrgByIDOrName(orgs, defaultOrg)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		return org, nil
+	}
 	return GetDefaultOrNilOrg(orgs), nil
 }
 
+// findOrgByIDOrName errors instead of silently picking another org when the configured one is gone
+func findOrgByIDOrName(orgs []entity.Organization, idOrName string) (*entity.Organization, error) {
+	for i, o := range orgs {
+		if o.ID == idOrName || o.Name == idOrName {
+			return &orgs[i], nil
+		}
+	}
+	return nil, breverrors.NewValidationError(fmt.Sprintf("default org %s from brev config was not found, run 'brev config unset %s' or 'brev set <org>'", idOrName, config.KeyDefaultOrg))
+}
+
 var orgPath = "api/organizations"
 
 type GetOrganizationsOptions struct {
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/brevdev/brev-cli/pkg/config"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/spf13/afero"
+)
+
+var profileNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ProfilesFile records which profile 'brev profile use' picked
+type ProfilesFile struct {
+	Current string `json:"current"`
+}
+
+// Profile is a brev environment or account with its own credentials, active org and ssh config
+type Profile struct {
+	Name     string `json:"name"`
+	Current  bool   `json:"current"`
+	APIURL   string `json:"apiUrl"`
+	LoggedIn bool   `json:"loggedIn"`
+	Home     string `json:"home"`
+}
+
+func ValidateProfileName(name string) error {
+	if !profileNameRegex.MatchString(name) {
+		return breverrors.NewValidationError(fmt.Sprintf("invalid profile name %q, use lower case letters, digits, - and _", name))
+	}
+	return nil
+}
+
+func (f FileStore) getProfilesFile() (*ProfilesFile, error) {
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	path := files.GetProfilesPath(home)
+	exists, err := afero.Exists(f.fs, path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return &ProfilesFile{Current: files.DefaultProfile}, nil
+	}
+	var profiles ProfilesFile
+	err = files.ReadJSON(f.fs, path, &profiles)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if profiles.Current == "" {
+		profiles.Current = files.DefaultProfile
+	}
+	return &profiles, nil
+}
+
+// GetCurrentProfile is the profile picked with 'brev profile use', --profile and BREV_PROFILE
+// override it for a single command
+func (f FileStore) GetCurrentProfile() (string, error) {
+	profiles, err := f.getProfilesFile()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return profiles.Current, nil
+}
+
+func (f FileStore) SetCurrentProfile(name string) error {
+	err := ValidateProfileName(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	exists, err := f.ProfileExists(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return breverrors.NewValidationError(fmt.Sprintf("profile %s does not exist, add it with: brev profile add %s", name, name))
+	}
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	path := files.GetProfilesPath(home)
+	err = f.fs.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	data, err := json.MarshalIndent(ProfilesFile{Current: name}, "", " ")
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = afero.WriteFile(f.fs, path, data, 0o644)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// ProfileExists is always true for the default profile, a name that is not a valid profile
+// name is an error so it never reaches a path
+func (f FileStore) ProfileExists(name string) (bool, error) {
+	if name == files.DefaultProfile {
+		return true, nil
+	}
+	err := ValidateProfileName(name)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	exists, err := afero.DirExists(f.fs, files.GetProfileHome(home, name))
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return exists, nil
+}
+
+// AddProfile creates the profile's home, an empty apiURL keeps the default brev api
+func (f FileStore) AddProfile(name string, apiURL string) error {
+	err := ValidateProfileName(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	exists, err := f.ProfileExists(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if exists {
+		return breverrors.NewValidationError(fmt.Sprintf("profile %s already exists", name))
+	}
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	profileHome := files.GetProfileHome(home, name)
+	err = f.fs.MkdirAll(profileHome, 0o755)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if apiURL != "" {
+		err = config.SetConfigFileValue(f.fs, files.GetProfileConfigPath(home, name), string(config.KeyAPIURL), apiURL)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	return nil
+}
+
+// RemoveProfile deletes the profile's credentials and files, the current profile goes back to
+// the default one if it was removed
+func (f FileStore) RemoveProfile(name string) error {
+	if name == files.DefaultProfile {
+		return breverrors.NewValidationError("the default profile can not be removed")
+	}
+	err := ValidateProfileName(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	exists, err := f.ProfileExists(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return breverrors.NewValidationError(fmt.Sprintf("profile %s does not exist", name))
+	}
+	current, err := f.GetCurrentProfile()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if current == name {
+		err = f.SetCurrentProfile(files.DefaultProfile)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = f.fs.RemoveAll(files.GetProfileHome(home, name))
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// GetProfiles lists the default profile first and then the others by name
+func (f FileStore) GetProfiles() ([]Profile, error) {
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	current, err := f.GetCurrentProfile()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	names := []string{}
+	entries, err := afero.ReadDir(f.fs, files.GetProfilesDirectory(home))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	names = append([]string{files.DefaultProfile}, names...)
+
+	rootConfig, _ := config.ReadConfigFile(f.fs, files.GetRootUserConfigPath(home))
+	profiles := []Profile{}
+	for _, name := range names {
+		profileHome := files.GetProfileHome(home, name)
+		p := Profile{Name: name, Current: name == current, Home: profileHome}
+		// a profile that sets no api url of its own uses the one of the default profile
+		p.APIURL = rootConfig[string(config.KeyAPIURL)]
+		profileConfig, _ := config.ReadConfigFile(f.fs, files.GetProfileConfigPath(home, name))
+		if u, ok := profileConfig[string(config.KeyAPIURL)]; ok {
+			p.APIURL = u
+		}
+		if p.APIURL == "" {
+			p.APIURL = config.NewConstants().GetBrevAPIURl()
+		}
+		// tokens kept by a credential helper don't show up here
+		for _, c := range []string{brevCredentialsFile, brevEncryptedCredentialsFile} {
+			exists, err := afero.Exists(f.fs, filepath.Join(profileHome, c))
+			if err != nil {
+				return nil, breverrors.WrapAndTrace(err)
+			}
+			p.LoggedIn = p.LoggedIn || exists
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfiles(t *testing.T) {
+	fs := MakeMockFileStore().WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+
+	current, err := fs.GetCurrentProfile()
+	assert.Nil(t, err)
+	assert.Equal(t, files.DefaultProfile, current)
+
+	err = fs.AddProfile("Staging", "")
+	assert.Error(t, err)
+	err = fs.SetCurrentProfile("staging")
+	assert.Error(t, err)
+
+	err = fs.AddProfile("staging", "https://api.staging.example.com")
+	assert.Nil(t, err)
+	err = fs.AddProfile("staging", "")
+	assert.Error(t, err)
+	err = fs.SetCurrentProfile("staging")
+	assert.Nil(t, err)
+
+	err = afero.WriteFile(fs.fs, "/home/test/.brev/profiles/staging/credentials.json", []byte("{}"), 0o600)
+	assert.Nil(t, err)
+
+	profiles, err := fs.GetProfiles()
+	assert.Nil(t, err)
+	if assert.Len(t, profiles, 2) {
+		assert.Equal(t, files.DefaultProfile, profiles[0].Name)
+		assert.False(t, profiles[0].Current)
+		assert.False(t, profiles[0].LoggedIn)
+		assert.Equal(t, "staging", profiles[1].Name)
+		assert.True(t, profiles[1].Current)
+		assert.True(t, profiles[1].LoggedIn)
+		assert.Equal(t, "https://api.staging.example.com", profiles[1].APIURL)
+		assert.Equal(t, "/home/test/.brev/profiles/staging", profiles[1].Home)
+	}
+
+	err = fs.RemoveProfile(files.DefaultProfile)
+	assert.Error(t, err)
+	err = fs.RemoveProfile("staging")
+	assert.Nil(t, err)
+	current, err = fs.GetCurrentProfile()
+	assert.Nil(t, err)
+	assert.Equal(t, files.DefaultProfile, current)
+	exists, err := fs.ProfileExists("staging")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
+
+func TestCredentialsArePerProfile(t *testing.T) {
+	t.Cleanup(func() { files.SetActiveProfile(files.DefaultProfile) })
+	fs := MakeMockFileStore().WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+
+	path, err := fs.GetBrevHomePath()
+	assert.Nil(t, err)
+	assert.Equal(t, "/home/test/.brev", path)
+
+	files.SetActiveProfile("staging")
+	path, err = fs.GetBrevHomePath()
+	assert.Nil(t, err)
+	assert.Equal(t, "/home/test/.brev/profiles/staging", path)
+}
+
+func TestProfileNamesStayInProfilesDirectory(t *testing.T) {
+	fs := MakeMockFileStore().WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+	assert.Nil(t, fs.fs.MkdirAll("/home/test/.brev/profiles", 0o755))
+	assert.Nil(t, afero.WriteFile(fs.fs, "/home/test/notes.txt", []byte("keep"), 0o600))
+
+	for _, name := range []string{"../..", "..", "a/b", "../x", ""} {
+		_, err := fs.ProfileExists(name)
+		assert.Error(t, err, name)
+		assert.Error(t, fs.SetCurrentProfile(name), name)
+		assert.Error(t, fs.RemoveProfile(name), name)
+		assert.Error(t, fs.AddProfile(name, ""), name)
+	}
+
+	for _, path := range []string{"/home/test/notes.txt", "/home/test/.brev/profiles"} {
+		exists, err := afero.Exists(fs.fs, path)
+		assert.Nil(t, err)
+		assert.True(t, exists, path)
+	}
+	current, err := fs.GetCurrentProfile()
+	assert.Nil(t, err)
+	assert.Equal(t, files.DefaultProfile, current)
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/spf13/afero"
+)
+
+// ProtectedWorkspace is an instance labeled on this machine so "brev delete" refuses it,
+// the label is never sent to the brev api
+type ProtectedWorkspace struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ProtectedAt time.Time `json:"protectedAt"`
+}
+
+func (f FileStore) GetProtectedWorkspacesPath() (string, error) {
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return files.GetProtectedWorkspacesPath(home), nil
+}
+
+// GetProtectedWorkspaces returns an empty list if nothing has been protected yet
+func (f FileStore) GetProtectedWorkspaces() ([]ProtectedWorkspace, error) {
+	path, err := f.GetProtectedWorkspacesPath()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	exists, err := afero.Exists(f.fs, path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return []ProtectedWorkspace{}, nil
+	}
+	protected := []ProtectedWorkspace{}
+	err = files.ReadJSON(f.fs, path, &protected)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return protected, nil
+}
+
+func (f FileStore) IsWorkspaceProtected(workspaceID string) (bool, error) {
+	protected, err := f.GetProtectedWorkspaces()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	for _, p := range protected {
+		if p.ID == workspaceID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ProtectWorkspace is a no-op for an instance that is already protected
+func (f FileStore) ProtectWorkspace(workspace entity.Workspace) error {
+	protected, err := f.GetProtectedWorkspaces()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	for _, p := range protected {
+		if p.ID == workspace.ID {
+			return nil
+		}
+	}
+	protected = append(protected, ProtectedWorkspace{ID: workspace.ID, Name: workspace.Name, ProtectedAt: time.Now().UTC()})
+	sort.Slice(protected, func(i, j int) bool { return protected[i].Name < protected[j].Name })
+	return f.writeProtectedWorkspaces(protected)
+}
+
+// UnprotectWorkspace returns false if the instance was not protected
+func (f FileStore) UnprotectWorkspace(workspaceID string) (bool, error) {
+	protected, err := f.GetProtectedWorkspaces()
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	kept := []ProtectedWorkspace{}
+	for _, p := range protected {
+		if p.ID != workspaceID {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(protected) {
+		return false, nil
+	}
+	err = f.writeProtectedWorkspaces(kept)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	return true, nil
+}
+
+func (f FileStore) writeProtectedWorkspaces(protected []ProtectedWorkspace) error {
+	path, err := f.GetProtectedWorkspacesPath()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = f.fs.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	data, err := json.MarshalIndent(protected, "", " ")
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = afero.WriteFile(f.fs, path, data, 0o644)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtectedWorkspaces(t *testing.T) {
+	fs := MakeMockFileStore().WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+
+	protected, err := fs.IsWorkspaceProtected("ws-1")
+	assert.Nil(t, err)
+	assert.False(t, protected)
+
+	err = fs.ProtectWorkspace(entity.Workspace{ID: "ws-1", Name: "prod"})
+	assert.Nil(t, err)
+	// protecting twice keeps one entry
+	err = fs.ProtectWorkspace(entity.Workspace{ID: "ws-1", Name: "prod"})
+	assert.Nil(t, err)
+	err = fs.ProtectWorkspace(entity.Workspace{ID: "ws-2", Name: "db"})
+	assert.Nil(t, err)
+
+	list, err := fs.GetProtectedWorkspaces()
+	if assert.Nil(t, err) && assert.Len(t, list, 2) {
+		assert.Equal(t, "db", list[0].Name)
+		assert.Equal(t, "prod", list[1].Name)
+	}
+
+	protected, err = fs.IsWorkspaceProtected("ws-1")
+	assert.Nil(t, err)
+	assert.True(t, protected)
+
+	removed, err := fs.UnprotectWorkspace("ws-1")
+	assert.Nil(t, err)
+	assert.True(t, removed)
+	removed, err = fs.UnprotectWorkspace("ws-1")
+	assert.Nil(t, err)
+	assert.False(t, removed)
+
+	protected, err = fs.IsWorkspaceProtected("ws-1")
+	assert.Nil(t, err)
+	assert.False(t, protected)
+}
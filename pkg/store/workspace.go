@@ -58,14 +58,12 @@ type CreateWorkspacesOptions struct {
 }
 
 var (
-	DefaultWorkspaceClassID = config.GlobalConfig.GetDefaultWorkspaceClass()
-	UserWorkspaceClassID    = "2x8"
-	DevWorkspaceClassID     = "4x16"
+	UserWorkspaceClassID = "2x8"
+	DevWorkspaceClassID  = "4x16"
 
-	DefaultWorkspaceTemplateID = config.GlobalConfig.GetDefaultWorkspaceTemplate()
+	DefaultWorkspaceTemplateID = config.NewConstants().GetDefaultWorkspaceTemplate()
 	UserWorkspaceTemplateID    = "4nbb4lg2s"
 	DevWorkspaceTemplateID     = "v7nd45zsc"
-	DefaultDiskStorage         = "120Gi"
 )
 
 var (
@@ -84,13 +82,13 @@ func NewCreateWorkspacesOptions(clusterID, name string) *CreateWorkspacesOptions
 	return &CreateWorkspacesOptions{
 		Name:                 name,
 		WorkspaceGroupID:     clusterID,
-		WorkspaceClassID:     DefaultWorkspaceClassID,
+		WorkspaceClassID:     config.Global().GetDefaultWorkspaceClass(),
 		GitRepo:              "",
 		WorkspaceTemplateID:  DefaultWorkspaceTemplateID,
 		PrimaryApplicationID: DefaultApplicationID,
 		Applications:         DefaultApplicationList,
 		StartupScript:        setupscript.DefaultSetupScript,
-		DiskStorage:          DefaultDiskStorage,
+		DiskStorage:          config.Global().GetDiskSize(),
 	}
 }
 
@@ -695,7 +693,7 @@ var (
 )
 
 func ValidateOllamaModel(model string, tag string) (bool, error) {
-	restyClient := resty.New().SetBaseURL(config.NewConstants().GetOllamaAPIURL())
+	restyClient := resty.New().SetBaseURL(config.Global().GetOllamaAPIURL())
 	if tag == "" {
 		tag = "latest"
 	}
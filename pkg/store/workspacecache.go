@@ -0,0 +1,102 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/cmd/version"
+	"github.com/brevdev/brev-cli/pkg/entity"
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/brevdev/brev-cli/pkg/files"
+	"github.com/spf13/afero"
+)
+
+// WorkspaceCacheMaxAge is how long the cached instances are trusted without asking the api
+const WorkspaceCacheMaxAge = time.Minute
+
+// WorkspaceCache is the last list of your instances in the active org that the ssh config was
+// written for
+type WorkspaceCache struct {
+	OrgID      string             `json:"orgId"`
+	Hash       string             `json:"hash"`
+	UpdatedAt  time.Time          `json:"updatedAt"`
+	Workspaces []entity.Workspace `json:"workspaces"`
+	// CLIVersion wrote the cache, and the ssh config
+	CLIVersion string `json:"cliVersion"`
+}
+
+func (c WorkspaceCache) IsFresh(now time.Time) bool {
+	return now.Sub(c.UpdatedAt) < WorkspaceCacheMaxAge
+}
+
+// HashWorkspaces does not depend on the order the api returned the instances in
+func HashWorkspaces(workspaces []entity.Workspace) (string, error) {
+	sorted := make([]entity.Workspace, len(workspaces))
+	copy(sorted, workspaces)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	data, err := json.Marshal(sorted)
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (f FileStore) GetWorkspaceCachePath() (string, error) {
+	home, err := f.UserHomeDir()
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	return files.GetWorkspaceCachePath(home), nil
+}
+
+// GetWorkspaceCache returns nil if nothing was cached yet
+func (f FileStore) GetWorkspaceCache() (*WorkspaceCache, error) {
+	path, err := f.GetWorkspaceCachePath()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	exists, err := afero.Exists(f.fs, path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	var cache WorkspaceCache
+	err = files.ReadJSON(f.fs, path, &cache)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &cache, nil
+}
+
+func (f FileStore) WriteWorkspaceCache(orgID string, workspaces []entity.Workspace) (*WorkspaceCache, error) {
+	hash, err := HashWorkspaces(workspaces)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	cache := WorkspaceCache{OrgID: orgID, Hash: hash, UpdatedAt: time.Now().UTC(), Workspaces: workspaces, CLIVersion: version.Version}
+
+	path, err := f.GetWorkspaceCachePath()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	err = f.fs.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	data, err := json.MarshalIndent(cache, "", " ")
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	err = afero.WriteFile(f.fs, path, data, 0o644)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &cache, nil
+}
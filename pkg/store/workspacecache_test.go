@@ -0,0 +1,48 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brevdev/brev-cli/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashWorkspacesIgnoresOrder(t *testing.T) {
+	a := entity.Workspace{ID: "ws-1", Name: "a", Status: entity.Running}
+	b := entity.Workspace{ID: "ws-2", Name: "b", Status: entity.Running}
+
+	h1, err := HashWorkspaces([]entity.Workspace{a, b})
+	assert.Nil(t, err)
+	h2, err := HashWorkspaces([]entity.Workspace{b, a})
+	assert.Nil(t, err)
+	assert.Equal(t, h1, h2)
+
+	b.DNS = "b-org.brev.sh"
+	h3, err := HashWorkspaces([]entity.Workspace{a, b})
+	assert.Nil(t, err)
+	assert.NotEqual(t, h1, h3)
+}
+
+func TestWorkspaceCache(t *testing.T) {
+	fs := MakeMockFileStore().WithUserHomeDirGetter(func() (string, error) {
+		return "/home/test", nil
+	})
+
+	cache, err := fs.GetWorkspaceCache()
+	assert.Nil(t, err)
+	assert.Nil(t, cache)
+
+	workspaces := []entity.Workspace{{ID: "ws-1", Name: "a", Status: entity.Running}}
+	written, err := fs.WriteWorkspaceCache("org-1", workspaces)
+	assert.Nil(t, err)
+
+	cache, err = fs.GetWorkspaceCache()
+	if assert.Nil(t, err) && assert.NotNil(t, cache) {
+		assert.Equal(t, "org-1", cache.OrgID)
+		assert.Equal(t, written.Hash, cache.Hash)
+		assert.Equal(t, workspaces, cache.Workspaces)
+		assert.True(t, cache.IsFresh(cache.UpdatedAt.Add(time.Second)))
+		assert.False(t, cache.IsFresh(cache.UpdatedAt.Add(WorkspaceCacheMaxAge)))
+	}
+}
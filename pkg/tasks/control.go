@@ -0,0 +1,186 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+)
+
+// the host is ignored, every request goes over the unix socket
+const controlBaseURL = "http://brev-tasks"
+
+// DaemonStatus is what the task daemon reports over its control socket
+type DaemonStatus struct {
+	Pid       int          `json:"pid"`
+	StartedAt time.Time    `json:"startedAt"`
+	Tasks     []TaskStatus `json:"tasks"`
+}
+
+type controlError struct {
+	Error string `json:"error"`
+}
+
+// ServeControlSocket lets "brev tasks" inspect and control the runner through a unix socket,
+// call the returned func to shut the socket down
+func ServeControlSocket(tr *TaskRunner, sockPath string) (func(), error) {
+	err := removeStaleSocket(sockPath)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	l, err := net.Listen("unix", sockPath)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	err = os.Chmod(sockPath, 0o600)
+	if err != nil {
+		_ = l.Close()
+		return nil, breverrors.WrapAndTrace(err)
+	}
+
+	srv := &http.Server{Handler: newControlHandler(tr, time.Now()), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	return func() {
+		_ = srv.Close()
+		_ = os.Remove(sockPath)
+	}, nil
+}
+
+func newControlHandler(tr *TaskRunner, startedAt time.Time) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		writeControlJSON(w, http.StatusOK, DaemonStatus{Pid: os.Getpid(), StartedAt: startedAt, Tasks: tr.Status()})
+	})
+	mux.HandleFunc("/trigger", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		err := tr.Trigger(r.URL.Query().Get("name"))
+		if err != nil {
+			writeControlError(w, http.StatusNotFound, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("/stop", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		tr.SendStop()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
+
+func writeControlJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeControlError(w http.ResponseWriter, code int, err error) {
+	writeControlJSON(w, code, controlError{Error: err.Error()})
+}
+
+// removeStaleSocket clears a socket left behind by a daemon that did not shut down cleanly
+func removeStaleSocket(sockPath string) error {
+	if _, err := os.Stat(sockPath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", sockPath, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("a task daemon is already listening on %s", sockPath)
+	}
+	err = os.Remove(sockPath)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// ControlClient talks to a task daemon over its control socket
+type ControlClient struct {
+	client *http.Client
+}
+
+func NewControlClient(sockPath string) ControlClient {
+	return ControlClient{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", sockPath)
+				},
+			},
+		},
+	}
+}
+
+func (c ControlClient) Status() (*DaemonStatus, error) {
+	var status DaemonStatus
+	err := c.do(http.MethodGet, "/status", &status)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &status, nil
+}
+
+func (c ControlClient) Trigger(name string) error {
+	err := c.do(http.MethodPost, "/trigger?name="+url.QueryEscape(name), nil)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (c ControlClient) Stop() error {
+	err := c.do(http.MethodPost, "/stop", nil)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (c ControlClient) do(method string, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(context.Background(), method, controlBaseURL+path, nil)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	defer res.Body.Close() //nolint:errcheck // read only
+	if res.StatusCode >= 400 {
+		var ce controlError
+		_ = json.NewDecoder(res.Body).Decode(&ce)
+		if ce.Error == "" {
+			ce.Error = res.Status
+		}
+		return breverrors.NewValidationError(ce.Error)
+	}
+	if out != nil {
+		err = json.NewDecoder(res.Body).Decode(out)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	return nil
+}
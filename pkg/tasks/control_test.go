@@ -0,0 +1,95 @@
+package tasks
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlSocket(t *testing.T) {
+	dt := &DummyTask{TaskSpec: TaskSpec{Cron: "@every 1h"}}
+	tr := NewTaskRunner([]Task{NamedTask{Name: "dummy", Task: dt}})
+	sockPath := filepath.Join(t.TempDir(), "brev.sock")
+	closeControl, err := ServeControlSocket(tr, sockPath)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer closeControl()
+
+	runErr := make(chan error)
+	go func() {
+		runErr <- tr.Run()
+	}()
+
+	client := NewControlClient(sockPath)
+	assert.Eventually(t, func() bool {
+		status, err := client.Status()
+		return err == nil && len(status.Tasks) == 1 && status.Tasks[0].NextRun != nil
+	}, time.Second, 10*time.Millisecond)
+
+	status, err := client.Status()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "dummy", status.Tasks[0].Name)
+	assert.Equal(t, "@every 1h", status.Tasks[0].Cron)
+	assert.Nil(t, status.Tasks[0].LastRun)
+
+	err = client.Trigger("nope")
+	assert.Error(t, err)
+
+	err = client.Trigger("dummy")
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		status, err := client.Status()
+		return err == nil && status.Tasks[0].LastRun != nil
+	}, time.Second, 10*time.Millisecond)
+
+	err = client.Stop()
+	assert.Nil(t, err)
+	select {
+	case err := <-runErr:
+		assert.Nil(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("runner did not stop")
+	}
+}
+
+func TestServeControlSocketRefusesWhenInUse(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "brev.sock")
+	closeControl, err := ServeControlSocket(NewTaskRunner(nil), sockPath)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer closeControl()
+
+	_, err = ServeControlSocket(NewTaskRunner(nil), sockPath)
+	assert.Error(t, err)
+}
+
+func TestRunTasksWithControlSocket(t *testing.T) {
+	dt := &DummyTask{TaskSpec: TaskSpec{Cron: "@every 1h"}}
+	sockPath := filepath.Join(t.TempDir(), "brev.sock")
+	runErr := make(chan error)
+	go func() {
+		runErr <- RunTasksWithControlSocket([]Task{NamedTask{Name: "sshcd", Task: dt}}, sockPath)
+	}()
+
+	client := NewControlClient(sockPath)
+	assert.Eventually(t, func() bool {
+		status, err := client.Status()
+		return err == nil && len(status.Tasks) == 1 && status.Tasks[0].Name == "sshcd"
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, client.Stop())
+	select {
+	case err := <-runErr:
+		assert.Nil(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("tasks did not stop")
+	}
+	_, err := client.Status()
+	assert.Error(t, err)
+}
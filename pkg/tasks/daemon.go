@@ -0,0 +1,67 @@
+package tasks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/sevlyar/go-daemon"
+)
+
+const (
+	daemonPidFile = "task_daemon.pid"
+	daemonLogFile = "task_daemon.log"
+)
+
+func GetDaemonPidFilePath(brevHome string) string {
+	return filepath.Join(brevHome, daemonPidFile)
+}
+
+func GetDaemonLogFilePath(brevHome string) string {
+	return filepath.Join(brevHome, daemonLogFile)
+}
+
+// GetRunningDaemon returns the task daemon started by RunTaskAsDaemon, or nil if it is not running
+func GetRunningDaemon(brevHome string) (*os.Process, error) {
+	pid, err := daemon.ReadPidFile(GetDaemonPidFilePath(brevHome))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil, nil //nolint:nilerr // a missing process means the pid file is stale
+	}
+	if p.Signal(syscall.Signal(0)) != nil {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// StopDaemon asks the task daemon to stop and waits for it to exit, returns false if it was not running
+func StopDaemon(brevHome string, timeout time.Duration) (bool, error) {
+	p, err := GetRunningDaemon(brevHome)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	if p == nil {
+		return false, nil
+	}
+	err = p.Signal(syscall.SIGTERM)
+	if err != nil {
+		return false, breverrors.WrapAndTrace(err)
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if p.Signal(syscall.Signal(0)) != nil {
+			return true, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false, breverrors.WrapAndTrace(errors.New("timed out waiting for the task daemon to stop"))
+}
@@ -0,0 +1,27 @@
+package tasks
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRunningDaemon(t *testing.T) {
+	brevHome := t.TempDir()
+
+	p, err := GetRunningDaemon(brevHome)
+	assert.Nil(t, err)
+	assert.Nil(t, p)
+
+	err = os.WriteFile(GetDaemonPidFilePath(brevHome), []byte(fmt.Sprint(os.Getpid())), 0o600)
+	if !assert.Nil(t, err) {
+		return
+	}
+	p, err = GetRunningDaemon(brevHome)
+	assert.Nil(t, err)
+	if assert.NotNil(t, p) {
+		assert.Equal(t, os.Getpid(), p.Pid)
+	}
+}
@@ -0,0 +1,112 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+)
+
+// Clock is the time source for backoff and timeouts so they can be tested without sleeping
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// runWithPolicy runs the task, retrying with exponential backoff up to spec.MaxAttempts
+func runWithPolicy(clock Clock, name string, task Task, spec TaskSpec) error {
+	attempts := spec.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := spec.Backoff
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = runAttempt(clock, task, spec.Timeout)
+		if err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		log.Printf("%s: attempt %d/%d failed, retrying in %s: %v", name, attempt, attempts, backoff, err)
+		<-clock.After(backoff)
+		backoff *= 2
+		if spec.MaxBackoff > 0 && backoff > spec.MaxBackoff {
+			backoff = spec.MaxBackoff
+		}
+	}
+	return breverrors.WrapAndTrace(err)
+}
+
+func runAttempt(clock Clock, task Task, timeout time.Duration) error {
+	if timeout <= 0 {
+		return runTask(context.Background(), task)
+	}
+	if !cancelable(task) {
+		return errNotCancelable(timeout)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- runTask(ctx, task)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-clock.After(timeout):
+		// wait for the task to stop so the job stays running until it has, otherwise the next run overlaps it
+		cancel()
+		<-done
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+func cancelable(task Task) bool {
+	if n, ok := task.(NamedTask); ok {
+		task = n.Task
+	}
+	_, ok := task.(ContextTask)
+	return ok
+}
+
+func errNotCancelable(timeout time.Duration) error {
+	return breverrors.NewValidationError(fmt.Sprintf("a timeout of %s needs a task that implements ContextTask so it can be canceled", timeout))
+}
+
+// validateSpec refuses specs the runner can't honor
+func validateSpec(task Task, spec TaskSpec) error {
+	if spec.Timeout > 0 && !cancelable(task) {
+		return breverrors.WrapAndTrace(errNotCancelable(spec.Timeout))
+	}
+	return nil
+}
+
+func runTask(ctx context.Context, task Task) error {
+	if n, ok := task.(NamedTask); ok {
+		task = n.Task
+	}
+	var err error
+	if ct, ok := task.(ContextTask); ok {
+		err = ct.RunContext(ctx)
+	} else {
+		err = task.Run()
+	}
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mu        sync.Mutex
+	now       time.Time
+	waiters   []fakeWaiter
+	requested []time.Duration
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requested = append(c.requested, d)
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := []fakeWaiter{}
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+func (c *fakeClock) waitForWaiters(t *testing.T, n int) {
+	assert.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.waiters) == n
+	}, time.Second, time.Millisecond)
+}
+
+type flakyTask struct {
+	mu       sync.Mutex
+	attempts int
+	failures int
+	spec     TaskSpec
+}
+
+func (f *flakyTask) Run() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.attempts <= f.failures {
+		return fmt.Errorf("transient failure %d", f.attempts)
+	}
+	return nil
+}
+
+func (f *flakyTask) getAttempts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts
+}
+
+func (f *flakyTask) GetTaskSpec() TaskSpec { return f.spec }
+
+func (f *flakyTask) Configure() error { return nil }
+
+func TestRunWithPolicyRetriesWithBackoff(t *testing.T) {
+	clock := newFakeClock()
+	task := &flakyTask{failures: 2}
+	spec := TaskSpec{MaxAttempts: 3, Backoff: time.Second, MaxBackoff: 1500 * time.Millisecond}
+
+	done := make(chan error)
+	go func() {
+		done <- runWithPolicy(clock, "flaky", task, spec)
+	}()
+
+	clock.waitForWaiters(t, 1)
+	assert.Equal(t, 1, task.getAttempts())
+	clock.Advance(time.Second)
+
+	clock.waitForWaiters(t, 1)
+	assert.Equal(t, 2, task.getAttempts())
+	clock.Advance(1500 * time.Millisecond)
+
+	assert.Nil(t, <-done)
+	assert.Equal(t, 3, task.getAttempts())
+	// doubled from 1s to 2s then capped
+	assert.Equal(t, []time.Duration{time.Second, 1500 * time.Millisecond}, clock.requested)
+}
+
+func TestRunWithPolicyGivesUp(t *testing.T) {
+	clock := newFakeClock()
+	task := &flakyTask{failures: 10}
+
+	done := make(chan error)
+	go func() {
+		done <- runWithPolicy(clock, "flaky", task, TaskSpec{MaxAttempts: 2, Backoff: time.Second})
+	}()
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Second)
+
+	err := <-done
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "transient failure 2")
+	}
+	assert.Equal(t, 2, task.getAttempts())
+}
+
+func TestRunWithPolicyNoRetriesByDefault(t *testing.T) {
+	task := &flakyTask{failures: 1}
+	err := runWithPolicy(newFakeClock(), "flaky", task, TaskSpec{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, task.getAttempts())
+}
+
+type blockingContextTask struct {
+	canceled chan struct{}
+}
+
+func (b *blockingContextTask) Run() error { return nil }
+
+func (b *blockingContextTask) RunContext(ctx context.Context) error {
+	<-ctx.Done()
+	close(b.canceled)
+	return ctx.Err()
+}
+
+func (b *blockingContextTask) GetTaskSpec() TaskSpec { return TaskSpec{} }
+
+func (b *blockingContextTask) Configure() error { return nil }
+
+func TestRunWithPolicyTimeoutCancelsContext(t *testing.T) {
+	clock := newFakeClock()
+	task := &blockingContextTask{canceled: make(chan struct{})}
+
+	done := make(chan error)
+	go func() {
+		done <- runWithPolicy(clock, "blocking", NamedTask{Name: "blocking", Task: task}, TaskSpec{Timeout: time.Minute})
+	}()
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+
+	err := <-done
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "timed out after 1m0s")
+	}
+	select {
+	case <-task.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("task context was not canceled")
+	}
+}
+
+func TestRunWithPolicyRefusesTimeoutForUncancelableTask(t *testing.T) {
+	task := &flakyTask{}
+	err := runWithPolicy(newFakeClock(), "flaky", task, TaskSpec{Timeout: time.Minute})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "ContextTask")
+	}
+	assert.Equal(t, 0, task.getAttempts())
+
+	assert.Error(t, validateSpec(task, TaskSpec{Timeout: time.Minute}))
+	assert.Nil(t, validateSpec(task, TaskSpec{}))
+	assert.Nil(t, validateSpec(NamedTask{Name: "blocking", Task: &blockingContextTask{}}, TaskSpec{Timeout: time.Minute}))
+}
+
+type slowToStopTask struct {
+	stopping chan struct{}
+	stop     chan struct{}
+}
+
+func (s *slowToStopTask) Run() error { return nil }
+
+func (s *slowToStopTask) RunContext(ctx context.Context) error {
+	<-ctx.Done()
+	close(s.stopping)
+	<-s.stop
+	return ctx.Err()
+}
+
+func (s *slowToStopTask) GetTaskSpec() TaskSpec { return TaskSpec{Timeout: time.Minute} }
+
+func (s *slowToStopTask) Configure() error { return nil }
+
+func TestTimedOutJobStaysRunningUntilTaskStops(t *testing.T) {
+	clock := newFakeClock()
+	task := &slowToStopTask{stopping: make(chan struct{}), stop: make(chan struct{})}
+	state := newRunnerState([]Task{task})
+	state.clock = clock
+
+	done := make(chan struct{})
+	go func() {
+		state.job(0)()
+		close(done)
+	}()
+	clock.waitForWaiters(t, 1)
+	clock.Advance(time.Minute)
+	<-task.stopping
+
+	assert.True(t, state.statuses()[0].Running)
+	close(task.stop)
+	<-done
+	status := state.statuses()[0]
+	assert.False(t, status.Running)
+	assert.Equal(t, "timed out after 1m0s", status.LastError)
+}
+
+type gatedTask struct {
+	mu      sync.Mutex
+	started chan struct{}
+	release chan struct{}
+	runs    int
+	spec    TaskSpec
+}
+
+func newGatedTask(overlap OverlapPolicy) *gatedTask {
+	return &gatedTask{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+		spec:    TaskSpec{Overlap: overlap},
+	}
+}
+
+func (g *gatedTask) Run() error {
+	g.mu.Lock()
+	g.runs++
+	g.mu.Unlock()
+	g.started <- struct{}{}
+	<-g.release
+	return nil
+}
+
+func (g *gatedTask) getRuns() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.runs
+}
+
+func (g *gatedTask) GetTaskSpec() TaskSpec { return g.spec }
+
+func (g *gatedTask) Configure() error { return nil }
+
+func runOverlapping(task *gatedTask) {
+	state := newRunnerState([]Task{task})
+	job := state.job(0)
+	done := make(chan struct{})
+	go func() {
+		job()
+		close(done)
+	}()
+	<-task.started
+	// both are due while the first run is still going
+	job()
+	job()
+	close(task.release)
+	<-done
+}
+
+func TestOverlapSkip(t *testing.T) {
+	task := newGatedTask(OverlapSkip)
+	runOverlapping(task)
+	assert.Equal(t, 1, task.getRuns())
+}
+
+func TestOverlapDefaultsToSkip(t *testing.T) {
+	task := newGatedTask("")
+	runOverlapping(task)
+	assert.Equal(t, 1, task.getRuns())
+}
+
+func TestOverlapQueue(t *testing.T) {
+	task := newGatedTask(OverlapQueue)
+	runOverlapping(task)
+	// the two due runs are coalesced into one
+	assert.Equal(t, 2, task.getRuns())
+}
+
+func TestJobRecordsLastError(t *testing.T) {
+	clock := newFakeClock()
+	task := &flakyTask{failures: 1}
+	state := newRunnerState([]Task{NamedTask{Name: "flaky", Task: task}})
+	state.clock = clock
+
+	state.job(0)()
+	status := state.statuses()[0]
+	assert.Equal(t, "flaky", status.Name)
+	assert.Equal(t, "transient failure 1", status.LastError)
+	if assert.NotNil(t, status.LastRun) {
+		assert.Equal(t, clock.Now(), *status.LastRun)
+	}
+
+	state.job(0)()
+	assert.Equal(t, "", state.statuses()[0].LastError)
+}
@@ -0,0 +1,68 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+)
+
+// Registry maps the names used by "brev tasks run <name>" to their tasks
+type Registry struct {
+	tasks map[string]Task
+	// unsupported are names services may still run that have no task, with why
+	unsupported map[string]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tasks: map[string]Task{}, unsupported: map[string]string{}}
+}
+
+func (r *Registry) Register(name string, task Task) *Registry {
+	r.tasks[name] = task
+	return r
+}
+
+// Unsupported makes Get explain why there is no task of this name instead of calling it unknown
+func (r *Registry) Unsupported(name string, reason string) *Registry {
+	r.unsupported[name] = reason
+	return r
+}
+
+func (r Registry) Names() []string {
+	names := []string{}
+	for n := range r.tasks {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r Registry) Get(name string) (Task, error) {
+	if reason, ok := r.unsupported[name]; ok {
+		return nil, breverrors.NewValidationError(fmt.Sprintf("%s is not supported by this version of brev: %s", name, reason))
+	}
+	task, ok := r.tasks[name]
+	if !ok {
+		return nil, breverrors.NewValidationError(fmt.Sprintf("unknown task %s, available tasks: %s", name, strings.Join(r.Names(), ", ")))
+	}
+	return task, nil
+}
+
+// Select returns the named tasks, or every registered task if no names are given,
+// wrapped in NamedTask so the runner can report on them by name
+func (r Registry) Select(names []string) ([]Task, error) {
+	if len(names) == 0 {
+		names = r.Names()
+	}
+	selected := []Task{}
+	for _, n := range names {
+		task, err := r.Get(n)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		selected = append(selected, NamedTask{Name: n, Task: task})
+	}
+	return selected, nil
+}
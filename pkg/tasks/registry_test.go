@@ -0,0 +1,39 @@
+package tasks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	a := &DummyTask{}
+	b := &DummyTask{}
+	r := NewRegistry().Register("b", b).Register("a", a)
+
+	assert.Equal(t, []string{"a", "b"}, r.Names())
+
+	task, err := r.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, a, task)
+
+	_, err = r.Get("vpnd")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown task vpnd, available tasks: a, b")
+	}
+
+	r.Unsupported("rpcd", "there is no rpc daemon")
+	assert.Equal(t, []string{"a", "b"}, r.Names())
+	_, err = r.Select([]string{"rpcd"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "rpcd is not supported by this version of brev: there is no rpc daemon")
+	}
+
+	all, err := r.Select(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []Task{NamedTask{"a", a}, NamedTask{"b", b}}, all)
+
+	some, err := r.Select([]string{"b"})
+	assert.Nil(t, err)
+	assert.Equal(t, []Task{NamedTask{"b", b}}, some)
+}
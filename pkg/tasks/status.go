@@ -0,0 +1,152 @@
+package tasks
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/pkg/errors"
+	cron "github.com/robfig/cron/v3"
+)
+
+// NamedTask gives a task the name it is registered under so it can be found in status output
+type NamedTask struct {
+	Name string
+	Task
+}
+
+func taskName(t Task, index int) string {
+	if n, ok := t.(NamedTask); ok {
+		return n.Name
+	}
+	return fmt.Sprintf("task-%d", index)
+}
+
+type TaskStatus struct {
+	Name      string     `json:"name"`
+	Cron      string     `json:"cron"`
+	Running   bool       `json:"running"`
+	LastRun   *time.Time `json:"lastRun,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+	NextRun   *time.Time `json:"nextRun,omitempty"`
+}
+
+type runnerState struct {
+	mu      sync.Mutex
+	tasks   []Task
+	status  []TaskStatus
+	queued  map[int]bool
+	entries map[int]cron.EntryID
+	cron    *cron.Cron
+	clock   Clock
+}
+
+func newRunnerState(tasks []Task) *runnerState {
+	status := make([]TaskStatus, len(tasks))
+	for i, t := range tasks {
+		status[i] = TaskStatus{Name: taskName(t, i), Cron: t.GetTaskSpec().Cron}
+	}
+	return &runnerState{
+		tasks:   tasks,
+		status:  status,
+		queued:  map[int]bool{},
+		entries: map[int]cron.EntryID{},
+		clock:   realClock{},
+	}
+}
+
+func (s *runnerState) setCron(c *cron.Cron) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cron = c
+}
+
+func (s *runnerState) setEntry(index int, e cron.EntryID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[index] = e
+}
+
+// job runs the task at index with its retry policy, recording when it ran and how it went.
+// A job that is due while the previous run is still going is skipped or queued per the spec.
+func (s *runnerState) job(index int) func() {
+	return func() {
+		task := s.tasks[index]
+		spec := task.GetTaskSpec()
+		name := taskName(task, index)
+
+		s.mu.Lock()
+		if s.status[index].Running {
+			if spec.Overlap == OverlapQueue {
+				s.queued[index] = true
+			} else {
+				log.Printf("%s: previous run is still going, skipping", name)
+			}
+			s.mu.Unlock()
+			return
+		}
+		s.status[index].Running = true
+		s.mu.Unlock()
+
+		for {
+			started := s.clock.Now()
+			err := runWithPolicy(s.clock, name, task, spec)
+			if err != nil {
+				log.Print(err)
+			}
+
+			s.mu.Lock()
+			s.status[index].LastRun = &started
+			s.status[index].LastError = ""
+			if err != nil {
+				// the root cause reads better in status output than the trace
+				s.status[index].LastError = errors.Cause(err).Error()
+			}
+			if s.queued[index] {
+				s.queued[index] = false
+				s.mu.Unlock()
+				continue
+			}
+			s.status[index].Running = false
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (s *runnerState) statuses() []TaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]TaskStatus, len(s.status))
+	copy(out, s.status)
+	for i := range out {
+		e, ok := s.entries[i]
+		if !ok || s.cron == nil {
+			continue
+		}
+		next := s.cron.Entry(e).Next
+		if !next.IsZero() {
+			out[i].NextRun = &next
+		}
+	}
+	return out
+}
+
+func (s *runnerState) trigger(name string) error {
+	s.mu.Lock()
+	index := -1
+	for i, st := range s.status {
+		if st.Name == name {
+			index = i
+			break
+		}
+	}
+	s.mu.Unlock()
+	if index == -1 {
+		return breverrors.NewValidationError(fmt.Sprintf("task %s is not running in the daemon", name))
+	}
+	go s.job(index)()
+	return nil
+}
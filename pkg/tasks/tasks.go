@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
 	cron "github.com/robfig/cron/v3"
@@ -17,6 +18,7 @@ import (
 type RunTaskAsDaemonStore interface {
 	BuildBrevHome() error
 	GetBrevHomePath() (string, error)
+	GetServerSockFile() string
 }
 
 func RunTaskAsDaemon(tasks []Task, store RunTaskAsDaemonStore) error {
@@ -28,8 +30,8 @@ func RunTaskAsDaemon(tasks []Task, store RunTaskAsDaemonStore) error {
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-	pidFile := fmt.Sprintf("%s/task_daemon.pid", brevHome)
-	logFile := fmt.Sprintf("%s/task_daemon.log", brevHome)
+	pidFile := GetDaemonPidFilePath(brevHome)
+	logFile := GetDaemonLogFilePath(brevHome)
 	cntxt := &daemon.Context{
 		PidFileName: pidFile,
 		PidFilePerm: 0o644,
@@ -58,7 +60,7 @@ func RunTaskAsDaemon(tasks []Task, store RunTaskAsDaemonStore) error {
 	log.Print("- - - - - - - - - - - - - - -")
 	log.Print("daemon started")
 
-	err = RunTasks(tasks)
+	err = RunTasksWithControlSocket(tasks, store.GetServerSockFile())
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
@@ -70,6 +72,25 @@ func RunTaskAsDaemon(tasks []Task, store RunTaskAsDaemonStore) error {
 	return nil
 }
 
+// RunTasksWithControlSocket runs the tasks in the foreground, as the installed services do, and
+// serves the control socket so brev tasks status, trigger and stop can reach them
+func RunTasksWithControlSocket(tasks []Task, sockFile string) error {
+	tr := NewTaskRunner(tasks)
+	closeControl, err := ServeControlSocket(tr, sockFile)
+	if err != nil {
+		// the tasks can still be stopped with a signal
+		log.Printf("control socket unavailable: %v", err)
+	} else {
+		defer closeControl()
+	}
+
+	err = tr.Run()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
 func RunTasks(tasks []Task) error {
 	d := NewTaskRunner(tasks)
 
@@ -90,17 +111,40 @@ type Task interface {
 type TaskSpec struct {
 	Cron               string // can be "" if want to run once // https://pkg.go.dev/github.com/robfig/cron?utm_source=godoc#hdr-CRON_Expression_Format
 	RunCronImmediately bool   // only applied if cron not ""
+
+	MaxAttempts int           // attempts per run including the first, 0 and 1 mean no retries
+	Backoff     time.Duration // wait before the first retry, doubled after every failed retry
+	MaxBackoff  time.Duration // caps the doubled backoff, 0 means no cap
+	Timeout     time.Duration // per attempt, 0 means no timeout // only allowed for tasks implementing ContextTask, which are canceled
+	Overlap     OverlapPolicy // what to do when a run is due while the previous one is still going
+}
+
+type OverlapPolicy string
+
+const (
+	// OverlapSkip drops a run that is due while the previous one is still going, this is the default
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue runs once more after the current run finishes, however many runs were due meanwhile
+	OverlapQueue OverlapPolicy = "queue"
+)
+
+// ContextTask is implemented by tasks that can stop early when their run times out or the runner stops
+type ContextTask interface {
+	Task
+	RunContext(ctx context.Context) error
 }
 
 type TaskRunner struct {
 	Tasks       []Task
 	StopSignals chan os.Signal
+	state       *runnerState
 }
 
 func NewTaskRunner(tasks []Task) *TaskRunner {
 	return &TaskRunner{
 		tasks,
 		make(chan os.Signal, 1),
+		newRunnerState(tasks),
 	}
 }
 
@@ -115,19 +159,26 @@ func LogErr(f func() error) func() {
 
 func (tr TaskRunner) Run() error {
 	c := cron.New()
-	for _, t := range tr.Tasks {
+	tr.state.setCron(c)
+	for i, t := range tr.Tasks {
 		spec := t.GetTaskSpec()
+		err := validateSpec(t, spec)
+		if err != nil {
+			return breverrors.WrapAndTrace(fmt.Errorf("%s: %w", taskName(t, i), err))
+		}
+		job := tr.state.job(i)
 		if spec.Cron != "" {
-			e, err := c.AddFunc(spec.Cron, LogErr(t.Run))
+			e, err := c.AddFunc(spec.Cron, job)
 			if err != nil {
 				return breverrors.WrapAndTrace(err)
 			}
+			tr.state.setEntry(i, e)
 			if spec.RunCronImmediately {
 				c.Entry(e).Job.Run()
 			}
 		} else {
 			// we do this so that the context still applies
-			e, err := c.AddFunc("@yearly", LogErr(t.Run))
+			e, err := c.AddFunc("@yearly", job)
 			if err != nil {
 				return breverrors.WrapAndTrace(err)
 			}
@@ -144,6 +195,26 @@ func (tr TaskRunner) Run() error {
 	return nil
 }
 
+// WithClock replaces the clock used for backoff and timeouts, for tests
+func (tr *TaskRunner) WithClock(clock Clock) *TaskRunner {
+	tr.state.clock = clock
+	return tr
+}
+
+// Status reports on every task the runner knows about
+func (tr TaskRunner) Status() []TaskStatus {
+	return tr.state.statuses()
+}
+
+// Trigger runs the named task now without waiting for its next scheduled run
+func (tr TaskRunner) Trigger(name string) error {
+	err := tr.state.trigger(name)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
 func (tr TaskRunner) WaitTillSignal(ctxfn func() context.Context) {
 	signal.Notify(tr.StopSignals, syscall.SIGQUIT)
 	signal.Notify(tr.StopSignals, syscall.SIGTERM)
@@ -157,5 +228,8 @@ func (tr TaskRunner) WaitTillSignal(ctxfn func() context.Context) {
 }
 
 func (tr *TaskRunner) SendStop() {
-	tr.StopSignals <- syscall.SIGQUIT
+	select {
+	case tr.StopSignals <- syscall.SIGQUIT:
+	default: // a stop is already pending
+	}
 }
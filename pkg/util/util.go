@@ -116,6 +116,15 @@ func TryRunVsCodeCommand(args []string, extraPaths ...string) ([]byte, error) {
 	return out, nil
 }
 
+func TryRunCursorCommand(args []string, extraPaths ...string) ([]byte, error) {
+	extraPaths = append(commonCursorPaths, extraPaths...)
+	out, err := runManyVsCodeCommand(extraPaths, args)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return out, nil
+}
+
 func runManyVsCodeCommand(vscodepaths []string, args []string) ([]byte, error) {
 	errs := multierror.Append(nil)
 	for _, vscodepath := range vscodepaths {
@@ -150,3 +159,11 @@ var commonVSCodePaths = []string{
 	"/usr/share/code-oss/bin/code-oss",
 	"/usr/share/code/bin/code",
 }
+
+var commonCursorPaths = []string{
+	"cursor",
+	"/Applications/Cursor.app/Contents/Resources/app/bin/cursor",
+	"/usr/bin/cursor",
+	"/usr/local/bin/cursor",
+	"/opt/cursor/resources/app/bin/cursor",
+}
@@ -0,0 +1,198 @@
+package workspacemanagerv2
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+)
+
+// TarPath writes srcPath, a file or a directory, as a tar stream with its base name at the root,
+// which is the layout the container engines use for copies
+func TarPath(w io.Writer, srcPath string) error {
+	tw := tar.NewWriter(w)
+	err := writeTree(tw, srcPath, filepath.Base(filepath.Clean(srcPath)))
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return breverrors.WrapAndTrace(tw.Close())
+}
+
+// writeTree adds srcPath to the archive as name, with what is in it when it is a directory
+func writeTree(tw *tar.Writer, srcPath string, name string) error {
+	srcPath = filepath.Clean(srcPath)
+	err := filepath.WalkDir(srcPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		rel, err := filepath.Rel(srcPath, path)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		header.Name = pathpkg.Join(name, filepath.ToSlash(rel))
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path) //nolint:gosec // the user picks what to copy
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		defer f.Close() //nolint:errcheck // defer
+		_, err = io.Copy(tw, f)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// Untar extracts a tar stream into dstDir, entries that would end up outside of it are refused
+func Untar(r io.Reader, dstDir string) error {
+	return untar(r, dstDir, "")
+}
+
+// untar only extracts the entries under prefix, without it, when prefix is not empty
+func untar(r io.Reader, dstDir string, prefix string) error {
+	dstDir = filepath.Clean(dstDir)
+	err := os.MkdirAll(dstDir, 0o755)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	realDstDir, err := filepath.EvalSymlinks(dstDir)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		name := header.Name
+		if prefix != "" {
+			var ok bool
+			name, ok = cutPathPrefix(name, prefix)
+			if !ok {
+				continue
+			}
+		}
+		path := filepath.Join(dstDir, filepath.FromSlash(name)) //nolint:gosec // checked below
+		if path == dstDir {
+			continue
+		}
+		if !isInDir(dstDir, path) || !parentIsInDir(realDstDir, path) {
+			return breverrors.WrapAndTrace(fmt.Errorf("refusing to extract %s outside of %s", header.Name, dstDir))
+		}
+		err = extractEntry(tr, header, path)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+}
+
+// cutPathPrefix is name relative to prefix, if name is prefix or in it
+func cutPathPrefix(name string, prefix string) (string, bool) {
+	name = strings.TrimPrefix(pathpkg.Clean("/"+name), "/")
+	prefix = strings.Trim(prefix, "/")
+	if name == prefix {
+		return "", true
+	}
+	rest, ok := strings.CutPrefix(name, prefix+"/")
+	return rest, ok
+}
+
+func isInDir(dir string, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// parentIsInDir catches a symlink extracted earlier that points out of dir
+func parentIsInDir(realDir string, path string) bool {
+	parent := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(parent); err == nil {
+			break
+		}
+		parent = filepath.Dir(parent)
+	}
+	resolved, err := filepath.EvalSymlinks(parent)
+	if err != nil {
+		return false
+	}
+	return isInDir(realDir, resolved)
+}
+
+func extractEntry(tr *tar.Reader, header *tar.Header, path string) error {
+	mode := header.FileInfo().Mode().Perm()
+	switch header.Typeflag {
+	case tar.TypeDir:
+		err := os.MkdirAll(path, mode|0o700)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	case tar.TypeReg, tar.TypeRegA: //nolint:staticcheck // archives from older engines use TypeRegA
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		// a symlink at the path would be followed when opening it
+		_ = os.Remove(path)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_EXCL, mode) //nolint:gosec // checked by Untar
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		_, err = io.Copy(f, tr) //nolint:gosec // the size is bounded by what the user copies
+		if err != nil {
+			_ = f.Close()
+			return breverrors.WrapAndTrace(err)
+		}
+		return breverrors.WrapAndTrace(f.Close())
+	case tar.TypeSymlink:
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		_ = os.Remove(path)
+		err = os.Symlink(header.Linkname, path)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	default:
+		// devices, fifos and hard links are not copied
+	}
+	return nil
+}
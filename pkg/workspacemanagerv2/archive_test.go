@@ -0,0 +1,54 @@
+package workspacemanagerv2
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarPathThenUntar(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "notes.txt")
+	assert.Nil(t, os.WriteFile(src, []byte("hi"), 0o600))
+
+	archive := &bytes.Buffer{}
+	assert.Nil(t, TarPath(archive, src))
+	dst := filepath.Join(t.TempDir(), "new")
+	assert.Nil(t, Untar(archive, dst))
+
+	info, err := os.Stat(filepath.Join(dst, "notes.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func writeTestTar(t *testing.T, headers ...*tar.Header) *bytes.Buffer {
+	archive := &bytes.Buffer{}
+	tw := tar.NewWriter(archive)
+	for _, h := range headers {
+		assert.Nil(t, tw.WriteHeader(h))
+		if h.Typeflag == tar.TypeReg {
+			_, err := tw.Write(make([]byte, h.Size))
+			assert.Nil(t, err)
+		}
+	}
+	assert.Nil(t, tw.Close())
+	return archive
+}
+
+func TestUntarRefusesToEscape(t *testing.T) {
+	dst := t.TempDir()
+	err := Untar(writeTestTar(t, &tar.Header{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1}), dst)
+	assert.ErrorContains(t, err, "refusing to extract ../evil")
+
+	outside := t.TempDir()
+	err = Untar(writeTestTar(t,
+		&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside},
+		&tar.Header{Name: "link/evil", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1},
+	), dst)
+	assert.ErrorContains(t, err, "refusing to extract link/evil")
+	_, err = os.Stat(filepath.Join(outside, "evil"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -1,22 +1,58 @@
 package workspacemanagerv2
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	units "github.com/docker/go-units"
 )
 
-type DockerContainerManager struct{}
+// DockerContainerManager runs the docker cli, without a config containers are privileged
+type DockerContainerManager struct {
+	config *EngineConfig
+}
 
 var _ ContainerManager = DockerContainerManager{}
 
+// NewDockerContainerManager creates containers with the privileges and labels of config, the host
+// is the one of the docker cli
+func NewDockerContainerManager(config EngineConfig) DockerContainerManager {
+	return DockerContainerManager{config: &config}
+}
+
+func (c DockerContainerManager) configArgs() []string {
+	if c.config == nil {
+		return []string{"--privileged"}
+	}
+	args := []string{}
+	if c.config.Privileged {
+		args = append(args, "--privileged")
+	}
+	for _, capability := range c.config.CapAdd {
+		args = append(args, "--cap-add", capability)
+	}
+	for k, v := range c.config.Labels {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", k, v))
+	}
+	return args
+}
+
 type inspectResult struct {
-	ID    string `json:"Id"`
-	State state  `json:"State"`
+	ID     string        `json:"Id"`
+	State  state         `json:"State"`
+	Config inspectConfig `json:"Config"`
+}
+
+type inspectConfig struct {
+	Labels map[string]string `json:"Labels"`
 }
 
 type state struct {
@@ -29,6 +65,9 @@ func (c DockerContainerManager) GetContainer(ctx context.Context, containerIdent
 	cmd := exec.CommandContext(ctx, "docker", "container", "inspect", containerIdentifier)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
+		if isNoSuchContainer(fmt.Errorf(string(out))) {
+			return nil, &NotFoundError{Kind: "container", ID: containerIdentifier, Message: strings.TrimSpace(string(out))}
+		}
 		return nil, breverrors.WrapAndTrace(fmt.Errorf(string(out)))
 	}
 
@@ -43,6 +82,7 @@ func (c DockerContainerManager) GetContainer(ctx context.Context, containerIdent
 	return &Container{
 		ID:     res[0].ID,
 		Status: DockerStatusToContainerStatus(res[0].State.Status),
+		Labels: res[0].Config.Labels,
 	}, nil
 }
 
@@ -99,8 +139,12 @@ func (c DockerContainerManager) CreateContainer(ctx context.Context, options Cre
 	for _, p := range options.Ports {
 		ports = append(ports, "--publish", p)
 	}
+	labels := []string{"--label", fmt.Sprintf("%s=%s", ManagedByLabel, ManagedByValue)}
+	for k, v := range options.Labels {
+		labels = append(labels, "--label", fmt.Sprintf("%s=%s", k, v))
+	}
 	portsAndVolumes := append(ports, volumes...) //nolint:gocritic // not clear why the linter doesn't like this pattern
-	createArgs := append([]string{"--name", options.Name, "--privileged"}, portsAndVolumes...)
+	createArgs := append(append(append([]string{"--name", options.Name}, c.configArgs()...), labels...), portsAndVolumes...)
 	command := []string{}
 	if options.Command != "" {
 		command = []string{options.Command}
@@ -120,6 +164,170 @@ func (c DockerContainerManager) CreateContainer(ctx context.Context, options Cre
 	return res[len(res)-1], nil
 }
 
+func (c DockerContainerManager) ExecContainer(ctx context.Context, containerIdentifier string, options ExecOptions) (int, error) {
+	args := []string{"container", "exec"}
+	if options.Stdin != nil {
+		args = append(args, "--interactive")
+	}
+	if options.TTY {
+		args = append(args, "--tty")
+	}
+	if options.User != "" {
+		args = append(args, "--user", options.User)
+	}
+	if options.WorkingDir != "" {
+		args = append(args, "--workdir", options.WorkingDir)
+	}
+	for _, e := range options.Env {
+		args = append(args, "--env", e)
+	}
+	args = append(append(args, containerIdentifier), options.Cmd...)
+	cmd := exec.CommandContext(ctx, "docker", args...) //nolint:gosec // in sandboxed env
+	cmd.Stdin = options.Stdin
+	cmd.Stdout = orDiscard(options.Stdout)
+	cmd.Stderr = orDiscard(options.Stderr)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, breverrors.WrapAndTrace(err)
+	}
+	return 0, nil
+}
+
+func (c DockerContainerManager) ContainerLogs(ctx context.Context, containerIdentifier string, options LogsOptions) error {
+	args := []string{"container", "logs"}
+	if options.Follow {
+		args = append(args, "--follow")
+	}
+	if options.Tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(options.Tail))
+	}
+	cmd := exec.CommandContext(ctx, "docker", append(args, containerIdentifier)...) //nolint:gosec // in sandboxed env
+	cmd.Stdout = orDiscard(options.Stdout)
+	cmd.Stderr = orDiscard(options.Stderr)
+	err := cmd.Run()
+	if err != nil && ctx.Err() == nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (c DockerContainerManager) CopyToContainer(ctx context.Context, containerIdentifier string, dstDir string, tarStream io.Reader) error {
+	cmd := exec.CommandContext(ctx, "docker", "container", "cp", "-", containerIdentifier+":"+dstDir) //nolint:gosec // in sandboxed env
+	cmd.Stdin = tarStream
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return breverrors.WrapAndTrace(fmt.Errorf(string(out)))
+	}
+	return nil
+}
+
+func (c DockerContainerManager) CopyFromContainer(ctx context.Context, containerIdentifier string, srcPath string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "docker", "container", "cp", containerIdentifier+":"+srcPath, "-") //nolint:gosec // in sandboxed env
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return &cmdOutput{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+// cmdOutput waits for the command when it is closed
+type cmdOutput struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (o *cmdOutput) Close() error {
+	_ = o.ReadCloser.Close()
+	err := o.cmd.Wait()
+	if err != nil {
+		return breverrors.WrapAndTrace(fmt.Errorf("%s", strings.TrimSpace(o.stderr.String())))
+	}
+	return nil
+}
+
+type statsResult struct {
+	CPUPerc  string `json:"CPUPerc"`
+	MemUsage string `json:"MemUsage"`
+	NetIO    string `json:"NetIO"`
+	PIDs     string `json:"PIDs"`
+}
+
+func (c DockerContainerManager) GetContainerStats(ctx context.Context, containerIdentifier string) (*ContainerStats, error) {
+	cmd := exec.CommandContext(ctx, "docker", "container", "stats", "--no-stream", "--format", "{{json .}}", containerIdentifier) //nolint:gosec // in sandboxed env
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if isNoSuchContainer(fmt.Errorf(string(out))) {
+			return nil, &NotFoundError{Kind: "container", ID: containerIdentifier, Message: strings.TrimSpace(string(out))}
+		}
+		return nil, breverrors.WrapAndTrace(fmt.Errorf(string(out)))
+	}
+	res := statsResult{}
+	err = json.Unmarshal(out, &res)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return parseStatsResult(res)
+}
+
+// parseStatsResult reads the human sizes of docker stats, memory is in binary units and network io in decimal ones
+func parseStatsResult(res statsResult) (*ContainerStats, error) {
+	stats := ContainerStats{}
+	var err error
+	if cpu := strings.TrimSuffix(res.CPUPerc, "%"); cpu != "" && cpu != "--" {
+		stats.CPUPercent, err = strconv.ParseFloat(cpu, 64)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+	}
+	stats.MemoryUsage, stats.MemoryLimit, err = parseSizePair(res.MemUsage, units.RAMInBytes)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	stats.NetworkRx, stats.NetworkTx, err = parseSizePair(res.NetIO, units.FromHumanSize)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if pids := strings.TrimSpace(res.PIDs); pids != "" && pids != "--" {
+		stats.PIDs, err = strconv.ParseUint(pids, 10, 64)
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+	}
+	return &stats, nil
+}
+
+// parseSizePair parses "1.5MiB / 2GiB", a stopped container shows "-- / --"
+func parseSizePair(pair string, parse func(string) (int64, error)) (uint64, uint64, error) {
+	sizes := []uint64{}
+	for _, s := range strings.SplitN(pair, "/", 2) {
+		s = strings.TrimSpace(s)
+		if s == "" || s == "--" {
+			sizes = append(sizes, 0)
+			continue
+		}
+		size, err := parse(s)
+		if err != nil {
+			return 0, 0, breverrors.WrapAndTrace(err)
+		}
+		sizes = append(sizes, uint64(size))
+	}
+	for len(sizes) < 2 {
+		sizes = append(sizes, 0)
+	}
+	return sizes[0], sizes[1], nil
+}
+
 // [
 //     {
 //         "Id": "149a3bed2b0d0595df159e4eb032fdc62f3f3f508a59bacf86cfc9e131f98910",
@@ -139,3 +139,22 @@ func Test_Volumes(t *testing.T) {
 		assert.Len(t, info, 2)
 	}
 }
+
+func TestParseStatsResult(t *testing.T) {
+	stats, err := parseStatsResult(statsResult{CPUPerc: "12.50%", MemUsage: "1.5MiB / 2GiB", NetIO: "1.2kB / 0B", PIDs: "3"})
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerStats{CPUPercent: 12.5, MemoryUsage: 1572864, MemoryLimit: 2147483648, NetworkRx: 1200, PIDs: 3}, stats)
+
+	stats, err = parseStatsResult(statsResult{CPUPerc: "--", MemUsage: "-- / --", NetIO: "-- / --", PIDs: "--"})
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerStats{}, stats)
+}
+
+func TestDockerContainerManagerConfigArgs(t *testing.T) {
+	assert.Equal(t, []string{"--privileged"}, DockerContainerManager{}.configArgs())
+	assert.Equal(t, []string{}, NewDockerContainerManager(EngineConfig{}).configArgs())
+	assert.Equal(t,
+		[]string{"--privileged", "--cap-add", "SYS_ADMIN", "--cap-add", "NET_ADMIN"},
+		NewDockerContainerManager(EngineConfig{Privileged: true, CapAdd: []string{"SYS_ADMIN", "NET_ADMIN"}}).configArgs(),
+	)
+}
@@ -0,0 +1,374 @@
+package workspacemanagerv2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+)
+
+// podman serves the same api, 1.41 is the newest version it supports
+const engineAPIVersion = "v1.41"
+
+// EngineConfig is where the engine listens and how containers are created on it
+type EngineConfig struct {
+	// Host is unix:///path/to/socket or tcp://host:port
+	Host       string
+	Privileged bool
+	CapAdd     []string
+	// Labels are put on every container, along with ManagedByLabel
+	Labels map[string]string
+}
+
+// EngineContainerManager talks to the Docker Engine API, which podman also serves
+type EngineContainerManager struct {
+	client  *http.Client
+	baseURL string
+	config  EngineConfig
+	// dial connects to the engine, exec needs the raw connection
+	dial func(ctx context.Context) (net.Conn, error)
+}
+
+var _ ContainerManager = EngineContainerManager{}
+
+// DefaultDockerHost is DOCKER_HOST, like the docker cli
+func DefaultDockerHost() string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	return "unix:///var/run/docker.sock"
+}
+
+// DefaultPodmanHost is CONTAINER_HOST like the podman cli, then the rootless socket of the user,
+// then the rootful one
+func DefaultPodmanHost() string {
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		return host
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		socket := filepath.Join(runtimeDir, "podman", "podman.sock")
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket
+		}
+	}
+	return "unix:///run/podman/podman.sock"
+}
+
+func NewDockerEngineContainerManager(config EngineConfig) (*EngineContainerManager, error) {
+	if config.Host == "" {
+		config.Host = DefaultDockerHost()
+	}
+	return NewEngineContainerManager(config)
+}
+
+func NewPodmanContainerManager(config EngineConfig) (*EngineContainerManager, error) {
+	if config.Host == "" {
+		config.Host = DefaultPodmanHost()
+	}
+	return NewEngineContainerManager(config)
+}
+
+func NewEngineContainerManager(config EngineConfig) (*EngineContainerManager, error) {
+	hostURL, err := url.Parse(config.Host)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	var dial func(ctx context.Context) (net.Conn, error)
+	baseURL := ""
+	switch hostURL.Scheme {
+	case "unix":
+		socket := hostURL.Path
+		dial = func(ctx context.Context) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}
+		// the host of the url is ignored by the dialer
+		baseURL = "http://engine"
+	case "tcp", "http":
+		host := hostURL.Host
+		dial = func(ctx context.Context) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "tcp", host)
+		}
+		baseURL = "http://" + host
+	default:
+		return nil, breverrors.NewValidationError(fmt.Sprintf("unsupported container engine host %s, use unix:// or tcp://", config.Host))
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx)
+		},
+	}
+	return &EngineContainerManager{client: &http.Client{Transport: transport}, baseURL: baseURL, config: config, dial: dial}, nil
+}
+
+func (e EngineContainerManager) GetContainer(ctx context.Context, containerID string) (*Container, error) {
+	res := types.ContainerJSON{}
+	err := e.doJSON(ctx, http.MethodGet, "/containers/"+url.PathEscape(containerID)+"/json", nil, nil, &res, "container", containerID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // keep the error typed for IsNotFound
+	}
+	c := Container{}
+	if res.ContainerJSONBase != nil {
+		c.ID = res.ID
+		if res.State != nil {
+			c.Status = DockerStatusToContainerStatus(res.State.Status)
+		}
+	}
+	if res.Config != nil {
+		c.Labels = res.Config.Labels
+	}
+	return &c, nil
+}
+
+// StopContainer is a no-op for a container that is not running
+func (e EngineContainerManager) StopContainer(ctx context.Context, containerID string) error {
+	err := e.doJSON(ctx, http.MethodPost, "/containers/"+url.PathEscape(containerID)+"/stop", nil, nil, nil, "container", containerID)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed for IsNotFound
+	}
+	return nil
+}
+
+func (e EngineContainerManager) DeleteContainer(ctx context.Context, containerID string) error {
+	err := e.doJSON(ctx, http.MethodDelete, "/containers/"+url.PathEscape(containerID), nil, nil, nil, "container", containerID)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed for IsNotFound and IsConflict
+	}
+	return nil
+}
+
+// StartContainer is a no-op for a container that is already running
+func (e EngineContainerManager) StartContainer(ctx context.Context, containerID string) error {
+	err := e.doJSON(ctx, http.MethodPost, "/containers/"+url.PathEscape(containerID)+"/start", nil, nil, nil, "container", containerID)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed for IsNotFound
+	}
+	return nil
+}
+
+func (e EngineContainerManager) DeleteVolume(ctx context.Context, volumeName string) error {
+	err := e.doJSON(ctx, http.MethodDelete, "/volumes/"+url.PathEscape(volumeName), nil, nil, nil, "volume", volumeName)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed for IsNotFound and IsConflict
+	}
+	return nil
+}
+
+type containerCreateRequest struct {
+	*container.Config
+	HostConfig *container.HostConfig `json:"HostConfig,omitempty"`
+}
+
+// CreateContainer pulls the image if the engine does not have it, like docker create does
+func (e EngineContainerManager) CreateContainer(ctx context.Context, options CreateContainerOptions, image string) (string, error) {
+	req, err := e.makeCreateRequest(options, image)
+	if err != nil {
+		return "", breverrors.WrapAndTrace(err)
+	}
+	query := url.Values{}
+	if options.Name != "" {
+		query.Set("name", options.Name)
+	}
+	res := container.ContainerCreateCreatedBody{}
+	err = e.doJSON(ctx, http.MethodPost, "/containers/create", query, req, &res, "image", image)
+	if IsNotFound(err) {
+		err = e.PullImage(ctx, image)
+		if err != nil {
+			return "", err //nolint:wrapcheck // keep the error typed
+		}
+		err = e.doJSON(ctx, http.MethodPost, "/containers/create", query, req, &res, "image", image)
+	}
+	if IsConflict(err) {
+		return "", &ConflictError{Kind: "container", ID: options.Name, Message: err.Error()}
+	}
+	if err != nil {
+		return "", err //nolint:wrapcheck // keep the error typed
+	}
+	return res.ID, nil
+}
+
+func (e EngineContainerManager) makeCreateRequest(options CreateContainerOptions, image string) (*containerCreateRequest, error) {
+	labels := map[string]string{}
+	for k, v := range e.config.Labels {
+		labels[k] = v
+	}
+	for k, v := range options.Labels {
+		labels[k] = v
+	}
+	labels[ManagedByLabel] = ManagedByValue
+
+	binds := []string{}
+	for _, v := range options.Volumes {
+		binds = append(binds, fmt.Sprintf("%s:%s", v.GetIdentifier(), v.GetMountToPath()))
+	}
+	exposedPorts, portBindings, err := nat.ParsePortSpecs(options.Ports)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	cmd := []string{}
+	if options.Command != "" {
+		cmd = append(cmd, options.Command)
+	}
+	cmd = append(cmd, options.CommandArgs...)
+
+	req := containerCreateRequest{
+		Config: &container.Config{
+			Image:        image,
+			Labels:       labels,
+			ExposedPorts: exposedPorts,
+		},
+		HostConfig: &container.HostConfig{
+			Binds:        binds,
+			PortBindings: portBindings,
+			Privileged:   e.config.Privileged,
+			CapAdd:       e.config.CapAdd,
+		},
+	}
+	if len(cmd) > 0 {
+		req.Cmd = cmd
+	}
+	return &req, nil
+}
+
+type pullProgress struct {
+	Error string `json:"error"`
+}
+
+// PullImage waits for the pull to finish, the engine reports a failed pull in the progress stream
+func (e EngineContainerManager) PullImage(ctx context.Context, image string) error {
+	query := url.Values{}
+	query.Set("fromImage", image)
+	if !hasTagOrDigest(image) {
+		// without a tag every tag of the image is pulled
+		query.Set("tag", "latest")
+	}
+	res, err := e.do(ctx, http.MethodPost, "/images/create", query, nil, "", "image", image)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed
+	}
+	defer res.Body.Close() //nolint:errcheck // defer
+	decoder := json.NewDecoder(res.Body)
+	for {
+		progress := pullProgress{}
+		err := decoder.Decode(&progress)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		if progress.Error != "" {
+			return breverrors.WrapAndTrace(fmt.Errorf("could not pull %s: %s", image, progress.Error))
+		}
+	}
+}
+
+func hasTagOrDigest(image string) bool {
+	if strings.Contains(image, "@") {
+		return true
+	}
+	lastSegment := image[strings.LastIndex(image, "/")+1:]
+	return strings.Contains(lastSegment, ":")
+}
+
+// doJSON decodes the response into out if it is not nil
+func (e EngineContainerManager) doJSON(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}, kind string, id string) error {
+	var reqBody io.Reader
+	contentType := ""
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		reqBody = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	res, err := e.do(ctx, method, path, query, reqBody, contentType, kind, id)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed
+	}
+	defer res.Body.Close() //nolint:errcheck // defer
+	if out == nil || res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	err = json.NewDecoder(res.Body).Decode(out)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// do returns NotFoundError and ConflictError unwrapped, kind and id name what the request is about
+func (e EngineContainerManager) do(ctx context.Context, method string, path string, query url.Values, body io.Reader, contentType string, kind string, id string) (*http.Response, error) {
+	req, err := e.newRequest(ctx, method, path, query, body, contentType)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, e.unreachableError(err)
+	}
+	if res.StatusCode < 400 {
+		return res, nil
+	}
+	defer res.Body.Close() //nolint:errcheck // defer
+	return nil, engineError(res, kind, id)
+}
+
+func (e EngineContainerManager) newRequest(ctx context.Context, method string, path string, query url.Values, body io.Reader, contentType string) (*http.Request, error) {
+	u := e.baseURL + "/" + engineAPIVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+func (e EngineContainerManager) unreachableError(err error) error {
+	return breverrors.WrapAndTrace(fmt.Errorf("could not reach the container engine at %s, is it running? %w", e.config.Host, err))
+}
+
+// engineError is the typed error of a failed response
+func engineError(res *http.Response, kind string, id string) error {
+	message := readEngineError(res)
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		return &NotFoundError{Kind: kind, ID: id, Message: message}
+	case http.StatusConflict:
+		return &ConflictError{Kind: kind, ID: id, Message: message}
+	default:
+		return breverrors.WrapAndTrace(fmt.Errorf("container engine returned %d: %s", res.StatusCode, message))
+	}
+}
+
+func readEngineError(res *http.Response) string {
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return http.StatusText(res.StatusCode)
+	}
+	errRes := types.ErrorResponse{}
+	if json.Unmarshal(data, &errRes) == nil && errRes.Message != "" {
+		return errRes.Message
+	}
+	if s := strings.TrimSpace(string(data)); s != "" {
+		return s
+	}
+	return http.StatusText(res.StatusCode)
+}
@@ -0,0 +1,236 @@
+package workspacemanagerv2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func (e EngineContainerManager) ExecContainer(ctx context.Context, containerID string, options ExecOptions) (int, error) {
+	created := types.IDResponse{}
+	err := e.doJSON(ctx, http.MethodPost, "/containers/"+url.PathEscape(containerID)+"/exec", nil, types.ExecConfig{
+		User:         options.User,
+		Tty:          options.TTY,
+		AttachStdin:  options.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          options.Env,
+		WorkingDir:   options.WorkingDir,
+		Cmd:          options.Cmd,
+	}, &created, "container", containerID)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // keep the error typed
+	}
+	execPath := "/exec/" + url.PathEscape(created.ID)
+
+	conn, output, err := e.hijack(ctx, execPath+"/start", types.ExecStartCheck{Tty: options.TTY}, "exec", created.ID)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // keep the error typed
+	}
+	defer conn.Close() //nolint:errcheck // defer
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// the connection outlives the request, so it is closed when ctx is done
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	if options.TTY && options.Width > 0 && options.Height > 0 {
+		// best effort, the command just sees the default size
+		_ = e.ResizeExec(ctx, created.ID, options.Width, options.Height)
+	}
+	if options.TTY && options.Resizes != nil {
+		go func() {
+			for {
+				select {
+				case size, ok := <-options.Resizes:
+					if !ok {
+						return
+					}
+					_ = e.ResizeExec(ctx, created.ID, size.Width, size.Height)
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	if options.Stdin != nil {
+		go func() {
+			_, _ = io.Copy(conn, options.Stdin)
+			// the command sees the end of its input
+			if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+				_ = cw.CloseWrite()
+			}
+		}()
+	}
+	stdout, stderr := orDiscard(options.Stdout), orDiscard(options.Stderr)
+	if options.TTY {
+		_, err = io.Copy(stdout, output)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, output)
+	}
+	if err != nil && ctx.Err() == nil {
+		return 0, breverrors.WrapAndTrace(err)
+	}
+
+	inspect := types.ContainerExecInspect{}
+	err = e.doJSON(ctx, http.MethodGet, execPath+"/json", nil, nil, &inspect, "exec", created.ID)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // keep the error typed
+	}
+	return inspect.ExitCode, nil
+}
+
+// ResizeExec sets the terminal size of an exec started with TTY
+func (e EngineContainerManager) ResizeExec(ctx context.Context, execID string, width uint, height uint) error {
+	query := url.Values{}
+	query.Set("w", strconv.FormatUint(uint64(width), 10))
+	query.Set("h", strconv.FormatUint(uint64(height), 10))
+	err := e.doJSON(ctx, http.MethodPost, "/exec/"+url.PathEscape(execID)+"/resize", query, nil, nil, "exec", execID)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed
+	}
+	return nil
+}
+
+// hijack takes over the connection of the request, the engine streams stdin and the output over it
+func (e EngineContainerManager) hijack(ctx context.Context, path string, body interface{}, kind string, id string) (net.Conn, *bufio.Reader, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, breverrors.WrapAndTrace(err)
+	}
+	req, err := e.newRequest(ctx, http.MethodPost, path, nil, bytes.NewReader(data), "application/json")
+	if err != nil {
+		return nil, nil, breverrors.WrapAndTrace(err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := e.dial(ctx)
+	if err != nil {
+		return nil, nil, e.unreachableError(err)
+	}
+	err = req.Write(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, breverrors.WrapAndTrace(err)
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, breverrors.WrapAndTrace(err)
+	}
+	// older engines answer 200 and still hand over the connection
+	if res.StatusCode != http.StatusSwitchingProtocols && res.StatusCode != http.StatusOK {
+		defer conn.Close() //nolint:errcheck // defer
+		return nil, nil, engineError(res, kind, id)
+	}
+	return conn, reader, nil
+}
+
+// ContainerLogs splits the logs into stdout and stderr, which needs a container created without a TTY
+func (e EngineContainerManager) ContainerLogs(ctx context.Context, containerID string, options LogsOptions) error {
+	query := url.Values{}
+	query.Set("stdout", "1")
+	query.Set("stderr", "1")
+	if options.Follow {
+		query.Set("follow", "1")
+	}
+	if options.Tail > 0 {
+		query.Set("tail", strconv.Itoa(options.Tail))
+	}
+	res, err := e.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(containerID)+"/logs", query, nil, "", "container", containerID)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed
+	}
+	defer res.Body.Close() //nolint:errcheck // defer
+	_, err = stdcopy.StdCopy(orDiscard(options.Stdout), orDiscard(options.Stderr), res.Body)
+	if err != nil && ctx.Err() == nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (e EngineContainerManager) CopyToContainer(ctx context.Context, containerID string, dstDir string, tarStream io.Reader) error {
+	query := url.Values{}
+	query.Set("path", dstDir)
+	res, err := e.do(ctx, http.MethodPut, "/containers/"+url.PathEscape(containerID)+"/archive", query, tarStream, "application/x-tar", "path", dstDir)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed
+	}
+	return breverrors.WrapAndTrace(res.Body.Close())
+}
+
+func (e EngineContainerManager) CopyFromContainer(ctx context.Context, containerID string, srcPath string) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("path", srcPath)
+	res, err := e.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(containerID)+"/archive", query, nil, "", "path", srcPath)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // keep the error typed
+	}
+	return res.Body, nil
+}
+
+// GetContainerStats computes the cpu usage like docker stats, from the sample the engine takes a second apart
+func (e EngineContainerManager) GetContainerStats(ctx context.Context, containerID string) (*ContainerStats, error) {
+	query := url.Values{}
+	query.Set("stream", "false")
+	res := types.StatsJSON{}
+	err := e.doJSON(ctx, http.MethodGet, "/containers/"+url.PathEscape(containerID)+"/stats", query, nil, &res, "container", containerID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // keep the error typed
+	}
+	stats := statsFromEngine(res)
+	return &stats, nil
+}
+
+func statsFromEngine(s types.StatsJSON) ContainerStats {
+	stats := ContainerStats{
+		MemoryUsage: s.MemoryStats.Usage,
+		MemoryLimit: s.MemoryStats.Limit,
+		PIDs:        s.PidsStats.Current,
+	}
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+	// the page cache is not counted, it is total_inactive_file on cgroup v1 and inactive_file on v2
+	for _, key := range []string{"total_inactive_file", "inactive_file"} {
+		if cache, ok := s.MemoryStats.Stats[key]; ok && cache < stats.MemoryUsage {
+			stats.MemoryUsage -= cache
+			break
+		}
+	}
+	for _, n := range s.Networks {
+		stats.NetworkRx += n.RxBytes
+		stats.NetworkTx += n.TxBytes
+	}
+	return stats
+}
+
+func orDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
@@ -0,0 +1,130 @@
+package workspacemanagerv2
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRunningTestContainer(t *testing.T) (*EngineContainerManager, *fakeEngine) {
+	engine := newFakeEngine()
+	engine.containers["dev"] = &fakeEngineContainer{status: "running"}
+	return newTestEngineContainerManager(t, engine, EngineConfig{}), engine
+}
+
+func TestEngineExecContainer(t *testing.T) {
+	ctx := context.Background()
+	cm, engine := newRunningTestContainer(t)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code, err := cm.ExecContainer(ctx, "dev", ExecOptions{Cmd: []string{"cat"}, Stdin: strings.NewReader("hello\n"), Stdout: stdout, Stderr: stderr})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "hello\n", stdout.String())
+	assert.Empty(t, stderr.String())
+	assert.True(t, engine.execs["exec0"].config.AttachStdin)
+
+	stdout.Reset()
+	code, err = cm.ExecContainer(ctx, "dev", ExecOptions{Cmd: []string{"false"}, User: "brev", Stdout: stdout, Stderr: stderr})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "failed\n", stderr.String())
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, "brev", engine.execs["exec1"].config.User)
+	assert.False(t, engine.execs["exec1"].config.AttachStdin)
+
+	// with a tty the output is not multiplexed
+	code, err = cm.ExecContainer(ctx, "dev", ExecOptions{Cmd: []string{"echo", "hi"}, TTY: true, Width: 80, Height: 24, Stdout: stdout})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "hi\n", stdout.String())
+	assert.Equal(t, []string{"80x24"}, engine.resizes)
+
+	engine.containers["dev"].status = "exited"
+	_, err = cm.ExecContainer(ctx, "dev", ExecOptions{Cmd: []string{"true"}})
+	assert.True(t, IsConflict(err))
+	_, err = cm.ExecContainer(ctx, "dne", ExecOptions{Cmd: []string{"true"}})
+	assert.True(t, IsNotFound(err))
+}
+
+func TestEngineExecContainerFollowsResizes(t *testing.T) {
+	cm, engine := newRunningTestContainer(t)
+	stdin, stdinWriter := io.Pipe()
+	resizes := make(chan TerminalSize)
+	result := make(chan error, 1)
+	go func() {
+		_, err := cm.ExecContainer(context.Background(), "dev", ExecOptions{
+			Cmd: []string{"cat"}, TTY: true, Width: 80, Height: 24, Resizes: resizes, Stdin: stdin,
+		})
+		result <- err
+	}()
+
+	// only read once the exec runs
+	resizes <- TerminalSize{Width: 120, Height: 40}
+	assert.Eventually(t, func() bool {
+		engine.mu.Lock()
+		defer engine.mu.Unlock()
+		return len(engine.resizes) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, stdinWriter.Close())
+	assert.Nil(t, <-result)
+	assert.Equal(t, []string{"80x24", "120x40"}, engine.resizes)
+}
+
+func TestEngineContainerLogs(t *testing.T) {
+	cm, engine := newRunningTestContainer(t)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	err := cm.ContainerLogs(context.Background(), "dev", LogsOptions{Follow: true, Tail: 10, Stdout: stdout, Stderr: stderr})
+	assert.Nil(t, err)
+	assert.Equal(t, "setting up\n", stdout.String())
+	assert.Equal(t, "warning\n", stderr.String())
+	assert.Equal(t, []string{"follow=1&stderr=1&stdout=1&tail=10"}, engine.logQueries)
+}
+
+func TestEngineCopyToAndFromContainer(t *testing.T) {
+	ctx := context.Background()
+	cm, _ := newRunningTestContainer(t)
+	src := filepath.Join(t.TempDir(), "project")
+	assert.Nil(t, os.MkdirAll(filepath.Join(src, "sub"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(src, "sub", "main.go"), []byte("package main\n"), 0o644))
+
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(TarPath(pw, src))
+	}()
+	assert.Nil(t, cm.CopyToContainer(ctx, "dev", "/home/brev/workspace", pr))
+
+	archive, err := cm.CopyFromContainer(ctx, "dev", "/home/brev/workspace/project")
+	assert.Nil(t, err)
+	dst := t.TempDir()
+	assert.Nil(t, Untar(archive, dst))
+	assert.Nil(t, archive.Close())
+	data, err := os.ReadFile(filepath.Join(dst, "project", "sub", "main.go"))
+	assert.Nil(t, err)
+	assert.Equal(t, "package main\n", string(data))
+
+	_, err = cm.CopyFromContainer(ctx, "dev", "/etc/dne")
+	assert.True(t, IsNotFound(err))
+	assert.ErrorContains(t, err, "Could not find the file /etc/dne")
+}
+
+func TestEngineGetContainerStats(t *testing.T) {
+	cm, _ := newRunningTestContainer(t)
+	stats, err := cm.GetContainerStats(context.Background(), "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerStats{
+		CPUPercent:  40,
+		MemoryUsage: 800,
+		MemoryLimit: 4000,
+		NetworkRx:   101,
+		NetworkTx:   50,
+		PIDs:        7,
+	}, stats)
+}
@@ -0,0 +1,370 @@
+package workspacemanagerv2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEngineContainer struct {
+	request  containerCreateRequest
+	status   string
+	archives map[string][]byte
+}
+
+type fakeEngineExec struct {
+	config   types.ExecConfig
+	exitCode int
+}
+
+// fakeEngine serves the part of the Docker Engine API EngineContainerManager uses
+type fakeEngine struct {
+	mu         sync.Mutex
+	images     map[string]bool
+	containers map[string]*fakeEngineContainer
+	execs      map[string]*fakeEngineExec
+	pulls      []string
+	resizes    []string
+	logQueries []string
+}
+
+func newFakeEngine() *fakeEngine {
+	return &fakeEngine{images: map[string]bool{}, containers: map[string]*fakeEngineContainer{}, execs: map[string]*fakeEngineExec{}}
+}
+
+func writeEngineError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(types.ErrorResponse{Message: message})
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, "/"+engineAPIVersion)
+	if !ok {
+		writeEngineError(w, http.StatusBadRequest, "unversioned request "+r.URL.Path)
+		return
+	}
+	if strings.HasPrefix(path, "/exec/") && strings.HasSuffix(path, "/start") {
+		// streams without the lock, the client resizes while the exec runs
+		f.startExec(w, r, strings.TrimSuffix(strings.TrimPrefix(path, "/exec/"), "/start"))
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && path == "/images/create":
+		image := r.URL.Query().Get("fromImage")
+		if tag := r.URL.Query().Get("tag"); tag != "" {
+			image += ":" + tag
+		}
+		f.pulls = append(f.pulls, image)
+		if strings.HasPrefix(image, "private/") {
+			_, _ = w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"error":"pull access denied"}` + "\n"))
+			return
+		}
+		f.images[image] = true
+		_, _ = w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"status":"Downloaded"}` + "\n"))
+	case r.Method == http.MethodPost && path == "/containers/create":
+		req := containerCreateRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		name := r.URL.Query().Get("name")
+		image := req.Image
+		if !hasTagOrDigest(image) {
+			image += ":latest"
+		}
+		if !f.images[image] {
+			writeEngineError(w, http.StatusNotFound, "No such image: "+req.Image)
+			return
+		}
+		if _, ok := f.containers[name]; ok {
+			writeEngineError(w, http.StatusConflict, `Conflict. The container name "/`+name+`" is already in use`)
+			return
+		}
+		f.containers[name] = &fakeEngineContainer{request: req, status: "created"}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(container.ContainerCreateCreatedBody{ID: name})
+	case strings.HasPrefix(path, "/containers/"):
+		parts := strings.Split(strings.TrimPrefix(path, "/containers/"), "/")
+		c, ok := f.containers[parts[0]]
+		if !ok {
+			writeEngineError(w, http.StatusNotFound, "No such container: "+parts[0])
+			return
+		}
+		f.serveContainer(w, r, parts, c)
+	case strings.HasPrefix(path, "/exec/"):
+		parts := strings.Split(strings.TrimPrefix(path, "/exec/"), "/")
+		e, ok := f.execs[parts[0]]
+		if !ok {
+			writeEngineError(w, http.StatusNotFound, "No such exec instance: "+parts[0])
+			return
+		}
+		if parts[1] == "resize" {
+			f.resizes = append(f.resizes, r.URL.Query().Get("w")+"x"+r.URL.Query().Get("h"))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(types.ContainerExecInspect{ExecID: parts[0], ExitCode: e.exitCode})
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/volumes/"):
+		writeEngineError(w, http.StatusNotFound, "get "+strings.TrimPrefix(path, "/volumes/")+": no such volume")
+	default:
+		writeEngineError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+func (f *fakeEngine) serveContainer(w http.ResponseWriter, r *http.Request, parts []string, c *fakeEngineContainer) {
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+	switch {
+	case r.Method == http.MethodGet && action == "json":
+		_ = json.NewEncoder(w).Encode(types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{ID: parts[0], State: &types.ContainerState{Status: c.status}},
+			Config:            c.request.Config,
+		})
+	case r.Method == http.MethodPost && action == "start":
+		if c.status == "running" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.status = "running"
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && action == "stop":
+		if c.status != "running" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.status = "exited"
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && action == "exec":
+		if c.status != "running" {
+			writeEngineError(w, http.StatusConflict, "Container "+parts[0]+" is not running")
+			return
+		}
+		config := types.ExecConfig{}
+		_ = json.NewDecoder(r.Body).Decode(&config)
+		id := fmt.Sprintf("exec%d", len(f.execs))
+		f.execs[id] = &fakeEngineExec{config: config}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(types.IDResponse{ID: id})
+	case r.Method == http.MethodGet && action == "logs":
+		f.logQueries = append(f.logQueries, r.URL.RawQuery)
+		_, _ = stdcopy.NewStdWriter(w, stdcopy.Stdout).Write([]byte("setting up\n"))
+		_, _ = stdcopy.NewStdWriter(w, stdcopy.Stderr).Write([]byte("warning\n"))
+	case r.Method == http.MethodPut && action == "archive":
+		data, _ := io.ReadAll(r.Body)
+		if c.archives == nil {
+			c.archives = map[string][]byte{}
+		}
+		c.archives[r.URL.Query().Get("path")] = data
+	case r.Method == http.MethodGet && action == "archive":
+		// serves what was copied to the parent directory
+		data, ok := c.archives[filepath.Dir(r.URL.Query().Get("path"))]
+		if !ok {
+			writeEngineError(w, http.StatusNotFound, "Could not find the file "+r.URL.Query().Get("path")+" in container "+parts[0])
+			return
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodGet && action == "stats":
+		stats := types.StatsJSON{Networks: map[string]types.NetworkStats{"eth0": {RxBytes: 100, TxBytes: 50}, "eth1": {RxBytes: 1}}}
+		stats.CPUStats.CPUUsage.TotalUsage = 300
+		stats.CPUStats.SystemUsage = 2000
+		stats.CPUStats.OnlineCPUs = 4
+		stats.PreCPUStats.CPUUsage.TotalUsage = 200
+		stats.PreCPUStats.SystemUsage = 1000
+		stats.MemoryStats = types.MemoryStats{Usage: 1000, Limit: 4000, Stats: map[string]uint64{"inactive_file": 200}}
+		stats.PidsStats.Current = 7
+		_ = json.NewEncoder(w).Encode(stats)
+	case r.Method == http.MethodDelete && action == "":
+		if c.status == "running" {
+			writeEngineError(w, http.StatusConflict, "You cannot remove a running container "+parts[0])
+			return
+		}
+		delete(f.containers, parts[0])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeEngineError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+// startExec runs cat, false or echo
+func (f *fakeEngine) startExec(w http.ResponseWriter, r *http.Request, id string) {
+	f.mu.Lock()
+	e, ok := f.execs[id]
+	f.mu.Unlock()
+	if !ok {
+		writeEngineError(w, http.StatusNotFound, "No such exec instance: "+id)
+		return
+	}
+	if r.Header.Get("Upgrade") != "tcp" {
+		writeEngineError(w, http.StatusBadRequest, "exec start without upgrade")
+		return
+	}
+	_, _ = io.Copy(io.Discard, r.Body)
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close() //nolint:errcheck // defer
+	_, _ = buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	_ = buf.Flush()
+
+	stdout, stderr := io.Writer(conn), io.Writer(conn)
+	if !e.config.Tty {
+		stdout, stderr = stdcopy.NewStdWriter(conn, stdcopy.Stdout), stdcopy.NewStdWriter(conn, stdcopy.Stderr)
+	}
+	switch e.config.Cmd[0] {
+	case "cat":
+		_, _ = io.Copy(stdout, buf)
+	case "false":
+		_, _ = stderr.Write([]byte("failed\n"))
+		f.mu.Lock()
+		e.exitCode = 1
+		f.mu.Unlock()
+	default:
+		_, _ = stdout.Write([]byte(strings.Join(e.config.Cmd[1:], " ") + "\n"))
+	}
+}
+
+func newTestEngineContainerManager(t *testing.T, engine *fakeEngine, config EngineConfig) *EngineContainerManager {
+	srv := httptest.NewServer(engine)
+	t.Cleanup(srv.Close)
+	config.Host = "tcp://" + strings.TrimPrefix(srv.URL, "http://")
+	cm, err := NewEngineContainerManager(config)
+	assert.Nil(t, err)
+	return cm
+}
+
+func TestEngineContainerLifecycle(t *testing.T) {
+	ctx := context.Background()
+	engine := newFakeEngine()
+	cm := newTestEngineContainerManager(t, engine, EngineConfig{
+		CapAdd: []string{"SYS_ADMIN"},
+		Labels: map[string]string{"team": "infra"},
+	})
+
+	_, err := cm.GetContainer(ctx, "dev")
+	assert.True(t, IsNotFound(err))
+	assert.True(t, isNoSuchContainer(err))
+
+	id, err := cm.CreateContainer(ctx, CreateContainerOptions{
+		Name:    "dev",
+		Volumes: []Volume{SimpleVolume{Identifier: "/host/workspace", MountToPath: "/home/brev/workspace"}},
+		Ports:   []string{"2222:22"},
+		Labels:  map[string]string{WorkspaceLabel: "dev"},
+	}, "brevdev/ubuntu-proxy")
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", id)
+	assert.Equal(t, []string{"brevdev/ubuntu-proxy:latest"}, engine.pulls)
+
+	req := engine.containers["dev"].request
+	assert.Equal(t, []string{"/host/workspace:/home/brev/workspace"}, req.HostConfig.Binds)
+	assert.False(t, req.HostConfig.Privileged)
+	assert.Equal(t, []string{"SYS_ADMIN"}, []string(req.HostConfig.CapAdd))
+	assert.Equal(t, "2222", req.HostConfig.PortBindings["22/tcp"][0].HostPort)
+	assert.Empty(t, req.Cmd)
+
+	c, err := cm.GetContainer(ctx, "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, ContainerStopped, c.Status)
+	assert.True(t, c.IsManagedByBrev())
+	assert.Equal(t, map[string]string{ManagedByLabel: ManagedByValue, WorkspaceLabel: "dev", "team": "infra"}, c.Labels)
+
+	assert.Nil(t, cm.StartContainer(ctx, "dev"))
+	assert.Nil(t, cm.StartContainer(ctx, "dev"))
+	c, err = cm.GetContainer(ctx, "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, ContainerRunning, c.Status)
+
+	err = cm.DeleteContainer(ctx, "dev")
+	assert.True(t, IsConflict(err))
+	assert.ErrorContains(t, err, "cannot remove a running container")
+
+	assert.Nil(t, cm.StopContainer(ctx, "dev"))
+	assert.Nil(t, cm.StopContainer(ctx, "dev"))
+	assert.Nil(t, cm.DeleteContainer(ctx, "dev"))
+	assert.True(t, IsNotFound(cm.StartContainer(ctx, "dev")))
+	assert.True(t, IsNotFound(cm.DeleteVolume(ctx, "dev")))
+}
+
+func TestEngineCreateContainerErrors(t *testing.T) {
+	ctx := context.Background()
+	engine := newFakeEngine()
+	engine.images["nginx:1.25"] = true
+	cm := newTestEngineContainerManager(t, engine, EngineConfig{Privileged: true})
+
+	_, err := cm.CreateContainer(ctx, CreateContainerOptions{Name: "web", Command: "nginx", CommandArgs: []string{"-g", "daemon off;"}}, "nginx:1.25")
+	assert.Nil(t, err)
+	assert.Empty(t, engine.pulls)
+	assert.True(t, engine.containers["web"].request.HostConfig.Privileged)
+	assert.Equal(t, []string{"nginx", "-g", "daemon off;"}, []string(engine.containers["web"].request.Cmd))
+
+	_, err = cm.CreateContainer(ctx, CreateContainerOptions{Name: "web"}, "nginx:1.25")
+	assert.True(t, IsConflict(err))
+	var conflict *ConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "container", conflict.Kind)
+
+	_, err = cm.CreateContainer(ctx, CreateContainerOptions{Name: "secret"}, "private/image:1")
+	assert.ErrorContains(t, err, "could not pull private/image:1: pull access denied")
+
+	_, err = cm.CreateContainer(ctx, CreateContainerOptions{Name: "bad", Ports: []string{"not a port"}}, "nginx:1.25")
+	assert.Error(t, err)
+}
+
+func TestEngineContainerManagerOverUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "engine.sock")
+	listener, err := net.Listen("unix", socket)
+	if !assert.Nil(t, err) {
+		return
+	}
+	srv := httptest.NewUnstartedServer(newFakeEngine())
+	srv.Listener = listener
+	srv.Start()
+	defer srv.Close()
+
+	cm, err := NewPodmanContainerManager(EngineConfig{Host: "unix://" + socket})
+	assert.Nil(t, err)
+	_, err = cm.GetContainer(context.Background(), "dne")
+	assert.True(t, IsNotFound(err))
+
+	gone, err := NewEngineContainerManager(EngineConfig{Host: "unix://" + filepath.Join(t.TempDir(), "gone.sock")})
+	assert.Nil(t, err)
+	_, err = gone.GetContainer(context.Background(), "dne")
+	assert.ErrorContains(t, err, "could not reach the container engine")
+	assert.False(t, IsNotFound(err))
+}
+
+func TestDefaultEngineHosts(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "tcp://docker.example.com:2375")
+	assert.Equal(t, "tcp://docker.example.com:2375", DefaultDockerHost())
+
+	t.Setenv("CONTAINER_HOST", "")
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	assert.Equal(t, "unix:///run/podman/podman.sock", DefaultPodmanHost())
+	t.Setenv("CONTAINER_HOST", "unix:///tmp/podman.sock")
+	assert.Equal(t, "unix:///tmp/podman.sock", DefaultPodmanHost())
+
+	_, err := NewEngineContainerManager(EngineConfig{Host: "ssh://host"})
+	assert.ErrorContains(t, err, "unsupported container engine host")
+}
+
+func TestHasTagOrDigest(t *testing.T) {
+	assert.True(t, hasTagOrDigest("nginx:1.25"))
+	assert.True(t, hasTagOrDigest("localhost:5000/nginx:1.25"))
+	assert.True(t, hasTagOrDigest("nginx@sha256:abc"))
+	assert.False(t, hasTagOrDigest("localhost:5000/nginx"))
+	assert.False(t, hasTagOrDigest("brevdev/ubuntu-proxy"))
+}
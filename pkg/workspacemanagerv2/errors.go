@@ -0,0 +1,51 @@
+package workspacemanagerv2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NotFoundError is a container, image or volume the container engine does not have
+type NotFoundError struct {
+	Kind    string
+	ID      string
+	Message string
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("no such %s: %s", e.Kind, e.ID)
+}
+
+// ConflictError is an operation the engine refused because of the state of the object, e.g. a
+// container name that is already taken, removing a running container or a volume in use
+type ConflictError struct {
+	Kind    string
+	ID      string
+	Message string
+}
+
+func (e *ConflictError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("conflict for %s %s", e.Kind, e.ID)
+}
+
+func IsNotFound(err error) bool {
+	var notFound *NotFoundError
+	return errors.As(err, &notFound)
+}
+
+func IsConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
+
+// isNoSuchContainer also knows the output of the docker and podman cli, which only differ in case
+func isNoSuchContainer(err error) bool {
+	return IsNotFound(err) || strings.Contains(strings.ToLower(err.Error()), "no such container")
+}
@@ -0,0 +1,330 @@
+package workspacemanagerv2
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	breverrors "github.com/brevdev/brev-cli/pkg/errors"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	snapshotTimeFormat = "20060102-150405"
+	snapshotExt        = ".tar"
+	snapshotZstdExt    = ".tar.zst"
+)
+
+// snapshot ids are <time>-<reason>, the time is UTC so they sort by age
+var snapshotIDRegex = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}-[a-z0-9-]+$`)
+
+// SnapshotVolume is a volume whose data is on the host and can be snapshot
+type SnapshotVolume interface {
+	Volume
+	// GetSnapshotPath is the directory on the host with the data of the volume
+	GetSnapshotPath() string
+}
+
+func (s SimpleVolume) GetSnapshotPath() string {
+	return s.Identifier
+}
+
+// GetSnapshotPath is the target of the link, LocalVolumePath is only the link
+func (s SymLinkVolume) GetSnapshotPath() string {
+	return s.FromSymLinkPath
+}
+
+func (s DynamicVolume) GetSnapshotPath() string {
+	return s.GetMountFromPath()
+}
+
+// Snapshot is a tarball of the volumes of a workspace, each under the path it is mounted to
+type Snapshot struct {
+	ID         string    `json:"id"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Size       int64     `json:"size"`
+	Compressed bool      `json:"compressed"`
+	Path       string    `json:"path"`
+}
+
+type RetentionPolicy struct {
+	// Keep is the number of newest snapshots that are kept, all of them when 0
+	Keep int
+	// MaxAge is the age after which snapshots are deleted, never when 0
+	MaxAge time.Duration
+}
+
+type SnapshotOptions struct {
+	Compress  bool
+	Retention RetentionPolicy
+}
+
+// SnapshotStore keeps the snapshots of one workspace in a directory
+type SnapshotStore struct {
+	Dir string
+}
+
+func NewSnapshotStore(dir string) *SnapshotStore {
+	return &SnapshotStore{Dir: dir}
+}
+
+// Create snapshots the volumes that have data on the host, reason is a word like rebuild or manual
+func (s SnapshotStore) Create(volumes []SnapshotVolume, reason string, compress bool, now time.Time) (*Snapshot, error) {
+	err := os.MkdirAll(s.Dir, 0o700)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	id, err := s.newID(reason, now)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	ext := snapshotExt
+	if compress {
+		ext = snapshotZstdExt
+	}
+	path := filepath.Join(s.Dir, id+ext)
+	// written next to the snapshot and renamed so a failed one is never listed
+	partial := filepath.Join(s.Dir, "."+id+ext+".partial")
+	err = writeSnapshot(partial, volumes, compress)
+	if err != nil {
+		_ = os.Remove(partial)
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	err = os.Rename(partial, path)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return s.Get(id)
+}
+
+func (s SnapshotStore) newID(reason string, now time.Time) (string, error) {
+	base := now.UTC().Format(snapshotTimeFormat) + "-" + reason
+	if !snapshotIDRegex.MatchString(base) {
+		return "", breverrors.NewValidationError(fmt.Sprintf("invalid snapshot reason %q, use lowercase letters, digits and dashes", reason))
+	}
+	id := base
+	for i := 2; ; i++ {
+		snapshot, err := s.Get(id)
+		if err != nil && !IsNotFound(err) {
+			return "", breverrors.WrapAndTrace(err)
+		}
+		if snapshot == nil {
+			return id, nil
+		}
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+func writeSnapshot(path string, volumes []SnapshotVolume, compress bool) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) //nolint:gosec // in the snapshot dir
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	defer f.Close() //nolint:errcheck // closed below, this is for the error paths
+	var w io.Writer = f
+	var zw *zstd.Encoder
+	if compress {
+		zw, err = zstd.NewWriter(f)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for _, v := range volumes {
+		_, err := os.Stat(v.GetSnapshotPath())
+		if os.IsNotExist(err) {
+			// the workspace was never started
+			continue
+		}
+		err = writeTree(tw, v.GetSnapshotPath(), snapshotEntryName(v))
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	if zw != nil {
+		err = zw.Close()
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	err = f.Sync()
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return breverrors.WrapAndTrace(f.Close())
+}
+
+// snapshotEntryName is the mount path, which does not change when the workspace is moved on the host
+func snapshotEntryName(v SnapshotVolume) string {
+	name := v.GetMountToPath()
+	if d, ok := v.(DynamicVolume); ok {
+		name = d.ToMountPath
+	}
+	return strings.Trim(filepath.ToSlash(name), "/")
+}
+
+// List is sorted from newest to oldest
+func (s SnapshotStore) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	snapshots := []Snapshot{}
+	for _, e := range entries {
+		snapshot, ok := s.parseSnapshot(e.Name())
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, breverrors.WrapAndTrace(err)
+		}
+		snapshot.Size = info.Size()
+		snapshots = append(snapshots, *snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+func (s SnapshotStore) parseSnapshot(fileName string) (*Snapshot, bool) {
+	snapshot := Snapshot{Path: filepath.Join(s.Dir, fileName)}
+	switch {
+	case strings.HasSuffix(fileName, snapshotZstdExt):
+		snapshot.ID = strings.TrimSuffix(fileName, snapshotZstdExt)
+		snapshot.Compressed = true
+	case strings.HasSuffix(fileName, snapshotExt):
+		snapshot.ID = strings.TrimSuffix(fileName, snapshotExt)
+	default:
+		return nil, false
+	}
+	if !snapshotIDRegex.MatchString(snapshot.ID) {
+		return nil, false
+	}
+	createdAt, err := time.Parse(snapshotTimeFormat, snapshot.ID[:len(snapshotTimeFormat)])
+	if err != nil {
+		return nil, false
+	}
+	snapshot.CreatedAt = createdAt
+	snapshot.Reason = snapshot.ID[len(snapshotTimeFormat)+1:]
+	return &snapshot, true
+}
+
+// Get returns a NotFoundError for a snapshot that does not exist
+func (s SnapshotStore) Get(id string) (*Snapshot, error) {
+	snapshots, err := s.List()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID == id {
+			snapshot := snapshot
+			return &snapshot, nil
+		}
+	}
+	return nil, &NotFoundError{Kind: "snapshot", ID: id}
+}
+
+// Restore replaces the data of the volumes with the one in the snapshot, a volume that was not
+// in the snapshot ends up empty
+func (s SnapshotStore) Restore(id string, volumes []SnapshotVolume) error {
+	snapshot, err := s.Get(id)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed for IsNotFound
+	}
+	for _, v := range volumes {
+		err = restoreVolume(*snapshot, v)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	return nil
+}
+
+// restoreVolume extracts next to the volume and swaps it in, a failed restore leaves the volume as is
+func restoreVolume(snapshot Snapshot, v SnapshotVolume) error {
+	path := filepath.Clean(v.GetSnapshotPath())
+	staging := path + ".restoring"
+	err := os.RemoveAll(staging)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = extractSnapshot(snapshot, staging, snapshotEntryName(v))
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return breverrors.WrapAndTrace(err)
+	}
+	err = os.RemoveAll(path)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = os.Rename(staging, path)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func extractSnapshot(snapshot Snapshot, dstDir string, prefix string) error {
+	f, err := os.Open(snapshot.Path)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	defer f.Close() //nolint:errcheck // defer
+	var r io.Reader = f
+	if snapshot.Compressed {
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return untar(r, dstDir, prefix)
+}
+
+func (s SnapshotStore) Delete(id string) error {
+	snapshot, err := s.Get(id)
+	if err != nil {
+		return err //nolint:wrapcheck // keep the error typed for IsNotFound
+	}
+	return breverrors.WrapAndTrace(os.Remove(snapshot.Path))
+}
+
+// Prune deletes the snapshots the policy does not keep and returns them
+func (s SnapshotStore) Prune(policy RetentionPolicy, now time.Time) ([]Snapshot, error) {
+	snapshots, err := s.List()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	pruned := []Snapshot{}
+	for i, snapshot := range snapshots {
+		tooMany := policy.Keep > 0 && i >= policy.Keep
+		tooOld := policy.MaxAge > 0 && now.Sub(snapshot.CreatedAt) > policy.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		err = os.Remove(snapshot.Path)
+		if err != nil {
+			return pruned, breverrors.WrapAndTrace(err)
+		}
+		pruned = append(pruned, snapshot)
+	}
+	return pruned, nil
+}
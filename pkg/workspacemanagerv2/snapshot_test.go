@@ -0,0 +1,103 @@
+package workspacemanagerv2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotCreateListRestore(t *testing.T) {
+	dir := t.TempDir()
+	workspace := filepath.Join(dir, "volumes", "workspace")
+	assert.Nil(t, os.MkdirAll(filepath.Join(workspace, "src"), 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(workspace, "src", "main.go"), []byte("v1"), 0o644))
+	// the data of a symlink volume is where the link points to
+	linked := filepath.Join(dir, "cache")
+	assert.Nil(t, os.MkdirAll(linked, 0o755))
+	assert.Nil(t, os.WriteFile(filepath.Join(linked, "pip.txt"), []byte("cached"), 0o644))
+	volumes := []SnapshotVolume{
+		SimpleVolume{Identifier: workspace, MountToPath: "/home/brev/workspace"},
+		SymLinkVolume{FromSymLinkPath: linked, LocalVolumePath: filepath.Join(dir, "link"), MountToPath: "/home/brev/.cache"},
+		DynamicVolume{FromMountPathPrefix: filepath.Join(dir, "never-created"), ToMountPath: "/etc/dynamic"},
+	}
+
+	s := NewSnapshotStore(filepath.Join(dir, "snapshots"))
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	plain, err := s.Create(volumes, "manual", false, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "20260102-030405-manual", plain.ID)
+	assert.False(t, plain.Compressed)
+	assert.Equal(t, now, plain.CreatedAt)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(workspace, "src", "main.go"), []byte("v2"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(workspace, "new.txt"), []byte("new"), 0o644))
+	compressed, err := s.Create(volumes, "manual", true, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "20260102-030405-manual-2", compressed.ID)
+	assert.True(t, compressed.Compressed)
+	assert.Equal(t, filepath.Join(dir, "snapshots", "20260102-030405-manual-2.tar.zst"), compressed.Path)
+
+	snapshots, err := s.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{compressed.ID, plain.ID}, []string{snapshots[0].ID, snapshots[1].ID})
+	assert.Greater(t, snapshots[1].Size, int64(0))
+
+	assert.Nil(t, s.Restore(plain.ID, volumes))
+	data, err := os.ReadFile(filepath.Join(workspace, "src", "main.go"))
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(data))
+	_, err = os.Stat(filepath.Join(workspace, "new.txt"))
+	assert.True(t, os.IsNotExist(err))
+	data, err = os.ReadFile(filepath.Join(linked, "pip.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "cached", string(data))
+
+	assert.Nil(t, s.Restore(compressed.ID, volumes))
+	data, err = os.ReadFile(filepath.Join(workspace, "new.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "new", string(data))
+
+	assert.True(t, IsNotFound(s.Restore("20200101-000000-dne", volumes)))
+	_, err = s.Create(volumes, "Not Valid", false, now)
+	assert.ErrorContains(t, err, "invalid snapshot reason")
+}
+
+func TestSnapshotPrune(t *testing.T) {
+	s := NewSnapshotStore(t.TempDir())
+	now := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	for _, days := range []int{9, 5, 2, 1} {
+		_, err := s.Create(nil, "rebuild", false, now.AddDate(0, 0, -days))
+		assert.Nil(t, err)
+	}
+	// not a snapshot, pruning leaves it alone
+	assert.Nil(t, os.WriteFile(filepath.Join(s.Dir, "notes.txt"), nil, 0o644))
+
+	pruned, err := s.Prune(RetentionPolicy{}, now)
+	assert.Nil(t, err)
+	assert.Empty(t, pruned)
+
+	pruned, err = s.Prune(RetentionPolicy{MaxAge: 7 * 24 * time.Hour}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20260101-000000-rebuild"}, snapshotIDs(pruned))
+
+	pruned, err = s.Prune(RetentionPolicy{Keep: 2}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20260105-000000-rebuild"}, snapshotIDs(pruned))
+
+	snapshots, err := s.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20260109-000000-rebuild", "20260108-000000-rebuild"}, snapshotIDs(snapshots))
+	_, err = os.Stat(filepath.Join(s.Dir, "notes.txt"))
+	assert.Nil(t, err)
+}
+
+func snapshotIDs(snapshots []Snapshot) []string {
+	ids := []string{}
+	for _, s := range snapshots {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/brevdev/brev-cli/pkg/entity"
 	breverrors "github.com/brevdev/brev-cli/pkg/errors"
@@ -20,6 +21,10 @@ import (
 type WorkspaceManager struct {
 	ContainerManager ContainerManager
 	Store            WorkspaceManagerStore
+	// Local is set off of a cluster, where there is no k8s token or fuse device to mount
+	Local bool
+	// AutoSnapshot snapshots the workspace directory before Rebuild and RestoreSnapshot when set
+	AutoSnapshot *SnapshotOptions
 }
 
 type ContainerStatus string
@@ -29,15 +34,29 @@ const (
 	ContainerStopped ContainerStatus = "stopped"
 )
 
+// every container brev creates is labeled so it is never mistaken for someone else's
+const (
+	ManagedByLabel = "dev.brev.managed-by"
+	ManagedByValue = "brev-cli"
+	WorkspaceLabel = "dev.brev.workspace-id"
+)
+
 type Container struct {
 	ID     string
 	Status ContainerStatus
+	Labels map[string]string
+}
+
+func (c Container) IsManagedByBrev() bool {
+	return c.Labels[ManagedByLabel] == ManagedByValue
 }
 
 type CreateContainerOptions struct {
 	Name    string
 	Volumes []Volume
 	Ports   []string
+	// Labels are added to ManagedByLabel
+	Labels map[string]string
 
 	Command     string
 	CommandArgs []string
@@ -51,6 +70,60 @@ type ContainerManager interface {
 	CreateContainer(ctx context.Context, options CreateContainerOptions, image string) (string, error)
 	StartContainer(ctx context.Context, containerID string) error
 	DeleteVolume(ctx context.Context, volumeName string) error
+	// ExecContainer returns the exit code of the command, a command that fails is not an error
+	ExecContainer(ctx context.Context, containerID string, options ExecOptions) (int, error)
+	// ContainerLogs returns when the logs are written, or with Follow when ctx is done or the container stops
+	ContainerLogs(ctx context.Context, containerID string, options LogsOptions) error
+	// CopyToContainer extracts a tar stream into dstDir, which has to exist in the container
+	CopyToContainer(ctx context.Context, containerID string, dstDir string, tarStream io.Reader) error
+	// CopyFromContainer is a tar stream of srcPath with its base name at the root
+	CopyFromContainer(ctx context.Context, containerID string, srcPath string) (io.ReadCloser, error)
+	GetContainerStats(ctx context.Context, containerID string) (*ContainerStats, error)
+}
+
+type ExecOptions struct {
+	Cmd        []string
+	User       string
+	WorkingDir string
+	Env        []string
+
+	// TTY runs the command in a pseudo terminal, its output is then only written to Stdout
+	TTY bool
+	// Width and Height are the initial size of the terminal
+	Width  uint
+	Height uint
+	// Resizes are the sizes the terminal changes to while the command runs, the docker cli
+	// follows its terminal itself and ignores them
+	Resizes <-chan TerminalSize
+
+	// Stdin is not attached when nil, a nil Stdout or Stderr discards the output
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// TerminalSize is in characters
+type TerminalSize struct {
+	Width  uint
+	Height uint
+}
+
+type LogsOptions struct {
+	Follow bool
+	// Tail is the number of lines from the end of the logs, all of them when 0
+	Tail   int
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ContainerStats is a snapshot of the resource usage of a container, sizes are in bytes
+type ContainerStats struct {
+	CPUPercent  float64 `json:"cpuPercent"`
+	MemoryUsage uint64  `json:"memoryUsage"`
+	MemoryLimit uint64  `json:"memoryLimit"`
+	NetworkRx   uint64  `json:"networkRx"`
+	NetworkTx   uint64  `json:"networkTx"`
+	PIDs        uint64  `json:"pids"`
 }
 
 type WorkspaceManagerStore interface {
@@ -58,12 +131,19 @@ type WorkspaceManagerStore interface {
 	GetWorkspaceMeta(id string) (*store.WorkspaceMeta, error)
 	GetWorkspaceSetupParams(id string) (*store.SetupParamsV0, error)
 	GetWorkspaceSecretsConfig(id string) (string, error)
+	// GetWorkspaceVolumesPath is where the volumes of the workspace are kept on the host
+	GetWorkspaceVolumesPath(id string) (string, error)
+	GetWorkspaceSnapshotsPath(id string) (string, error)
 }
 
 func NewWorkspaceManager(cm ContainerManager, store WorkspaceManagerStore) *WorkspaceManager {
 	return &WorkspaceManager{ContainerManager: cm, Store: store}
 }
 
+func NewLocalWorkspaceManager(cm ContainerManager, store WorkspaceManagerStore) *WorkspaceManager {
+	return &WorkspaceManager{ContainerManager: cm, Store: store, Local: true}
+}
+
 func (w WorkspaceManager) MakeContainerWorkspace(workspaceID string) (*ContainerWorkspace, error) {
 	workspace, err := w.Store.GetWorkspace(workspaceID)
 	if err != nil {
@@ -95,8 +175,10 @@ func (w WorkspaceManager) MakeContainerWorkspace(workspaceID string) (*Container
 		return nil, breverrors.WrapAndTrace(err)
 	}
 
-	basePath := "/tmp/brev/volumes" // TODO proper path that will be saved
-	workspaceVolumesPath := filepath.Join(basePath, workspace.ID)
+	workspaceVolumesPath, err := w.Store.GetWorkspaceVolumesPath(workspaceID)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
 
 	localMeta := filepath.Join(workspaceVolumesPath, "etc/meta")
 	metaVolumes := NewStaticFiles("/etc/meta", map[string]io.Reader{
@@ -117,25 +199,35 @@ func (w WorkspaceManager) MakeContainerWorkspace(workspaceID string) (*Container
 		MountToPath: "/home/brev/workspace",
 	}
 
-	k8sTokenVol := SimpleVolume{
-		Identifier:  "/var/run/secrets",
-		MountToPath: "/var/run/secrets",
+	volumes := []Volume{
+		metaVolumes,
+		secretsConfigVolumes,
+		workspaceVol,
 	}
 
-	fuseVol := SimpleVolume{
-		Identifier:  "/dev/fuse",
-		MountToPath: "/dev/fuse",
+	if !w.Local {
+		k8sTokenVol := SimpleVolume{
+			Identifier:  "/var/run/secrets",
+			MountToPath: "/var/run/secrets",
+		}
+
+		fuseVol := SimpleVolume{
+			Identifier:  "/dev/fuse",
+			MountToPath: "/dev/fuse",
+		}
+		volumes = append(volumes, k8sTokenVol, fuseVol)
 	}
 
-	containerWorkspace := NewContainerWorkspace(w.ContainerManager, workspaceID, workspace.WorkspaceTemplate.Image,
-		[]Volume{
-			metaVolumes,
-			secretsConfigVolumes,
-			workspaceVol,
-			k8sTokenVol,
-			fuseVol,
-		},
-	)
+	snapshotsPath, err := w.Store.GetWorkspaceSnapshotsPath(workspaceID)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+
+	containerWorkspace := NewContainerWorkspace(w.ContainerManager, workspaceID, workspace.WorkspaceTemplate.Image, volumes)
+	// the other volumes are written from the store on every start
+	containerWorkspace.SnapshotVolumes = []SnapshotVolume{workspaceVol}
+	containerWorkspace.Snapshots = NewSnapshotStore(snapshotsPath)
+	containerWorkspace.AutoSnapshot = w.AutoSnapshot
 
 	return containerWorkspace, nil
 }
@@ -177,11 +269,101 @@ func (w WorkspaceManager) Stop(ctx context.Context, workspaceID string) error {
 	return nil
 }
 
+// Rebuild recreates the container and its volumes, the workspace directory is kept
+func (w WorkspaceManager) Rebuild(ctx context.Context, workspaceID string) error {
+	containerWorkspace, err := w.MakeContainerWorkspace(workspaceID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = containerWorkspace.Rebuild(ctx)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// Delete removes the container and its volumes, the workspace directory is kept
+func (w WorkspaceManager) Delete(ctx context.Context, workspaceID string) error {
+	containerWorkspace, err := w.MakeContainerWorkspace(workspaceID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = containerWorkspace.Delete(ctx)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (w WorkspaceManager) Snapshot(workspaceID string, reason string, compress bool) (*Snapshot, error) {
+	containerWorkspace, err := w.MakeContainerWorkspace(workspaceID)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	snapshot, err := containerWorkspace.Snapshot(reason, compress)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return snapshot, nil
+}
+
+func (w WorkspaceManager) ListSnapshots(workspaceID string) ([]Snapshot, error) {
+	snapshotsPath, err := w.Store.GetWorkspaceSnapshotsPath(workspaceID)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	snapshots, err := NewSnapshotStore(snapshotsPath).List()
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return snapshots, nil
+}
+
+// RestoreSnapshot recreates the container with the workspace directory of the snapshot
+func (w WorkspaceManager) RestoreSnapshot(ctx context.Context, workspaceID string, snapshotID string) error {
+	containerWorkspace, err := w.MakeContainerWorkspace(workspaceID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = containerWorkspace.RestoreSnapshot(ctx, snapshotID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (w WorkspaceManager) PruneSnapshots(workspaceID string, policy RetentionPolicy) ([]Snapshot, error) {
+	snapshotsPath, err := w.Store.GetWorkspaceSnapshotsPath(workspaceID)
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	pruned, err := NewSnapshotStore(snapshotsPath).Prune(policy, time.Now())
+	if err != nil {
+		return pruned, breverrors.WrapAndTrace(err)
+	}
+	return pruned, nil
+}
+
+// GetContainer returns nil if the workspace has no container yet
+func (w WorkspaceManager) GetContainer(ctx context.Context, workspaceID string) (*Container, error) {
+	container, err := w.ContainerManager.GetContainer(ctx, workspaceID)
+	if err != nil && !isNoSuchContainer(err) {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return container, nil
+}
+
 type ContainerWorkspace struct {
 	ContainerManager ContainerManager
 	Identifier       string
 	Image            string
 	Volumes          []Volume
+
+	// SnapshotVolumes are the volumes whose data is kept in snapshots
+	SnapshotVolumes []SnapshotVolume
+	Snapshots       *SnapshotStore
+	// AutoSnapshot snapshots before Rebuild and RestoreSnapshot when set
+	AutoSnapshot *SnapshotOptions
 }
 
 func NewContainerWorkspace(cm ContainerManager, identifier string, image string, volumes []Volume) *ContainerWorkspace {
@@ -190,7 +372,7 @@ func NewContainerWorkspace(cm ContainerManager, identifier string, image string,
 
 func (c ContainerWorkspace) Start(ctx context.Context) error {
 	container, err := c.ContainerManager.GetContainer(ctx, c.Identifier)
-	if err != nil && !strings.Contains(err.Error(), "No such container") {
+	if err != nil && !isNoSuchContainer(err) {
 		return breverrors.WrapAndTrace(err)
 	}
 	if container == nil { //nolint:gocritic // I like the else statement here
@@ -218,6 +400,7 @@ func (c ContainerWorkspace) CreateNew(ctx context.Context) error {
 	containerID, err := c.ContainerManager.CreateContainer(ctx, CreateContainerOptions{
 		Name:    c.Identifier,
 		Volumes: c.Volumes,
+		Labels:  map[string]string{WorkspaceLabel: c.Identifier},
 	}, c.Image)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
@@ -253,15 +436,66 @@ func (c ContainerWorkspace) Reset(ctx context.Context) error {
 }
 
 func (c ContainerWorkspace) Rebuild(ctx context.Context) error {
-	err := c.Stop(ctx)
+	err := c.autoSnapshot("rebuild")
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-	err = c.ContainerManager.DeleteContainer(ctx, c.Identifier)
+	err = c.Delete(ctx)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
-	err = c.DeleteVolumes(ctx)
+	err = c.Start(ctx)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+func (c ContainerWorkspace) Snapshot(reason string, compress bool) (*Snapshot, error) {
+	if c.Snapshots == nil {
+		return nil, breverrors.WrapAndTrace(fmt.Errorf("workspace %s has no snapshot store", c.Identifier))
+	}
+	snapshot, err := c.Snapshots.Create(c.SnapshotVolumes, reason, compress, time.Now())
+	if err != nil {
+		return nil, breverrors.WrapAndTrace(err)
+	}
+	return snapshot, nil
+}
+
+// autoSnapshot prunes right after, so the snapshots of destructive operations don't pile up
+func (c ContainerWorkspace) autoSnapshot(reason string) error {
+	if c.AutoSnapshot == nil {
+		return nil
+	}
+	_, err := c.Snapshot(reason, c.AutoSnapshot.Compress)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	_, err = c.Snapshots.Prune(c.AutoSnapshot.Retention, time.Now())
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
+// RestoreSnapshot deletes the container before replacing the volumes it mounts, then creates a new one
+func (c ContainerWorkspace) RestoreSnapshot(ctx context.Context, snapshotID string) error {
+	if c.Snapshots == nil {
+		return breverrors.WrapAndTrace(fmt.Errorf("workspace %s has no snapshot store", c.Identifier))
+	}
+	_, err := c.Snapshots.Get(snapshotID)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = c.autoSnapshot("restore")
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = c.Delete(ctx)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	err = c.Snapshots.Restore(snapshotID, c.SnapshotVolumes)
 	if err != nil {
 		return breverrors.WrapAndTrace(err)
 	}
@@ -272,6 +506,31 @@ func (c ContainerWorkspace) Rebuild(ctx context.Context) error {
 	return nil
 }
 
+// Delete is a no-op for the container if it was never created, the volumes are torn down either way
+func (c ContainerWorkspace) Delete(ctx context.Context) error {
+	container, err := c.ContainerManager.GetContainer(ctx, c.Identifier)
+	if err != nil && !isNoSuchContainer(err) {
+		return breverrors.WrapAndTrace(err)
+	}
+	if container != nil {
+		if container.Status == ContainerRunning {
+			err = c.Stop(ctx)
+			if err != nil {
+				return breverrors.WrapAndTrace(err)
+			}
+		}
+		err = c.ContainerManager.DeleteContainer(ctx, c.Identifier)
+		if err != nil {
+			return breverrors.WrapAndTrace(err)
+		}
+	}
+	err = c.DeleteVolumes(ctx)
+	if err != nil {
+		return breverrors.WrapAndTrace(err)
+	}
+	return nil
+}
+
 func (c ContainerWorkspace) CreateVolumes(ctx context.Context) error {
 	// two kinds of updates depending on env
 	// // start volume processes (to support dynamic volumes like k8s token, hcl config, etc)
@@ -3,6 +3,8 @@ package workspacemanagerv2
 import (
 	"context"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -51,6 +53,14 @@ func (t TestStore) GetWorkspaceSecretsConfig(_ string) (string, error) {
 	return "my config", nil
 }
 
+func (t TestStore) GetWorkspaceVolumesPath(id string) (string, error) {
+	return filepath.Join(os.TempDir(), "brev", "volumes", id), nil
+}
+
+func (t TestStore) GetWorkspaceSnapshotsPath(id string) (string, error) {
+	return filepath.Join(os.TempDir(), "brev", "snapshots", id), nil
+}
+
 func (t TestStore) GetWorkspaceMeta(id string) (*store.WorkspaceMeta, error) {
 	return &store.WorkspaceMeta{
 		WorkspaceID:      id,